@@ -4,7 +4,9 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
     "net"
@@ -16,9 +18,13 @@ import (
 	"github.com/Soliard/go-tpl-metrics/internal/config"
 	"github.com/Soliard/go-tpl-metrics/internal/logger"
 	"github.com/Soliard/go-tpl-metrics/internal/server"
+	"github.com/Soliard/go-tpl-metrics/internal/server/alerting"
+	"github.com/Soliard/go-tpl-metrics/internal/server/statsd"
 	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/internal/tlsutil"
 	"go.uber.org/zap"
     "google.golang.org/grpc"
+    "google.golang.org/grpc/credentials"
 )
 
 // Глобальные переменные для информации о сборке
@@ -41,16 +47,27 @@ func main() {
 		log.Fatalf("cannot create config: %v", err)
 	}
 
-	logger, err := logger.New(config.LogLevel)
+	logger, logLevel, err := logger.NewWithLevel(config.LogLevel)
 	if err != nil {
 		log.Fatalf("failed to initialize logger: %v", err)
 	}
 	defer logger.Sync()
-	fmt.Printf("server config: %v", config)
+	fmt.Printf("server config: %+v\n", config.Redacted())
 
 	appCtx, appCancel := context.WithCancel(context.Background())
 	defer appCancel()
 
+	// подкоманды выполняются вместо запуска сервера
+	if len(config.Command) > 0 {
+		if config.Command[0] != "migrate" {
+			logger.Fatal("unknown command", zap.String("command", config.Command[0]))
+		}
+		if err := runMigrate(appCtx, config.DatabaseDSN, config.Command[1:], os.Stdout); err != nil {
+			logger.Fatal("migrate failed", zap.Error(err))
+		}
+		return
+	}
+
 	storage, err := store.New(appCtx, config)
 	if err != nil {
 		logger.Fatal("error while creating storage", zap.Error(err))
@@ -58,13 +75,57 @@ func main() {
 	fmt.Println("storage type: ", storage)
 
 	service := server.NewMetricsService(storage, config, logger)
+
+	// алертинг (если задан файл правил)
+	if config.AlertRulesFile != "" {
+		rules, err := alerting.LoadRules(config.AlertRulesFile)
+		if err != nil {
+			logger.Fatal("error while loading alert rules", zap.Error(err))
+		}
+		notifiers := []alerting.Notifier{alerting.NewLogNotifier(config.AlertLogFile, logger)}
+		if config.AlertWebhookURL != "" {
+			notifiers = append(notifiers, alerting.NewWebhookNotifier(config.AlertWebhookURL))
+		}
+		engine := alerting.NewEngine(service, rules, notifiers, logger)
+		go engine.Run(appCtx, time.Second*time.Duration(config.AlertIntervalSeconds))
+		logger.Info("alerting started", zap.Int("rules", len(rules)))
+	}
+
+	// StatsD (если задан адрес); statsdDone закрывается после последнего сброса агрегатов
+	statsdDone := make(chan struct{})
+	if config.StatsDAddress != "" {
+		statsdSrv, err := statsd.Listen(config.StatsDAddress, service,
+			time.Second*time.Duration(config.StatsDFlushSeconds), logger)
+		if err != nil {
+			logger.Fatal("failed to listen statsd", zap.Error(err))
+		}
+		go func() {
+			defer close(statsdDone)
+			statsdSrv.Serve(appCtx)
+		}()
+		logger.Info("statsd listener started", zap.String("address", statsdSrv.Addr().String()))
+	} else {
+		close(statsdDone)
+	}
+
+    // TLS (если задан сертификат) общий для HTTP и gRPC; сертификаты перечитываются при изменении файлов
+    var tlsConfig *tls.Config
+    if config.TLSCertFile != "" || config.TLSKeyFile != "" || config.TLSClientCAFile != "" {
+        tlsConfig, err = tlsutil.NewServerConfig(config.TLSCertFile, config.TLSKeyFile, config.TLSClientCAFile, logger)
+        if err != nil {
+            logger.Fatal("failed to configure tls", zap.Error(err))
+        }
+        logger.Info("tls enabled", zap.Bool("mutual", config.TLSClientCAFile != ""))
+    }
+
     // HTTP сервер (если адрес задан)
     var httpSrv *http.Server
     if config.ServerHost != "" {
         metricRouter := server.MetricRouter(service)
         httpSrv = &http.Server{
-            Addr:    service.ServerHost,
-            Handler: metricRouter,
+            Addr:      service.ServerHost,
+            Handler:   metricRouter,
+            TLSConfig: tlsConfig,
         }
     }
 
@@ -72,7 +133,11 @@ func main() {
     var grpcSrv *grpc.Server
     var grpcLis net.Listener
     if config.GRPCServerHost != "" {
-        grpcSrv = server.NewGRPCServer(service)
+        var opts []grpc.ServerOption
+        if tlsConfig != nil {
+            opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+        }
+        grpcSrv = server.NewGRPCServer(service, opts...)
         var err error
         grpcLis, err = net.Listen("tcp", config.GRPCServerHost)
         if err != nil {
@@ -80,6 +145,20 @@ func main() {
         }
     }
 
+    // административный сервер (если адрес задан): pprof, уровень логирования, сохранение снимка, конфигурация
+    var adminSrv *http.Server
+    if config.AdminAddress != "" {
+        adminRouter, err := server.AdminRouter(service, config, logLevel)
+        if err != nil {
+            logger.Fatal("failed to configure admin server", zap.Error(err))
+        }
+        adminSrv = &http.Server{
+            Addr:      config.AdminAddress,
+            Handler:   adminRouter,
+            TLSConfig: tlsConfig,
+        }
+    }
+
     if httpSrv == nil && grpcSrv == nil {
         logger.Fatal("no server address provided: set address and/or grpc_address")
     }
@@ -89,11 +168,32 @@ func main() {
 
     if httpSrv != nil {
         go func() {
-            if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            var err error
+            if tlsConfig != nil {
+                // сертификат берется из TLSConfig
+                err = httpSrv.ListenAndServeTLS("", "")
+            } else {
+                err = httpSrv.ListenAndServe()
+            }
+            if err != nil && err != http.ErrServerClosed {
                 logger.Fatal("fatal error while http serving", zap.Error(err))
             }
         }()
     }
+    if adminSrv != nil {
+        go func() {
+            var err error
+            if tlsConfig != nil {
+                err = adminSrv.ListenAndServeTLS("", "")
+            } else {
+                err = adminSrv.ListenAndServe()
+            }
+            if err != nil && err != http.ErrServerClosed {
+                logger.Fatal("fatal error while admin serving", zap.Error(err))
+            }
+        }()
+        logger.Info("admin server started", zap.String("address", config.AdminAddress))
+    }
     if grpcSrv != nil {
         go func() {
             if err := grpcSrv.Serve(grpcLis); err != nil {
@@ -115,8 +215,25 @@ func main() {
     if grpcSrv != nil {
         grpcSrv.GracefulStop()
     }
+    if adminSrv != nil {
+        if err := adminSrv.Shutdown(shCtx); err != nil {
+            logger.Error("admin server Shutdown", zap.Error(err))
+        }
+    }
+    // события аудита принятых запросов доставляются до закрытия сервиса
+    if err := service.Close(shCtx); err != nil {
+        logger.Error("service close", zap.Error(err))
+    }
 
 	appCancel()
+	<-statsdDone
+
+	// последнее сохранение файлового хранилища после остановки приема метрик
+	if closer, ok := storage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Error("storage close", zap.Error(err))
+		}
+	}
 
 	fmt.Println("server shutdown gracefully")
 }
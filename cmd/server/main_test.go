@@ -1 +1,41 @@
 package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMigrateCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		want      migrateCommand
+		wantError bool
+	}{
+		{name: "up", args: []string{"up"}, want: migrateCommand{action: "up"}},
+		{name: "status", args: []string{"status"}, want: migrateCommand{action: "status"}},
+		{name: "down", args: []string{"down", "2"}, want: migrateCommand{action: "down", arg: 2}},
+		{name: "force", args: []string{"force", "5"}, want: migrateCommand{action: "force", arg: 5}},
+		{name: "force nil version", args: []string{"force", "-1"}, want: migrateCommand{action: "force", arg: -1}},
+		{name: "no action", args: nil, wantError: true},
+		{name: "unknown action", args: []string{"redo"}, wantError: true},
+		{name: "down without steps", args: []string{"down"}, wantError: true},
+		{name: "down zero", args: []string{"down", "0"}, wantError: true},
+		{name: "force not a number", args: []string{"force", "v5"}, wantError: true},
+		{name: "force below nil version", args: []string{"force", "-2"}, wantError: true},
+		{name: "extra args", args: []string{"up", "1"}, wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMigrateCommand(tt.args)
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
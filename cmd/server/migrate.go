@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+)
+
+// migrateUsage описывает подкоманду migrate
+const migrateUsage = "usage: server [flags] migrate up | down N | status | force V"
+
+// migrateCommand - разобранная подкоманда migrate
+type migrateCommand struct {
+	action string // up, down, status или force
+	arg    int    // число откатываемых миграций для down, версия для force
+}
+
+// parseMigrateCommand разбирает аргументы после слова migrate
+func parseMigrateCommand(args []string) (migrateCommand, error) {
+	if len(args) == 0 {
+		return migrateCommand{}, errors.New(migrateUsage)
+	}
+	cmd := migrateCommand{action: args[0]}
+	switch cmd.action {
+	case "up", "status":
+		if len(args) != 1 {
+			return cmd, errors.New(migrateUsage)
+		}
+	case "down", "force":
+		if len(args) != 2 {
+			return cmd, errors.New(migrateUsage)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return cmd, fmt.Errorf("invalid %s argument %q: %w", cmd.action, args[1], err)
+		}
+		if cmd.action == "down" && n < 1 {
+			return cmd, fmt.Errorf("down expects a positive number of migrations, got %d", n)
+		}
+		if cmd.action == "force" && n < -1 {
+			return cmd, fmt.Errorf("force expects a version >= -1, got %d", n)
+		}
+		cmd.arg = n
+	default:
+		return cmd, fmt.Errorf("unknown migrate action %q; %s", cmd.action, migrateUsage)
+	}
+	return cmd, nil
+}
+
+// runMigrate выполняет подкоманду migrate без запуска HTTP и gRPC серверов
+// и печатает итоговое состояние схемы в out
+func runMigrate(ctx context.Context, databaseDSN string, args []string, out io.Writer) error {
+	cmd, err := parseMigrateCommand(args)
+	if err != nil {
+		return err
+	}
+	if databaseDSN == "" {
+		return errors.New("database DSN is required for migrate: set DATABASE_DSN or -d")
+	}
+
+	migrator, err := store.NewMigrator(ctx, databaseDSN)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch cmd.action {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down(cmd.arg)
+	case "force":
+		err = migrator.Force(cmd.arg)
+	}
+	if err != nil {
+		return err
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "version: %d\ndirty: %t\nlatest: %d\n", status.Version, status.Dirty, status.Latest)
+	return nil
+}
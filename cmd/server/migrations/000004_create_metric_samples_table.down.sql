@@ -0,0 +1 @@
+DROP TABLE IF EXISTS metric_samples;
\ No newline at end of file
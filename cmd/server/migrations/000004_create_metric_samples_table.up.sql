@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS metric_samples (
+    id VARCHAR(255) NOT NULL,
+    ts TIMESTAMPTZ NOT NULL,
+    delta bigint NULL,
+    value float8 NULL
+);
+CREATE INDEX IF NOT EXISTS metric_samples_id_ts ON metric_samples (id, ts);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS processed_batches;
\ No newline at end of file
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS processed_batches (
+    batch_key TEXT PRIMARY KEY,
+    processed_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS processed_batches_processed_at ON processed_batches (processed_at);
\ No newline at end of file
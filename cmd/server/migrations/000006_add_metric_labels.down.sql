@@ -0,0 +1,7 @@
+DELETE FROM metric_samples WHERE length(id) > 255;
+ALTER TABLE metric_samples ALTER COLUMN id TYPE VARCHAR(255);
+DELETE FROM metrics WHERE series_key <> id;
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_pkey;
+ALTER TABLE metrics ADD PRIMARY KEY (id);
+ALTER TABLE metrics DROP COLUMN IF EXISTS series_key;
+ALTER TABLE metrics DROP COLUMN IF EXISTS labels;
\ No newline at end of file
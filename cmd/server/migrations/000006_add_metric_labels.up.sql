@@ -0,0 +1,7 @@
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS series_key TEXT;
+UPDATE metrics SET series_key = id WHERE series_key IS NULL;
+ALTER TABLE metrics ALTER COLUMN series_key SET NOT NULL;
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_pkey;
+ALTER TABLE metrics ADD PRIMARY KEY (series_key);
+ALTER TABLE metric_samples ALTER COLUMN id TYPE TEXT;
\ No newline at end of file
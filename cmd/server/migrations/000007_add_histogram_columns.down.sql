@@ -0,0 +1,5 @@
+DELETE FROM metrics WHERE type = 'histogram';
+ALTER TABLE metrics DROP COLUMN IF EXISTS hist_count;
+ALTER TABLE metrics DROP COLUMN IF EXISTS hist_sum;
+ALTER TABLE metrics DROP COLUMN IF EXISTS hist_counts;
+ALTER TABLE metrics DROP COLUMN IF EXISTS hist_bounds;
\ No newline at end of file
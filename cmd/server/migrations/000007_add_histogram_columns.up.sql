@@ -0,0 +1,4 @@
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS hist_bounds float8[] NULL;
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS hist_counts bigint[] NULL;
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS hist_sum float8 NULL;
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS hist_count bigint NULL;
\ No newline at end of file
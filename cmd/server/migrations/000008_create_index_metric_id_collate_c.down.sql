@@ -0,0 +1 @@
+DROP INDEX IF EXISTS metric_id_series_key_c;
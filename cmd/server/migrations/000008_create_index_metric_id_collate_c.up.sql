@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS metric_id_series_key_c ON metrics (id COLLATE "C", series_key COLLATE "C");
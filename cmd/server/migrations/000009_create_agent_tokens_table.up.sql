@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS agent_tokens (
+    token_hash TEXT PRIMARY KEY,
+    agent TEXT NOT NULL,
+    prefixes TEXT[] NOT NULL DEFAULT '{}',
+    scope TEXT NOT NULL CHECK (scope IN ('read', 'write'))
+);
@@ -0,0 +1,13 @@
+// Package migrations содержит SQL миграции схемы базы данных, встроенные в бинарный файл сервера.
+//
+// Новая миграция создается из корня репозитория командой:
+//
+//	migrate create -ext sql -dir cmd/server/migrations -seq <name>
+package migrations
+
+import "embed"
+
+// FS содержит файлы миграций вида <версия>_<имя>.up.sql и <версия>_<имя>.down.sql
+//
+//go:embed *.sql
+var FS embed.FS
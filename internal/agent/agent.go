@@ -4,22 +4,31 @@ package agent
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/Soliard/go-tpl-metrics/internal/agent/spool"
 	"github.com/Soliard/go-tpl-metrics/internal/compressor"
 	"github.com/Soliard/go-tpl-metrics/internal/config"
 	"github.com/Soliard/go-tpl-metrics/internal/crypto"
 	metricspb "github.com/Soliard/go-tpl-metrics/internal/proto"
+	metricspbv2 "github.com/Soliard/go-tpl-metrics/internal/proto/v2"
 	"github.com/Soliard/go-tpl-metrics/internal/signer"
+	"github.com/Soliard/go-tpl-metrics/internal/tlsutil"
+	"github.com/Soliard/go-tpl-metrics/models"
 	"github.com/go-resty/resty/v2"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
@@ -32,25 +41,54 @@ type Agent struct {
 	Logger           *zap.Logger
 	pollInterval     time.Duration
 	reportInterval   time.Duration
-	signKey          []byte
+	keys             *signer.Keyring
 	requestRateLimit int
 	publicKey        *rsa.PublicKey
+	tlsConfig        *tls.Config // nil, если TLS не настроен
 	agentIP          string
+	agentID          string
+	token            string // токен агента для заголовка Authorization, пустой - не передается
+	labels           map[string]string
+	gcPauseBuckets   []float64
+	// sendLatency накапливает задержки отправки до следующего пакета
+	sendLatency   *models.HistogramValue
+	sendLatencyMu sync.Mutex
+	// spool хранит неотправленные пакеты, nil если буфер отключен
+	spool *spool.Spool
+	// collectors - включенные коллекторы с интервалами сбора
+	collectors []scheduledCollector
 	// gRPC
-	grpcConn   *grpc.ClientConn
-	grpcClient metricspb.MetricsClient
-	grpcOnce   sync.Once
+	grpcConn     *grpc.ClientConn
+	grpcClient   metricspb.MetricsClient
+	grpcClientV2 metricspbv2.MetricsClient
+	grpcOnce     sync.Once
 }
 
 // New создает новый экземпляр агента с указанной конфигурацией.
 // Настраивает HTTP клиент с повторными попытками и нормализует URL сервера.
 func New(config *config.AgentConfig, logger *zap.Logger) *Agent {
+	// TLS включается заданным CA или клиентским сертификатом и используется для HTTP и gRPC
+	var tlsConfig *tls.Config
+	if config.TLSCAFile != "" || config.TLSCertFile != "" {
+		var err error
+		tlsConfig, err = tlsutil.NewClientConfig(config.TLSCAFile, config.TLSCertFile, config.TLSKeyFile, logger)
+		if err != nil {
+			logger.Fatal("cant configure tls", zap.Error(err))
+		}
+	}
+
 	// Инициализируем HTTP клиент только если HTTP адрес задан
 	var client *resty.Client
 	if config.ServerHost != "" {
 		client = resty.New().
 			SetRetryCount(3).
 			SetRetryMaxWaitTime(2)
+		if tlsConfig != nil {
+			client.SetTLSClientConfig(tlsConfig)
+		}
+		if config.Token != "" {
+			client.SetAuthToken(config.Token)
+		}
 	}
 
 	// Загружаем публичный ключ для шифрования
@@ -64,18 +102,70 @@ func New(config *config.AgentConfig, logger *zap.Logger) *Agent {
 		logger.Info("public key loaded successfully for encryption")
 	}
 
-	return &Agent{
-		serverHostURL:    normalizeServerURL(config.ServerHost),
+	labels, err := models.ParseLabels(config.Labels)
+	if err != nil {
+		logger.Fatal("invalid agent labels", zap.Error(err))
+	}
+	gcPauseBuckets, err := models.ParseBuckets(config.GCPauseBuckets, defaultGCPauseBuckets)
+	if err != nil {
+		logger.Fatal("invalid gc pause buckets", zap.Error(err))
+	}
+	latencyBuckets, err := models.ParseBuckets(config.LatencyBuckets, models.DefaultBuckets)
+	if err != nil {
+		logger.Fatal("invalid latency buckets", zap.Error(err))
+	}
+	collectorSettings, err := config.CollectorSettings()
+	if err != nil {
+		logger.Fatal("invalid collector settings", zap.Error(err))
+	}
+
+	var batchSpool *spool.Spool
+	if config.SpoolDir != "" {
+		batchSpool, err = spool.Open(config.SpoolDir, config.SpoolMaxBytes, time.Second*time.Duration(config.SpoolMaxAgeSeconds))
+		if err != nil {
+			logger.Fatal("cant open spool", zap.Error(err))
+		}
+		if stats := batchSpool.Stats(); stats.Batches > 0 {
+			logger.Info("spooled batches restored", zap.Int("batches", stats.Batches))
+		}
+	}
+
+	keys, err := signer.KeyringFromConfig(config.SignKey, config.SignKeysFile)
+	if err != nil {
+		logger.Fatal("cant load signing keys", zap.Error(err))
+	}
+	if keys.Enabled() && !keys.CanSign() {
+		logger.Fatal("signing keys file has no active key")
+	}
+
+	a := &Agent{
+		serverHostURL:    normalizeServerURL(config.ServerHost, tlsConfig != nil),
 		grpcServerHost:   config.GRPCServerHost,
 		httpClient:       client,
 		Logger:           logger,
 		pollInterval:     time.Second * time.Duration(config.PollIntervalSeconds),
 		reportInterval:   time.Second * time.Duration(config.ReportIntervalSeconds),
-		signKey:          []byte(config.SignKey),
+		keys:             keys,
 		requestRateLimit: config.RequestsLimit,
 		publicKey:        publicKey,
+		tlsConfig:        tlsConfig,
 		agentIP:          detectOutboundIP(),
+		agentID:          config.AgentID,
+		token:            config.Token,
+		labels:           labels,
+		gcPauseBuckets:   gcPauseBuckets,
+		sendLatency:      models.NewHistogram(latencyBuckets),
+		spool:            batchSpool,
+	}
+	if client != nil {
+		// resty повторяет запрос с теми же заголовками, поэтому подпись обновляется перед каждой попыткой
+		client.OnBeforeRequest(a.signRequest)
+	}
+	a.collectors, err = a.newCollectors(collectorSettings)
+	if err != nil {
+		logger.Fatal("cant create collectors", zap.Error(err))
 	}
+	return a
 }
 
 func (a *Agent) ensureGRPCConn(ctx context.Context) error {
@@ -86,8 +176,12 @@ func (a *Agent) ensureGRPCConn(ctx context.Context) error {
 		}
 
 		// Новый API с опциями
+		creds := insecure.NewCredentials()
+		if a.tlsConfig != nil {
+			creds = credentials.NewTLS(a.tlsConfig)
+		}
 		opts := []grpc.DialOption{
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithTransportCredentials(creds),
 		}
 
 		conn, connErr := grpc.NewClient(a.grpcServerHost, opts...)
@@ -98,6 +192,7 @@ func (a *Agent) ensureGRPCConn(ctx context.Context) error {
 
 		a.grpcConn = conn
 		a.grpcClient = metricspb.NewMetricsClient(conn)
+		a.grpcClientV2 = metricspbv2.NewMetricsClient(conn)
 	})
 	return err
 }
@@ -107,20 +202,24 @@ func (a *Agent) closeGRPCConn() {
 		_ = a.grpcConn.Close()
 		a.grpcConn = nil
 		a.grpcClient = nil
+		a.grpcClientV2 = nil
 	}
 }
 
-// normalizeServerURL добавляет протокол http:// к URL если он не указан
-func normalizeServerURL(url string) string {
+// normalizeServerURL добавляет протокол к URL если он не указан: https:// при настроенном TLS, иначе http://
+func normalizeServerURL(url string, useTLS bool) string {
 	if strings.HasPrefix(url, "http") {
 		return url
 	}
+	if useTLS {
+		return "https://" + url
+	}
 	return "http://" + url
 }
 
 // hasSignKey проверяет, настроен ли ключ для подписи данных
 func (a *Agent) hasSignKey() bool {
-	return signer.SignKeyExists(a.signKey)
+	return a.keys.CanSign()
 }
 
 // hasCryptoKey проверяет, настроен ли ключ для шифрования данных
@@ -128,6 +227,16 @@ func (a *Agent) hasCryptoKey() bool {
 	return a.publicKey != nil
 }
 
+// newBatchID генерирует случайный идентификатор пакета метрик.
+// Идентификатор передается серверу и не меняется при повторных отправках пакета.
+func newBatchID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
 // detectOutboundIP определяет исходящий IP-адрес
 // через UDP подключение к публичному серверу
 func detectOutboundIP() string {
@@ -146,28 +255,68 @@ func detectOutboundIP() string {
 
 // prepareJSONPayload подготавливает тело запроса:
 // json.Marshal -> EncryptHybrid (если ключ есть) -> gzip.
-// Возвращает сжатый буфер и подпись (по сжатому буферу) в base64, если есть signKey.
-func (a *Agent) prepareJSONPayload(v any) (compressed []byte, signatureB64 string, err error) {
+func (a *Agent) prepareJSONPayload(v any) ([]byte, error) {
 	buf, err := json.Marshal(v)
 	if err != nil {
-		return nil, "", fmt.Errorf("cant marshal payload: %v", err)
+		return nil, fmt.Errorf("cant marshal payload: %v", err)
 	}
 	if a.hasCryptoKey() {
 		enc, err := crypto.EncryptHybrid(buf, a.publicKey)
 		if err != nil {
-			return nil, "", fmt.Errorf("cant encrypt data: %v", err)
+			return nil, fmt.Errorf("cant encrypt data: %v", err)
 		}
 		buf = enc
 		a.Logger.Info("payload encrypted successfully")
 	}
 	comp, err := compressor.CompressData(buf)
 	if err != nil {
-		return nil, "", fmt.Errorf("cant compress data: %v", err)
+		return nil, fmt.Errorf("cant compress data: %v", err)
 	}
-	var signB64 string
-	if a.hasSignKey() {
-		sig := signer.Sign(comp, a.signKey)
-		signB64 = signer.EncodeSign(sig)
+	return comp, nil
+}
+
+// signatureHeaders подписывает строку запроса request и данные запроса активным ключом вместе со временем подписи
+// и одноразовым значением, чтобы сервер мог отклонить повтор запроса. Возвращает заголовки HTTP или ключи metadata gRPC,
+// пустые, если ключ не настроен. Вызывается для каждой отправки, в том числе повторной отправки из буфера.
+func (a *Agent) signatureHeaders(request string, payload []byte) (map[string]string, error) {
+	if !a.hasSignKey() {
+		return nil, nil
+	}
+	timestamp, nonce, err := signer.NewNonce()
+	if err != nil {
+		return nil, fmt.Errorf("cant generate nonce: %w", err)
+	}
+	headers := map[string]string{
+		"HashSHA256":           signer.EncodeSign(a.keys.Sign(signer.SignedPayload(request, timestamp, nonce, payload))),
+		signer.TimestampHeader: timestamp,
+		signer.NonceHeader:     nonce,
+	}
+	if id := a.keys.ActiveID(); id != "" {
+		headers[signer.KeyIDHeader] = id
+	}
+	return headers, nil
+}
+
+// signRequest подписывает метод, путь и тело HTTP запроса новым временем и одноразовым значением.
+// Вызывается resty перед каждой попыткой отправки, чтобы сервер не отклонил повтор как replay.
+// Запрос без тела (обновление через URL) подписывается с пустым телом: значение метрики передается в пути.
+func (a *Agent) signRequest(_ *resty.Client, req *resty.Request) error {
+	var body []byte
+	switch b := req.Body.(type) {
+	case nil:
+	case []byte:
+		body = b
+	default:
+		return fmt.Errorf("cant sign request body of type %T", b)
+	}
+	target, err := url.Parse(req.URL)
+	if err != nil {
+		return err
+	}
+	headers, err := a.signatureHeaders(signer.HTTPRequestLine(req.Method, target.RequestURI()), body)
+	if err != nil {
+		return err
 	}
-	return comp, signB64, nil
+	req.SetHeaders(headers)
+	return nil
 }
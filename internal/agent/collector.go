@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"go.uber.org/zap"
+)
+
+// Collector - источник метрик агента.
+// Collect вызывается из одной горутины с интервалом коллектора, поэтому реализация может хранить состояние между вызовами.
+type Collector interface {
+	Collect(ctx context.Context) ([]*models.Metrics, error)
+}
+
+// CollectorFactory создает коллектор для агента, например с учетом его настроек гистограмм
+type CollectorFactory func(a *Agent) Collector
+
+var (
+	collectorsMu sync.RWMutex
+	collectors   = map[string]CollectorFactory{}
+)
+
+// RegisterCollector регистрирует коллектор под именем name, по которому он настраивается через COLLECTORS.
+// Паникует при повторной регистрации имени.
+func RegisterCollector(name string, factory CollectorFactory) {
+	collectorsMu.Lock()
+	defer collectorsMu.Unlock()
+	if _, ok := collectors[name]; ok {
+		panic("agent: collector registered twice: " + name)
+	}
+	collectors[name] = factory
+}
+
+// Collectors возвращает отсортированные имена зарегистрированных коллекторов
+func Collectors() []string {
+	collectorsMu.RLock()
+	defer collectorsMu.RUnlock()
+	return collectorNames()
+}
+
+// collectorNames возвращает отсортированные имена коллекторов. Вызывается под collectorsMu.
+func collectorNames() []string {
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scheduledCollector - включенный коллектор с интервалом сбора
+type scheduledCollector struct {
+	name      string
+	interval  time.Duration
+	collector Collector
+}
+
+// newCollectors создает включенные коллекторы в порядке имен.
+// Возвращает ошибку, если в настройках упомянут незарегистрированный коллектор.
+func (a *Agent) newCollectors(settings map[string]config.CollectorSettings) ([]scheduledCollector, error) {
+	collectorsMu.RLock()
+	defer collectorsMu.RUnlock()
+	for name := range settings {
+		if _, ok := collectors[name]; !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+	}
+
+	var scheduled []scheduledCollector
+	for _, name := range collectorNames() {
+		s, ok := settings[name]
+		if ok && !s.Enabled {
+			continue
+		}
+		interval := s.Interval
+		if interval == 0 {
+			interval = a.pollInterval
+		}
+		scheduled = append(scheduled, scheduledCollector{
+			name:      name,
+			interval:  interval,
+			collector: collectors[name](a),
+		})
+	}
+	return scheduled, nil
+}
+
+// runCollector вызывает коллектор с его интервалом и передает собранные пакеты в result до отмены контекста.
+// Ошибки и паники коллектора логируются и не затрагивают остальные коллекторы.
+func (a *Agent) runCollector(ctx context.Context, sc scheduledCollector, result chan<- []*models.Metrics) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(sc.interval):
+			batch, err := collectSafe(ctx, sc.collector)
+			if err != nil {
+				a.Logger.Error("collector failed", zap.String("collector", sc.name), zap.Error(err))
+				continue
+			}
+			if len(batch) == 0 {
+				continue
+			}
+			select {
+			case result <- batch:
+			case <-ctx.Done():
+				return
+			}
+			a.Logger.Info("metrics collected", zap.String("collector", sc.name), zap.Int("count", len(batch)))
+		}
+	}
+}
+
+// collectSafe вызывает коллектор, превращая панику в ошибку
+func collectSafe(ctx context.Context, c Collector) (batch []*models.Metrics, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("collector panic: %v", r)
+		}
+	}()
+	return c.Collect(ctx)
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewCollectors(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      string
+		want      map[string]time.Duration
+		wantError bool
+	}{
+		{
+			name: "defaults",
+			spec: "",
+			want: map[string]time.Duration{"ps": 2 * time.Second, "runtime": 2 * time.Second},
+		},
+		{
+			name: "disable and override interval",
+			spec: "ps=off,runtime=500ms",
+			want: map[string]time.Duration{"runtime": 500 * time.Millisecond},
+		},
+		{
+			name: "explicit on",
+			spec: "ps=on",
+			want: map[string]time.Duration{"ps": 2 * time.Second, "runtime": 2 * time.Second},
+		},
+		{
+			name:      "unknown collector",
+			spec:      "disk=on",
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := New(&config.AgentConfig{PollIntervalSeconds: 2}, zap.NewNop())
+			settings, err := config.ParseCollectors(tt.spec)
+			require.NoError(t, err)
+
+			scheduled, err := agent.newCollectors(settings)
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got := map[string]time.Duration{}
+			for _, sc := range scheduled {
+				got[sc.name] = sc.interval
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseCollectors_Invalid(t *testing.T) {
+	for _, spec := range []string{"ps", "ps=sometimes", "=on", "runtime=-1s"} {
+		_, err := config.ParseCollectors(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// collectorFunc адаптирует функцию к интерфейсу Collector
+type collectorFunc func(ctx context.Context) ([]*models.Metrics, error)
+
+func (f collectorFunc) Collect(ctx context.Context) ([]*models.Metrics, error) {
+	return f(ctx)
+}
+
+func TestRunCollector_Isolation(t *testing.T) {
+	agent := New(&config.AgentConfig{}, zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scheduled := []scheduledCollector{
+		{
+			name:     "panics",
+			interval: 10 * time.Millisecond,
+			collector: collectorFunc(func(ctx context.Context) ([]*models.Metrics, error) {
+				panic("boom")
+			}),
+		},
+		{
+			name:     "fails",
+			interval: 10 * time.Millisecond,
+			collector: collectorFunc(func(ctx context.Context) ([]*models.Metrics, error) {
+				return nil, errors.New("source unavailable")
+			}),
+		},
+		{
+			name:     "works",
+			interval: 10 * time.Millisecond,
+			collector: collectorFunc(func(ctx context.Context) ([]*models.Metrics, error) {
+				return []*models.Metrics{models.NewGaugeMetric("ok", 1.5)}, nil
+			}),
+		},
+	}
+
+	jobs := make(chan []*models.Metrics)
+	var wg sync.WaitGroup
+	for _, sc := range scheduled {
+		wg.Add(1)
+		go func(sc scheduledCollector) {
+			defer wg.Done()
+			agent.runCollector(ctx, sc, jobs)
+		}(sc)
+	}
+
+	// рабочий коллектор продолжает отдавать пакеты несмотря на паники и ошибки соседей
+	for i := 0; i < 3; i++ {
+		select {
+		case batch := <-jobs:
+			require.Len(t, batch, 1)
+			assert.Equal(t, "ok", batch[0].ID)
+		case <-time.After(time.Second):
+			t.Fatal("no batch from working collector")
+		}
+	}
+	cancel()
+	wg.Wait()
+}
@@ -10,10 +10,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Soliard/go-tpl-metrics/internal/agent/spool"
 	metricspb "github.com/Soliard/go-tpl-metrics/internal/proto"
+	metricspbv2 "github.com/Soliard/go-tpl-metrics/internal/proto/v2"
 	"github.com/Soliard/go-tpl-metrics/models"
 	"go.uber.org/zap"
 	"golang.org/x/sync/semaphore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -24,22 +28,14 @@ func (a *Agent) Run(ctx context.Context) {
 	jobs := make(chan []*models.Metrics, 10)
 	sem := semaphore.NewWeighted(int64(a.requestRateLimit))
 
-	var wg sync.WaitGroup
+	var wg, collectorsWg sync.WaitGroup
 
-	// collectors
-	for c := 1; c < 2; c++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			a.Collector(ctx, id, jobs)
-		}(c)
-	}
-
-	for c := 1; c < 2; c++ {
-		wg.Add(1)
-		go func(id int) {
-			defer wg.Done()
-			a.CollectorPS(ctx, id, jobs)
+	// collectors: каждый в своей горутине со своим интервалом
+	for _, c := range a.collectors {
+		collectorsWg.Add(1)
+		go func(c scheduledCollector) {
+			defer collectorsWg.Done()
+			a.runCollector(ctx, c, jobs)
 		}(c)
 	}
 
@@ -54,10 +50,42 @@ func (a *Agent) Run(ctx context.Context) {
 
 	// Ждем сигнал отмены
 	<-ctx.Done()
+	// Очередь закрывается только после остановки коллекторов, иначе отправка в нее может паниковать
+	collectorsWg.Wait()
 	// Закрываем очередь заданий — отправители корректно дочитают
 	close(jobs)
 	// Дожидаемся завершения всех горутин
 	wg.Wait()
+	// Отправляем пакеты, накопленные в буфере за время недоступности сервера
+	a.drainSpool()
+}
+
+// applyLabels добавляет метки агента к метрикам, не переопределяя уже заданные метки
+func (a *Agent) applyLabels(metrics []*models.Metrics) {
+	if len(a.labels) == 0 {
+		return
+	}
+	for _, m := range metrics {
+		if m.Labels == nil {
+			m.Labels = make(map[string]string, len(a.labels))
+		}
+		for k, v := range a.labels {
+			if _, ok := m.Labels[k]; !ok {
+				m.Labels[k] = v
+			}
+		}
+	}
+}
+
+// signMetrics записывает в Hash подпись каждой метрики, если настроен ключ.
+// Вызывается после добавления меток, так как они входят в подпись.
+func (a *Agent) signMetrics(metrics []*models.Metrics) {
+	if !a.hasSignKey() {
+		return
+	}
+	for _, m := range metrics {
+		m.Hash = a.keys.SignMetric(m)
+	}
 }
 
 // StartSender отправляет метрики на сервер с ограничением скорости.
@@ -106,9 +134,119 @@ func (a *Agent) StartSender(ctx context.Context, jobs <-chan []*models.Metrics,
 	}
 }
 
+// reportMetricsBatch отправляет пакет метрик вместе с накопленной гистограммой задержек отправки.
+// Если отправка не удалась, пакет сохраняется в дисковый буфер.
+// Пока буфер не пуст, новые пакеты ставятся в очередь за ранее неотправленными,
+// чтобы сервер получал пакеты в порядке сбора.
 func (a *Agent) reportMetricsBatch(metrics []*models.Metrics) error {
+	latency := a.takeSendLatency()
+	if latency != nil {
+		metrics = append(metrics, models.NewHistogramMetric("SendLatencySeconds", latency))
+	}
+	metrics = append(metrics, a.spoolMetrics()...)
+	a.applyLabels(metrics)
+	a.signMetrics(metrics)
+
+	batchID := newBatchID()
+	if a.spool != nil && a.spool.Stats().Batches > 0 {
+		if err := a.spoolBatch(batchID, metrics); err == nil {
+			return a.replaySpool()
+		}
+	}
+
+	start := time.Now()
+	err := a.sendMetricsBatch(metrics, batchID)
+	elapsed := time.Since(start)
+	if err != nil && a.spool != nil && a.spoolBatch(batchID, metrics) == nil {
+		// наблюдения задержек сохранены в буфере вместе с пакетом
+		latency = nil
+	}
+	a.observeSendLatency(elapsed, latency, err)
+	return err
+}
+
+// spoolMetrics возвращает метрики глубины буфера неотправленных пакетов
+func (a *Agent) spoolMetrics() []*models.Metrics {
+	if a.spool == nil {
+		return nil
+	}
+	stats := a.spool.Stats()
+	return []*models.Metrics{
+		models.NewGaugeMetric("SpoolBatches", float64(stats.Batches)),
+		models.NewGaugeMetric("SpoolBytes", float64(stats.Bytes)),
+	}
+}
+
+// spoolBatch сохраняет пакет в буфер неотправленных пакетов
+func (a *Agent) spoolBatch(batchID string, metrics []*models.Metrics) error {
+	dropped, err := a.spool.Put(spool.Batch{ID: batchID, Metrics: metrics})
+	if err != nil {
+		a.Logger.Error("cant spool metrics batch", zap.String("batch", batchID), zap.Error(err))
+		return err
+	}
+	if dropped > 0 {
+		a.Logger.Warn("spool limits exceeded, oldest batches dropped", zap.Int("dropped", dropped))
+	}
+	a.Logger.Info("metrics batch spooled", zap.String("batch", batchID))
+	return nil
+}
+
+// replaySpool отправляет пакеты из буфера от старых к новым до первой ошибки.
+// Пакеты отправляются с исходными идентификаторами, поэтому сервер не применит их повторно.
+func (a *Agent) replaySpool() error {
+	sent, err := a.spool.Replay(func(b spool.Batch) error {
+		start := time.Now()
+		err := a.sendMetricsBatch(b.Metrics, b.ID)
+		a.observeSendLatency(time.Since(start), nil, err)
+		return err
+	})
+	if sent > 0 {
+		a.Logger.Info("spooled batches sent", zap.Int("count", sent))
+	}
+	return err
+}
+
+// drainSpool отправляет оставшиеся в буфере пакеты при остановке агента.
+// Неотправленные пакеты остаются на диске до следующего запуска.
+func (a *Agent) drainSpool() {
+	if a.spool == nil {
+		return
+	}
+	if err := a.replaySpool(); err != nil {
+		a.Logger.Warn("spool not drained, batches kept for next start",
+			zap.Int("batches", a.spool.Stats().Batches),
+			zap.Error(err))
+	}
+}
+
+// takeSendLatency забирает накопленную гистограмму задержек отправки.
+// Возвращает nil, если с прошлой отправки не было наблюдений.
+func (a *Agent) takeSendLatency() *models.HistogramValue {
+	a.sendLatencyMu.Lock()
+	defer a.sendLatencyMu.Unlock()
+	if a.sendLatency.Count == 0 {
+		return nil
+	}
+	h := a.sendLatency
+	a.sendLatency = models.NewHistogram(h.Bounds)
+	return h
+}
+
+// observeSendLatency добавляет задержку отправки в гистограмму.
+// Если отправка не удалась, неотправленные наблюдения возвращаются для следующего пакета.
+func (a *Agent) observeSendLatency(d time.Duration, unsent *models.HistogramValue, sendErr error) {
+	a.sendLatencyMu.Lock()
+	defer a.sendLatencyMu.Unlock()
+	a.sendLatency.Observe(d.Seconds())
+	if sendErr != nil && unsent != nil {
+		a.sendLatency.Merge(unsent)
+	}
+}
+
+// sendMetricsBatch отправляет пакет метрик по gRPC, если он настроен, иначе по HTTP
+func (a *Agent) sendMetricsBatch(metrics []*models.Metrics, batchID string) error {
 	if a.grpcServerHost != "" {
-		return a.reportMetricsBatchGRPC(metrics)
+		return a.reportMetricsBatchGRPC(metrics, batchID)
 	}
 
 	url, err := url.JoinPath(a.serverHostURL, "updates")
@@ -117,7 +255,7 @@ func (a *Agent) reportMetricsBatch(metrics []*models.Metrics) error {
 	}
 	req := a.httpClient.R()
 
-	compBody, signB64, err := a.prepareJSONPayload(metrics)
+	compBody, err := a.prepareJSONPayload(metrics)
 	if err != nil {
 		return err
 	}
@@ -128,10 +266,11 @@ func (a *Agent) reportMetricsBatch(metrics []*models.Metrics) error {
 	if a.agentIP != "" {
 		req.Header.Set("X-Real-IP", a.agentIP)
 	}
-	if signB64 != "" {
-		req.Header.Set("HashSHA256", signB64)
-	}
+	// идентификаторы агента и пакета позволяют серверу не применять повторы пакета
+	req.Header.Set("X-Agent-ID", a.agentID)
+	req.Header.Set("X-Batch-ID", batchID)
 
+	// подпись добавляет signRequest перед каждой попыткой
 	req.SetBody(compBody)
 
 	res, err := req.Post(url)
@@ -153,37 +292,94 @@ func (a *Agent) reportMetricsBatch(metrics []*models.Metrics) error {
 	return nil
 }
 
-// reportMetricsBatchGRPC отправляет метрики через gRPC
-func (a *Agent) reportMetricsBatchGRPC(metrics []*models.Metrics) error {
+// reportMetricsBatchGRPC отправляет метрики через gRPC.
+// Без ключа шифрования используется типизированный API v2 со сжатием gzip на транспорте,
+// с ключом - v1, так как гибридное шифрование полезной нагрузки поддерживается только им.
+func (a *Agent) reportMetricsBatchGRPC(metrics []*models.Metrics, batchID string) error {
 	if a.grpcServerHost == "" {
 		return fmt.Errorf("grpc address not configured")
 	}
 
-	// подготовка полезной нагрузки (общая)
-	comp, signB64, err := a.prepareJSONPayload(metrics)
-	if err != nil {
+	// ensure shared connection and client
+	if err := a.ensureGRPCConn(context.Background()); err != nil {
 		return err
 	}
+	if a.hasCryptoKey() {
+		return a.reportMetricsBatchGRPCv1(metrics, batchID)
+	}
 
-	// ensure shared connection and client
-	if err := a.ensureGRPCConn(context.Background()); err != nil {
+	req := &metricspbv2.UpdatesRequest{
+		Metrics: make([]*metricspbv2.Metric, 0, len(metrics)),
+		AgentId: a.agentID,
+		BatchId: batchID,
+	}
+	for _, m := range metrics {
+		req.Metrics = append(req.Metrics, metricspbv2.FromModel(m))
+	}
+
+	// metadata: signature, token and x-real-ip
+	md := a.outgoingMetadata()
+	if a.hasSignKey() {
+		payload, err := metricspbv2.SigningPayload(req)
+		if err != nil {
+			return fmt.Errorf("cant marshal request for signing: %w", err)
+		}
+		signHeaders, err := a.signatureHeaders(metricspbv2.Metrics_Updates_FullMethodName, payload)
+		if err != nil {
+			return err
+		}
+		for k, v := range signHeaders {
+			md.Set(k, v)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	_, err := a.grpcClientV2.Updates(ctx, req, grpc.UseCompressor(gzip.Name))
+	if err != nil {
+		a.Logger.Error("grpc v2 Updates failed", zap.Error(err))
 		return err
 	}
-	client := a.grpcClient
+	return nil
+}
 
-	// metadata: signature and x-real-ip
+// outgoingMetadata создает metadata gRPC запроса с адресом и токеном агента
+func (a *Agent) outgoingMetadata() metadata.MD {
 	md := metadata.New(nil)
 	if a.agentIP != "" {
 		md.Set("x-real-ip", a.agentIP)
 	}
-	if signB64 != "" {
-		md.Set("HashSHA256", signB64)
+	if a.token != "" {
+		md.Set("authorization", "Bearer "+a.token)
+	}
+	return md
+}
+
+// reportMetricsBatchGRPCv1 отправляет метрики через gRPC v1 в виде сжатого и зашифрованного JSON
+func (a *Agent) reportMetricsBatchGRPCv1(metrics []*models.Metrics, batchID string) error {
+	// подготовка полезной нагрузки (общая)
+	comp, err := a.prepareJSONPayload(metrics)
+	if err != nil {
+		return err
+	}
+	signHeaders, err := a.signatureHeaders(metricspb.Metrics_Updates_FullMethodName, comp)
+	if err != nil {
+		return err
 	}
+
+	// metadata: signature, token and x-real-ip
+	md := a.outgoingMetadata()
+	for k, v := range signHeaders {
+		md.Set(k, v)
+	}
+	md.Set("x-agent-id", a.agentID)
+	md.Set("x-batch-id", batchID)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	ctx = metadata.NewOutgoingContext(ctx, md)
 
-	_, err = client.Updates(ctx, &metricspb.BatchBytes{Payload: comp})
+	_, err = a.grpcClient.Updates(ctx, &metricspb.BatchBytes{Payload: comp})
 	if err != nil {
 		a.Logger.Error("grpc Updates failed", zap.Error(err))
 		return err
@@ -198,7 +394,8 @@ func (a *Agent) sendMetricJSON(metric *models.Metrics) error {
 	}
 	req := a.httpClient.R()
 
-	compressed, signB64, err := a.prepareJSONPayload(metric)
+	a.signMetrics([]*models.Metrics{metric})
+	compressed, err := a.prepareJSONPayload(metric)
 	if err != nil {
 		return fmt.Errorf("cant prepare body: %v", err)
 	}
@@ -209,11 +406,7 @@ func (a *Agent) sendMetricJSON(metric *models.Metrics) error {
 	if a.agentIP != "" {
 		req.Header.Set("X-Real-IP", a.agentIP)
 	}
-
-	if signB64 != "" {
-		req.Header.Set("HashSHA256", signB64)
-	}
-
+	// подпись добавляет signRequest перед каждой попыткой
 	req.SetBody(compressed)
 
 	res, err := req.Post(url)
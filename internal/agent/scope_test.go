@@ -2,15 +2,27 @@ package agent
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
+	"github.com/Soliard/go-tpl-metrics/internal/compressor"
 	"github.com/Soliard/go-tpl-metrics/internal/config"
 	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/internal/signer"
+	"github.com/Soliard/go-tpl-metrics/internal/tlsutil"
+	"github.com/Soliard/go-tpl-metrics/internal/tlsutil/tlstest"
 	"github.com/Soliard/go-tpl-metrics/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func setupTestAgent(serverHost string) *Agent {
@@ -169,3 +181,273 @@ func TestAgent_sendMetricJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestAgent_reportMetricsBatchSpool(t *testing.T) {
+	var down atomic.Bool
+	var mu sync.Mutex
+	var received []float64 // значения SpoolBatches в принятых пакетах
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if down.Load() {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		body, err = compressor.UncompressData(body)
+		require.NoError(t, err)
+		var metrics []*models.Metrics
+		require.NoError(t, json.Unmarshal(body, &metrics))
+		for _, m := range metrics {
+			if m.ID == "SpoolBatches" {
+				mu.Lock()
+				received = append(received, *m.Value)
+				mu.Unlock()
+			}
+		}
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger, err := logger.New("info")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	agent := New(&config.AgentConfig{
+		ServerHost:         server.URL,
+		SpoolDir:           dir,
+		SpoolMaxBytes:      1 << 20,
+		SpoolMaxAgeSeconds: 60,
+	}, logger)
+
+	down.Store(true)
+	assert.Error(t, agent.reportMetricsBatch([]*models.Metrics{models.NewCounterMetric("PollCount", 1)}))
+	assert.Error(t, agent.reportMetricsBatch([]*models.Metrics{models.NewCounterMetric("PollCount", 1)}))
+	assert.Equal(t, 2, agent.spool.Stats().Batches)
+
+	// буфер переживает перезапуск агента
+	agent = New(&config.AgentConfig{
+		ServerHost:         server.URL,
+		SpoolDir:           dir,
+		SpoolMaxBytes:      1 << 20,
+		SpoolMaxAgeSeconds: 60,
+	}, logger)
+	assert.Equal(t, 2, agent.spool.Stats().Batches)
+
+	down.Store(false)
+	assert.NoError(t, agent.reportMetricsBatch([]*models.Metrics{models.NewCounterMetric("PollCount", 1)}))
+	assert.Equal(t, 0, agent.spool.Stats().Batches)
+	assert.Equal(t, []float64{0, 1, 2}, received)
+}
+
+func TestAgent_reportMetricsBatchSignsMetrics(t *testing.T) {
+	key := []byte("secret")
+	var received []*models.Metrics
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		body, err = compressor.UncompressData(body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &received))
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger, err := logger.New("info")
+	require.NoError(t, err)
+	agent := New(&config.AgentConfig{
+		ServerHost: server.URL,
+		SignKey:    string(key),
+		Labels:     "host=a",
+	}, logger)
+
+	require.NoError(t, agent.reportMetricsBatch([]*models.Metrics{
+		models.NewCounterMetric("PollCount", 1),
+		models.NewGaugeMetric("Alloc", 1.5),
+	}))
+	require.NotEmpty(t, received)
+	for _, m := range received {
+		assert.Equal(t, "a", m.Labels["host"])
+		assert.True(t, signer.VerifyMetric(m, key), m.ID)
+	}
+}
+
+func TestAgent_reportMetricsBatchKeyID(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(keysFile,
+		[]byte(`{"active":"new","keys":[{"id":"old","key":"old secret"},{"id":"new","key":"new secret"}]}`), 0o600))
+
+	var headers http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		require.NoError(t, err)
+		headers = req.Header.Clone()
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger, err := logger.New("info")
+	require.NoError(t, err)
+	agent := New(&config.AgentConfig{
+		ServerHost:   server.URL,
+		SignKeysFile: keysFile,
+	}, logger)
+
+	require.NoError(t, agent.reportMetricsBatch([]*models.Metrics{models.NewGaugeMetric("Alloc", 1.5)}))
+	assert.Equal(t, "new", headers.Get(signer.KeyIDHeader))
+	timestamp, nonce := headers.Get(signer.TimestampHeader), headers.Get(signer.NonceHeader)
+	require.NotEmpty(t, timestamp)
+	require.NotEmpty(t, nonce)
+	signature, err := signer.DecodeSign(headers.Get("HashSHA256"))
+	require.NoError(t, err)
+	assert.True(t, signer.Verify(signer.SignedPayload("POST /updates", timestamp, nonce, body), []byte("new secret"), signature))
+
+	// каждая отправка подписывается с новым одноразовым значением
+	require.NoError(t, agent.reportMetricsBatch([]*models.Metrics{models.NewGaugeMetric("Alloc", 1.5)}))
+	assert.NotEqual(t, nonce, headers.Get(signer.NonceHeader))
+}
+
+// lostResponseTransport доставляет первый запрос серверу, но возвращает клиенту ошибку сети,
+// как при обрыве соединения до получения ответа
+type lostResponseTransport struct {
+	attempts atomic.Int32
+}
+
+func (tr *lostResponseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if tr.attempts.Add(1) > 1 || err != nil {
+		return res, err
+	}
+	res.Body.Close()
+	return nil, errors.New("connection reset by peer")
+}
+
+func TestAgent_retrySignsEachAttempt(t *testing.T) {
+	key := []byte("secret")
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	accepted := 0
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		timestamp, nonce := req.Header.Get(signer.TimestampHeader), req.Header.Get(signer.NonceHeader)
+		signature, err := signer.DecodeSign(req.Header.Get("HashSHA256"))
+		request := signer.HTTPRequestLine(req.Method, req.URL.RequestURI())
+		if err != nil || !signer.Verify(signer.SignedPayload(request, timestamp, nonce, body), key, signature) {
+			http.Error(res, "bad signature", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// сервер отклоняет повторно использованное одноразовое значение
+		if seen[nonce] {
+			http.Error(res, "replayed request", http.StatusBadRequest)
+			return
+		}
+		seen[nonce] = true
+		accepted++
+		if req.URL.Path == "/update" {
+			metric, err := compressor.UncompressData(body)
+			require.NoError(t, err)
+			res.Write(metric)
+			return
+		}
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger, err := logger.New("info")
+	require.NoError(t, err)
+	agent := New(&config.AgentConfig{ServerHost: server.URL, SignKey: string(key)}, logger)
+
+	tests := []struct {
+		name string
+		send func() error
+	}{
+		{
+			name: "batch",
+			send: func() error {
+				return agent.sendMetricsBatch([]*models.Metrics{models.NewGaugeMetric("Alloc", 1.5)}, "batch-1")
+			},
+		},
+		{
+			name: "single json",
+			send: func() error {
+				return agent.sendMetricJSON(models.NewGaugeMetric("Alloc", 1.5))
+			},
+		},
+		{
+			name: "url without body",
+			send: func() error {
+				return agent.sendMetric(models.NewGaugeMetric("Alloc", 1.5))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &lostResponseTransport{}
+			agent.httpClient.SetTransport(transport)
+			mu.Lock()
+			before := accepted
+			mu.Unlock()
+
+			require.NoError(t, tt.send())
+			assert.Equal(t, int32(2), transport.attempts.Load())
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, 2, accepted-before, "retry is signed with a fresh nonce")
+		})
+	}
+}
+
+func TestAgent_reportMetricsBatchToken(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		authorization = req.Header.Get("Authorization")
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logger, err := logger.New("info")
+	require.NoError(t, err)
+	agent := New(&config.AgentConfig{ServerHost: server.URL, Token: "web1 token"}, logger)
+
+	require.NoError(t, agent.reportMetricsBatch([]*models.Metrics{models.NewGaugeMetric("Alloc", 1.5)}))
+	assert.Equal(t, "Bearer web1 token", authorization)
+	assert.Equal(t, []string{"Bearer web1 token"}, agent.outgoingMetadata().Get("authorization"))
+}
+
+func TestAgent_reportMetricsBatchMutualTLS(t *testing.T) {
+	ca := tlstest.NewCA(t)
+	certFile, keyFile := ca.ServerCert("server")
+	logger, err := logger.New("info")
+	require.NoError(t, err)
+	serverTLS, err := tlsutil.NewServerConfig(certFile, keyFile, ca.CertFile, logger)
+	require.NoError(t, err)
+
+	var clientName string
+	// httptest подставляет свой сертификат вместо GetCertificate, поэтому сервер запускается вручную
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			clientName = req.TLS.PeerCertificates[0].Subject.CommonName
+			res.WriteHeader(http.StatusOK)
+		}),
+		TLSConfig: serverTLS,
+	}
+	go server.ServeTLS(lis, "", "")
+	defer server.Close()
+
+	clientCert, clientKey := ca.ClientCert("agent-1")
+	agent := New(&config.AgentConfig{
+		// схема не указана: при настроенном TLS используется https
+		ServerHost:  lis.Addr().String(),
+		TLSCAFile:   ca.CertFile,
+		TLSCertFile: clientCert,
+		TLSKeyFile:  clientKey,
+	}, logger)
+
+	require.NoError(t, agent.reportMetricsBatch([]*models.Metrics{models.NewGaugeMetric("Alloc", 1.5)}))
+	assert.Equal(t, "agent-1", clientName)
+}
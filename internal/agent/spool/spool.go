@@ -0,0 +1,270 @@
+// Package spool реализует дисковый буфер пакетов метрик, которые агент не смог отправить.
+// Каждый пакет хранится в отдельном файле, имя которого начинается со времени записи,
+// поэтому пакеты воспроизводятся от старых к новым и переживают перезапуск агента.
+package spool
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+const (
+	batchExt = ".json"
+	tmpExt   = ".tmp"
+)
+
+// Batch - пакет метрик в буфере.
+// ID сохраняется между повторными отправками, чтобы сервер мог отбросить уже примененный пакет.
+type Batch struct {
+	ID      string            `json:"id"`
+	Created time.Time         `json:"created"`
+	Metrics []*models.Metrics `json:"metrics"`
+}
+
+// Stats - текущая глубина буфера
+type Stats struct {
+	Batches int   // количество пакетов
+	Bytes   int64 // суммарный размер файлов пакетов
+}
+
+// entry - файл пакета в каталоге буфера
+type entry struct {
+	name string
+	size int64
+}
+
+// Spool - ограниченный по размеру и возрасту дисковый буфер пакетов
+type Spool struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries []entry // отсортированы от старых к новым
+	bytes   int64
+	seq     int64 // последнее использованное время в имени файла, обеспечивает уникальность имен
+
+	// replayMu не дает воспроизводить буфер одновременно из нескольких отправителей
+	replayMu sync.Mutex
+}
+
+// Open открывает буфер в каталоге dir, создавая его при необходимости.
+// Пакеты, оставшиеся от предыдущего запуска, загружаются, недописанные временные файлы удаляются.
+// maxBytes и maxAge ограничивают суммарный размер и возраст пакетов, 0 отключает ограничение.
+func Open(dir string, maxBytes int64, maxAge time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cant create spool dir: %w", err)
+	}
+	s := &Spool{dir: dir, maxBytes: maxBytes, maxAge: maxAge, now: time.Now}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cant read spool dir: %w", err)
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		switch filepath.Ext(f.Name()) {
+		case tmpExt:
+			os.Remove(filepath.Join(dir, f.Name()))
+		case batchExt:
+			info, err := f.Info()
+			if err != nil {
+				continue
+			}
+			s.entries = append(s.entries, entry{name: f.Name(), size: info.Size()})
+			s.bytes += info.Size()
+		}
+	}
+	sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].name < s.entries[j].name })
+	if n := len(s.entries); n > 0 {
+		s.seq = entryTime(s.entries[n-1].name)
+	}
+	s.enforceLimits()
+	return s, nil
+}
+
+// Put записывает пакет в буфер и применяет ограничения.
+// Возвращает количество пакетов, удаленных из-за превышения размера или возраста.
+func (s *Spool) Put(batch Batch) (dropped int, err error) {
+	if batch.Created.IsZero() {
+		batch.Created = s.now()
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return 0, fmt.Errorf("cant marshal batch: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := s.nextName(batch)
+	tmp := filepath.Join(s.dir, name+tmpExt)
+	if err := writeFileSync(tmp, data); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("cant commit spool file: %w", err)
+	}
+	s.entries = append(s.entries, entry{name: name, size: int64(len(data))})
+	s.bytes += int64(len(data))
+
+	return s.enforceLimits(), nil
+}
+
+// nextName возвращает имя файла, упорядочивающее пакеты по времени записи
+func (s *Spool) nextName(batch Batch) string {
+	ts := batch.Created.UnixNano()
+	if ts <= s.seq {
+		ts = s.seq + 1
+	}
+	s.seq = ts
+	return fmt.Sprintf("%020d-%s%s", ts, sanitize(batch.ID), batchExt)
+}
+
+// enforceLimits удаляет устаревшие пакеты и самые старые пакеты сверх лимита размера
+func (s *Spool) enforceLimits() int {
+	dropped := 0
+	if s.maxAge > 0 {
+		cutoff := s.now().Add(-s.maxAge).UnixNano()
+		for len(s.entries) > 0 && entryTime(s.entries[0].name) < cutoff {
+			s.removeFirst()
+			dropped++
+		}
+	}
+	if s.maxBytes > 0 {
+		for len(s.entries) > 0 && s.bytes > s.maxBytes {
+			s.removeFirst()
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// removeFirst удаляет самый старый пакет. Вызывается под s.mu.
+func (s *Spool) removeFirst() {
+	e := s.entries[0]
+	os.Remove(filepath.Join(s.dir, e.name))
+	s.entries = s.entries[1:]
+	s.bytes -= e.size
+}
+
+// remove удаляет пакет с указанным именем файла, если он еще есть в буфере
+func (s *Spool) remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if e.name == name {
+			os.Remove(filepath.Join(s.dir, e.name))
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			s.bytes -= e.size
+			return
+		}
+	}
+}
+
+// oldest возвращает имя файла самого старого пакета после применения ограничения возраста
+func (s *Spool) oldest() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enforceLimits()
+	if len(s.entries) == 0 {
+		return "", false
+	}
+	return s.entries[0].name, true
+}
+
+// Replay передает пакеты в send от старых к новым и удаляет успешно отправленные.
+// Останавливается на первой ошибке send и возвращает ее, оставшиеся пакеты остаются в буфере.
+// Поврежденные файлы удаляются. Если буфер уже воспроизводится, сразу возвращает 0, nil.
+func (s *Spool) Replay(send func(Batch) error) (int, error) {
+	if !s.replayMu.TryLock() {
+		return 0, nil
+	}
+	defer s.replayMu.Unlock()
+
+	sent := 0
+	for {
+		name, ok := s.oldest()
+		if !ok {
+			return sent, nil
+		}
+		batch, err := s.read(name)
+		if err != nil {
+			s.remove(name)
+			continue
+		}
+		if err := send(batch); err != nil {
+			return sent, err
+		}
+		s.remove(name)
+		sent++
+	}
+}
+
+// read читает пакет из файла
+func (s *Spool) read(name string) (Batch, error) {
+	var batch Batch
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return batch, err
+	}
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return batch, fmt.Errorf("corrupted spool file %s: %w", name, err)
+	}
+	return batch, nil
+}
+
+// Stats возвращает текущую глубину буфера
+func (s *Spool) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{Batches: len(s.entries), Bytes: s.bytes}
+}
+
+// writeFileSync записывает файл и сбрасывает его на диск
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("cant create spool file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("cant write spool file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("cant sync spool file: %w", err)
+	}
+	return f.Close()
+}
+
+// entryTime возвращает время записи пакета из имени файла
+func entryTime(name string) int64 {
+	prefix, _, _ := strings.Cut(name, "-")
+	ts, _ := strconv.ParseInt(prefix, 10, 64)
+	return ts
+}
+
+// sanitize оставляет в идентификаторе пакета только символы, допустимые в имени файла
+func sanitize(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return -1
+	}, id)
+}
@@ -0,0 +1,159 @@
+package spool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBatch(id string, created time.Time) Batch {
+	return Batch{
+		ID:      id,
+		Created: created,
+		Metrics: []*models.Metrics{models.NewCounterMetric("requests", 1)},
+	}
+}
+
+func replayIDs(t *testing.T, s *Spool) []string {
+	var ids []string
+	_, err := s.Replay(func(b Batch) error {
+		ids = append(ids, b.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	return ids
+}
+
+func TestSpool_ReplayOrder(t *testing.T) {
+	s, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	now := time.Now()
+	// пакеты с одинаковым временем сохраняют порядок записи
+	for _, id := range []string{"a", "b", "c"} {
+		_, err := s.Put(testBatch(id, now))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, s.Stats().Batches)
+
+	assert.Equal(t, []string{"a", "b", "c"}, replayIDs(t, s))
+	assert.Equal(t, Stats{}, s.Stats())
+}
+
+func TestSpool_ReplayStopsOnError(t *testing.T) {
+	s, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	now := time.Now()
+	for i, id := range []string{"a", "b", "c"} {
+		_, err := s.Put(testBatch(id, now.Add(time.Duration(i)*time.Second)))
+		require.NoError(t, err)
+	}
+
+	sendErr := errors.New("server down")
+	var calls []string
+	sent, err := s.Replay(func(b Batch) error {
+		calls = append(calls, b.ID)
+		if b.ID == "b" {
+			return sendErr
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, sendErr)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"a", "b"}, calls)
+	assert.Equal(t, []string{"b", "c"}, replayIDs(t, s))
+}
+
+func TestSpool_Limits(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int64
+		maxAge   time.Duration
+		ages     []time.Duration
+		want     []string
+		dropped  int
+	}{
+		{
+			name: "no limits",
+			ages: []time.Duration{3 * time.Hour, 2 * time.Hour, 0},
+			want: []string{"b0", "b1", "b2"},
+		},
+		{
+			name:    "max age",
+			maxAge:  time.Hour,
+			ages:    []time.Duration{3 * time.Hour, 2 * time.Hour, 0},
+			want:    []string{"b2"},
+			dropped: 2,
+		},
+		{
+			name:     "max bytes smaller than batch",
+			maxBytes: 1,
+			ages:     []time.Duration{2 * time.Second, time.Second, 0},
+			want:     nil,
+			dropped:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open(t.TempDir(), tt.maxBytes, tt.maxAge)
+			require.NoError(t, err)
+			now := time.Now()
+			dropped := 0
+			for i, age := range tt.ages {
+				n, err := s.Put(testBatch("b"+string(rune('0'+i)), now.Add(-age)))
+				require.NoError(t, err)
+				dropped += n
+			}
+			assert.Equal(t, tt.dropped, dropped)
+			assert.Equal(t, tt.want, replayIDs(t, s))
+		})
+	}
+}
+
+func TestSpool_MaxBytesDropsOldest(t *testing.T) {
+	s, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	_, err = s.Put(testBatch("a", time.Now()))
+	require.NoError(t, err)
+	size := s.Stats().Bytes
+
+	s.maxBytes = 2 * size
+	now := time.Now()
+	for i, id := range []string{"b", "c"} {
+		_, err := s.Put(testBatch(id, now.Add(time.Duration(i+1)*time.Second)))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"b", "c"}, replayIDs(t, s))
+}
+
+func TestSpool_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, 0, 0)
+	require.NoError(t, err)
+	now := time.Now()
+	for i, id := range []string{"a", "b"} {
+		_, err := s.Put(testBatch(id, now.Add(time.Duration(i)*time.Second)))
+		require.NoError(t, err)
+	}
+	// недописанный файл и поврежденный пакет от предыдущего запуска
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001-x.json.tmp"), []byte("{"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000002-y.json"), []byte("{"), 0o644))
+
+	reopened, err := Open(dir, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, reopened.Stats().Batches)
+
+	_, err = reopened.Put(testBatch("c", now))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, replayIDs(t, reopened))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -10,97 +10,126 @@ import (
 	"github.com/Soliard/go-tpl-metrics/models"
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/v4/mem"
-	"go.uber.org/zap"
 )
 
-// Collector собирает метрики Go runtime (память, GC, горутины и т.д.).
-// Запускается в отдельной горутине с заданным интервалом.
-func (a *Agent) Collector(ctx context.Context, id int, result chan<- []*models.Metrics) {
+// defaultGCPauseBuckets - границы корзин гистограммы пауз GC по умолчанию: от 10мкс до ~160мс
+var defaultGCPauseBuckets = models.ExponentialBuckets(0.00001, 4, 8)
+
+func init() {
+	RegisterCollector("runtime", func(a *Agent) Collector { return newRuntimeCollector(a.gcPauseBuckets) })
+	RegisterCollector("ps", func(a *Agent) Collector { return &psCollector{} })
+}
+
+// runtimeCollector собирает метрики Go runtime (память, GC, горутины и т.д.)
+type runtimeCollector struct {
+	buckets   []float64 // границы корзин гистограммы пауз GC
+	pollCount int64
+	lastNumGC uint32
+}
+
+// newRuntimeCollector создает коллектор метрик Go runtime
+func newRuntimeCollector(gcPauseBuckets []float64) *runtimeCollector {
+	return &runtimeCollector{buckets: gcPauseBuckets}
+}
+
+// Collect читает MemStats и возвращает пакет метрик runtime
+func (c *runtimeCollector) Collect(ctx context.Context) ([]*models.Metrics, error) {
 	var m runtime.MemStats
-	polCount := 0
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(a.pollInterval):
-			runtime.ReadMemStats(&m)
-			batch := make([]*models.Metrics, 0, 28)
-			polCount++
-			batch = append(batch,
-				models.NewGaugeMetric("Alloc", float64(m.Alloc)),
-				models.NewGaugeMetric("BuckHashSys", float64(m.BuckHashSys)),
-				models.NewGaugeMetric("Frees", float64(m.Frees)),
-				models.NewGaugeMetric("GCCPUFraction", float64(m.GCCPUFraction)),
-				models.NewGaugeMetric("GCSys", float64(m.GCSys)),
-				models.NewGaugeMetric("HeapAlloc", float64(m.HeapAlloc)),
-				models.NewGaugeMetric("HeapIdle", float64(m.HeapIdle)),
-				models.NewGaugeMetric("HeapInuse", float64(m.HeapInuse)),
-				models.NewGaugeMetric("HeapObjects", float64(m.HeapObjects)),
-				models.NewGaugeMetric("HeapReleased", float64(m.HeapReleased)),
-				models.NewGaugeMetric("HeapSys", float64(m.HeapSys)),
-				models.NewGaugeMetric("LastGC", float64(m.LastGC)),
-				models.NewGaugeMetric("Lookups", float64(m.Lookups)),
-				models.NewGaugeMetric("MCacheInuse", float64(m.MCacheInuse)),
-				models.NewGaugeMetric("MCacheSys", float64(m.MCacheSys)),
-				models.NewGaugeMetric("MSpanInuse", float64(m.MSpanInuse)),
-				models.NewGaugeMetric("MSpanSys", float64(m.MSpanSys)),
-				models.NewGaugeMetric("Mallocs", float64(m.Mallocs)),
-				models.NewGaugeMetric("NextGC", float64(m.NextGC)),
-				models.NewGaugeMetric("NumForcedGC", float64(m.NumForcedGC)),
-				models.NewGaugeMetric("NumGC", float64(m.NumGC)),
-				models.NewGaugeMetric("OtherSys", float64(m.OtherSys)),
-				models.NewGaugeMetric("PauseTotalNs", float64(m.PauseTotalNs)),
-				models.NewGaugeMetric("StackInuse", float64(m.StackInuse)),
-				models.NewGaugeMetric("StackSys", float64(m.StackSys)),
-				models.NewGaugeMetric("Sys", float64(m.Sys)),
-				models.NewGaugeMetric("TotalAlloc", float64(m.TotalAlloc)),
-				models.NewGaugeMetric("RandomValue", float64(rand.Float64())),
-				models.NewCounterMetric("PollCount", int64(polCount)),
-			)
-			select {
-			case result <- batch:
-			case <-ctx.Done():
-				return
-			}
-			a.Logger.Info("memory stats colected by collector", zap.Int("collector id", id))
-		}
+	runtime.ReadMemStats(&m)
+	batch := make([]*models.Metrics, 0, 28)
+	c.pollCount++
+	batch = append(batch,
+		models.NewGaugeMetric("Alloc", float64(m.Alloc)),
+		models.NewGaugeMetric("BuckHashSys", float64(m.BuckHashSys)),
+		models.NewGaugeMetric("Frees", float64(m.Frees)),
+		models.NewGaugeMetric("GCCPUFraction", float64(m.GCCPUFraction)),
+		models.NewGaugeMetric("GCSys", float64(m.GCSys)),
+		models.NewGaugeMetric("HeapAlloc", float64(m.HeapAlloc)),
+		models.NewGaugeMetric("HeapIdle", float64(m.HeapIdle)),
+		models.NewGaugeMetric("HeapInuse", float64(m.HeapInuse)),
+		models.NewGaugeMetric("HeapObjects", float64(m.HeapObjects)),
+		models.NewGaugeMetric("HeapReleased", float64(m.HeapReleased)),
+		models.NewGaugeMetric("HeapSys", float64(m.HeapSys)),
+		models.NewGaugeMetric("LastGC", float64(m.LastGC)),
+		models.NewGaugeMetric("Lookups", float64(m.Lookups)),
+		models.NewGaugeMetric("MCacheInuse", float64(m.MCacheInuse)),
+		models.NewGaugeMetric("MCacheSys", float64(m.MCacheSys)),
+		models.NewGaugeMetric("MSpanInuse", float64(m.MSpanInuse)),
+		models.NewGaugeMetric("MSpanSys", float64(m.MSpanSys)),
+		models.NewGaugeMetric("Mallocs", float64(m.Mallocs)),
+		models.NewGaugeMetric("NextGC", float64(m.NextGC)),
+		models.NewGaugeMetric("NumForcedGC", float64(m.NumForcedGC)),
+		models.NewGaugeMetric("NumGC", float64(m.NumGC)),
+		models.NewGaugeMetric("OtherSys", float64(m.OtherSys)),
+		models.NewHistogramMetric("GCPauseSeconds", c.gcPauses(&m, c.lastNumGC)),
+		models.NewGaugeMetric("StackInuse", float64(m.StackInuse)),
+		models.NewGaugeMetric("StackSys", float64(m.StackSys)),
+		models.NewGaugeMetric("Sys", float64(m.Sys)),
+		models.NewGaugeMetric("TotalAlloc", float64(m.TotalAlloc)),
+		models.NewGaugeMetric("RandomValue", float64(rand.Float64())),
+		models.NewCounterMetric("PollCount", c.pollCount),
+	)
+	c.lastNumGC = m.NumGC
+	return batch, nil
+}
+
+// gcPauses возвращает гистограмму пауз GC, произошедших после сборки с номером lastNumGC.
+// Runtime хранит длительности только последних 256 пауз, более ранние пропускаются.
+func (c *runtimeCollector) gcPauses(m *runtime.MemStats, lastNumGC uint32) *models.HistogramValue {
+	h := models.NewHistogram(c.buckets)
+	from := lastNumGC
+	if m.NumGC-from > uint32(len(m.PauseNs)) {
+		from = m.NumGC - uint32(len(m.PauseNs))
+	}
+	for n := from + 1; n <= m.NumGC; n++ {
+		pause := m.PauseNs[(n+uint32(len(m.PauseNs))-1)%uint32(len(m.PauseNs))]
+		h.Observe(time.Duration(pause).Seconds())
 	}
+	return h
 }
 
-// CollectorPS собирает системные метрики (память и CPU) через gopsutil.
-// Запускается в отдельной горутине с заданным интервалом.
-func (a *Agent) CollectorPS(ctx context.Context, id int, result chan<- []*models.Metrics) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(a.pollInterval):
-			memory, err := mem.VirtualMemory()
-			if err != nil {
-				a.Logger.Error("failed to get memory stats", zap.Error(err))
-				continue
-			}
+// psCollector собирает системные метрики (память и CPU) через gopsutil
+type psCollector struct{}
 
-			cpuPercents, err := cpu.Percent(time.Second, true)
-			if err != nil {
-				a.Logger.Error("failed to get CPU stats", zap.Error(err))
-				continue
-			}
+// Collect возвращает объем памяти и загрузку каждого CPU за секунду
+func (c *psCollector) Collect(ctx context.Context) ([]*models.Metrics, error) {
+	memory, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get memory stats: %w", err)
+	}
 
-			batch := make([]*models.Metrics, 0, len(cpuPercents)+2)
-			batch = append(batch,
-				models.NewGaugeMetric("TotalMemory", float64(memory.Total)),
-				models.NewGaugeMetric("FreeMemory", float64(memory.Free)),
-			)
-			for i, c := range cpuPercents {
-				batch = append(batch, models.NewGaugeMetric(fmt.Sprint("CPUutilization", i), c))
-			}
-			select {
-			case result <- batch:
-			case <-ctx.Done():
-				return
-			}
-			a.Logger.Info("ps stats colected by collectorps", zap.Int("collectorps id", id))
-		}
+	cpuPercents, err := cpu.PercentWithContext(ctx, time.Second, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CPU stats: %w", err)
 	}
+
+	batch := make([]*models.Metrics, 0, len(cpuPercents)+2)
+	batch = append(batch,
+		models.NewGaugeMetric("TotalMemory", float64(memory.Total)),
+		models.NewGaugeMetric("FreeMemory", float64(memory.Free)),
+	)
+	for i, c := range cpuPercents {
+		batch = append(batch, models.NewGaugeMetric(fmt.Sprint("CPUutilization", i), c))
+	}
+	return batch, nil
+}
+
+// Collector собирает метрики Go runtime с интервалом POLL_INTERVAL независимо от настроек COLLECTORS.
+// Запускается в отдельной горутине.
+func (a *Agent) Collector(ctx context.Context, id int, result chan<- []*models.Metrics) {
+	a.runCollector(ctx, scheduledCollector{
+		name:      fmt.Sprint("runtime#", id),
+		interval:  a.pollInterval,
+		collector: newRuntimeCollector(a.gcPauseBuckets),
+	}, result)
+}
+
+// CollectorPS собирает системные метрики (память и CPU) через gopsutil с интервалом POLL_INTERVAL
+// независимо от настроек COLLECTORS. Запускается в отдельной горутине.
+func (a *Agent) CollectorPS(ctx context.Context, id int, result chan<- []*models.Metrics) {
+	a.runCollector(ctx, scheduledCollector{
+		name:      fmt.Sprint("ps#", id),
+		interval:  a.pollInterval,
+		collector: &psCollector{},
+	}, result)
 }
@@ -2,10 +2,12 @@ package agent
 
 import (
 	"context"
+	"runtime"
 	"testing"
 
 	"github.com/Soliard/go-tpl-metrics/internal/config"
 	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
 
@@ -40,3 +42,20 @@ func TestCollector(t *testing.T) {
 		}
 	}
 }
+
+func TestGCPauses(t *testing.T) {
+	agent := New(&config.AgentConfig{GCPauseBuckets: "0.001,0.01"}, zap.NewNop())
+	var m runtime.MemStats
+	m.NumGC = 3
+	m.PauseNs[0] = 500_000   // 0.5ms
+	m.PauseNs[1] = 5_000_000 // 5ms
+	m.PauseNs[2] = 50_000_000
+
+	c := newRuntimeCollector(agent.gcPauseBuckets)
+	h := c.gcPauses(&m, 1)
+	assert.Equal(t, []int64{0, 1, 1}, h.Counts)
+	assert.Equal(t, int64(2), h.Count)
+
+	h = c.gcPauses(&m, 3)
+	assert.Equal(t, int64(0), h.Count)
+}
@@ -95,11 +95,7 @@ func GzipMiddleware(log *zap.Logger) func(next http.Handler) http.Handler {
 			next.ServeHTTP(bw, r)
 
 			contentType := bw.Header().Get("Content-Type")
-			shouldCompress := strings.Contains(contentType, "html") ||
-				strings.Contains(contentType, "json") ||
-				strings.Contains(contentType, "xml")
-
-			if shouldCompress {
+			if shouldCompress(contentType) {
 				loggerFromCtx.Info("supports compression, response will be compressed")
 				w.Header().Set("Content-Encoding", "gzip")
 				if bw.statusCode != 0 {
@@ -120,3 +116,13 @@ func GzipMiddleware(log *zap.Logger) func(next http.Handler) http.Handler {
 		})
 	}
 }
+
+// shouldCompress определяет, нужно ли сжимать ответ с указанным Content-Type.
+// Помимо html, json и xml сжимаются форматы экспозиции Prometheus и OpenMetrics.
+func shouldCompress(contentType string) bool {
+	return strings.Contains(contentType, "html") ||
+		strings.Contains(contentType, "json") ||
+		strings.Contains(contentType, "xml") ||
+		strings.Contains(contentType, "openmetrics") ||
+		strings.HasPrefix(contentType, "text/plain; version=")
+}
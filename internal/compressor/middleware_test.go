@@ -49,6 +49,14 @@ func TestGzipMiddleware(t *testing.T) {
 			expectCompression:   true,
 			expectDecompression: true,
 		},
+		{
+			name:                "prometheus exposition format",
+			acceptEncoding:      "gzip",
+			contentType:         "text/plain; version=0.0.4; charset=utf-8",
+			responseBody:        "# TYPE test gauge\ntest 1\n",
+			expectCompression:   true,
+			expectDecompression: false,
+		},
 		{
 			name:                "non-compressible content type",
 			acceptEncoding:      "gzip",
@@ -4,7 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/Soliard/go-tpl-metrics/models"
 	"github.com/caarlos0/env/v6"
 )
 
@@ -16,8 +18,21 @@ type AgentConfig struct {
 	ReportIntervalSeconds int    `env:"REPORT_INTERVAL" json:"report_interval"` // интервал отправки метрик
 	LogLevel              string `env:"LOG_LEVEL" json:"log_level"`             // уровень логирования
 	SignKey               string `env:"KEY" json:"sign_key"`                    // ключ для подписи данных
+	SignKeysFile          string `env:"SIGN_KEYS_FILE" json:"sign_keys_file"`   // путь к JSON файлу ключей подписи, подписывает активный ключ
 	RequestsLimit         int    `env:"RATE_LIMIT" json:"rate_limit"`           // лимит одновременных запросов
 	CryptoKey             string `env:"CRYPTO_KEY" json:"crypto_key"`           // путь к публичному ключу для шифрования
+	TLSCAFile             string `env:"TLS_CA_FILE" json:"tls_ca_file"`         // путь к PEM CA для проверки сертификата сервера, включает TLS
+	TLSCertFile           string `env:"TLS_CERT_FILE" json:"tls_cert_file"`     // путь к PEM клиентскому сертификату для взаимного TLS
+	TLSKeyFile            string `env:"TLS_KEY_FILE" json:"tls_key_file"`       // путь к PEM ключу клиентского сертификата
+	AgentID               string `env:"AGENT_ID" json:"agent_id"`               // идентификатор агента для дедупликации пакетов
+	Token                 string `env:"TOKEN" json:"token"`                     // токен агента, передается в заголовке Authorization: Bearer
+	Labels                string `env:"LABELS" json:"labels"`                   // метки отправляемых метрик в формате key=value,key2=value2
+	GCPauseBuckets        string `env:"GC_PAUSE_BUCKETS" json:"gc_pause_buckets"` // границы корзин гистограммы пауз GC в секундах
+	LatencyBuckets        string `env:"LATENCY_BUCKETS" json:"latency_buckets"`   // границы корзин гистограммы задержек отправки в секундах
+	SpoolDir              string `env:"SPOOL_DIR" json:"spool_dir"`               // каталог буфера неотправленных пакетов, пустой отключает буфер
+	SpoolMaxBytes         int64  `env:"SPOOL_MAX_BYTES" json:"spool_max_bytes"`   // максимальный размер буфера в байтах
+	SpoolMaxAgeSeconds    int    `env:"SPOOL_MAX_AGE" json:"spool_max_age"`       // максимальный возраст пакета в буфере в секундах, не больше BatchDedupTTL
+	Collectors            string `env:"COLLECTORS" json:"collectors"`             // настройки коллекторов в формате name=on|off|интервал, например ps=off,runtime=1s
 }
 
 // AgentJSONConfig представляет структуру JSON конфигурации для агента
@@ -27,6 +42,20 @@ type AgentJSONConfig struct {
 	ReportInterval string `json:"report_interval"` // аналог переменной окружения REPORT_INTERVAL или флага -r
 	PollInterval   string `json:"poll_interval"`   // аналог переменной окружения POLL_INTERVAL или флага -p
 	CryptoKey      string `json:"crypto_key"`      // аналог переменной окружения CRYPTO_KEY или флага -crypto-key
+	TLSCAFile      string `json:"tls_ca_file"`     // аналог переменной окружения TLS_CA_FILE или флага -tls-ca
+	TLSCertFile    string `json:"tls_cert_file"`   // аналог переменной окружения TLS_CERT_FILE или флага -tls-cert
+	TLSKeyFile     string `json:"tls_key_file"`    // аналог переменной окружения TLS_KEY_FILE или флага -tls-key
+	SignKeysFile   string `json:"sign_keys_file"`  // аналог переменной окружения SIGN_KEYS_FILE или флага -sign-keys
+	AgentID        string `json:"agent_id"`        // аналог переменной окружения AGENT_ID или флага -id
+	Token          string `json:"token"`           // аналог переменной окружения TOKEN или флага -token
+	Labels         string `json:"labels"`          // аналог переменной окружения LABELS или флага -labels
+	GCPauseBuckets string `json:"gc_pause_buckets"` // аналог переменной окружения GC_PAUSE_BUCKETS или флага -gc-buckets
+	LatencyBuckets string `json:"latency_buckets"`  // аналог переменной окружения LATENCY_BUCKETS или флага -latency-buckets
+	SpoolDir       string `json:"spool_dir"`        // аналог переменной окружения SPOOL_DIR или флага -spool-dir
+	SpoolMaxBytes  int64  `json:"spool_max_bytes"`  // аналог переменной окружения SPOOL_MAX_BYTES или флага -spool-max-bytes
+	SpoolMaxAge    string `json:"spool_max_age"`    // аналог переменной окружения SPOOL_MAX_AGE или флага -spool-max-age, например "30m"
+	// аналог переменной окружения COLLECTORS или флага -collectors, например {"ps": {"enabled": false}, "runtime": {"interval": "1s"}}
+	Collectors map[string]CollectorJSONConfig `json:"collectors"`
 }
 
 func fillAgentDefaults(c *AgentConfig) {
@@ -45,6 +74,15 @@ func fillAgentDefaults(c *AgentConfig) {
 	if c.RequestsLimit == 0 {
 		c.RequestsLimit = 100
 	}
+	if c.AgentID == "" {
+		c.AgentID, _ = os.Hostname()
+	}
+	if c.SpoolMaxBytes <= 0 {
+		c.SpoolMaxBytes = 64 << 20
+	}
+	if c.SpoolMaxAgeSeconds <= 0 {
+		c.SpoolMaxAgeSeconds = int(BatchDedupTTL / time.Second)
+	}
 }
 
 // NewAgentConfig создает новую конфигурацию агента.
@@ -72,6 +110,22 @@ func NewAgentConfig() (*AgentConfig, error) {
 
 	fillAgentDefaults(config)
 
+	if _, err := models.ParseLabels(config.Labels); err != nil {
+		return nil, err
+	}
+	if _, err := models.ParseBuckets(config.GCPauseBuckets, nil); err != nil {
+		return nil, fmt.Errorf("invalid gc pause buckets: %w", err)
+	}
+	if _, err := models.ParseBuckets(config.LatencyBuckets, nil); err != nil {
+		return nil, fmt.Errorf("invalid latency buckets: %w", err)
+	}
+	if _, err := ParseCollectors(config.Collectors); err != nil {
+		return nil, err
+	}
+	if time.Duration(config.SpoolMaxAgeSeconds)*time.Second > BatchDedupTTL {
+		return nil, fmt.Errorf("spool max age %ds exceeds batch dedup window %s", config.SpoolMaxAgeSeconds, BatchDedupTTL)
+	}
+
 	return config, nil
 }
 
@@ -86,6 +140,18 @@ func loadAgentFromJSON(config *AgentConfig, reader *ConfigFileReader, filePath s
 	config.ServerHost = jsonConfig.Address
     config.GRPCServerHost = jsonConfig.GRPCAddress
 	config.CryptoKey = jsonConfig.CryptoKey
+	config.TLSCAFile = jsonConfig.TLSCAFile
+	config.TLSCertFile = jsonConfig.TLSCertFile
+	config.TLSKeyFile = jsonConfig.TLSKeyFile
+	config.SignKeysFile = jsonConfig.SignKeysFile
+	config.AgentID = jsonConfig.AgentID
+	config.Token = jsonConfig.Token
+	config.Labels = jsonConfig.Labels
+	config.GCPauseBuckets = jsonConfig.GCPauseBuckets
+	config.LatencyBuckets = jsonConfig.LatencyBuckets
+	config.SpoolDir = jsonConfig.SpoolDir
+	config.SpoolMaxBytes = jsonConfig.SpoolMaxBytes
+	config.Collectors = formatCollectors(jsonConfig.Collectors)
 
 	// Парсим интервалы из строк в секунды
 	if jsonConfig.ReportInterval != "" {
@@ -104,6 +170,14 @@ func loadAgentFromJSON(config *AgentConfig, reader *ConfigFileReader, filePath s
 		config.PollIntervalSeconds = seconds
 	}
 
+	if jsonConfig.SpoolMaxAge != "" {
+		seconds, err := reader.ParseDurationFromString(jsonConfig.SpoolMaxAge)
+		if err != nil {
+			return err
+		}
+		config.SpoolMaxAgeSeconds = seconds
+	}
+
 	return nil
 }
 
@@ -117,8 +191,21 @@ func parseAgentFlags(config *AgentConfig) error {
 	fs.IntVar(&config.ReportIntervalSeconds, "r", config.ReportIntervalSeconds, "metrics send interval in seconds")
 	fs.StringVar(&config.LogLevel, "ll", config.LogLevel, "log level")
 	fs.StringVar(&config.SignKey, "k", config.SignKey, "key will be used for signing data from agent")
+	fs.StringVar(&config.SignKeysFile, "sign-keys", config.SignKeysFile, "path to JSON file with signing keys, data is signed with the active key")
 	fs.IntVar(&config.RequestsLimit, "l", config.RequestsLimit, "server request rate limit")
 	fs.StringVar(&config.CryptoKey, "crypto-key", config.CryptoKey, "path to public PEM key for encryption")
+	fs.StringVar(&config.TLSCAFile, "tls-ca", config.TLSCAFile, "path to PEM CA bundle for server certificate, enables TLS")
+	fs.StringVar(&config.TLSCertFile, "tls-cert", config.TLSCertFile, "path to PEM client certificate for mutual TLS")
+	fs.StringVar(&config.TLSKeyFile, "tls-key", config.TLSKeyFile, "path to PEM private key of the client certificate")
+	fs.StringVar(&config.AgentID, "id", config.AgentID, "agent identifier used for batch deduplication")
+	fs.StringVar(&config.Token, "token", config.Token, "agent token sent in Authorization: Bearer header")
+	fs.StringVar(&config.Labels, "labels", config.Labels, "labels attached to reported metrics, e.g. host=web1,env=prod")
+	fs.StringVar(&config.GCPauseBuckets, "gc-buckets", config.GCPauseBuckets, "GC pause histogram bucket bounds in seconds, e.g. 0.0001,0.001,0.01")
+	fs.StringVar(&config.LatencyBuckets, "latency-buckets", config.LatencyBuckets, "send latency histogram bucket bounds in seconds, e.g. 0.01,0.1,1")
+	fs.StringVar(&config.SpoolDir, "spool-dir", config.SpoolDir, "directory for batches that failed to send, empty disables spooling")
+	fs.Int64Var(&config.SpoolMaxBytes, "spool-max-bytes", config.SpoolMaxBytes, "max total size of spooled batches in bytes")
+	fs.IntVar(&config.SpoolMaxAgeSeconds, "spool-max-age", config.SpoolMaxAgeSeconds, "max age of a spooled batch in seconds, at most the server batch dedup window")
+	fs.StringVar(&config.Collectors, "collectors", config.Collectors, "collector settings, e.g. ps=off,runtime=1s")
 
 	err := fs.Parse(os.Args[1:])
 	return err
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CollectorSettings - настройки коллектора агента
+type CollectorSettings struct {
+	Enabled  bool          // коллектор запускается
+	Interval time.Duration // интервал сбора, 0 означает интервал POLL_INTERVAL
+}
+
+// CollectorJSONConfig - настройки коллектора в JSON конфигурации
+type CollectorJSONConfig struct {
+	Enabled  *bool  `json:"enabled"`  // по умолчанию коллектор включен
+	Interval string `json:"interval"` // интервал сбора, например "5s"
+}
+
+// ParseCollectors разбирает настройки коллекторов в формате name=on|off|интервал через запятую,
+// например "runtime=1s,ps=off". Коллекторы, не упомянутые в строке, включены с интервалом POLL_INTERVAL.
+func ParseCollectors(spec string) (map[string]CollectorSettings, error) {
+	settings := map[string]CollectorSettings{}
+	if strings.TrimSpace(spec) == "" {
+		return settings, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid collector setting %q, expected name=on|off|interval", part)
+		}
+		switch value = strings.TrimSpace(value); value {
+		case "on":
+			settings[name] = CollectorSettings{Enabled: true}
+		case "off":
+			settings[name] = CollectorSettings{}
+		default:
+			interval, err := time.ParseDuration(value)
+			if err != nil || interval <= 0 {
+				return nil, fmt.Errorf("invalid interval %q for collector %s", value, name)
+			}
+			settings[name] = CollectorSettings{Enabled: true, Interval: interval}
+		}
+	}
+	return settings, nil
+}
+
+// CollectorSettings возвращает разобранные настройки коллекторов из поля Collectors
+func (c *AgentConfig) CollectorSettings() (map[string]CollectorSettings, error) {
+	return ParseCollectors(c.Collectors)
+}
+
+// formatCollectors преобразует настройки коллекторов из JSON в формат ParseCollectors
+func formatCollectors(collectors map[string]CollectorJSONConfig) string {
+	names := make([]string, 0, len(collectors))
+	for name := range collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		c := collectors[name]
+		value := "on"
+		switch {
+		case c.Enabled != nil && !*c.Enabled:
+			value = "off"
+		case c.Interval != "":
+			value = c.Interval
+		}
+		parts = append(parts, name+"="+value)
+	}
+	return strings.Join(parts, ",")
+}
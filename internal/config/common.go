@@ -7,6 +7,10 @@ import (
 	"time"
 )
 
+// BatchDedupTTL - окно, в течение которого сервер распознает повтор пакета агента.
+// Буфер агента не хранит пакеты дольше, иначе досланный пакет может примениться повторно.
+const BatchDedupTTL = time.Hour
+
 // ConfigFileReader предоставляет общие функции для работы с конфигурационными файлами
 type ConfigFileReader struct{}
 
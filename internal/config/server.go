@@ -3,35 +3,83 @@ package config
 import (
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 
 	"github.com/caarlos0/env/v6"
 )
 
 // ServerConfig содержит все настройки сервера метрик
 type ServerConfig struct {
-	ServerHost           string `env:"ADDRESS" json:"address"`               // адрес сервера
-	GRPCServerHost       string `env:"GRPC_ADDRESS" json:"grpc_address"`     // адрес gRPC сервера
-	LogLevel             string `env:"LOG_LEVEL" json:"log_level"`           // уровень логирования
-	StoreIntervalSeconds int    `env:"STORE_INTERVAL" json:"store_interval"` // интервал сохранения
-	FileStoragePath      string `env:"FILE_STORAGE_PATH" json:"store_file"`  // путь к файлу хранилища
-	IsRestoreFromFile    bool   `env:"RESTORE" json:"restore"`               // восстанавливать из файла
-	DatabaseDSN          string `env:"DATABASE_DSN" json:"database_dsn"`     // строка подключения к БД
-	SignKey              string `env:"KEY" json:"sign_key"`                  // ключ для подписи данных
-	CryptoKey            string `env:"CRYPTO_KEY" json:"crypto_key"`         // путь к приватному ключу
-	TrustedSubnet        string `env:"TRUSTED_SUBNET" json:"trusted_subnet"` // доверенная подсеть (CIDR)
+	ServerHost           string `env:"ADDRESS" json:"address"`                       // адрес сервера
+	GRPCServerHost       string `env:"GRPC_ADDRESS" json:"grpc_address"`             // адрес gRPC сервера
+	AdminAddress         string `env:"ADMIN_ADDRESS" json:"admin_address"`           // адрес административного сервера с pprof, пусто - отключен
+	AdminToken           string `env:"ADMIN_TOKEN" json:"admin_token"`               // токен доступа к административному серверу
+	AdminSubnet          string `env:"ADMIN_SUBNET" json:"admin_subnet"`             // подсети CIDR через запятую, с которых доступен административный сервер
+	LogLevel             string `env:"LOG_LEVEL" json:"log_level"`                   // уровень логирования
+	StoreIntervalSeconds int    `env:"STORE_INTERVAL" json:"store_interval"`         // интервал сохранения, 0 - синхронно
+	FileStoragePath      string `env:"FILE_STORAGE_PATH" json:"store_file"`          // путь к файлу хранилища
+	IsRestoreFromFile    bool   `env:"RESTORE" json:"restore"`                       // восстанавливать из файла
+	DatabaseDSN          string `env:"DATABASE_DSN" json:"database_dsn"`             // строка подключения к БД
+	SignKey              string `env:"KEY" json:"sign_key"`                          // ключ для подписи данных
+	SignKeysFile         string `env:"SIGN_KEYS_FILE" json:"sign_keys_file"`         // путь к JSON файлу ключей подписи с идентификаторами
+	SignMaxSkewSeconds   int    `env:"SIGN_MAX_SKEW" json:"sign_max_skew"`           // допустимое расхождение времени подписи запроса, 0 - без защиты от повторов
+	NonceCacheSize       int    `env:"NONCE_CACHE_SIZE" json:"nonce_cache_size"`     // сколько одноразовых значений запросов хранится для защиты от повторов
+	CryptoKey            string `env:"CRYPTO_KEY" json:"crypto_key"`                 // путь к приватному ключу
+	TLSCertFile          string `env:"TLS_CERT_FILE" json:"tls_cert_file"`           // путь к PEM сертификату сервера, включает TLS
+	TLSKeyFile           string `env:"TLS_KEY_FILE" json:"tls_key_file"`             // путь к PEM ключу сертификата сервера
+	TLSClientCAFile      string `env:"TLS_CLIENT_CA_FILE" json:"tls_client_ca_file"` // путь к PEM CA клиентских сертификатов, включает взаимный TLS
+	TrustedSubnet        string `env:"TRUSTED_SUBNET" json:"trusted_subnet"`         // разрешенные подсети CIDR через запятую, пусто - все адреса
+	DeniedSubnets        string `env:"DENIED_SUBNETS" json:"denied_subnets"`         // запрещенные подсети CIDR через запятую, имеют приоритет над разрешенными
+	TrustedProxies       string `env:"TRUSTED_PROXIES" json:"trusted_proxies"`       // подсети прокси, от которых принимаются X-Forwarded-For и X-Real-IP
+	AuthTokensFile       string `env:"AUTH_TOKENS_FILE" json:"auth_tokens_file"`     // путь к JSON файлу токенов агентов, включает аутентификацию
+	AuthTokensDB         bool   `env:"AUTH_TOKENS_DB" json:"auth_tokens_db"`         // читать токены агентов из таблицы agent_tokens базы DATABASE_DSN
+	AuditLogFile         string `env:"AUDIT_LOG_FILE" json:"audit_log_file"`         // файл аудита изменений метрик в формате JSON lines
+	AuditWebhookURL      string `env:"AUDIT_WEBHOOK_URL" json:"audit_webhook_url"`   // URL для отправки событий аудита
+	AuditQueueSize       int    `env:"AUDIT_QUEUE_SIZE" json:"audit_queue_size"`     // размер очереди событий аудита каждого приемника
+	AlertRulesFile       string `env:"ALERT_RULES_FILE" json:"alert_rules_file"`     // путь к файлу правил алертинга
+	AlertIntervalSeconds int    `env:"ALERT_INTERVAL" json:"alert_interval"`         // интервал вычисления правил
+	AlertWebhookURL      string `env:"ALERT_WEBHOOK_URL" json:"alert_webhook_url"`   // URL для отправки алертов
+	AlertLogFile         string `env:"ALERT_LOG_FILE" json:"alert_log_file"`         // файл для записи алертов
+	StatsDAddress        string `env:"STATSD_ADDRESS" json:"statsd_address"`         // UDP адрес приема метрик StatsD
+	StatsDFlushSeconds   int    `env:"STATSD_FLUSH_INTERVAL" json:"statsd_flush"`    // интервал сброса агрегированных метрик StatsD
+
+	Command []string `json:"-"` // аргументы после флагов, например migrate up; пусто - запуск сервера
 }
 
 // ServerJSONConfig представляет структуру JSON конфигурации для сервера
 type ServerJSONConfig struct {
-	Address       string `json:"address"`        // аналог переменной окружения ADDRESS или флага -a
-	GRPCAddress   string `json:"grpc_address"`   // адрес gRPC сервера
-	Restore       bool   `json:"restore"`        // аналог переменной окружения RESTORE или флага -r
-	StoreInterval string `json:"store_interval"` // аналог переменной окружения STORE_INTERVAL или флага -i
-	StoreFile     string `json:"store_file"`     // аналог переменной окружения STORE_FILE или -f
-	DatabaseDSN   string `json:"database_dsn"`   // аналог переменной окружения DATABASE_DSN или флага -d
-	CryptoKey     string `json:"crypto_key"`     // аналог переменной окружения CRYPTO_KEY или флага -crypto-key
-	TrustedSubnet string `json:"trusted_subnet"` // аналог TRUSTED_SUBNET или флага -t
+	Address       string `json:"address"`            // аналог переменной окружения ADDRESS или флага -a
+	GRPCAddress   string `json:"grpc_address"`       // адрес gRPC сервера
+	AdminAddress  string `json:"admin_address"`      // аналог ADMIN_ADDRESS или флага -admin-address
+	AdminToken    string `json:"admin_token"`        // аналог ADMIN_TOKEN или флага -admin-token
+	AdminSubnet   string `json:"admin_subnet"`       // аналог ADMIN_SUBNET или флага -admin-subnet
+	Restore       bool   `json:"restore"`            // аналог переменной окружения RESTORE или флага -r
+	StoreInterval string `json:"store_interval"`     // аналог переменной окружения STORE_INTERVAL или флага -i
+	StoreFile     string `json:"store_file"`         // аналог переменной окружения STORE_FILE или -f
+	DatabaseDSN   string `json:"database_dsn"`       // аналог переменной окружения DATABASE_DSN или флага -d
+	CryptoKey     string `json:"crypto_key"`         // аналог переменной окружения CRYPTO_KEY или флага -crypto-key
+	TLSCertFile   string `json:"tls_cert_file"`      // аналог TLS_CERT_FILE или флага -tls-cert
+	TLSKeyFile    string `json:"tls_key_file"`       // аналог TLS_KEY_FILE или флага -tls-key
+	TLSClientCA   string `json:"tls_client_ca_file"` // аналог TLS_CLIENT_CA_FILE или флага -tls-client-ca
+	SignKeysFile  string `json:"sign_keys_file"`     // аналог переменной окружения SIGN_KEYS_FILE или флага -sign-keys
+	SignMaxSkew   string `json:"sign_max_skew"`      // аналог SIGN_MAX_SKEW или флага -sign-max-skew
+	NonceCache    int    `json:"nonce_cache_size"`   // аналог NONCE_CACHE_SIZE или флага -nonce-cache
+	TrustedSubnet string `json:"trusted_subnet"`     // аналог TRUSTED_SUBNET или флага -t
+	DeniedSubnets string `json:"denied_subnets"`     // аналог DENIED_SUBNETS или флага -deny
+	TrustedProxy  string `json:"trusted_proxies"`    // аналог TRUSTED_PROXIES или флага -trusted-proxies
+	AuthTokens    string `json:"auth_tokens_file"`   // аналог AUTH_TOKENS_FILE или флага -auth-tokens
+	AuthTokensDB  bool   `json:"auth_tokens_db"`     // аналог AUTH_TOKENS_DB или флага -auth-tokens-db
+	AuditLogFile  string `json:"audit_log_file"`     // аналог AUDIT_LOG_FILE или флага -audit-log
+	AuditWebhook  string `json:"audit_webhook_url"`  // аналог AUDIT_WEBHOOK_URL или флага -audit-webhook
+	AuditQueue    int    `json:"audit_queue_size"`   // аналог AUDIT_QUEUE_SIZE или флага -audit-queue
+	AlertRules    string `json:"alert_rules_file"`   // аналог ALERT_RULES_FILE или флага -alert-rules
+	AlertInterval string `json:"alert_interval"`     // аналог ALERT_INTERVAL или флага -alert-interval
+	AlertWebhook  string `json:"alert_webhook_url"`  // аналог ALERT_WEBHOOK_URL или флага -alert-webhook
+	AlertLogFile  string `json:"alert_log_file"`     // аналог ALERT_LOG_FILE или флага -alert-log
+	StatsDAddress string `json:"statsd_address"`     // аналог STATSD_ADDRESS или флага -statsd
+	StatsDFlush   string `json:"statsd_flush"`       // аналог STATSD_FLUSH_INTERVAL или флага -statsd-flush
 }
 
 func fillServerDefaults(c *ServerConfig) {
@@ -41,15 +89,35 @@ func fillServerDefaults(c *ServerConfig) {
 	if c.LogLevel == "" {
 		c.LogLevel = "warn"
 	}
-	if c.StoreIntervalSeconds == 0 {
+	if c.StoreIntervalSeconds < 0 {
 		c.StoreIntervalSeconds = 5
 	}
+	if c.AlertIntervalSeconds < 1 {
+		c.AlertIntervalSeconds = 15
+	}
+	if c.StatsDFlushSeconds < 1 {
+		c.StatsDFlushSeconds = 10
+	}
+	if c.SignMaxSkewSeconds < 0 {
+		c.SignMaxSkewSeconds = 300
+	}
+	if c.NonceCacheSize < 1 {
+		c.NonceCacheSize = 100000
+	}
+	if c.AuditQueueSize < 1 {
+		c.AuditQueueSize = 10000
+	}
+	if c.AdminSubnet == "" {
+		c.AdminSubnet = "127.0.0.0/8,::1/128"
+	}
 }
 
 // NewServerConfig создает новую конфигурацию сервера.
 // Приоритет: переменные окружения > флаги командной строки > JSON файл > значения по умолчанию
 func NewServerConfig() (*ServerConfig, error) {
-	config := &ServerConfig{}
+	// отрицательный интервал означает "не задан": явный 0 включает синхронное сохранение
+	// или отключает защиту от повторов
+	config := &ServerConfig{StoreIntervalSeconds: -1, SignMaxSkewSeconds: -1}
 	reader := &ConfigFileReader{}
 
 	// 1. Читаем JSON конфигурацию (если указана)
@@ -84,11 +152,30 @@ func loadServerFromJSON(config *ServerConfig, reader *ConfigFileReader, filePath
 	// Применяем значения из JSON
 	config.ServerHost = jsonConfig.Address
 	config.GRPCServerHost = jsonConfig.GRPCAddress
+	config.AdminAddress = jsonConfig.AdminAddress
+	config.AdminToken = jsonConfig.AdminToken
+	config.AdminSubnet = jsonConfig.AdminSubnet
 	config.IsRestoreFromFile = jsonConfig.Restore
 	config.FileStoragePath = jsonConfig.StoreFile
 	config.DatabaseDSN = jsonConfig.DatabaseDSN
 	config.CryptoKey = jsonConfig.CryptoKey
+	config.TLSCertFile = jsonConfig.TLSCertFile
+	config.TLSKeyFile = jsonConfig.TLSKeyFile
+	config.TLSClientCAFile = jsonConfig.TLSClientCA
+	config.SignKeysFile = jsonConfig.SignKeysFile
+	config.NonceCacheSize = jsonConfig.NonceCache
 	config.TrustedSubnet = jsonConfig.TrustedSubnet
+	config.DeniedSubnets = jsonConfig.DeniedSubnets
+	config.TrustedProxies = jsonConfig.TrustedProxy
+	config.AuthTokensFile = jsonConfig.AuthTokens
+	config.AuthTokensDB = jsonConfig.AuthTokensDB
+	config.AuditLogFile = jsonConfig.AuditLogFile
+	config.AuditWebhookURL = jsonConfig.AuditWebhook
+	config.AuditQueueSize = jsonConfig.AuditQueue
+	config.AlertRulesFile = jsonConfig.AlertRules
+	config.AlertWebhookURL = jsonConfig.AlertWebhook
+	config.AlertLogFile = jsonConfig.AlertLogFile
+	config.StatsDAddress = jsonConfig.StatsDAddress
 
 	// Парсим store_interval из строки в секунды
 	if jsonConfig.StoreInterval != "" {
@@ -99,6 +186,30 @@ func loadServerFromJSON(config *ServerConfig, reader *ConfigFileReader, filePath
 		config.StoreIntervalSeconds = seconds
 	}
 
+	if jsonConfig.AlertInterval != "" {
+		seconds, err := reader.ParseDurationFromString(jsonConfig.AlertInterval)
+		if err != nil {
+			return err
+		}
+		config.AlertIntervalSeconds = seconds
+	}
+
+	if jsonConfig.SignMaxSkew != "" {
+		seconds, err := reader.ParseDurationFromString(jsonConfig.SignMaxSkew)
+		if err != nil {
+			return err
+		}
+		config.SignMaxSkewSeconds = seconds
+	}
+
+	if jsonConfig.StatsDFlush != "" {
+		seconds, err := reader.ParseDurationFromString(jsonConfig.StatsDFlush)
+		if err != nil {
+			return err
+		}
+		config.StatsDFlushSeconds = seconds
+	}
+
 	return nil
 }
 
@@ -108,15 +219,85 @@ func parseServerFlags(config *ServerConfig) error {
 
 	fs.StringVar(&config.ServerHost, "a", config.ServerHost, "server address")
 	fs.StringVar(&config.GRPCServerHost, "ga", config.GRPCServerHost, "grpc server address")
+	fs.StringVar(&config.AdminAddress, "admin-address", config.AdminAddress, "admin server address with pprof and operational endpoints, empty disables it")
+	fs.StringVar(&config.AdminToken, "admin-token", config.AdminToken, "bearer token required by the admin server")
+	fs.StringVar(&config.AdminSubnet, "admin-subnet", config.AdminSubnet, "subnets in CIDR separated by comma allowed to reach the admin server, default loopback only")
 	fs.StringVar(&config.LogLevel, "l", config.LogLevel, "log level")
-	fs.IntVar(&config.StoreIntervalSeconds, "i", config.StoreIntervalSeconds, "store data interval in seconds")
+	fs.IntVar(&config.StoreIntervalSeconds, "i", config.StoreIntervalSeconds, "store data interval in seconds, 0 saves synchronously")
 	fs.StringVar(&config.FileStoragePath, "f", config.FileStoragePath, "file storage name")
 	fs.BoolVar(&config.IsRestoreFromFile, "r", config.IsRestoreFromFile, "is need to restore data from existed file")
 	fs.StringVar(&config.DatabaseDSN, "d", config.DatabaseDSN, "database connection string")
 	fs.StringVar(&config.SignKey, "k", config.SignKey, "key will be used for signing and verifying data")
+	fs.StringVar(&config.SignKeysFile, "sign-keys", config.SignKeysFile, "path to JSON file with signing keys and their ids")
+	fs.IntVar(&config.SignMaxSkewSeconds, "sign-max-skew", config.SignMaxSkewSeconds, "allowed clock skew of signed requests in seconds, 0 disables replay protection")
+	fs.IntVar(&config.NonceCacheSize, "nonce-cache", config.NonceCacheSize, "max number of remembered request nonces for replay protection")
 	fs.StringVar(&config.CryptoKey, "crypto-key", config.CryptoKey, "path to private PEM key for decryption")
-	fs.StringVar(&config.TrustedSubnet, "t", config.TrustedSubnet, "trusted subnet in CIDR (e.g. 192.168.1.0/24)")
+	fs.StringVar(&config.TLSCertFile, "tls-cert", config.TLSCertFile, "path to PEM certificate, enables TLS for HTTP and gRPC servers")
+	fs.StringVar(&config.TLSKeyFile, "tls-key", config.TLSKeyFile, "path to PEM private key of the certificate")
+	fs.StringVar(&config.TLSClientCAFile, "tls-client-ca", config.TLSClientCAFile, "path to PEM CA bundle for client certificates, enables mutual TLS")
+	fs.StringVar(&config.TrustedSubnet, "t", config.TrustedSubnet, "allowed subnets in CIDR separated by comma (e.g. 192.168.1.0/24,10.0.0.0/8)")
+	fs.StringVar(&config.DeniedSubnets, "deny", config.DeniedSubnets, "denied subnets in CIDR separated by comma, take precedence over allowed")
+	fs.StringVar(&config.TrustedProxies, "trusted-proxies", config.TrustedProxies, "proxy subnets in CIDR whose X-Forwarded-For and X-Real-IP headers are trusted")
+	fs.StringVar(&config.AuthTokensFile, "auth-tokens", config.AuthTokensFile, "path to JSON file with agent token hashes, enables bearer token authentication")
+	fs.BoolVar(&config.AuthTokensDB, "auth-tokens-db", config.AuthTokensDB, "read agent tokens from agent_tokens table of the database, enables bearer token authentication")
+	fs.StringVar(&config.AuditLogFile, "audit-log", config.AuditLogFile, "file for audit events of metric updates in JSON lines")
+	fs.StringVar(&config.AuditWebhookURL, "audit-webhook", config.AuditWebhookURL, "webhook URL for audit events of metric updates")
+	fs.IntVar(&config.AuditQueueSize, "audit-queue", config.AuditQueueSize, "max number of queued audit events per sink, extra events are dropped")
+	fs.StringVar(&config.AlertRulesFile, "alert-rules", config.AlertRulesFile, "path to JSON file with alert rules")
+	fs.IntVar(&config.AlertIntervalSeconds, "alert-interval", config.AlertIntervalSeconds, "alert rules evaluation interval in seconds")
+	fs.StringVar(&config.AlertWebhookURL, "alert-webhook", config.AlertWebhookURL, "webhook URL for alert notifications")
+	fs.StringVar(&config.AlertLogFile, "alert-log", config.AlertLogFile, "file for alert notifications in JSON lines")
+	fs.StringVar(&config.StatsDAddress, "statsd", config.StatsDAddress, "UDP address for StatsD listener (e.g. :8125)")
+	fs.IntVar(&config.StatsDFlushSeconds, "statsd-flush", config.StatsDFlushSeconds, "StatsD aggregation flush interval in seconds")
 
 	err := fs.Parse(os.Args[1:])
+	config.Command = fs.Args()
 	return err
 }
+
+// redactedValue заменяет секреты в выводе конфигурации
+const redactedValue = "[REDACTED]"
+
+// dsnPasswordRe находит пароль в строке подключения вида "host=... password=..."
+var dsnPasswordRe = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// Redacted возвращает копию конфигурации без секретов для вывода в лог и административный API:
+// скрываются ключ подписи, токен администратора, пароль подключения к БД, путь и параметры URL webhook.
+// Пути к файлам ключей и токенов не скрываются.
+func (c ServerConfig) Redacted() ServerConfig {
+	r := c
+	if r.SignKey != "" {
+		r.SignKey = redactedValue
+	}
+	if r.AdminToken != "" {
+		r.AdminToken = redactedValue
+	}
+	r.DatabaseDSN = redactDSN(r.DatabaseDSN)
+	r.AuditWebhookURL = redactURL(r.AuditWebhookURL)
+	r.AlertWebhookURL = redactURL(r.AlertWebhookURL)
+	r.Command = append([]string(nil), c.Command...)
+	return r
+}
+
+// redactDSN скрывает пароль в строке подключения в формате URL или ключ=значение
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	return dsnPasswordRe.ReplaceAllString(dsn, "${1}"+redactedValue)
+}
+
+// redactURL оставляет от URL только схему и хост: путь и параметры webhook часто содержат токены
+func redactURL(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return redactedValue
+	}
+	if u.User == nil && (u.Path == "" || u.Path == "/") && u.RawQuery == "" {
+		return rawURL
+	}
+	return u.Scheme + "://" + u.Host + "/" + redactedValue
+}
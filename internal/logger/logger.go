@@ -18,9 +18,16 @@ type ctxKey string
 // New создает новый логгер с указанным уровнем логирования.
 // Использует production конфигурацию с настраиваемым уровнем.
 func New(level string) (*zap.Logger, error) {
+	zl, _, err := NewWithLevel(level)
+	return zl, err
+}
+
+// NewWithLevel создает логгер как New и возвращает его уровень,
+// через который уровень логирования можно менять во время работы.
+func NewWithLevel(level string) (*zap.Logger, zap.AtomicLevel, error) {
 	lvl, err := zap.ParseAtomicLevel(level)
 	if err != nil {
-		return nil, err
+		return nil, lvl, err
 	}
 	// создаём новую конфигурацию логера
 	cfg := zap.NewProductionConfig()
@@ -29,9 +36,9 @@ func New(level string) (*zap.Logger, error) {
 	// создаём логер на основе конфигурации
 	zl, err := cfg.Build()
 	if err != nil {
-		return nil, err
+		return nil, lvl, err
 	}
-	return zl, nil
+	return zl, lvl, nil
 }
 
 // CtxWithLogger добавляет логгер в контекст для передачи между слоями приложения.
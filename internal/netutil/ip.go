@@ -2,42 +2,118 @@ package netutil
 
 import (
     "context"
+    "fmt"
     "net"
     "net/http"
+    "strings"
 
     "google.golang.org/grpc/metadata"
     "google.golang.org/grpc/peer"
 )
 
-// ExtractIPFromHTTPRequest извлекает IP из заголовка X-Real-IP
-func ExtractIPFromHTTPRequest(r *http.Request) net.IP {
-    realIP := r.Header.Get("X-Real-IP")
-    if realIP == "" {
-        return nil
+// ExtractIPFromHTTPRequest возвращает IP клиента по адресу соединения.
+// Заголовки X-Forwarded-For и X-Real-IP учитываются, только если соединение установлено
+// доверенным прокси из trustedProxies, иначе любой клиент мог бы подменить свой адрес.
+func ExtractIPFromHTTPRequest(r *http.Request, trustedProxies []*net.IPNet) net.IP {
+    remote := hostIP(r.RemoteAddr)
+    if remote == nil || !ContainsIP(trustedProxies, remote) {
+        return remote
+    }
+    if ip := forwardedIP(r.Header.Values("X-Forwarded-For"), trustedProxies); ip != nil {
+        return ip
     }
-    return net.ParseIP(realIP)
+    if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+        return ip
+    }
+    return remote
 }
 
-// ExtractIPFromGRPCContext извлекает IP из metadata x-real-ip или из peer.Addr
-func ExtractIPFromGRPCContext(ctx context.Context) net.IP {
-    if md, ok := metadata.FromIncomingContext(ctx); ok {
-        vals := md.Get("x-real-ip")
-        if len(vals) > 0 {
-            if ip := net.ParseIP(vals[0]); ip != nil {
-                return ip
-            }
+// ExtractIPFromGRPCContext возвращает IP клиента по адресу peer.Addr.
+// Ключи metadata x-forwarded-for и x-real-ip учитываются, только если соединение установлено доверенным прокси.
+func ExtractIPFromGRPCContext(ctx context.Context, trustedProxies []*net.IPNet) net.IP {
+    var remote net.IP
+    if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+        remote = hostIP(p.Addr.String())
+    }
+    if remote == nil || !ContainsIP(trustedProxies, remote) {
+        return remote
+    }
+    md, _ := metadata.FromIncomingContext(ctx)
+    if ip := forwardedIP(md.Get("x-forwarded-for"), trustedProxies); ip != nil {
+        return ip
+    }
+    if vals := md.Get("x-real-ip"); len(vals) > 0 {
+        if ip := net.ParseIP(strings.TrimSpace(vals[0])); ip != nil {
+            return ip
         }
     }
-    if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
-        host, _, _ := net.SplitHostPort(p.Addr.String())
-        if host == "" {
-            host = p.Addr.String()
+    return remote
+}
+
+// forwardedIP возвращает адрес клиента из цепочки X-Forwarded-For: первый справа адрес,
+// не принадлежащий доверенным прокси. Левее него адреса мог записать сам клиент.
+// Возвращает nil, если цепочка пустая или содержит некорректный адрес.
+func forwardedIP(values []string, trustedProxies []*net.IPNet) net.IP {
+    var chain []string
+    for _, v := range values {
+        chain = append(chain, strings.Split(v, ",")...)
+    }
+    var ip net.IP
+    for i := len(chain) - 1; i >= 0; i-- {
+        ip = net.ParseIP(strings.TrimSpace(chain[i]))
+        if ip == nil {
+            return nil
         }
-        if ip := net.ParseIP(host); ip != nil {
+        if !ContainsIP(trustedProxies, ip) {
             return ip
         }
     }
-    return nil
+    // все адреса цепочки - доверенные прокси
+    return ip
+}
+
+// hostIP извлекает IP из адреса вида host:port или host
+func hostIP(addr string) net.IP {
+    host, _, err := net.SplitHostPort(addr)
+    if err != nil {
+        host = addr
+    }
+    return net.ParseIP(host)
+}
+
+// ContainsIP проверяет, входит ли ip хотя бы в одну из подсетей
+func ContainsIP(nets []*net.IPNet, ip net.IP) bool {
+    for _, n := range nets {
+        if n.Contains(ip) {
+            return true
+        }
+    }
+    return false
+}
+
+// ParseCIDRs парсит список подсетей CIDR через запятую. Одиночный адрес считается подсетью из одного адреса.
+func ParseCIDRs(list string) ([]*net.IPNet, error) {
+    var nets []*net.IPNet
+    for _, item := range strings.Split(list, ",") {
+        item = strings.TrimSpace(item)
+        if item == "" {
+            continue
+        }
+        if ip := net.ParseIP(item); ip != nil {
+            bits := 8 * net.IPv6len
+            if ip4 := ip.To4(); ip4 != nil {
+                ip, bits = ip4, 8*net.IPv4len
+            }
+            nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+            continue
+        }
+        _, n, err := net.ParseCIDR(item)
+        if err != nil {
+            return nil, fmt.Errorf("invalid CIDR %q: %w", item, err)
+        }
+        nets = append(nets, n)
+    }
+    return nets, nil
 }
 
 // ParseCIDR безопасно парсит CIDR и возвращает *net.IPNet (или nil)
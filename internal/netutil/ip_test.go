@@ -0,0 +1,115 @@
+package netutil
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func mustCIDRs(t *testing.T, list string) []*net.IPNet {
+	t.Helper()
+	nets, err := ParseCIDRs(list)
+	require.NoError(t, err)
+	return nets
+}
+
+func TestExtractIPFromHTTPRequest(t *testing.T) {
+	proxies := mustCIDRs(t, "10.0.0.0/8,fd00::/8")
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{name: "remote address", remoteAddr: "192.168.1.5:4000", want: "192.168.1.5"},
+		{
+			name:       "spoofed headers from untrusted client",
+			remoteAddr: "192.168.1.5:4000",
+			headers:    map[string]string{"X-Real-IP": "10.1.1.1", "X-Forwarded-For": "10.1.1.1"},
+			want:       "192.168.1.5",
+		},
+		{
+			name:       "x-real-ip from trusted proxy",
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Real-IP": "192.168.1.5"},
+			want:       "192.168.1.5",
+		},
+		{
+			name:       "forwarded chain through trusted proxies",
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Forwarded-For": "1.1.1.1, 192.168.1.5, 10.0.0.3"},
+			want:       "192.168.1.5",
+		},
+		{
+			name:       "forwarded header takes precedence",
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Forwarded-For": "192.168.1.5", "X-Real-IP": "192.168.1.6"},
+			want:       "192.168.1.5",
+		},
+		{
+			name:       "malformed chain falls back to x-real-ip",
+			remoteAddr: "10.0.0.2:4000",
+			headers:    map[string]string{"X-Forwarded-For": "192.168.1.5, garbage", "X-Real-IP": "192.168.1.6"},
+			want:       "192.168.1.6",
+		},
+		{name: "trusted proxy without headers", remoteAddr: "10.0.0.2:4000", want: "10.0.0.2"},
+		{
+			name:       "ipv6 proxy",
+			remoteAddr: "[fd00::1]:4000",
+			headers:    map[string]string{"X-Forwarded-For": "2001:db8::5"},
+			want:       "2001:db8::5",
+		},
+		{name: "invalid remote address", remoteAddr: "pipe", want: "<nil>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, ExtractIPFromHTTPRequest(r, proxies).String())
+		})
+	}
+}
+
+func TestExtractIPFromGRPCContext(t *testing.T) {
+	proxies := mustCIDRs(t, "10.0.0.0/8")
+	ctxFrom := func(remote string, md metadata.MD) context.Context {
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+		addr, err := net.ResolveTCPAddr("tcp", remote)
+		require.NoError(t, err)
+		return peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+
+	assert.Equal(t, "192.168.1.5",
+		ExtractIPFromGRPCContext(ctxFrom("192.168.1.5:4000", metadata.Pairs("x-real-ip", "10.1.1.1")), proxies).String())
+	assert.Equal(t, "192.168.1.5",
+		ExtractIPFromGRPCContext(ctxFrom("10.0.0.2:4000", metadata.Pairs("x-forwarded-for", "192.168.1.5")), proxies).String())
+	assert.Equal(t, "192.168.1.6",
+		ExtractIPFromGRPCContext(ctxFrom("10.0.0.2:4000", metadata.Pairs("x-real-ip", "192.168.1.6")), proxies).String())
+	assert.Nil(t, ExtractIPFromGRPCContext(context.Background(), proxies))
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs(" 192.168.0.0/16, 10.0.0.1 ,2001:db8::/32,,::1")
+	require.NoError(t, err)
+	got := make([]string, 0, len(nets))
+	for _, n := range nets {
+		got = append(got, n.String())
+	}
+	assert.Equal(t, []string{"192.168.0.0/16", "10.0.0.1/32", "2001:db8::/32", "::1/128"}, got)
+
+	nets, err = ParseCIDRs("")
+	require.NoError(t, err)
+	assert.Empty(t, nets)
+
+	_, err = ParseCIDRs("192.168.0.0/16,not-a-cidr")
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+)
+
+// IPPolicy ограничивает доступ по IP клиента списками разрешенных и запрещенных подсетей.
+// Запрет имеет приоритет; если список разрешенных пуст, разрешены все незапрещенные адреса.
+// TrustedProxies - подсети прокси, которым разрешено передавать адрес клиента в заголовках.
+type IPPolicy struct {
+	Allow          []*net.IPNet
+	Deny           []*net.IPNet
+	TrustedProxies []*net.IPNet
+}
+
+// NewIPPolicy создает политику из списков подсетей CIDR через запятую
+func NewIPPolicy(allow, deny, trustedProxies string) (*IPPolicy, error) {
+	var p IPPolicy
+	var err error
+	if p.Allow, err = ParseCIDRs(allow); err != nil {
+		return nil, fmt.Errorf("allowed subnets: %w", err)
+	}
+	if p.Deny, err = ParseCIDRs(deny); err != nil {
+		return nil, fmt.Errorf("denied subnets: %w", err)
+	}
+	if p.TrustedProxies, err = ParseCIDRs(trustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted proxies: %w", err)
+	}
+	return &p, nil
+}
+
+// Enabled проверяет, что заданы ограничения доступа
+func (p *IPPolicy) Enabled() bool {
+	return p != nil && (len(p.Allow) > 0 || len(p.Deny) > 0)
+}
+
+// Allowed проверяет, разрешен ли доступ с адреса ip. Неизвестный адрес запрещен, если заданы ограничения.
+func (p *IPPolicy) Allowed(ip net.IP) bool {
+	if !p.Enabled() {
+		return true
+	}
+	if ip == nil || ContainsIP(p.Deny, ip) {
+		return false
+	}
+	return len(p.Allow) == 0 || ContainsIP(p.Allow, ip)
+}
+
+// Proxies возвращает подсети доверенных прокси
+func (p *IPPolicy) Proxies() []*net.IPNet {
+	if p == nil {
+		return nil
+	}
+	return p.TrustedProxies
+}
@@ -0,0 +1,47 @@
+package netutil
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPPolicyAllowed(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow string
+		deny  string
+		ip    string
+		want  bool
+	}{
+		{name: "no restrictions", ip: "1.2.3.4", want: true},
+		{name: "in allowed subnet", allow: "10.0.0.0/8,192.168.0.0/16", ip: "192.168.3.4", want: true},
+		{name: "outside allowed subnets", allow: "10.0.0.0/8,192.168.0.0/16", ip: "172.16.0.1", want: false},
+		{name: "denied inside allowed", allow: "10.0.0.0/8", deny: "10.1.0.0/16", ip: "10.1.2.3", want: false},
+		{name: "only deny list", deny: "10.1.0.0/16", ip: "172.16.0.1", want: true},
+		{name: "ipv6 allowed", allow: "2001:db8::/32", ip: "2001:db8::1", want: true},
+		{name: "ipv4 mapped ipv6", allow: "10.0.0.0/8", ip: "::ffff:10.0.0.1", want: true},
+		{name: "unknown address", allow: "10.0.0.0/8", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewIPPolicy(tt.allow, tt.deny, "")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p.Allowed(net.ParseIP(tt.ip)))
+		})
+	}
+}
+
+func TestNewIPPolicyErrors(t *testing.T) {
+	for _, args := range [][3]string{{"bad", "", ""}, {"", "bad", ""}, {"", "", "bad"}} {
+		_, err := NewIPPolicy(args[0], args[1], args[2])
+		assert.Error(t, err, "%v", args)
+	}
+
+	var p *IPPolicy
+	assert.False(t, p.Enabled())
+	assert.True(t, p.Allowed(nil))
+	assert.Nil(t, p.Proxies())
+}
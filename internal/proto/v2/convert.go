@@ -0,0 +1,102 @@
+package metricspbv2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+// ErrInvalidMetric возвращается для сообщения Metric, тип которого не соответствует значению
+var ErrInvalidMetric = errors.New("invalid metric message")
+
+// TypeFromModel возвращает тип сообщения для типа метрики модели
+func TypeFromModel(mtype string) MetricType {
+	switch mtype {
+	case models.Gauge:
+		return MetricType_METRIC_TYPE_GAUGE
+	case models.Counter:
+		return MetricType_METRIC_TYPE_COUNTER
+	case models.Histogram:
+		return MetricType_METRIC_TYPE_HISTOGRAM
+	default:
+		return MetricType_METRIC_TYPE_UNSPECIFIED
+	}
+}
+
+// TypeToModel возвращает тип метрики модели, пустую строку для неизвестного типа
+func TypeToModel(t MetricType) string {
+	switch t {
+	case MetricType_METRIC_TYPE_GAUGE:
+		return models.Gauge
+	case MetricType_METRIC_TYPE_COUNTER:
+		return models.Counter
+	case MetricType_METRIC_TYPE_HISTOGRAM:
+		return models.Histogram
+	default:
+		return ""
+	}
+}
+
+// FromModel преобразует метрику модели в сообщение. Временная метка не заполняется.
+func FromModel(m *models.Metrics) *Metric {
+	pm := &Metric{
+		Id:     m.ID,
+		Type:   TypeFromModel(m.MType),
+		Labels: m.Labels,
+	}
+	switch {
+	case m.Histogram != nil:
+		pm.Data = &Metric_Histogram{Histogram: &Histogram{
+			Bounds: m.Histogram.Bounds,
+			Counts: m.Histogram.Counts,
+			Sum:    m.Histogram.Sum,
+			Count:  m.Histogram.Count,
+		}}
+	case m.Delta != nil:
+		pm.Data = &Metric_Delta{Delta: *m.Delta}
+	case m.Value != nil:
+		pm.Data = &Metric_Value{Value: *m.Value}
+	}
+	return pm
+}
+
+// ToModel преобразует сообщение в метрику модели.
+// Возвращает ErrInvalidMetric, если тип не задан или значение не соответствует типу.
+func ToModel(pm *Metric) (*models.Metrics, error) {
+	if pm == nil {
+		return nil, fmt.Errorf("%w: empty metric", ErrInvalidMetric)
+	}
+	m := &models.Metrics{
+		ID:     pm.GetId(),
+		MType:  TypeToModel(pm.GetType()),
+		Labels: pm.GetLabels(),
+	}
+	switch data := pm.GetData().(type) {
+	case *Metric_Delta:
+		if m.MType != models.Counter {
+			return nil, fmt.Errorf("%w: delta for %s metric", ErrInvalidMetric, m.MType)
+		}
+		delta := data.Delta
+		m.Delta = &delta
+	case *Metric_Value:
+		if m.MType != models.Gauge {
+			return nil, fmt.Errorf("%w: value for %s metric", ErrInvalidMetric, m.MType)
+		}
+		value := data.Value
+		m.Value = &value
+	case *Metric_Histogram:
+		if m.MType != models.Histogram || data.Histogram == nil {
+			return nil, fmt.Errorf("%w: histogram for %s metric", ErrInvalidMetric, m.MType)
+		}
+		m.Histogram = &models.HistogramValue{
+			Bounds: data.Histogram.GetBounds(),
+			Counts: data.Histogram.GetCounts(),
+			Sum:    data.Histogram.GetSum(),
+			Count:  data.Histogram.GetCount(),
+		}
+	default:
+		return nil, fmt.Errorf("%w: no value", ErrInvalidMetric)
+	}
+	return m, nil
+}
@@ -0,0 +1,1130 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v6.33.0
+// source: internal/proto/v2/metrics.proto
+
+package metricspbv2
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// MetricType - тип метрики
+type MetricType int32
+
+const (
+	MetricType_METRIC_TYPE_UNSPECIFIED MetricType = 0
+	MetricType_METRIC_TYPE_GAUGE       MetricType = 1
+	MetricType_METRIC_TYPE_COUNTER     MetricType = 2
+	MetricType_METRIC_TYPE_HISTOGRAM   MetricType = 3
+)
+
+// Enum value maps for MetricType.
+var (
+	MetricType_name = map[int32]string{
+		0: "METRIC_TYPE_UNSPECIFIED",
+		1: "METRIC_TYPE_GAUGE",
+		2: "METRIC_TYPE_COUNTER",
+		3: "METRIC_TYPE_HISTOGRAM",
+	}
+	MetricType_value = map[string]int32{
+		"METRIC_TYPE_UNSPECIFIED": 0,
+		"METRIC_TYPE_GAUGE":       1,
+		"METRIC_TYPE_COUNTER":     2,
+		"METRIC_TYPE_HISTOGRAM":   3,
+	}
+)
+
+func (x MetricType) Enum() *MetricType {
+	p := new(MetricType)
+	*p = x
+	return p
+}
+
+func (x MetricType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MetricType) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_proto_v2_metrics_proto_enumTypes[0].Descriptor()
+}
+
+func (MetricType) Type() protoreflect.EnumType {
+	return &file_internal_proto_v2_metrics_proto_enumTypes[0]
+}
+
+func (x MetricType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MetricType.Descriptor instead.
+func (MetricType) EnumDescriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{0}
+}
+
+// Histogram - значение метрики типа histogram
+type Histogram struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// верхние границы корзин
+	Bounds []float64 `protobuf:"fixed64,1,rep,packed,name=bounds,proto3" json:"bounds,omitempty"`
+	// количество наблюдений в корзинах, на одну больше числа границ
+	Counts []int64 `protobuf:"varint,2,rep,packed,name=counts,proto3" json:"counts,omitempty"`
+	// сумма наблюдений
+	Sum float64 `protobuf:"fixed64,3,opt,name=sum,proto3" json:"sum,omitempty"`
+	// общее количество наблюдений
+	Count         int64 `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Histogram) Reset() {
+	*x = Histogram{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Histogram) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Histogram) ProtoMessage() {}
+
+func (x *Histogram) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Histogram.ProtoReflect.Descriptor instead.
+func (*Histogram) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Histogram) GetBounds() []float64 {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Histogram) GetCounts() []int64 {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+func (x *Histogram) GetSum() float64 {
+	if x != nil {
+		return x.Sum
+	}
+	return 0
+}
+
+func (x *Histogram) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// Metric - метрика с типом, значением и метками серии
+type Metric struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// имя метрики
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// тип метрики
+	Type MetricType `protobuf:"varint,2,opt,name=type,proto3,enum=metrics.v2.MetricType" json:"type,omitempty"`
+	// значение метрики в соответствии с типом
+	//
+	// Types that are valid to be assigned to Data:
+	//
+	//	*Metric_Delta
+	//	*Metric_Value
+	//	*Metric_Histogram
+	Data isMetric_Data `protobuf_oneof:"data"`
+	// метки серии
+	Labels map[string]string `protobuf:"bytes,6,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// время, на которое актуально значение
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Metric) Reset() {
+	*x = Metric{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Metric) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Metric) ProtoMessage() {}
+
+func (x *Metric) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Metric.ProtoReflect.Descriptor instead.
+func (*Metric) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Metric) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Metric) GetType() MetricType {
+	if x != nil {
+		return x.Type
+	}
+	return MetricType_METRIC_TYPE_UNSPECIFIED
+}
+
+func (x *Metric) GetData() isMetric_Data {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Metric) GetDelta() int64 {
+	if x != nil {
+		if x, ok := x.Data.(*Metric_Delta); ok {
+			return x.Delta
+		}
+	}
+	return 0
+}
+
+func (x *Metric) GetValue() float64 {
+	if x != nil {
+		if x, ok := x.Data.(*Metric_Value); ok {
+			return x.Value
+		}
+	}
+	return 0
+}
+
+func (x *Metric) GetHistogram() *Histogram {
+	if x != nil {
+		if x, ok := x.Data.(*Metric_Histogram); ok {
+			return x.Histogram
+		}
+	}
+	return nil
+}
+
+func (x *Metric) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Metric) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+type isMetric_Data interface {
+	isMetric_Data()
+}
+
+type Metric_Delta struct {
+	// значение counter
+	Delta int64 `protobuf:"varint,3,opt,name=delta,proto3,oneof"`
+}
+
+type Metric_Value struct {
+	// значение gauge
+	Value float64 `protobuf:"fixed64,4,opt,name=value,proto3,oneof"`
+}
+
+type Metric_Histogram struct {
+	// значение histogram
+	Histogram *Histogram `protobuf:"bytes,5,opt,name=histogram,proto3,oneof"`
+}
+
+func (*Metric_Delta) isMetric_Data() {}
+
+func (*Metric_Value) isMetric_Data() {}
+
+func (*Metric_Histogram) isMetric_Data() {}
+
+type UpdateMetricRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metric        *Metric                `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateMetricRequest) Reset() {
+	*x = UpdateMetricRequest{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateMetricRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateMetricRequest) ProtoMessage() {}
+
+func (x *UpdateMetricRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateMetricRequest.ProtoReflect.Descriptor instead.
+func (*UpdateMetricRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UpdateMetricRequest) GetMetric() *Metric {
+	if x != nil {
+		return x.Metric
+	}
+	return nil
+}
+
+type UpdateMetricResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// метрика после обновления
+	Metric        *Metric `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateMetricResponse) Reset() {
+	*x = UpdateMetricResponse{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateMetricResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateMetricResponse) ProtoMessage() {}
+
+func (x *UpdateMetricResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateMetricResponse.ProtoReflect.Descriptor instead.
+func (*UpdateMetricResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UpdateMetricResponse) GetMetric() *Metric {
+	if x != nil {
+		return x.Metric
+	}
+	return nil
+}
+
+type UpdatesRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Metrics []*Metric              `protobuf:"bytes,1,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	// идентификатор агента для дедупликации пакетов
+	AgentId string `protobuf:"bytes,2,opt,name=agent_id,json=agentId,proto3" json:"agent_id,omitempty"`
+	// идентификатор пакета, не меняется при повторных отправках
+	BatchId       string `protobuf:"bytes,3,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdatesRequest) Reset() {
+	*x = UpdatesRequest{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdatesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdatesRequest) ProtoMessage() {}
+
+func (x *UpdatesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdatesRequest.ProtoReflect.Descriptor instead.
+func (*UpdatesRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UpdatesRequest) GetMetrics() []*Metric {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+func (x *UpdatesRequest) GetAgentId() string {
+	if x != nil {
+		return x.AgentId
+	}
+	return ""
+}
+
+func (x *UpdatesRequest) GetBatchId() string {
+	if x != nil {
+		return x.BatchId
+	}
+	return ""
+}
+
+type UpdatesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdatesResponse) Reset() {
+	*x = UpdatesResponse{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdatesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdatesResponse) ProtoMessage() {}
+
+func (x *UpdatesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdatesResponse.ProtoReflect.Descriptor instead.
+func (*UpdatesResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{5}
+}
+
+type GetMetricRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type  MetricType             `protobuf:"varint,2,opt,name=type,proto3,enum=metrics.v2.MetricType" json:"type,omitempty"`
+	// матчеры меток серии
+	Labels        map[string]string `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMetricRequest) Reset() {
+	*x = GetMetricRequest{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMetricRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMetricRequest) ProtoMessage() {}
+
+func (x *GetMetricRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMetricRequest.ProtoReflect.Descriptor instead.
+func (*GetMetricRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetMetricRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetMetricRequest) GetType() MetricType {
+	if x != nil {
+		return x.Type
+	}
+	return MetricType_METRIC_TYPE_UNSPECIFIED
+}
+
+func (x *GetMetricRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type GetMetricResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metric        *Metric                `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMetricResponse) Reset() {
+	*x = GetMetricResponse{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMetricResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMetricResponse) ProtoMessage() {}
+
+func (x *GetMetricResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMetricResponse.ProtoReflect.Descriptor instead.
+func (*GetMetricResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GetMetricResponse) GetMetric() *Metric {
+	if x != nil {
+		return x.Metric
+	}
+	return nil
+}
+
+type ListMetricsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMetricsRequest) Reset() {
+	*x = ListMetricsRequest{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMetricsRequest) ProtoMessage() {}
+
+func (x *ListMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMetricsRequest.ProtoReflect.Descriptor instead.
+func (*ListMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{8}
+}
+
+type ListMetricsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metrics       []*Metric              `protobuf:"bytes,1,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMetricsResponse) Reset() {
+	*x = ListMetricsResponse{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMetricsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMetricsResponse) ProtoMessage() {}
+
+func (x *ListMetricsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMetricsResponse.ProtoReflect.Descriptor instead.
+func (*ListMetricsResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListMetricsResponse) GetMetrics() []*Metric {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+type DeleteMetricRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type  MetricType             `protobuf:"varint,2,opt,name=type,proto3,enum=metrics.v2.MetricType" json:"type,omitempty"`
+	// матчеры меток серии
+	Labels        map[string]string `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMetricRequest) Reset() {
+	*x = DeleteMetricRequest{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMetricRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMetricRequest) ProtoMessage() {}
+
+func (x *DeleteMetricRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMetricRequest.ProtoReflect.Descriptor instead.
+func (*DeleteMetricRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeleteMetricRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteMetricRequest) GetType() MetricType {
+	if x != nil {
+		return x.Type
+	}
+	return MetricType_METRIC_TYPE_UNSPECIFIED
+}
+
+func (x *DeleteMetricRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type DeleteMetricResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMetricResponse) Reset() {
+	*x = DeleteMetricResponse{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMetricResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMetricResponse) ProtoMessage() {}
+
+func (x *DeleteMetricResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMetricResponse.ProtoReflect.Descriptor instead.
+func (*DeleteMetricResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{11}
+}
+
+type DeleteByPrefixRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// префикс имени метрики, пустой префикс не допускается
+	Prefix        string `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteByPrefixRequest) Reset() {
+	*x = DeleteByPrefixRequest{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteByPrefixRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteByPrefixRequest) ProtoMessage() {}
+
+func (x *DeleteByPrefixRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteByPrefixRequest.ProtoReflect.Descriptor instead.
+func (*DeleteByPrefixRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DeleteByPrefixRequest) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+type DeleteByPrefixResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// количество удаленных серий
+	Deleted       int64 `protobuf:"varint,1,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteByPrefixResponse) Reset() {
+	*x = DeleteByPrefixResponse{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteByPrefixResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteByPrefixResponse) ProtoMessage() {}
+
+func (x *DeleteByPrefixResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteByPrefixResponse.ProtoReflect.Descriptor instead.
+func (*DeleteByPrefixResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DeleteByPrefixResponse) GetDeleted() int64 {
+	if x != nil {
+		return x.Deleted
+	}
+	return 0
+}
+
+type ResetCounterRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// матчеры меток серии
+	Labels        map[string]string `protobuf:"bytes,2,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResetCounterRequest) Reset() {
+	*x = ResetCounterRequest{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetCounterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetCounterRequest) ProtoMessage() {}
+
+func (x *ResetCounterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetCounterRequest.ProtoReflect.Descriptor instead.
+func (*ResetCounterRequest) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ResetCounterRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ResetCounterRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type ResetCounterResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// метрика после обнуления
+	Metric        *Metric `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResetCounterResponse) Reset() {
+	*x = ResetCounterResponse{}
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetCounterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetCounterResponse) ProtoMessage() {}
+
+func (x *ResetCounterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_v2_metrics_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetCounterResponse.ProtoReflect.Descriptor instead.
+func (*ResetCounterResponse) Descriptor() ([]byte, []int) {
+	return file_internal_proto_v2_metrics_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ResetCounterResponse) GetMetric() *Metric {
+	if x != nil {
+		return x.Metric
+	}
+	return nil
+}
+
+var File_internal_proto_v2_metrics_proto protoreflect.FileDescriptor
+
+const file_internal_proto_v2_metrics_proto_rawDesc = "" +
+	"\n" +
+	"\x1finternal/proto/v2/metrics.proto\x12\n" +
+	"metrics.v2\x1a\x1fgoogle/protobuf/timestamp.proto\"c\n" +
+	"\tHistogram\x12\x16\n" +
+	"\x06bounds\x18\x01 \x03(\x01R\x06bounds\x12\x16\n" +
+	"\x06counts\x18\x02 \x03(\x03R\x06counts\x12\x10\n" +
+	"\x03sum\x18\x03 \x01(\x01R\x03sum\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x03R\x05count\"\xe0\x02\n" +
+	"\x06Metric\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12*\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x16.metrics.v2.MetricTypeR\x04type\x12\x16\n" +
+	"\x05delta\x18\x03 \x01(\x03H\x00R\x05delta\x12\x16\n" +
+	"\x05value\x18\x04 \x01(\x01H\x00R\x05value\x125\n" +
+	"\thistogram\x18\x05 \x01(\v2\x15.metrics.v2.HistogramH\x00R\thistogram\x126\n" +
+	"\x06labels\x18\x06 \x03(\v2\x1e.metrics.v2.Metric.LabelsEntryR\x06labels\x128\n" +
+	"\ttimestamp\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x06\n" +
+	"\x04data\"A\n" +
+	"\x13UpdateMetricRequest\x12*\n" +
+	"\x06metric\x18\x01 \x01(\v2\x12.metrics.v2.MetricR\x06metric\"B\n" +
+	"\x14UpdateMetricResponse\x12*\n" +
+	"\x06metric\x18\x01 \x01(\v2\x12.metrics.v2.MetricR\x06metric\"t\n" +
+	"\x0eUpdatesRequest\x12,\n" +
+	"\ametrics\x18\x01 \x03(\v2\x12.metrics.v2.MetricR\ametrics\x12\x19\n" +
+	"\bagent_id\x18\x02 \x01(\tR\aagentId\x12\x19\n" +
+	"\bbatch_id\x18\x03 \x01(\tR\abatchId\"\x11\n" +
+	"\x0fUpdatesResponse\"\xcb\x01\n" +
+	"\x10GetMetricRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12*\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x16.metrics.v2.MetricTypeR\x04type\x12@\n" +
+	"\x06labels\x18\x03 \x03(\v2(.metrics.v2.GetMetricRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"?\n" +
+	"\x11GetMetricResponse\x12*\n" +
+	"\x06metric\x18\x01 \x01(\v2\x12.metrics.v2.MetricR\x06metric\"\x14\n" +
+	"\x12ListMetricsRequest\"C\n" +
+	"\x13ListMetricsResponse\x12,\n" +
+	"\ametrics\x18\x01 \x03(\v2\x12.metrics.v2.MetricR\ametrics\"\xd1\x01\n" +
+	"\x13DeleteMetricRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12*\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x16.metrics.v2.MetricTypeR\x04type\x12C\n" +
+	"\x06labels\x18\x03 \x03(\v2+.metrics.v2.DeleteMetricRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x16\n" +
+	"\x14DeleteMetricResponse\"/\n" +
+	"\x15DeleteByPrefixRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\"2\n" +
+	"\x16DeleteByPrefixResponse\x12\x18\n" +
+	"\adeleted\x18\x01 \x01(\x03R\adeleted\"\xa5\x01\n" +
+	"\x13ResetCounterRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12C\n" +
+	"\x06labels\x18\x02 \x03(\v2+.metrics.v2.ResetCounterRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"B\n" +
+	"\x14ResetCounterResponse\x12*\n" +
+	"\x06metric\x18\x01 \x01(\v2\x12.metrics.v2.MetricR\x06metric*t\n" +
+	"\n" +
+	"MetricType\x12\x1b\n" +
+	"\x17METRIC_TYPE_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11METRIC_TYPE_GAUGE\x10\x01\x12\x17\n" +
+	"\x13METRIC_TYPE_COUNTER\x10\x02\x12\x19\n" +
+	"\x15METRIC_TYPE_HISTOGRAM\x10\x032\xb9\x04\n" +
+	"\aMetrics\x12Q\n" +
+	"\fUpdateMetric\x12\x1f.metrics.v2.UpdateMetricRequest\x1a .metrics.v2.UpdateMetricResponse\x12B\n" +
+	"\aUpdates\x12\x1a.metrics.v2.UpdatesRequest\x1a\x1b.metrics.v2.UpdatesResponse\x12H\n" +
+	"\tGetMetric\x12\x1c.metrics.v2.GetMetricRequest\x1a\x1d.metrics.v2.GetMetricResponse\x12N\n" +
+	"\vListMetrics\x12\x1e.metrics.v2.ListMetricsRequest\x1a\x1f.metrics.v2.ListMetricsResponse\x12Q\n" +
+	"\fDeleteMetric\x12\x1f.metrics.v2.DeleteMetricRequest\x1a .metrics.v2.DeleteMetricResponse\x12W\n" +
+	"\x0eDeleteByPrefix\x12!.metrics.v2.DeleteByPrefixRequest\x1a\".metrics.v2.DeleteByPrefixResponse\x12Q\n" +
+	"\fResetCounter\x12\x1f.metrics.v2.ResetCounterRequest\x1a .metrics.v2.ResetCounterResponseBAZ?github.com/Soliard/go-tpl-metrics/internal/proto/v2;metricspbv2b\x06proto3"
+
+var (
+	file_internal_proto_v2_metrics_proto_rawDescOnce sync.Once
+	file_internal_proto_v2_metrics_proto_rawDescData []byte
+)
+
+func file_internal_proto_v2_metrics_proto_rawDescGZIP() []byte {
+	file_internal_proto_v2_metrics_proto_rawDescOnce.Do(func() {
+		file_internal_proto_v2_metrics_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_internal_proto_v2_metrics_proto_rawDesc), len(file_internal_proto_v2_metrics_proto_rawDesc)))
+	})
+	return file_internal_proto_v2_metrics_proto_rawDescData
+}
+
+var file_internal_proto_v2_metrics_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_internal_proto_v2_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_internal_proto_v2_metrics_proto_goTypes = []any{
+	(MetricType)(0),                // 0: metrics.v2.MetricType
+	(*Histogram)(nil),              // 1: metrics.v2.Histogram
+	(*Metric)(nil),                 // 2: metrics.v2.Metric
+	(*UpdateMetricRequest)(nil),    // 3: metrics.v2.UpdateMetricRequest
+	(*UpdateMetricResponse)(nil),   // 4: metrics.v2.UpdateMetricResponse
+	(*UpdatesRequest)(nil),         // 5: metrics.v2.UpdatesRequest
+	(*UpdatesResponse)(nil),        // 6: metrics.v2.UpdatesResponse
+	(*GetMetricRequest)(nil),       // 7: metrics.v2.GetMetricRequest
+	(*GetMetricResponse)(nil),      // 8: metrics.v2.GetMetricResponse
+	(*ListMetricsRequest)(nil),     // 9: metrics.v2.ListMetricsRequest
+	(*ListMetricsResponse)(nil),    // 10: metrics.v2.ListMetricsResponse
+	(*DeleteMetricRequest)(nil),    // 11: metrics.v2.DeleteMetricRequest
+	(*DeleteMetricResponse)(nil),   // 12: metrics.v2.DeleteMetricResponse
+	(*DeleteByPrefixRequest)(nil),  // 13: metrics.v2.DeleteByPrefixRequest
+	(*DeleteByPrefixResponse)(nil), // 14: metrics.v2.DeleteByPrefixResponse
+	(*ResetCounterRequest)(nil),    // 15: metrics.v2.ResetCounterRequest
+	(*ResetCounterResponse)(nil),   // 16: metrics.v2.ResetCounterResponse
+	nil,                            // 17: metrics.v2.Metric.LabelsEntry
+	nil,                            // 18: metrics.v2.GetMetricRequest.LabelsEntry
+	nil,                            // 19: metrics.v2.DeleteMetricRequest.LabelsEntry
+	nil,                            // 20: metrics.v2.ResetCounterRequest.LabelsEntry
+	(*timestamppb.Timestamp)(nil),  // 21: google.protobuf.Timestamp
+}
+var file_internal_proto_v2_metrics_proto_depIdxs = []int32{
+	0,  // 0: metrics.v2.Metric.type:type_name -> metrics.v2.MetricType
+	1,  // 1: metrics.v2.Metric.histogram:type_name -> metrics.v2.Histogram
+	17, // 2: metrics.v2.Metric.labels:type_name -> metrics.v2.Metric.LabelsEntry
+	21, // 3: metrics.v2.Metric.timestamp:type_name -> google.protobuf.Timestamp
+	2,  // 4: metrics.v2.UpdateMetricRequest.metric:type_name -> metrics.v2.Metric
+	2,  // 5: metrics.v2.UpdateMetricResponse.metric:type_name -> metrics.v2.Metric
+	2,  // 6: metrics.v2.UpdatesRequest.metrics:type_name -> metrics.v2.Metric
+	0,  // 7: metrics.v2.GetMetricRequest.type:type_name -> metrics.v2.MetricType
+	18, // 8: metrics.v2.GetMetricRequest.labels:type_name -> metrics.v2.GetMetricRequest.LabelsEntry
+	2,  // 9: metrics.v2.GetMetricResponse.metric:type_name -> metrics.v2.Metric
+	2,  // 10: metrics.v2.ListMetricsResponse.metrics:type_name -> metrics.v2.Metric
+	0,  // 11: metrics.v2.DeleteMetricRequest.type:type_name -> metrics.v2.MetricType
+	19, // 12: metrics.v2.DeleteMetricRequest.labels:type_name -> metrics.v2.DeleteMetricRequest.LabelsEntry
+	20, // 13: metrics.v2.ResetCounterRequest.labels:type_name -> metrics.v2.ResetCounterRequest.LabelsEntry
+	2,  // 14: metrics.v2.ResetCounterResponse.metric:type_name -> metrics.v2.Metric
+	3,  // 15: metrics.v2.Metrics.UpdateMetric:input_type -> metrics.v2.UpdateMetricRequest
+	5,  // 16: metrics.v2.Metrics.Updates:input_type -> metrics.v2.UpdatesRequest
+	7,  // 17: metrics.v2.Metrics.GetMetric:input_type -> metrics.v2.GetMetricRequest
+	9,  // 18: metrics.v2.Metrics.ListMetrics:input_type -> metrics.v2.ListMetricsRequest
+	11, // 19: metrics.v2.Metrics.DeleteMetric:input_type -> metrics.v2.DeleteMetricRequest
+	13, // 20: metrics.v2.Metrics.DeleteByPrefix:input_type -> metrics.v2.DeleteByPrefixRequest
+	15, // 21: metrics.v2.Metrics.ResetCounter:input_type -> metrics.v2.ResetCounterRequest
+	4,  // 22: metrics.v2.Metrics.UpdateMetric:output_type -> metrics.v2.UpdateMetricResponse
+	6,  // 23: metrics.v2.Metrics.Updates:output_type -> metrics.v2.UpdatesResponse
+	8,  // 24: metrics.v2.Metrics.GetMetric:output_type -> metrics.v2.GetMetricResponse
+	10, // 25: metrics.v2.Metrics.ListMetrics:output_type -> metrics.v2.ListMetricsResponse
+	12, // 26: metrics.v2.Metrics.DeleteMetric:output_type -> metrics.v2.DeleteMetricResponse
+	14, // 27: metrics.v2.Metrics.DeleteByPrefix:output_type -> metrics.v2.DeleteByPrefixResponse
+	16, // 28: metrics.v2.Metrics.ResetCounter:output_type -> metrics.v2.ResetCounterResponse
+	22, // [22:29] is the sub-list for method output_type
+	15, // [15:22] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
+}
+
+func init() { file_internal_proto_v2_metrics_proto_init() }
+func file_internal_proto_v2_metrics_proto_init() {
+	if File_internal_proto_v2_metrics_proto != nil {
+		return
+	}
+	file_internal_proto_v2_metrics_proto_msgTypes[1].OneofWrappers = []any{
+		(*Metric_Delta)(nil),
+		(*Metric_Value)(nil),
+		(*Metric_Histogram)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_proto_v2_metrics_proto_rawDesc), len(file_internal_proto_v2_metrics_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_internal_proto_v2_metrics_proto_goTypes,
+		DependencyIndexes: file_internal_proto_v2_metrics_proto_depIdxs,
+		EnumInfos:         file_internal_proto_v2_metrics_proto_enumTypes,
+		MessageInfos:      file_internal_proto_v2_metrics_proto_msgTypes,
+	}.Build()
+	File_internal_proto_v2_metrics_proto = out.File
+	file_internal_proto_v2_metrics_proto_goTypes = nil
+	file_internal_proto_v2_metrics_proto_depIdxs = nil
+}
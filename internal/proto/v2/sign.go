@@ -0,0 +1,8 @@
+package metricspbv2
+
+import "google.golang.org/protobuf/proto"
+
+// SigningPayload возвращает детерминированное представление запроса, по которому вычисляется подпись HashSHA256
+func SigningPayload(req proto.Message) ([]byte, error) {
+	return proto.MarshalOptions{Deterministic: true}.Marshal(req)
+}
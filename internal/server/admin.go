@@ -0,0 +1,129 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/internal/netutil"
+	"github.com/Soliard/go-tpl-metrics/internal/server/auth"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// ErrFlushNotSupported возвращается, когда хранилище не сохраняет снимки (память, база данных)
+var ErrFlushNotSupported = errors.New("storage does not support flush")
+
+// flusher - хранилище, сохраняющее снимок метрик по запросу
+type flusher interface {
+	Flush() error
+}
+
+// FlushStorage сохраняет снимок метрик файлового хранилища, не дожидаясь интервала сохранения
+func (s *MetricsService) FlushStorage() error {
+	f, ok := s.storage.(flusher)
+	if !ok {
+		return ErrFlushNotSupported
+	}
+	return f.Flush()
+}
+
+// AdminRouter создает роутер административного сервера:
+//   - /debug/pprof/ - профилирование;
+//   - GET и PUT /admin/log-level - текущий уровень логирования и его изменение телом {"level":"debug"};
+//   - POST /admin/flush - сохранение снимка файлового хранилища;
+//   - GET /admin/config - конфигурация сервера без секретов;
+//   - GET /admin/audit - состояние аудита и число отброшенных событий.
+//
+// Доступ разрешен только из подсетей cfg.AdminSubnet с токеном cfg.AdminToken в заголовке Authorization: Bearer.
+func AdminRouter(s *MetricsService, cfg *config.ServerConfig, level zap.AtomicLevel) (chi.Router, error) {
+	if cfg.AdminToken == "" {
+		return nil, errors.New("admin token is required for admin server")
+	}
+	policy, err := netutil.NewIPPolicy(cfg.AdminSubnet, "", cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.Enabled() {
+		return nil, errors.New("admin subnet is required for admin server")
+	}
+	redacted := cfg.Redacted()
+
+	r := chi.NewRouter()
+	r.Use(
+		logger.LoggingMiddleware(s.Logger),
+		TrustedSubnetMiddleware(policy, s.Logger),
+		AdminTokenMiddleware(cfg.AdminToken, s.Logger),
+	)
+
+	r.HandleFunc("/debug/pprof/*", pprof.Index)
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Method(http.MethodGet, "/log-level", level)
+		r.Put("/log-level", func(w http.ResponseWriter, req *http.Request) {
+			level.ServeHTTP(w, req)
+			logger.LoggerFromCtx(req.Context(), s.Logger).Warn("log level changed by admin", zap.Stringer("level", level.Level()))
+		})
+		r.Post("/flush", s.adminFlushHandler)
+		r.Get("/config", func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(redacted)
+		})
+		r.Get("/audit", s.adminAuditHandler)
+	})
+	return r, nil
+}
+
+// adminFlushHandler сохраняет снимок файлового хранилища.
+// Для хранилищ без снимков возвращает 501 Not Implemented.
+func (s *MetricsService) adminFlushHandler(w http.ResponseWriter, req *http.Request) {
+	err := s.FlushStorage()
+	if errors.Is(err, ErrFlushNotSupported) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		logger.LoggerFromCtx(req.Context(), s.Logger).Error("cant flush storage", zap.Error(err))
+		http.Error(w, "cant flush storage", http.StatusInternalServerError)
+		return
+	}
+	logger.LoggerFromCtx(req.Context(), s.Logger).Info("storage flushed by admin")
+	w.WriteHeader(http.StatusOK)
+}
+
+// AuditStatus - состояние аудита изменений метрик
+type AuditStatus struct {
+	Enabled bool   `json:"enabled"`
+	Dropped uint64 `json:"dropped"` // события, отброшенные из-за переполнения очередей приемников
+}
+
+// adminAuditHandler возвращает состояние аудита в JSON
+func (s *MetricsService) adminAuditHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuditStatus{Enabled: s.auditor != nil, Dropped: s.auditor.Dropped()})
+}
+
+// AdminTokenMiddleware пропускает только запросы с токеном администратора в заголовке Authorization: Bearer
+func AdminTokenMiddleware(token string, log *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, err := auth.BearerToken(r.Header.Get("Authorization"))
+			if err != nil || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				logger.LoggerFromCtx(r.Context(), log).Warn("admin request without valid token rejected",
+					zap.String("remote_addr", r.RemoteAddr))
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"go.uber.org/zap"
+)
+
+// State - состояние правила алертинга
+type State string
+
+const (
+	// StateInactive - условие правила не выполняется
+	StateInactive State = "inactive"
+	// StatePending - условие выполняется, но еще не дольше For
+	StatePending State = "pending"
+	// StateFiring - условие выполняется дольше For, отправлено уведомление
+	StateFiring State = "firing"
+	// StateResolved - условие перестало выполняться после firing
+	StateResolved State = "resolved"
+)
+
+// MetricsSource предоставляет текущие значения метрик для вычисления правил.
+// Реализуется MetricsService.
+type MetricsSource interface {
+	FindMetric(ctx context.Context, id string, matchers map[string]string) (*models.Metrics, error)
+}
+
+// RuleStatus описывает текущее состояние правила
+type RuleStatus struct {
+	Rule        string    `json:"rule"`         // имя правила
+	State       State     `json:"state"`        // текущее состояние
+	ActiveSince time.Time `json:"active_since"` // с какого момента выполняется условие
+}
+
+// ruleState хранит состояние вычисления одного правила
+type ruleState struct {
+	rule        *Rule
+	state       State
+	activeSince time.Time
+	firedAt     time.Time
+	// для правил "did not increase"
+	lastDelta   *int64
+	lastChanged time.Time
+}
+
+// Engine периодически вычисляет правила и рассылает уведомления о переходах
+type Engine struct {
+	source    MetricsSource
+	notifiers []Notifier
+	logger    *zap.Logger
+	mu        sync.Mutex
+	states    []*ruleState
+}
+
+// NewEngine создает движок алертинга для заданных правил и уведомителей
+func NewEngine(source MetricsSource, rules []*Rule, notifiers []Notifier, logger *zap.Logger) *Engine {
+	states := make([]*ruleState, 0, len(rules))
+	for _, r := range rules {
+		states = append(states, &ruleState{rule: r, state: StateInactive})
+	}
+	return &Engine{
+		source:    source,
+		notifiers: notifiers,
+		logger:    logger,
+		states:    states,
+	}
+}
+
+// Run вычисляет правила с заданным интервалом до отмены контекста
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			e.Evaluate(ctx, now)
+		}
+	}
+}
+
+// Evaluate однократно вычисляет все правила на момент now
+func (e *Engine) Evaluate(ctx context.Context, now time.Time) {
+	e.mu.Lock()
+	alerts := make([]Alert, 0)
+	for _, st := range e.states {
+		metric, err := e.source.FindMetric(ctx, st.rule.MetricID, st.rule.Labels)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			e.logger.Error("cant get metric for alert rule",
+				zap.String("rule", st.rule.Name),
+				zap.Error(err))
+			continue
+		}
+		if metric != nil && metric.MType != st.rule.MType {
+			metric = nil
+		}
+		active := st.condition(metric, now)
+		if alert, ok := st.transition(active, metric, now); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, alert := range alerts {
+		e.notify(ctx, alert)
+	}
+}
+
+// Statuses возвращает текущее состояние всех правил
+func (e *Engine) Statuses() []RuleStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	statuses := make([]RuleStatus, 0, len(e.states))
+	for _, st := range e.states {
+		statuses = append(statuses, RuleStatus{
+			Rule:        st.rule.Name,
+			State:       st.state,
+			ActiveSince: st.activeSince,
+		})
+	}
+	return statuses
+}
+
+// notify отправляет алерт во все уведомители, ошибки одного уведомителя не мешают остальным
+func (e *Engine) notify(ctx context.Context, alert Alert) {
+	for _, n := range e.notifiers {
+		if err := n.Notify(ctx, alert); err != nil {
+			e.logger.Error("failed to send alert notification",
+				zap.String("rule", alert.Rule),
+				zap.Error(err))
+		}
+	}
+}
+
+// condition вычисляет условие правила для текущего значения метрики
+func (st *ruleState) condition(metric *models.Metrics, now time.Time) bool {
+	switch st.rule.kind {
+	case kindNoIncrease:
+		if st.lastChanged.IsZero() {
+			st.lastChanged = now
+		}
+		if metric != nil && metric.Delta != nil {
+			// любое изменение значения - рост или сброс счетчика - считается активностью,
+			// иначе после сброса рост ниже прежнего значения не был бы замечен
+			if st.lastDelta != nil && *metric.Delta != *st.lastDelta {
+				st.lastChanged = now
+			}
+			st.lastDelta = models.PInt(*metric.Delta)
+		}
+		return now.Sub(st.lastChanged) >= st.rule.For
+	default:
+		v, ok := metricValue(metric)
+		return ok && st.rule.compare(v)
+	}
+}
+
+// transition обновляет состояние правила и возвращает алерт, если нужно уведомление
+func (st *ruleState) transition(active bool, metric *models.Metrics, now time.Time) (Alert, bool) {
+	if !active {
+		prev := st.state
+		st.activeSince = time.Time{}
+		switch prev {
+		case StateFiring:
+			st.state = StateResolved
+			alert := st.alert(metric)
+			alert.EndsAt = &now
+			return alert, true
+		case StatePending:
+			st.state = StateInactive
+		}
+		return Alert{}, false
+	}
+
+	if st.state != StatePending && st.state != StateFiring {
+		st.state = StatePending
+		st.activeSince = now
+		if st.rule.kind == kindNoIncrease {
+			// окно уже отсчитано с момента последнего роста
+			st.activeSince = st.lastChanged
+		}
+	}
+	if st.state == StatePending && st.pendingElapsed(now) {
+		st.state = StateFiring
+		st.firedAt = now
+		return st.alert(metric), true
+	}
+	return Alert{}, false
+}
+
+// pendingElapsed проверяет, что условие держится достаточно долго для firing
+func (st *ruleState) pendingElapsed(now time.Time) bool {
+	if st.rule.kind == kindNoIncrease {
+		// окно "did not increase" уже учтено в условии
+		return true
+	}
+	return now.Sub(st.activeSince) >= st.rule.For
+}
+
+// alert формирует уведомление о текущем состоянии правила
+func (st *ruleState) alert(metric *models.Metrics) Alert {
+	alert := Alert{
+		Rule:     st.rule.Name,
+		Expr:     st.rule.Expr,
+		State:    st.state,
+		MetricID: st.rule.MetricID,
+		StartsAt: st.firedAt,
+	}
+	if metric != nil {
+		alert.Labels = metric.Labels
+	}
+	if v, ok := metricValue(metric); ok {
+		alert.Value = &v
+	}
+	return alert
+}
+
+// metricValue возвращает числовое значение метрики
+func metricValue(metric *models.Metrics) (float64, bool) {
+	if metric == nil {
+		return 0, false
+	}
+	if metric.Value != nil {
+		return *metric.Value, true
+	}
+	if metric.Delta != nil {
+		return float64(*metric.Delta), true
+	}
+	return 0, false
+}
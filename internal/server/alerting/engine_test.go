@@ -0,0 +1,214 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+	"github.com/Soliard/go-tpl-metrics/internal/server"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingNotifier запоминает полученные алерты
+type recordingNotifier struct {
+	alerts []Alert
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, alert Alert) error {
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+// newSource возвращает сервис метрик поверх storage для вычисления правил
+func newSource(storage store.Storage) MetricsSource {
+	return server.NewMetricsService(storage, &config.ServerConfig{}, zap.NewNop())
+}
+
+func TestEngine_ThresholdRule(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	rule, err := ParseRule("HighHeap", "gauge HeapAlloc > 500MB for 2m")
+	require.NoError(t, err)
+	notifier := &recordingNotifier{}
+	engine := NewEngine(newSource(storage), []*Rule{rule}, []Notifier{notifier}, zap.NewNop())
+	start := time.Now()
+
+	// метрики еще нет - правило неактивно
+	engine.Evaluate(ctx, start)
+	assert.Equal(t, StateInactive, engine.Statuses()[0].State)
+
+	storage.UpdateMetric(ctx, models.NewGaugeMetric("HeapAlloc", 600<<20))
+	engine.Evaluate(ctx, start.Add(time.Minute))
+	assert.Equal(t, StatePending, engine.Statuses()[0].State)
+	assert.Empty(t, notifier.alerts)
+
+	engine.Evaluate(ctx, start.Add(3*time.Minute))
+	assert.Equal(t, StateFiring, engine.Statuses()[0].State)
+	require.Len(t, notifier.alerts, 1)
+	assert.Equal(t, StateFiring, notifier.alerts[0].State)
+	assert.Equal(t, float64(600<<20), *notifier.alerts[0].Value)
+
+	// повторное вычисление не дублирует уведомление
+	engine.Evaluate(ctx, start.Add(4*time.Minute))
+	require.Len(t, notifier.alerts, 1)
+
+	storage.UpdateMetric(ctx, models.NewGaugeMetric("HeapAlloc", 100))
+	engine.Evaluate(ctx, start.Add(5*time.Minute))
+	assert.Equal(t, StateResolved, engine.Statuses()[0].State)
+	require.Len(t, notifier.alerts, 2)
+	assert.Equal(t, StateResolved, notifier.alerts[1].State)
+	assert.NotNil(t, notifier.alerts[1].EndsAt)
+}
+
+func TestEngine_PendingWithoutFiring(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	rule, err := ParseRule("HighHeap", "gauge HeapAlloc > 10 for 2m")
+	require.NoError(t, err)
+	notifier := &recordingNotifier{}
+	engine := NewEngine(newSource(storage), []*Rule{rule}, []Notifier{notifier}, zap.NewNop())
+	start := time.Now()
+
+	storage.UpdateMetric(ctx, models.NewGaugeMetric("HeapAlloc", 20))
+	engine.Evaluate(ctx, start)
+	assert.Equal(t, StatePending, engine.Statuses()[0].State)
+
+	storage.UpdateMetric(ctx, models.NewGaugeMetric("HeapAlloc", 5))
+	engine.Evaluate(ctx, start.Add(time.Minute))
+	assert.Equal(t, StateInactive, engine.Statuses()[0].State)
+	assert.Empty(t, notifier.alerts)
+}
+
+func TestEngine_NoIncreaseRule(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	rule, err := ParseRule("AgentStuck", "counter PollCount did not increase in 5m")
+	require.NoError(t, err)
+	notifier := &recordingNotifier{}
+	engine := NewEngine(newSource(storage), []*Rule{rule}, []Notifier{notifier}, zap.NewNop())
+	start := time.Now()
+
+	storage.UpdateMetric(ctx, models.NewCounterMetric("PollCount", 1))
+	engine.Evaluate(ctx, start)
+	storage.UpdateMetric(ctx, models.NewCounterMetric("PollCount", 1))
+	engine.Evaluate(ctx, start.Add(3*time.Minute))
+	assert.Equal(t, StateInactive, engine.Statuses()[0].State)
+
+	// счетчик не растет 5 минут с последнего увеличения
+	engine.Evaluate(ctx, start.Add(8*time.Minute))
+	assert.Equal(t, StateFiring, engine.Statuses()[0].State)
+	require.Len(t, notifier.alerts, 1)
+
+	storage.UpdateMetric(ctx, models.NewCounterMetric("PollCount", 1))
+	engine.Evaluate(ctx, start.Add(9*time.Minute))
+	assert.Equal(t, StateResolved, engine.Statuses()[0].State)
+	require.Len(t, notifier.alerts, 2)
+}
+
+func TestEngine_NoIncreaseRuleAfterReset(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	rule, err := ParseRule("AgentStuck", "counter PollCount did not increase in 5m")
+	require.NoError(t, err)
+	notifier := &recordingNotifier{}
+	engine := NewEngine(newSource(storage), []*Rule{rule}, []Notifier{notifier}, zap.NewNop())
+	start := time.Now()
+
+	storage.UpdateMetric(ctx, models.NewCounterMetric("PollCount", 10))
+	engine.Evaluate(ctx, start)
+
+	// счетчик сброшен и снова растет, но остается ниже значения до сброса
+	_, err = storage.ResetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	storage.UpdateMetric(ctx, models.NewCounterMetric("PollCount", 5))
+	engine.Evaluate(ctx, start.Add(3*time.Minute))
+	storage.UpdateMetric(ctx, models.NewCounterMetric("PollCount", 3))
+	engine.Evaluate(ctx, start.Add(7*time.Minute))
+	engine.Evaluate(ctx, start.Add(11*time.Minute))
+	assert.Equal(t, StateInactive, engine.Statuses()[0].State)
+	assert.Empty(t, notifier.alerts)
+
+	engine.Evaluate(ctx, start.Add(12*time.Minute))
+	assert.Equal(t, StateFiring, engine.Statuses()[0].State)
+	require.Len(t, notifier.alerts, 1)
+
+	// сброс без последующего роста тоже означает, что счетчик обновляется
+	_, err = storage.ResetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	engine.Evaluate(ctx, start.Add(13*time.Minute))
+	assert.Equal(t, StateResolved, engine.Statuses()[0].State)
+}
+
+func TestEngine_LabeledSeries(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	hostRule, err := ParseRule("HostA", `counter requests{host="a"} > 10`)
+	require.NoError(t, err)
+	anyRule, err := ParseRule("AnyHost", "counter requests > 10")
+	require.NoError(t, err)
+	notifier := &recordingNotifier{}
+	engine := NewEngine(newSource(storage), []*Rule{hostRule, anyRule}, []Notifier{notifier}, zap.NewNop())
+	start := time.Now()
+
+	storage.UpdateMetric(ctx, &models.Metrics{ID: "requests", MType: models.Counter, Delta: models.PInt(20), Labels: map[string]string{"host": "a"}})
+	engine.Evaluate(ctx, start)
+	assert.Equal(t, StateFiring, engine.Statuses()[0].State)
+	// без меток выбирается единственная серия с этим именем
+	assert.Equal(t, StateFiring, engine.Statuses()[1].State)
+	require.Len(t, notifier.alerts, 2)
+	assert.Equal(t, map[string]string{"host": "a"}, notifier.alerts[0].Labels)
+	assert.Equal(t, 20.0, *notifier.alerts[0].Value)
+
+	// серия другого хоста не влияет на правило с метками, а неоднозначное правило без меток сохраняет состояние
+	storage.UpdateMetric(ctx, &models.Metrics{ID: "requests", MType: models.Counter, Delta: models.PInt(1), Labels: map[string]string{"host": "b"}})
+	engine.Evaluate(ctx, start.Add(time.Minute))
+	assert.Equal(t, StateFiring, engine.Statuses()[0].State)
+	assert.Equal(t, StateFiring, engine.Statuses()[1].State)
+	require.Len(t, notifier.alerts, 2)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(server.URL)
+	err := notifier.Notify(context.Background(), Alert{Rule: "HighHeap", State: StateFiring})
+	require.NoError(t, err)
+	assert.Equal(t, "HighHeap", got.Rule)
+	assert.Equal(t, StateFiring, got.State)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	assert.Error(t, NewWebhookNotifier(failing.URL).Notify(context.Background(), Alert{}))
+}
+
+func TestLogNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.log")
+	notifier := NewLogNotifier(path, zap.NewNop())
+	require.NoError(t, notifier.Notify(context.Background(), Alert{Rule: "A", State: StateFiring}))
+	require.NoError(t, notifier.Notify(context.Background(), Alert{Rule: "A", State: StateResolved}))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"state":"resolved"`)
+}
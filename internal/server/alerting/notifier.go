@@ -0,0 +1,106 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Alert описывает переход правила в состояние firing или resolved
+type Alert struct {
+	Rule     string            `json:"rule"`              // имя правила
+	Expr     string            `json:"expr"`              // выражение правила
+	State    State             `json:"state"`             // новое состояние правила
+	MetricID string            `json:"metric_id"`         // имя метрики
+	Labels   map[string]string `json:"labels,omitempty"`  // метки серии метрики
+	Value    *float64          `json:"value,omitempty"`   // значение метрики при переходе, если метрика существует
+	StartsAt time.Time         `json:"starts_at"`         // время перехода в firing
+	EndsAt   *time.Time        `json:"ends_at,omitempty"` // время перехода в resolved
+}
+
+// Notifier доставляет уведомления о переходах правил
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// WebhookNotifier отправляет уведомления JSON POST запросом на указанный URL
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier создает уведомитель, отправляющий алерты на webhook
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify отправляет алерт в теле POST запроса
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// LogNotifier пишет уведомления в лог и, если задан путь, дописывает их в файл в формате JSON lines
+type LogNotifier struct {
+	path   string
+	logger *zap.Logger
+	mu     sync.Mutex
+}
+
+// NewLogNotifier создает уведомитель, пишущий алерты в лог и файл
+func NewLogNotifier(path string, logger *zap.Logger) *LogNotifier {
+	return &LogNotifier{path: path, logger: logger}
+}
+
+// Notify пишет алерт в лог и дописывает его в файл
+func (n *LogNotifier) Notify(ctx context.Context, alert Alert) error {
+	n.logger.Warn("alert state changed",
+		zap.String("rule", alert.Rule),
+		zap.String("state", string(alert.State)),
+		zap.String("metric", alert.MetricID))
+	if n.path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	os.MkdirAll(filepath.Dir(n.path), 0755)
+	file, err := os.OpenFile(n.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,232 @@
+// Package alerting реализует правила алертинга поверх метрик сервера.
+// Правила периодически вычисляются по текущим значениям метрик, состояние каждого правила
+// проходит через pending, firing и resolved, а переходы отправляются в уведомители.
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+// ruleKind определяет способ вычисления условия правила
+type ruleKind int
+
+const (
+	// kindThreshold - сравнение значения метрики с порогом
+	kindThreshold ruleKind = iota
+	// kindNoIncrease - counter не увеличивался в течение окна
+	kindNoIncrease
+)
+
+// ErrInvalidRule возвращается когда выражение правила не удалось разобрать
+var ErrInvalidRule = errors.New("invalid alert rule")
+
+// Rule описывает одно правило алертинга.
+// Поддерживаются выражения вида:
+//
+//	gauge HeapAlloc > 500MB for 2m
+//	counter PollCount did not increase in 5m
+//	counter requests{host=a,dc="eu"} > 100
+//
+// Метки в фигурных скобках выбирают серию метрики; без меток используется серия без меток
+// или единственная серия с этим именем.
+type Rule struct {
+	Name      string            // имя правила
+	Expr      string            // исходное выражение
+	MType     string            // тип метрики
+	MetricID  string            // имя метрики
+	Labels    map[string]string // метки, выбирающие серию метрики
+	Op        string            // оператор сравнения для порогового правила
+	Threshold float64           // порог для порогового правила
+	For       time.Duration     // сколько условие должно держаться до перехода в firing
+	kind      ruleKind
+}
+
+// ruleFile представляет структуру файла с правилами
+type ruleFile struct {
+	Rules []struct {
+		Name string `json:"name"` // имя правила
+		Expr string `json:"expr"` // выражение правила
+	} `json:"rules"`
+}
+
+// LoadRules загружает правила из JSON файла вида {"rules": [{"name": "...", "expr": "..."}]}
+func LoadRules(path string) ([]*Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read alert rules file %s: %w", path, err)
+	}
+	var file ruleFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cannot decode alert rules file: %w", err)
+	}
+
+	rules := make([]*Rule, 0, len(file.Rules))
+	names := map[string]bool{}
+	for i, r := range file.Rules {
+		rule, err := ParseRule(r.Name, r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule #%d: %w", i+1, err)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("rule #%d: %w: duplicate name %q", i+1, ErrInvalidRule, rule.Name)
+		}
+		names[rule.Name] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ParseRule разбирает выражение правила.
+// Если имя не задано, в качестве имени используется само выражение.
+func ParseRule(name, expr string) (*Rule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRule, expr)
+	}
+	if name == "" {
+		name = expr
+	}
+	id, labels, err := parseSelector(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	rule := &Rule{
+		Name:     name,
+		Expr:     expr,
+		MType:    fields[0],
+		MetricID: id,
+		Labels:   labels,
+	}
+	if rule.MType != models.Gauge && rule.MType != models.Counter {
+		return nil, fmt.Errorf("%w: unsupported metric type %q", ErrInvalidRule, rule.MType)
+	}
+
+	// counter <id> did not increase in <duration>
+	if strings.Join(fields[2:min(len(fields), 6)], " ") == "did not increase in" {
+		if rule.MType != models.Counter || len(fields) != 7 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, expr)
+		}
+		window, err := parsePositiveDuration(fields[6])
+		if err != nil {
+			return nil, err
+		}
+		rule.kind = kindNoIncrease
+		rule.For = window
+		return rule, nil
+	}
+
+	// <type> <id> <op> <threshold> [for <duration>]
+	if len(fields) != 4 && len(fields) != 6 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRule, expr)
+	}
+	switch fields[2] {
+	case ">", ">=", "<", "<=", "==", "!=":
+		rule.Op = fields[2]
+	default:
+		return nil, fmt.Errorf("%w: unsupported operator %q", ErrInvalidRule, fields[2])
+	}
+	threshold, err := parseThreshold(fields[3])
+	if err != nil {
+		return nil, err
+	}
+	rule.Threshold = threshold
+	if len(fields) == 6 {
+		if fields[4] != "for" {
+			return nil, fmt.Errorf("%w: expected \"for\", got %q", ErrInvalidRule, fields[4])
+		}
+		rule.For, err = parsePositiveDuration(fields[5])
+		if err != nil {
+			return nil, err
+		}
+	}
+	rule.kind = kindThreshold
+	return rule, nil
+}
+
+// parseSelector разбирает метрику правила вида name или name{key=value,...}.
+// Значения меток могут быть заключены в кавычки.
+func parseSelector(s string) (string, map[string]string, error) {
+	id, rest, ok := strings.Cut(s, "{")
+	if !ok {
+		return s, nil, nil
+	}
+	body, ok := strings.CutSuffix(rest, "}")
+	if !ok || id == "" {
+		return "", nil, fmt.Errorf("%w: invalid metric selector %q", ErrInvalidRule, s)
+	}
+	labels, err := models.ParseLabels(body)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	for k, v := range labels {
+		if unquoted, err := strconv.Unquote(v); err == nil {
+			labels[k] = unquoted
+		}
+	}
+	return id, labels, nil
+}
+
+// compare применяет оператор правила к значению
+func (r *Rule) compare(v float64) bool {
+	switch r.Op {
+	case ">":
+		return v > r.Threshold
+	case ">=":
+		return v >= r.Threshold
+	case "<":
+		return v < r.Threshold
+	case "<=":
+		return v <= r.Threshold
+	case "==":
+		return v == r.Threshold
+	case "!=":
+		return v != r.Threshold
+	}
+	return false
+}
+
+// byteSuffixes - поддерживаемые суффиксы размеров для порогов (степени 1024)
+var byteSuffixes = []struct {
+	suffix string
+	mult   float64
+}{
+	{"TB", 1 << 40},
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+}
+
+// parseThreshold разбирает числовой порог с необязательным суффиксом KB, MB, GB или TB
+func parseThreshold(s string) (float64, error) {
+	mult := 1.0
+	num := s
+	for _, bs := range byteSuffixes {
+		if strings.HasSuffix(strings.ToUpper(s), bs.suffix) {
+			mult = bs.mult
+			num = s[:len(s)-len(bs.suffix)]
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid threshold %q", ErrInvalidRule, s)
+	}
+	return v * mult, nil
+}
+
+// parsePositiveDuration разбирает положительный интервал в формате time.Duration
+func parsePositiveDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%w: invalid duration %q", ErrInvalidRule, s)
+	}
+	return d, nil
+}
@@ -0,0 +1,117 @@
+package alerting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    *Rule
+		wantErr bool
+	}{
+		{
+			name: "gauge threshold with for",
+			expr: "gauge HeapAlloc > 500MB for 2m",
+			want: &Rule{MType: "gauge", MetricID: "HeapAlloc", Op: ">", Threshold: 500 << 20, For: 2 * time.Minute, kind: kindThreshold},
+		},
+		{
+			name: "counter threshold without for",
+			expr: "counter PollCount >= 10",
+			want: &Rule{MType: "counter", MetricID: "PollCount", Op: ">=", Threshold: 10, kind: kindThreshold},
+		},
+		{
+			name: "counter did not increase",
+			expr: "counter PollCount did not increase in 5m",
+			want: &Rule{MType: "counter", MetricID: "PollCount", For: 5 * time.Minute, kind: kindNoIncrease},
+		},
+		{
+			name: "labeled series",
+			expr: `counter requests{host=a,dc="eu-west"} > 10`,
+			want: &Rule{MType: "counter", MetricID: "requests", Labels: map[string]string{"host": "a", "dc": "eu-west"}, Op: ">", Threshold: 10, kind: kindThreshold},
+		},
+		{
+			name:    "unclosed selector",
+			expr:    "counter requests{host=a > 10",
+			wantErr: true,
+		},
+		{
+			name:    "invalid label",
+			expr:    "counter requests{1host=a} > 10",
+			wantErr: true,
+		},
+		{
+			name:    "gauge did not increase",
+			expr:    "gauge HeapAlloc did not increase in 5m",
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			expr:    "histogram X > 1",
+			wantErr: true,
+		},
+		{
+			name:    "unknown operator",
+			expr:    "gauge X => 1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid threshold",
+			expr:    "gauge X > abc",
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			expr:    "gauge X > 1 for soon",
+			wantErr: true,
+		},
+		{
+			name:    "too short",
+			expr:    "gauge X >",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRule("", tt.expr)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidRule)
+				return
+			}
+			require.NoError(t, err)
+			tt.want.Name = tt.expr
+			tt.want.Expr = tt.expr
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	err := os.WriteFile(path, []byte(`{"rules": [
+		{"name": "HighHeap", "expr": "gauge HeapAlloc > 500MB for 2m"},
+		{"name": "AgentStuck", "expr": "counter PollCount did not increase in 5m"}
+	]}`), 0644)
+	require.NoError(t, err)
+
+	rules, err := LoadRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "HighHeap", rules[0].Name)
+	assert.Equal(t, "AgentStuck", rules[1].Name)
+
+	err = os.WriteFile(path, []byte(`{"rules": [
+		{"name": "A", "expr": "gauge X > 1"},
+		{"name": "A", "expr": "gauge Y > 1"}
+	]}`), 0644)
+	require.NoError(t, err)
+	_, err = LoadRules(path)
+	assert.ErrorIs(t, err, ErrInvalidRule)
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/internal/netutil"
+	"github.com/Soliard/go-tpl-metrics/internal/server/audit"
+	"github.com/Soliard/go-tpl-metrics/internal/server/auth"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"google.golang.org/grpc"
+)
+
+// recordUpdate записывает в аудит принятое изменение метрик. Агент берется из контекста запроса.
+func (s *MetricsService) recordUpdate(ctx context.Context, endpoint string, ip net.IP, agentID string, metrics []*models.Metrics) {
+	if s.auditor == nil {
+		return
+	}
+	e := audit.Event{
+		Time:     time.Now(),
+		Endpoint: endpoint,
+		AgentID:  agentID,
+		Metrics:  make([]string, 0, len(metrics)),
+	}
+	if ip != nil {
+		e.SourceIP = ip.String()
+	}
+	if id := auth.IdentityFromCtx(ctx); id != nil {
+		e.Agent = id.Name
+	}
+	// серии с разными метками различаются в аудите
+	for _, m := range metrics {
+		e.Metrics = append(e.Metrics, m.SeriesKey())
+	}
+	s.auditor.Record(e)
+}
+
+// recordHTTPUpdate записывает в аудит изменение метрик, принятое по HTTP
+func (s *MetricsService) recordHTTPUpdate(req *http.Request, agentID string, metrics ...*models.Metrics) {
+	s.recordUpdate(req.Context(), req.Method+" "+req.URL.Path,
+		netutil.ExtractIPFromHTTPRequest(req, s.ipPolicy.Proxies()), agentID, metrics)
+}
+
+// recordGRPCUpdate записывает в аудит изменение метрик, принятое по gRPC
+func (s *MetricsService) recordGRPCUpdate(ctx context.Context, agentID string, metrics ...*models.Metrics) {
+	method, _ := grpc.Method(ctx)
+	s.recordUpdate(ctx, method, netutil.ExtractIPFromGRPCContext(ctx, s.ipPolicy.Proxies()), agentID, metrics)
+}
@@ -0,0 +1,180 @@
+// Package audit записывает события изменения метрик: кто, откуда и какие метрики изменил.
+// События доставляются в приемники асинхронно через ограниченную очередь, поэтому медленный приемник
+// не задерживает прием метрик: при переполнении очереди события отбрасываются и учитываются в счетчике.
+package audit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Event - принятое изменение метрик
+type Event struct {
+	Time     time.Time `json:"time"`               // время приема запроса
+	Endpoint string    `json:"endpoint"`           // эндпоинт HTTP или метод gRPC
+	SourceIP string    `json:"source_ip"`          // адрес клиента, см. netutil
+	Agent    string    `json:"agent,omitempty"`    // агент, аутентифицированный по токену
+	AgentID  string    `json:"agent_id,omitempty"` // идентификатор, заявленный агентом в X-Agent-ID, не проверяется
+	Metrics  []string  `json:"metrics"`            // имена измененных метрик
+}
+
+// Sink доставляет события аудита
+type Sink interface {
+	Write(ctx context.Context, e Event) error
+}
+
+// Auditor распределяет события по приемникам. У каждого приемника своя очередь и горутина доставки,
+// так что медленный приемник не мешает остальным. Нулевой указатель отключает аудит.
+type Auditor struct {
+	workers []*worker
+	logger  *zap.Logger
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+	// ctx отменяется, если очереди не успели опустеть до истечения контекста Close
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type worker struct {
+	name  string
+	sink  Sink
+	queue chan Event
+}
+
+// NamedSink - приемник с именем для логов
+type NamedSink struct {
+	Name string
+	Sink Sink
+}
+
+// NewAuditor создает аудитор с очередью на queueSize событий (не меньше одного) для каждого приемника
+// и запускает доставку. Возвращает nil, если приемники не заданы.
+func NewAuditor(sinks []NamedSink, queueSize int, logger *zap.Logger) *Auditor {
+	if len(sinks) == 0 {
+		return nil
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Auditor{logger: logger, ctx: ctx, cancel: cancel}
+	for _, s := range sinks {
+		w := &worker{name: s.Name, sink: s.Sink, queue: make(chan Event, queueSize)}
+		a.workers = append(a.workers, w)
+		a.wg.Add(1)
+		go a.run(w)
+	}
+	return a
+}
+
+// Record ставит событие в очереди приемников без ожидания.
+// Если очередь приемника заполнена, событие для него отбрасывается.
+func (a *Auditor) Record(e Event) {
+	if a == nil {
+		return
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	for _, w := range a.workers {
+		select {
+		case w.queue <- e:
+		default:
+			// логируются первое и каждое 2^n-е отброшенное событие, чтобы не засорять лог при перегрузке
+			if n := a.dropped.Add(1); n&(n-1) == 0 {
+				a.logger.Warn("audit queue is full, events dropped",
+					zap.String("sink", w.name),
+					zap.Uint64("dropped_total", n))
+			}
+		}
+	}
+}
+
+// Dropped возвращает количество событий, отброшенных из-за переполнения очередей
+func (a *Auditor) Dropped() uint64 {
+	if a == nil {
+		return 0
+	}
+	return a.dropped.Load()
+}
+
+// Close прекращает прием событий и ждет доставки поставленных в очередь.
+// Если ctx истекает раньше, оставшиеся доставки прерываются.
+func (a *Auditor) Close(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	for _, w := range a.workers {
+		close(w.queue)
+	}
+	a.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		a.cancel()
+		<-done
+		err = ctx.Err()
+	}
+	a.cancel()
+	if dropped := a.Dropped(); dropped > 0 {
+		a.logger.Warn("audit stopped with dropped events", zap.Uint64("dropped_total", dropped))
+	}
+	return err
+}
+
+// run доставляет события из очереди приемника до ее закрытия
+func (a *Auditor) run(w *worker) {
+	defer a.wg.Done()
+	for e := range w.queue {
+		if err := w.sink.Write(a.ctx, e); err != nil {
+			a.logger.Error("cant deliver audit event",
+				zap.String("sink", w.name),
+				zap.String("endpoint", e.Endpoint),
+				zap.Error(err))
+		}
+	}
+	if c, ok := w.sink.(interface{ Close() error }); ok {
+		if err := c.Close(); err != nil {
+			a.logger.Error("cant close audit sink", zap.String("sink", w.name), zap.Error(err))
+		}
+	}
+}
+
+// FromConfig создает аудитор с приемниками из настроек: файлом JSON lines и webhook.
+// Возвращает nil, если ни один приемник не задан.
+func FromConfig(logFile, webhookURL string, queueSize int, logger *zap.Logger) (*Auditor, error) {
+	var sinks []NamedSink
+	if logFile != "" {
+		file, err := NewFileSink(logFile)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, NamedSink{Name: "file", Sink: file})
+	}
+	if webhookURL != "" {
+		sinks = append(sinks, NamedSink{Name: "webhook", Sink: NewWebhookSink(webhookURL)})
+	}
+	return NewAuditor(sinks, queueSize, logger), nil
+}
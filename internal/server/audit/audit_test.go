@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingSink запоминает доставленные события
+type recordingSink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *recordingSink) Write(ctx context.Context, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+// blockingSink не доставляет события до закрытия release или отмены контекста
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s *blockingSink) Write(ctx context.Context, e Event) error {
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestAuditorDelivers(t *testing.T) {
+	first, second := &recordingSink{}, &recordingSink{}
+	a := NewAuditor([]NamedSink{{Name: "first", Sink: first}, {Name: "second", Sink: second}}, 10, zap.NewNop())
+
+	a.Record(Event{Endpoint: "POST /updates/", Metrics: []string{"Alloc"}})
+	a.Record(Event{Endpoint: "POST /update/", Metrics: []string{"PollCount"}})
+	require.NoError(t, a.Close(context.Background()))
+
+	for _, s := range []*recordingSink{first, second} {
+		require.Len(t, s.events, 2)
+		assert.Equal(t, []string{"Alloc"}, s.events[0].Metrics)
+		assert.Equal(t, "POST /update/", s.events[1].Endpoint)
+	}
+	assert.Zero(t, a.Dropped())
+
+	// после закрытия события не принимаются
+	a.Record(Event{Endpoint: "POST /update/"})
+	assert.Len(t, first.events, 2)
+}
+
+func TestAuditorDropsWhenSinkIsSlow(t *testing.T) {
+	slow := &blockingSink{release: make(chan struct{})}
+	fast := &recordingSink{}
+	a := NewAuditor([]NamedSink{{Name: "slow", Sink: slow}, {Name: "fast", Sink: fast}}, 2, zap.NewNop())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			a.Record(Event{Endpoint: "POST /updates/"})
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Record blocked on slow sink")
+	}
+
+	// медленный приемник держит одно событие и два в очереди, остальные отброшены
+	assert.Equal(t, uint64(7), a.Dropped())
+	close(slow.release)
+	require.NoError(t, a.Close(context.Background()))
+	assert.Len(t, fast.events, 10)
+}
+
+func TestAuditorCloseTimeout(t *testing.T) {
+	slow := &blockingSink{release: make(chan struct{})}
+	a := NewAuditor([]NamedSink{{Name: "slow", Sink: slow}}, 10, zap.NewNop())
+	a.Record(Event{})
+	a.Record(Event{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, a.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestAuditorDisabled(t *testing.T) {
+	var a *Auditor
+	a.Record(Event{})
+	assert.Zero(t, a.Dropped())
+	assert.NoError(t, a.Close(context.Background()))
+
+	a, err := FromConfig("", "", 10, zap.NewNop())
+	require.NoError(t, err)
+	assert.Nil(t, a)
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	a, err := FromConfig(path, "", 10, zap.NewNop())
+	require.NoError(t, err)
+	a.Record(Event{Endpoint: "POST /updates/", SourceIP: "10.0.0.1", Agent: "web1", Metrics: []string{"Alloc"}})
+	a.Record(Event{Endpoint: "POST /update/", Metrics: []string{"PollCount"}})
+	require.NoError(t, a.Close(context.Background()))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	require.Len(t, events, 2)
+	assert.Equal(t, "web1", events[0].Agent)
+	assert.Equal(t, "10.0.0.1", events[0].SourceIP)
+	assert.Equal(t, []string{"PollCount"}, events[1].Metrics)
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	require.NoError(t, NewWebhookSink(server.URL).Write(context.Background(), Event{Agent: "web1", Metrics: []string{"Alloc"}}))
+	assert.Equal(t, "web1", received.Agent)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	assert.Error(t, NewWebhookSink(failing.URL).Write(context.Background(), Event{}))
+}
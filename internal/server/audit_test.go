@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	metricspbv2 "github.com/Soliard/go-tpl-metrics/internal/proto/v2"
+	"github.com/Soliard/go-tpl-metrics/internal/server/audit"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recordingAuditSink запоминает события аудита
+type recordingAuditSink struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (s *recordingAuditSink) Write(ctx context.Context, e audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestAuditHTTPUpdates(t *testing.T) {
+	lg, err := logger.New("info")
+	require.NoError(t, err)
+	service := NewMetricsService(store.NewMemoryStorage(), &config.ServerConfig{AuthTokensFile: writeTokensFile(t)}, lg)
+	sink := &recordingAuditSink{}
+	service.auditor = audit.NewAuditor([]audit.NamedSink{{Name: "test", Sink: sink}}, 10, zap.NewNop())
+	server := httptest.NewServer(MetricRouter(service))
+	defer server.Close()
+
+	send := func(method, path, contentType, body string) int {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer web1 token")
+		req.Header.Set("X-Agent-ID", "host-1")
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusOK, send(http.MethodPost, "/update/gauge/web1_cpu/1", "", ""))
+	require.Equal(t, http.StatusOK, send(http.MethodPost, "/update/", "application/json", `{"id":"web1_mem","type":"gauge","value":2}`))
+	require.Equal(t, http.StatusOK, send(http.MethodPost, "/updates/", "application/json",
+		`[{"id":"web1_cpu","type":"gauge","value":3},{"id":"web1_req","type":"counter","delta":1}]`))
+	// отклоненные запросы и чтение не попадают в аудит
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, "/update/gauge/web2_cpu/1", "", ""))
+	require.Equal(t, http.StatusOK, send(http.MethodGet, "/value/gauge/web1_cpu", "", ""))
+	require.NoError(t, service.Close(context.Background()))
+
+	require.Len(t, sink.events, 3)
+	assert.Equal(t, "POST /update/gauge/web1_cpu/1", sink.events[0].Endpoint)
+	assert.Equal(t, []string{"web1_cpu"}, sink.events[0].Metrics)
+	assert.Equal(t, []string{"web1_mem"}, sink.events[1].Metrics)
+	assert.Equal(t, "POST /updates/", sink.events[2].Endpoint)
+	assert.Equal(t, []string{"web1_cpu", "web1_req"}, sink.events[2].Metrics)
+	assert.Equal(t, "host-1", sink.events[2].AgentID)
+	for _, e := range sink.events {
+		assert.Equal(t, "web1", e.Agent)
+		assert.Equal(t, "127.0.0.1", e.SourceIP)
+		assert.False(t, e.Time.IsZero())
+	}
+}
+
+func TestAuditGRPCUpdates(t *testing.T) {
+	lg, err := logger.New("info")
+	require.NoError(t, err)
+	service := NewMetricsService(store.NewMemoryStorage(), &config.ServerConfig{}, lg)
+	sink := &recordingAuditSink{}
+	service.auditor = audit.NewAuditor([]audit.NamedSink{{Name: "test", Sink: sink}}, 10, zap.NewNop())
+	g := &grpcServerV2{svc: service}
+
+	_, err = g.Updates(context.Background(), &metricspbv2.UpdatesRequest{
+		Metrics: []*metricspbv2.Metric{metricspbv2.FromModel(models.NewGaugeMetric("temp", 1))},
+		AgentId: "agent",
+		BatchId: "1",
+	})
+	require.NoError(t, err)
+	require.NoError(t, service.Close(context.Background()))
+
+	require.Len(t, sink.events, 1)
+	assert.Equal(t, []string{"temp"}, sink.events[0].Metrics)
+	assert.Equal(t, "agent", sink.events[0].AgentID)
+	assert.Empty(t, sink.events[0].Agent)
+}
+
+func TestAuditSkipsDuplicateBatch(t *testing.T) {
+	service := NewMetricsService(store.NewMemoryStorage(), &config.ServerConfig{}, zap.NewNop())
+	sink := &recordingAuditSink{}
+	service.auditor = audit.NewAuditor([]audit.NamedSink{{Name: "test", Sink: sink}}, 10, zap.NewNop())
+	g := &grpcServerV2{svc: service}
+
+	metric := models.NewCounterMetric("requests", 1)
+	metric.Labels = map[string]string{"host": "a"}
+	req := &metricspbv2.UpdatesRequest{
+		Metrics: []*metricspbv2.Metric{metricspbv2.FromModel(metric)},
+		AgentId: "agent",
+		BatchId: "1",
+	}
+	for i := 0; i < 2; i++ {
+		_, err := g.Updates(context.Background(), req)
+		require.NoError(t, err)
+	}
+	require.NoError(t, service.Close(context.Background()))
+
+	require.Len(t, sink.events, 1, "duplicate batch is not applied and not audited")
+	assert.Equal(t, []string{`requests{host="a"}`}, sink.events[0].Metrics)
+}
@@ -0,0 +1,124 @@
+// Package auth проверяет токены агентов из заголовка Authorization: Bearer.
+// Реестр токенов хранит хеши токенов с именем агента, разрешенными префиксами метрик и областью доступа.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Scope - область доступа токена
+type Scope string
+
+const (
+	// ScopeRead разрешает только чтение метрик
+	ScopeRead Scope = "read"
+	// ScopeWrite разрешает чтение, запись и удаление метрик
+	ScopeWrite Scope = "write"
+)
+
+// ParseScope проверяет название области доступа
+func ParseScope(s string) (Scope, error) {
+	switch Scope(s) {
+	case ScopeRead, ScopeWrite:
+		return Scope(s), nil
+	default:
+		return "", fmt.Errorf("unknown token scope %q", s)
+	}
+}
+
+// ErrMissingToken возвращается, когда запрос не содержит токен Bearer
+var ErrMissingToken = errors.New("missing bearer token")
+
+// ErrInvalidToken возвращается, когда токен отсутствует в реестре
+var ErrInvalidToken = errors.New("invalid token")
+
+// Identity - агент, которому принадлежит предъявленный токен
+type Identity struct {
+	Name     string   // имя агента
+	Prefixes []string // префиксы имен метрик, которые агент может изменять; пустой список - любые
+	Scope    Scope    // область доступа
+}
+
+// Allows проверяет, что область доступа агента включает scope
+func (id *Identity) Allows(scope Scope) bool {
+	return id.Scope == ScopeWrite || id.Scope == scope
+}
+
+// AllowsMetric проверяет, что имя метрики начинается с одного из разрешенных агенту префиксов
+func (id *Identity) AllowsMetric(name string) bool {
+	if len(id.Prefixes) == 0 {
+		return true
+	}
+	for _, p := range id.Prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry находит агента по предъявленному токену
+type Registry interface {
+	// Authenticate возвращает агента, которому принадлежит токен, или ErrInvalidToken
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+// HashToken возвращает SHA-256 хеш токена в hex, в виде которого токены хранятся в реестре
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// BearerToken извлекает токен из значения заголовка Authorization вида "Bearer <token>"
+func BearerToken(header string) (string, error) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrMissingToken
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrMissingToken
+	}
+	return token, nil
+}
+
+type ctxKey struct{}
+
+// WithIdentity добавляет агента в контекст запроса
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// IdentityFromCtx возвращает агента из контекста или nil, если запрос не аутентифицирован
+func IdentityFromCtx(ctx context.Context) *Identity {
+	id, _ := ctx.Value(ctxKey{}).(*Identity)
+	return id
+}
+
+// RegistryFromConfig создает реестр токенов из настроек: JSON файла или таблицы agent_tokens базы databaseDSN.
+// Возвращает nil, если реестр не настроен и аутентификация отключена.
+func RegistryFromConfig(ctx context.Context, tokensFile string, useDatabase bool, databaseDSN string) (Registry, error) {
+	switch {
+	case tokensFile != "" && useDatabase:
+		return nil, errors.New("agent tokens must be read either from file or from database")
+	case tokensFile != "":
+		r, err := LoadFileRegistry(tokensFile)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	case useDatabase:
+		r, err := NewDBRegistry(ctx, databaseDSN)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{name: "bearer token", header: "Bearer secret", want: "secret"},
+		{name: "scheme in lower case", header: "bearer secret", want: "secret"},
+		{name: "empty header", header: "", wantErr: true},
+		{name: "basic auth", header: "Basic dXNlcjpwYXNz", wantErr: true},
+		{name: "scheme without token", header: "Bearer ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BearerToken(tt.header)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrMissingToken)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	reader := &Identity{Name: "dashboard", Scope: ScopeRead}
+	writer := &Identity{Name: "web1", Scope: ScopeWrite, Prefixes: []string{"web1_", "http_"}}
+
+	assert.True(t, reader.Allows(ScopeRead))
+	assert.False(t, reader.Allows(ScopeWrite))
+	assert.True(t, writer.Allows(ScopeRead))
+	assert.True(t, writer.Allows(ScopeWrite))
+
+	assert.True(t, reader.AllowsMetric("anything"), "empty prefixes allow any metric")
+	assert.True(t, writer.AllowsMetric("web1_cpu"))
+	assert.True(t, writer.AllowsMetric("http_requests{host=\"a\"}"))
+	assert.False(t, writer.AllowsMetric("web2_cpu"))
+}
+
+func TestFileRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"tokens":[
+		{"hash":"`+HashToken("web1 token")+`","agent":"web1","prefixes":["web1_"],"scope":"write"},
+		{"hash":"`+HashToken("dashboard token")+`","agent":"dashboard","scope":"read"}
+	]}`), 0o600))
+
+	r, err := LoadFileRegistry(path)
+	require.NoError(t, err)
+
+	id, err := r.Authenticate(context.Background(), "web1 token")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Name: "web1", Prefixes: []string{"web1_"}, Scope: ScopeWrite}, id)
+
+	id, err = r.Authenticate(context.Background(), "dashboard token")
+	require.NoError(t, err)
+	assert.Equal(t, ScopeRead, id.Scope)
+
+	_, err = r.Authenticate(context.Background(), HashToken("web1 token"))
+	assert.ErrorIs(t, err, ErrInvalidToken, "hash from file is not a token")
+}
+
+func TestLoadFileRegistryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "invalid json", content: `{"tokens":`},
+		{name: "unknown scope", content: `{"tokens":[{"hash":"a","agent":"web1","scope":"admin"}]}`},
+		{name: "token without hash", content: `{"tokens":[{"agent":"web1","scope":"read"}]}`},
+		{name: "duplicate token", content: `{"tokens":[{"hash":"a","agent":"web1","scope":"read"},{"hash":"a","agent":"web2","scope":"read"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			_, err := LoadFileRegistry(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRegistryFromConfig(t *testing.T) {
+	r, err := RegistryFromConfig(context.Background(), "", false, "")
+	require.NoError(t, err)
+	assert.Nil(t, r, "without settings authentication is disabled")
+
+	_, err = RegistryFromConfig(context.Background(), "tokens.json", true, "postgres://localhost/metrics")
+	assert.Error(t, err, "file and database together")
+
+	_, err = RegistryFromConfig(context.Background(), "", true, "")
+	assert.Error(t, err, "database without dsn")
+}
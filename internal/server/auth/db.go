@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/jackc/pgx/v5/pgtype"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// DBRegistry - реестр токенов в таблице agent_tokens PostgreSQL.
+// Токены читаются при каждом запросе, поэтому отзыв токена удалением строки действует сразу.
+type DBRegistry struct {
+	db *sqlx.DB
+}
+
+// NewDBRegistry подключается к базе данных и применяет встроенные миграции, создающие таблицу agent_tokens
+func NewDBRegistry(ctx context.Context, databaseDSN string) (*DBRegistry, error) {
+	if databaseDSN == "" {
+		return nil, errors.New("database dsn is required for agent tokens")
+	}
+	migr, err := store.NewMigrator(ctx, databaseDSN)
+	if err != nil {
+		return nil, err
+	}
+	err = migr.Up()
+	migr.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sqlx.Open("pgx", databaseDSN)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &DBRegistry{db: db}, nil
+}
+
+// Authenticate находит агента по хешу токена
+func (r *DBRegistry) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	var id Identity
+	var scope string
+	typeMap := pgtype.NewMap()
+	err := r.db.QueryRowContext(ctx,
+		`SELECT agent, prefixes, scope FROM agent_tokens WHERE token_hash = $1`, HashToken(token)).
+		Scan(&id.Name, typeMap.SQLScanner(&id.Prefixes), &scope)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if id.Scope, err = ParseScope(scope); err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// Close закрывает соединение с базой данных
+func (r *DBRegistry) Close() error {
+	return r.db.Close()
+}
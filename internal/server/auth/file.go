@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TokensFile - формат JSON файла токенов агентов.
+// Файл хранит только хеши токенов, поэтому его утечка не раскрывает сами токены.
+type TokensFile struct {
+	Tokens []TokenEntry `json:"tokens"`
+}
+
+// TokenEntry - токен агента в файле
+type TokenEntry struct {
+	Hash     string   `json:"hash"`     // SHA-256 токена в hex, см. HashToken
+	Agent    string   `json:"agent"`    // имя агента
+	Prefixes []string `json:"prefixes"` // префиксы имен метрик, которые агент может изменять
+	Scope    string   `json:"scope"`    // read или write
+}
+
+// FileRegistry - реестр токенов, загруженный из файла
+type FileRegistry struct {
+	tokens map[string]*Identity
+}
+
+// LoadFileRegistry читает реестр токенов из JSON файла в формате TokensFile
+func LoadFileRegistry(path string) (*FileRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file TokensFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cant parse tokens file: %w", err)
+	}
+	r := &FileRegistry{tokens: make(map[string]*Identity, len(file.Tokens))}
+	for _, e := range file.Tokens {
+		if e.Hash == "" || e.Agent == "" {
+			return nil, errors.New("tokens file contains token without hash or agent")
+		}
+		if _, ok := r.tokens[e.Hash]; ok {
+			return nil, fmt.Errorf("duplicate token of agent %q", e.Agent)
+		}
+		scope, err := ParseScope(e.Scope)
+		if err != nil {
+			return nil, fmt.Errorf("agent %q: %w", e.Agent, err)
+		}
+		r.tokens[e.Hash] = &Identity{Name: e.Agent, Prefixes: e.Prefixes, Scope: scope}
+	}
+	return r, nil
+}
+
+// Authenticate находит агента по хешу токена
+func (r *FileRegistry) Authenticate(_ context.Context, token string) (*Identity, error) {
+	id, ok := r.tokens[HashToken(token)]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	return id, nil
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/internal/server/auth"
+	"go.uber.org/zap"
+)
+
+// AuthMiddleware проверяет токен агента из заголовка Authorization: Bearer и добавляет агента в контекст запроса
+// и полем agent в логгер запроса. Без реестра токенов запросы пропускаются без проверки.
+func AuthMiddleware(registry auth.Registry, log *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if registry == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			reqLogger := logger.LoggerFromCtx(ctx, log)
+			token, err := auth.BearerToken(r.Header.Get("Authorization"))
+			var id *auth.Identity
+			if err == nil {
+				id, err = registry.Authenticate(ctx, token)
+			}
+			if err != nil {
+				if !errors.Is(err, auth.ErrMissingToken) && !errors.Is(err, auth.ErrInvalidToken) {
+					reqLogger.Error("cant check agent token", zap.Error(err))
+					http.Error(w, "cant check token", http.StatusInternalServerError)
+					return
+				}
+				reqLogger.Warn("request without valid token rejected",
+					zap.String("remote_addr", r.RemoteAddr),
+					zap.Error(err))
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+			ctx = auth.WithIdentity(ctx, id)
+			ctx = logger.CtxWithLogger(ctx, reqLogger.With(zap.String("agent", id.Name)))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope пропускает только запросы агентов, область доступа которых включает scope.
+// Используется после AuthMiddleware; без реестра токенов запросы пропускаются без проверки.
+func RequireScope(registry auth.Registry, scope auth.Scope, log *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if registry == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := auth.IdentityFromCtx(r.Context())
+			if id == nil || !id.Allows(scope) {
+				logger.LoggerFromCtx(r.Context(), log).Warn("request outside of token scope rejected",
+					zap.String("scope", string(scope)))
+				http.Error(w, "forbidden: token scope does not allow "+string(scope), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
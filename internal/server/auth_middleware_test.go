@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/internal/server/auth"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// writeTokensFile записывает файл токенов с агентом web1 (запись метрик web1_) и dashboard (только чтение)
+func writeTokensFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"tokens":[
+		{"hash":"`+auth.HashToken("web1 token")+`","agent":"web1","prefixes":["web1_"],"scope":"write"},
+		{"hash":"`+auth.HashToken("dashboard token")+`","agent":"dashboard","scope":"read"}
+	]}`), 0o600))
+	return path
+}
+
+func TestMetricRouterAuth(t *testing.T) {
+	lg, err := logger.New("info")
+	require.NoError(t, err)
+	service := NewMetricsService(store.NewMemoryStorage(), &config.ServerConfig{AuthTokensFile: writeTokensFile(t)}, lg)
+	server := httptest.NewServer(MetricRouter(service))
+	defer server.Close()
+
+	batch, err := json.Marshal([]*models.Metrics{models.NewGaugeMetric("web1_cpu", 1)})
+	require.NoError(t, err)
+	foreignBatch, err := json.Marshal([]*models.Metrics{models.NewGaugeMetric("web2_cpu", 1)})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		body         []byte
+		expectedCode int
+	}{
+		{name: "ping without token", method: http.MethodGet, path: "/ping", expectedCode: http.StatusOK},
+		{name: "read without token", method: http.MethodGet, path: "/api/v1/metrics", expectedCode: http.StatusUnauthorized},
+		{name: "read with unknown token", method: http.MethodGet, path: "/api/v1/metrics", token: "stolen", expectedCode: http.StatusUnauthorized},
+		{name: "read with read token", method: http.MethodGet, path: "/api/v1/metrics", token: "dashboard token", expectedCode: http.StatusOK},
+		{name: "read with write token", method: http.MethodGet, path: "/api/v1/metrics", token: "web1 token", expectedCode: http.StatusOK},
+		{name: "update with read token", method: http.MethodPost, path: "/update/gauge/web1_cpu/1", token: "dashboard token", expectedCode: http.StatusForbidden},
+		{name: "update with write token", method: http.MethodPost, path: "/update/gauge/web1_cpu/1", token: "web1 token", expectedCode: http.StatusOK},
+		{name: "update outside prefixes", method: http.MethodPost, path: "/update/gauge/web2_cpu/1", token: "web1 token", expectedCode: http.StatusForbidden},
+		{name: "batch without token", method: http.MethodPost, path: "/updates/", body: batch, expectedCode: http.StatusUnauthorized},
+		{name: "batch with read token", method: http.MethodPost, path: "/updates/", token: "dashboard token", body: batch, expectedCode: http.StatusForbidden},
+		{name: "batch with write token", method: http.MethodPost, path: "/updates/", token: "web1 token", body: batch, expectedCode: http.StatusOK},
+		{name: "batch outside prefixes", method: http.MethodPost, path: "/updates/", token: "web1 token", body: foreignBatch, expectedCode: http.StatusForbidden},
+		{name: "delete with read token", method: http.MethodDelete, path: "/value/gauge/web1_cpu", token: "dashboard token", expectedCode: http.StatusForbidden},
+		{name: "delete outside prefixes", method: http.MethodPost, path: "/delete/", token: "web1 token", body: []byte(`{"prefixes":["web"]}`), expectedCode: http.StatusForbidden},
+		{name: "delete with write token", method: http.MethodDelete, path: "/value/gauge/web1_cpu", token: "web1 token", expectedCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, bytes.NewReader(tt.body))
+			require.NoError(t, err)
+			if tt.body != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+			if tt.expectedCode == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareIdentity(t *testing.T) {
+	registry, err := auth.LoadFileRegistry(writeTokensFile(t))
+	require.NoError(t, err)
+	core, logs := observer.New(zap.InfoLevel)
+
+	var identity *auth.Identity
+	handler := AuthMiddleware(registry, zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity = auth.IdentityFromCtx(r.Context())
+		logger.LoggerFromCtx(r.Context(), zap.NewNop()).Info("handled")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer web1 token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, identity)
+	assert.Equal(t, "web1", identity.Name)
+	entries := logs.FilterMessage("handled").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "web1", entries[0].ContextMap()["agent"])
+}
+
+func TestAuthMiddlewareDisabled(t *testing.T) {
+	handler := AuthMiddleware(nil, zap.NewNop())(
+		RequireScope(nil, auth.ScopeWrite, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})))
+	r := httptest.NewRequest(http.MethodPost, "/update/", strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	metricspb "github.com/Soliard/go-tpl-metrics/internal/proto"
+	metricspbv2 "github.com/Soliard/go-tpl-metrics/internal/proto/v2"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthWatchInterval - период повторной проверки готовности для подписчиков Watch
+const healthWatchInterval = 5 * time.Second
+
+// healthServer реализует стандартный сервис grpc.health.v1.Health.
+// Статус вычисляется теми же проверками хранилища, что и /readyz,
+// одинаково для сервера в целом (пустое имя) и для сервисов метрик v1 и v2.
+type healthServer struct {
+	svc      *MetricsService
+	services []string
+	healthpb.UnimplementedHealthServer
+}
+
+// newHealthServer создает сервис здоровья для сервисов метрик
+func newHealthServer(svc *MetricsService) *healthServer {
+	return &healthServer{
+		svc:      svc,
+		services: []string{"", metricspb.Metrics_ServiceDesc.ServiceName, metricspbv2.Metrics_ServiceDesc.ServiceName},
+	}
+}
+
+// known сообщает, отвечает ли сервер за состояние сервиса
+func (h *healthServer) known(service string) bool {
+	for _, s := range h.services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// servingStatus выполняет проверки готовности хранилища
+func (h *healthServer) servingStatus(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	if h.svc.Readiness(ctx).Ready() {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// Check возвращает текущую готовность сервиса, для неизвестного сервиса - NotFound
+func (h *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if !h.known(req.GetService()) {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &healthpb.HealthCheckResponse{Status: h.servingStatus(ctx)}, nil
+}
+
+// List возвращает готовность всех сервисов сервера
+func (h *healthServer) List(ctx context.Context, _ *healthpb.HealthListRequest) (*healthpb.HealthListResponse, error) {
+	st := h.servingStatus(ctx)
+	res := &healthpb.HealthListResponse{Statuses: make(map[string]*healthpb.HealthCheckResponse, len(h.services))}
+	for _, s := range h.services {
+		res.Statuses[s] = &healthpb.HealthCheckResponse{Status: st}
+	}
+	return res, nil
+}
+
+// Watch отправляет готовность сервиса сразу и затем при каждом ее изменении,
+// проверяя хранилище раз в healthWatchInterval. Для неизвестного сервиса отправляет SERVICE_UNKNOWN.
+func (h *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ctx := stream.Context()
+	if !h.known(req.GetService()) {
+		if err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVICE_UNKNOWN}); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return status.Error(codes.Canceled, "stream has ended")
+	}
+
+	ticker := time.NewTicker(healthWatchInterval)
+	defer ticker.Stop()
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		if st := h.servingStatus(ctx); st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+		select {
+		case <-ctx.Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		case <-ticker.C:
+		}
+	}
+}
@@ -7,11 +7,17 @@ import (
 	"fmt"
 
 	metricspb "github.com/Soliard/go-tpl-metrics/internal/proto"
+	metricspbv2 "github.com/Soliard/go-tpl-metrics/internal/proto/v2"
 	"github.com/Soliard/go-tpl-metrics/internal/server/grpcinterceptor"
 	"github.com/Soliard/go-tpl-metrics/internal/store"
 	"github.com/Soliard/go-tpl-metrics/models"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // сжатие gzip на транспорте для v2
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -26,30 +32,67 @@ func (g *grpcServer) Updates(ctx context.Context, req *metricspb.BatchBytes) (*e
 	var metrics []*models.Metrics
 	if err := json.Unmarshal(req.Payload, &metrics); err != nil {
 		g.svc.Logger.Warn("cant decode body to metric slice", zap.Error(err))
-		return nil, fmt.Errorf("cant decode body to metrics: %w", err)
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("cant decode body to metrics: %v", err))
 	}
 
-	if err := g.svc.UpdateMetrics(ctx, metrics); err != nil {
-		if errors.Is(err, store.ErrInvalidMetricReceived) {
-			return nil, err
-		}
-		return nil, err
+	agentID, batchID := batchFromMetadata(ctx)
+	applied, err := g.svc.UpdateMetricsBatch(ctx, agentID, batchID, metrics)
+	if err != nil {
+		return nil, g.statusError(err)
+	}
+	if applied {
+		g.svc.recordGRPCUpdate(ctx, agentID, metrics...)
 	}
 
 	return &emptypb.Empty{}, nil
 }
 
-// NewGRPCServer создает gRPC сервер и регистрирует сервис
+// statusError преобразует ошибку обновления пакета в статус gRPC так же, как v2:
+// некорректные метрики - InvalidArgument, запрещенные агенту - PermissionDenied, остальное - Internal
+func (g *grpcServer) statusError(err error) error {
+	switch {
+	case errors.Is(err, ErrMetricNotAllowed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, store.ErrInvalidMetricReceived), errors.Is(err, store.ErrNotFound):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		g.svc.Logger.Error("grpc request failed", zap.Error(err))
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// batchFromMetadata извлекает идентификаторы агента и пакета из metadata x-agent-id и x-batch-id
+func batchFromMetadata(ctx context.Context) (agentID, batchID string) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", ""
+	}
+	if vals := md.Get("x-agent-id"); len(vals) > 0 {
+		agentID = vals[0]
+	}
+	if vals := md.Get("x-batch-id"); len(vals) > 0 {
+		batchID = vals[0]
+	}
+	return agentID, batchID
+}
+
+// NewGRPCServer создает gRPC сервер и регистрирует сервисы v1 и v2, а также grpc.health.v1 с готовностью хранилища.
+// v1 принимает сжатый и зашифрованный JSON от старых агентов,
+// v2 использует типизированные сообщения, сжатие и шифрование транспорта gRPC (opts).
 func NewGRPCServer(svc *MetricsService, opts ...grpc.ServerOption) *grpc.Server {
-	// chain: trusted subnet -> verify signature -> decrypt -> decompress
+	// chain: trusted subnet -> auth -> verify signature -> decompress -> decrypt
+	// агент шифрует JSON и затем сжимает результат, поэтому распаковка выполняется первой
 	chain := grpc.ChainUnaryInterceptor(
-		grpcinterceptor.TrustedSubnetInterceptor(svc.trustedSubnet, svc.Logger),
-		grpcinterceptor.VerifySignatureInterceptor(svc.signKey, svc.Logger),
-		grpcinterceptor.DecryptInterceptor(svc.privateKey, svc.Logger),
+		grpcinterceptor.TrustedSubnetInterceptor(svc.ipPolicy, svc.Logger),
+		grpcinterceptor.AuthInterceptor(svc.tokens, svc.Logger),
+		grpcinterceptor.VerifySignatureInterceptor(svc.signKeys, svc.replayGuard, svc.Logger),
 		grpcinterceptor.DecompressGzipInterceptor(svc.Logger),
+		grpcinterceptor.DecryptInterceptor(svc.privateKey, svc.Logger),
 	)
 	opts = append(opts, chain)
 	gs := grpc.NewServer(opts...)
 	metricspb.RegisterMetricsServer(gs, &grpcServer{svc: svc})
+	metricspbv2.RegisterMetricsServer(gs, &grpcServerV2{svc: svc})
+	healthpb.RegisterHealthServer(gs, newHealthServer(svc))
 	return gs
 }
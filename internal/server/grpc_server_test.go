@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+	metricspb "github.com/Soliard/go-tpl-metrics/internal/proto"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// brokenStorage - хранилище в памяти, отклоняющее запись пакетов ошибкой диска
+type brokenStorage struct {
+	store.Storage
+}
+
+func (s *brokenStorage) UpdateMetrics(ctx context.Context, metrics []*models.Metrics) error {
+	return errors.New("disk is full")
+}
+
+func TestGRPCServer_UpdatesStatus(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	_, err := storage.UpdateMetric(ctx, models.NewGaugeMetric("temp", 1))
+	require.NoError(t, err)
+	g := &grpcServer{svc: NewMetricsService(storage, &config.ServerConfig{}, zap.NewNop())}
+	broken := &grpcServer{svc: NewMetricsService(&brokenStorage{Storage: store.NewMemoryStorage()}, &config.ServerConfig{}, zap.NewNop())}
+
+	tests := []struct {
+		name    string
+		server  *grpcServer
+		payload string
+		code    codes.Code
+	}{
+		{name: "valid batch", server: g, payload: `[{"id":"requests","type":"counter","delta":1}]`, code: codes.OK},
+		{name: "invalid json", server: g, payload: `[{"id":`, code: codes.InvalidArgument},
+		{name: "type mismatch", server: g, payload: `[{"id":"temp","type":"counter","delta":1}]`, code: codes.InvalidArgument},
+		{name: "empty id", server: g, payload: `[{"id":"","type":"counter","delta":1}]`, code: codes.InvalidArgument},
+		{name: "unknown type", server: g, payload: `[{"id":"x","type":"summary","value":1}]`, code: codes.InvalidArgument},
+		{name: "storage error", server: broken, payload: `[{"id":"requests","type":"counter","delta":1}]`, code: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.server.Updates(ctx, &metricspb.BatchBytes{Payload: []byte(tt.payload)})
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	metricspbv2 "github.com/Soliard/go-tpl-metrics/internal/proto/v2"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServerV2 реализует metricspbv2.MetricsServer поверх MetricsService
+type grpcServerV2 struct {
+	svc *MetricsService
+	metricspbv2.UnimplementedMetricsServer
+}
+
+// UpdateMetric обновляет одну метрику и возвращает ее состояние после обновления
+func (g *grpcServerV2) UpdateMetric(ctx context.Context, req *metricspbv2.UpdateMetricRequest) (*metricspbv2.UpdateMetricResponse, error) {
+	metric, err := metricspbv2.ToModel(req.GetMetric())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// сообщения v2 не несут подпись метрики, запрос целиком подписывается в metadata
+	retMetric, err := g.svc.updateMetric(ctx, metric, hashNotCarried)
+	if err != nil {
+		return nil, g.statusError(err)
+	}
+	g.svc.recordGRPCUpdate(ctx, "", metric)
+	return &metricspbv2.UpdateMetricResponse{Metric: metricToProto(retMetric)}, nil
+}
+
+// Updates идемпотентно обновляет пакет метрик по agent_id и batch_id запроса
+func (g *grpcServerV2) Updates(ctx context.Context, req *metricspbv2.UpdatesRequest) (*metricspbv2.UpdatesResponse, error) {
+	metrics := make([]*models.Metrics, 0, len(req.GetMetrics()))
+	for _, pm := range req.GetMetrics() {
+		m, err := metricspbv2.ToModel(pm)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		metrics = append(metrics, m)
+	}
+	applied, err := g.svc.updateMetricsBatch(ctx, req.GetAgentId(), req.GetBatchId(), metrics, hashNotCarried)
+	if err != nil {
+		return nil, g.statusError(err)
+	}
+	if applied {
+		g.svc.recordGRPCUpdate(ctx, req.GetAgentId(), metrics...)
+	}
+	return &metricspbv2.UpdatesResponse{}, nil
+}
+
+// GetMetric возвращает серию метрики по имени и матчерам меток.
+// Если тип в запросе задан и не совпадает с типом метрики, возвращается NotFound.
+func (g *grpcServerV2) GetMetric(ctx context.Context, req *metricspbv2.GetMetricRequest) (*metricspbv2.GetMetricResponse, error) {
+	metric, err := g.svc.FindMetric(ctx, req.GetId(), req.GetLabels())
+	if err != nil {
+		return nil, g.statusError(err)
+	}
+	if req.GetType() != metricspbv2.MetricType_METRIC_TYPE_UNSPECIFIED && metricspbv2.TypeToModel(req.GetType()) != metric.MType {
+		return nil, status.Error(codes.NotFound, "metric with this name and type doesnt exists")
+	}
+	return &metricspbv2.GetMetricResponse{Metric: metricToProto(metric)}, nil
+}
+
+// ListMetrics возвращает все серии метрик
+func (g *grpcServerV2) ListMetrics(ctx context.Context, req *metricspbv2.ListMetricsRequest) (*metricspbv2.ListMetricsResponse, error) {
+	metrics, err := g.svc.GetAllMetrics(ctx)
+	if err != nil {
+		return nil, g.statusError(err)
+	}
+	resp := &metricspbv2.ListMetricsResponse{Metrics: make([]*metricspbv2.Metric, 0, len(metrics))}
+	for _, m := range metrics {
+		resp.Metrics = append(resp.Metrics, metricToProto(m))
+	}
+	return resp, nil
+}
+
+// DeleteMetric удаляет серию метрики, выбранную по имени и матчерам меток, как в GetMetric
+func (g *grpcServerV2) DeleteMetric(ctx context.Context, req *metricspbv2.DeleteMetricRequest) (*metricspbv2.DeleteMetricResponse, error) {
+	metric, err := g.svc.FindMetric(ctx, req.GetId(), req.GetLabels())
+	if err != nil {
+		return nil, g.statusError(err)
+	}
+	if req.GetType() != metricspbv2.MetricType_METRIC_TYPE_UNSPECIFIED && metricspbv2.TypeToModel(req.GetType()) != metric.MType {
+		return nil, status.Error(codes.NotFound, "metric with this name and type doesnt exists")
+	}
+	if err := g.svc.DeleteMetric(ctx, metric.SeriesKey()); err != nil {
+		return nil, g.statusError(err)
+	}
+	return &metricspbv2.DeleteMetricResponse{}, nil
+}
+
+// DeleteByPrefix удаляет все серии метрик, имя которых начинается с префикса
+func (g *grpcServerV2) DeleteByPrefix(ctx context.Context, req *metricspbv2.DeleteByPrefixRequest) (*metricspbv2.DeleteByPrefixResponse, error) {
+	deleted, err := g.svc.DeleteByPrefix(ctx, req.GetPrefix())
+	if err != nil {
+		return nil, g.statusError(err)
+	}
+	return &metricspbv2.DeleteByPrefixResponse{Deleted: int64(deleted)}, nil
+}
+
+// ResetCounter обнуляет counter серию, выбранную по имени и матчерам меток
+func (g *grpcServerV2) ResetCounter(ctx context.Context, req *metricspbv2.ResetCounterRequest) (*metricspbv2.ResetCounterResponse, error) {
+	metric, err := g.svc.FindMetric(ctx, req.GetId(), req.GetLabels())
+	if err != nil {
+		return nil, g.statusError(err)
+	}
+	if metric.MType != models.Counter {
+		return nil, status.Error(codes.InvalidArgument, "metric is not a counter")
+	}
+	retMetric, err := g.svc.ResetCounter(ctx, metric.SeriesKey())
+	if err != nil {
+		return nil, g.statusError(err)
+	}
+	return &metricspbv2.ResetCounterResponse{Metric: metricToProto(retMetric)}, nil
+}
+
+// statusError преобразует ошибку сервиса в статус gRPC
+func (g *grpcServerV2) statusError(err error) error {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return status.Error(codes.NotFound, "metric with this name doesnt exists")
+	case errors.Is(err, store.ErrInvalidMetricReceived):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrAmbiguousSeries):
+		return status.Error(codes.InvalidArgument, "several series match, specify labels")
+	case errors.Is(err, ErrEmptyPrefix):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrMetricNotAllowed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		g.svc.Logger.Error("grpc request failed", zap.Error(err))
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// metricToProto преобразует метрику в сообщение с текущим временем в качестве временной метки
+func metricToProto(m *models.Metrics) *metricspbv2.Metric {
+	pm := metricspbv2.FromModel(m)
+	pm.Timestamp = timestamppb.Now()
+	return pm
+}
@@ -0,0 +1,431 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	metricspbv2 "github.com/Soliard/go-tpl-metrics/internal/proto/v2"
+	"github.com/Soliard/go-tpl-metrics/internal/signer"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/internal/tlsutil"
+	"github.com/Soliard/go-tpl-metrics/internal/tlsutil/tlstest"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func setupTestGRPCServer(t *testing.T, cfg config.ServerConfig) metricspbv2.MetricsClient {
+	logger, err := logger.New("info")
+	require.NoError(t, err)
+	service := NewMetricsService(store.NewMemoryStorage(), &cfg, logger)
+	gs := NewGRPCServer(service)
+	lis := bufconn.Listen(1 << 20)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return metricspbv2.NewMetricsClient(conn)
+}
+
+func TestGRPCServerV2(t *testing.T) {
+	client := setupTestGRPCServer(t, config.ServerConfig{})
+	ctx := context.Background()
+
+	hist := models.NewHistogram([]float64{1, 2})
+	hist.Observe(1.5)
+	_, err := client.Updates(ctx, &metricspbv2.UpdatesRequest{
+		Metrics: []*metricspbv2.Metric{
+			metricspbv2.FromModel(models.NewCounterMetric("requests", 3)),
+			metricspbv2.FromModel(models.NewGaugeMetric("temp", 36.6)),
+			metricspbv2.FromModel(models.NewHistogramMetric("latency", hist)),
+		},
+		AgentId: "agent",
+		BatchId: "1",
+	})
+	require.NoError(t, err)
+
+	labeled := models.NewCounterMetric("requests", 2)
+	labeled.Labels = map[string]string{"host": "a"}
+	resp, err := client.UpdateMetric(ctx, &metricspbv2.UpdateMetricRequest{Metric: metricspbv2.FromModel(labeled)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), resp.GetMetric().GetDelta())
+	assert.NotNil(t, resp.GetMetric().GetTimestamp())
+
+	resp, err = client.UpdateMetric(ctx, &metricspbv2.UpdateMetricRequest{Metric: metricspbv2.FromModel(models.NewCounterMetric("requests", 4))})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), resp.GetMetric().GetDelta())
+
+	tests := []struct {
+		name    string
+		req     *metricspbv2.GetMetricRequest
+		code    codes.Code
+		checkFn func(t *testing.T, m *metricspbv2.Metric)
+	}{
+		{
+			name: "gauge",
+			req:  &metricspbv2.GetMetricRequest{Id: "temp", Type: metricspbv2.MetricType_METRIC_TYPE_GAUGE},
+			code: codes.OK,
+			checkFn: func(t *testing.T, m *metricspbv2.Metric) {
+				assert.Equal(t, 36.6, m.GetValue())
+			},
+		},
+		{
+			name: "labeled counter",
+			req:  &metricspbv2.GetMetricRequest{Id: "requests", Labels: map[string]string{"host": "a"}},
+			code: codes.OK,
+			checkFn: func(t *testing.T, m *metricspbv2.Metric) {
+				assert.Equal(t, int64(2), m.GetDelta())
+				assert.Equal(t, map[string]string{"host": "a"}, m.GetLabels())
+			},
+		},
+		{
+			name: "histogram",
+			req:  &metricspbv2.GetMetricRequest{Id: "latency"},
+			code: codes.OK,
+			checkFn: func(t *testing.T, m *metricspbv2.Metric) {
+				assert.Equal(t, []int64{0, 1, 0}, m.GetHistogram().GetCounts())
+				assert.Equal(t, int64(1), m.GetHistogram().GetCount())
+			},
+		},
+		{
+			name: "type mismatch",
+			req:  &metricspbv2.GetMetricRequest{Id: "temp", Type: metricspbv2.MetricType_METRIC_TYPE_COUNTER},
+			code: codes.NotFound,
+		},
+		{
+			name: "unknown metric",
+			req:  &metricspbv2.GetMetricRequest{Id: "missing"},
+			code: codes.NotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.GetMetric(ctx, tt.req)
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.checkFn != nil {
+				tt.checkFn(t, resp.GetMetric())
+			}
+		})
+	}
+
+	list, err := client.ListMetrics(ctx, &metricspbv2.ListMetricsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.GetMetrics(), 4)
+}
+
+func TestGRPCServerV2_InvalidMetric(t *testing.T) {
+	client := setupTestGRPCServer(t, config.ServerConfig{})
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		metric *metricspbv2.Metric
+	}{
+		{
+			name:   "no value",
+			metric: &metricspbv2.Metric{Id: "m", Type: metricspbv2.MetricType_METRIC_TYPE_GAUGE},
+		},
+		{
+			name: "value does not match type",
+			metric: &metricspbv2.Metric{
+				Id:   "m",
+				Type: metricspbv2.MetricType_METRIC_TYPE_COUNTER,
+				Data: &metricspbv2.Metric_Value{Value: 1.5},
+			},
+		},
+		{
+			name: "invalid histogram",
+			metric: &metricspbv2.Metric{
+				Id:   "m",
+				Type: metricspbv2.MetricType_METRIC_TYPE_HISTOGRAM,
+				Data: &metricspbv2.Metric_Histogram{Histogram: &metricspbv2.Histogram{Bounds: []float64{1}, Counts: []int64{1}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.UpdateMetric(ctx, &metricspbv2.UpdateMetricRequest{Metric: tt.metric})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func TestGRPCServerV2_Signature(t *testing.T) {
+	key := []byte("secret")
+	client := setupTestGRPCServer(t, config.ServerConfig{SignKey: string(key)})
+
+	req := &metricspbv2.UpdatesRequest{
+		Metrics: []*metricspbv2.Metric{metricspbv2.FromModel(models.NewGaugeMetric("temp", 1.5))},
+	}
+	body, err := metricspbv2.SigningPayload(req)
+	require.NoError(t, err)
+	payload := signer.SignedPayload(metricspbv2.Metrics_Updates_FullMethodName, "", "", body)
+
+	tests := []struct {
+		name string
+		sign string
+		code codes.Code
+	}{
+		{name: "valid signature", sign: signer.EncodeSign(signer.Sign(payload, key)), code: codes.OK},
+		{name: "wrong key", sign: signer.EncodeSign(signer.Sign(payload, []byte("other"))), code: codes.PermissionDenied},
+		{
+			name: "signature of another method",
+			sign: signer.EncodeSign(signer.Sign(signer.SignedPayload(metricspbv2.Metrics_UpdateMetric_FullMethodName, "", "", body), key)),
+			code: codes.PermissionDenied,
+		},
+		{name: "missing signature", code: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.sign != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "HashSHA256", tt.sign)
+			}
+			_, err := client.Updates(ctx, req)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+
+	// чтение не требует подписи
+	_, err = client.ListMetrics(context.Background(), &metricspbv2.ListMetricsRequest{})
+	assert.NoError(t, err)
+	// удаление требует подписи
+	_, err = client.DeleteByPrefix(context.Background(), &metricspbv2.DeleteByPrefixRequest{Prefix: "temp"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCServerV2_SignatureKeyID(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(keysFile,
+		[]byte(`{"active":"new","keys":[{"id":"old","key":"old secret"},{"id":"new","key":"new secret"}]}`), 0o600))
+	client := setupTestGRPCServer(t, config.ServerConfig{SignKeysFile: keysFile})
+
+	req := &metricspbv2.UpdatesRequest{
+		Metrics: []*metricspbv2.Metric{metricspbv2.FromModel(models.NewGaugeMetric("temp", 1.5))},
+	}
+	body, err := metricspbv2.SigningPayload(req)
+	require.NoError(t, err)
+	payload := signer.SignedPayload(metricspbv2.Metrics_Updates_FullMethodName, "", "", body)
+
+	tests := []struct {
+		name  string
+		key   string
+		keyID string
+		code  codes.Code
+	}{
+		{name: "active key", key: "new secret", keyID: "new", code: codes.OK},
+		{name: "previous key", key: "old secret", keyID: "old", code: codes.OK},
+		{name: "without key id", key: "old secret", code: codes.OK},
+		{name: "key id mismatch", key: "old secret", keyID: "new", code: codes.PermissionDenied},
+		{name: "unknown key id", key: "old secret", keyID: "retired", code: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(),
+				"HashSHA256", signer.EncodeSign(signer.Sign(payload, []byte(tt.key))))
+			if tt.keyID != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, signer.KeyIDHeader, tt.keyID)
+			}
+			_, err := client.Updates(ctx, req)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func TestGRPCServerV2_Replay(t *testing.T) {
+	key := []byte("secret")
+	client := setupTestGRPCServer(t, config.ServerConfig{SignKey: string(key), SignMaxSkewSeconds: 60, NonceCacheSize: 10})
+
+	req := &metricspbv2.UpdatesRequest{
+		Metrics: []*metricspbv2.Metric{metricspbv2.FromModel(models.NewCounterMetric("requests", 1))},
+	}
+	payload, err := metricspbv2.SigningPayload(req)
+	require.NoError(t, err)
+	signedCtx := func(timestamp, nonce string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(),
+			"HashSHA256", signer.EncodeSign(signer.Sign(signer.SignedPayload(metricspbv2.Metrics_Updates_FullMethodName, timestamp, nonce, payload), key)),
+			signer.TimestampHeader, timestamp,
+			signer.NonceHeader, nonce)
+	}
+
+	timestamp, nonce, err := signer.NewNonce()
+	require.NoError(t, err)
+	_, err = client.Updates(signedCtx(timestamp, nonce), req)
+	require.NoError(t, err)
+
+	// повтор перехваченного запроса не применяет приращение счетчика еще раз
+	_, err = client.Updates(signedCtx(timestamp, nonce), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	_, err = client.Updates(signedCtx(stale, "stale"), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// подпись без времени и одноразового значения не принимается
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "HashSHA256",
+		signer.EncodeSign(signer.Sign(signer.SignedPayload(metricspbv2.Metrics_Updates_FullMethodName, "", "", payload), key)))
+	_, err = client.Updates(ctx, req)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	got, err := client.GetMetric(context.Background(), &metricspbv2.GetMetricRequest{Id: "requests", Type: metricspbv2.MetricType_METRIC_TYPE_COUNTER})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), got.GetMetric().GetDelta())
+}
+
+func TestGRPCServerV2_MutualTLS(t *testing.T) {
+	log, err := logger.New("info")
+	require.NoError(t, err)
+	ca := tlstest.NewCA(t)
+	certFile, keyFile := ca.ServerCert("server")
+	serverTLS, err := tlsutil.NewServerConfig(certFile, keyFile, ca.CertFile, log)
+	require.NoError(t, err)
+
+	gs := NewGRPCServer(NewMetricsService(store.NewMemoryStorage(), &config.ServerConfig{}, log),
+		grpc.Creds(credentials.NewTLS(serverTLS)))
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	clientCert, clientKey := ca.ClientCert("agent")
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		wantErr  bool
+	}{
+		{name: "client certificate", certFile: clientCert, keyFile: clientKey},
+		{name: "without client certificate", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientTLS, err := tlsutil.NewClientConfig(ca.CertFile, tt.certFile, tt.keyFile, log)
+			require.NoError(t, err)
+			conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = metricspbv2.NewMetricsClient(conn).Updates(context.Background(), &metricspbv2.UpdatesRequest{
+				Metrics: []*metricspbv2.Metric{metricspbv2.FromModel(models.NewGaugeMetric("temp", 1))},
+			})
+			if tt.wantErr {
+				assert.Equal(t, codes.Unavailable, status.Code(err))
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestGRPCServerV2_Delete(t *testing.T) {
+	client := setupTestGRPCServer(t, config.ServerConfig{})
+	ctx := context.Background()
+
+	_, err := client.Updates(ctx, &metricspbv2.UpdatesRequest{
+		Metrics: []*metricspbv2.Metric{
+			metricspbv2.FromModel(models.NewCounterMetric("requests", 3)),
+			metricspbv2.FromModel(models.NewGaugeMetric("temp", 36.6)),
+			metricspbv2.FromModel(models.NewGaugeMetric("runtime.alloc", 1)),
+			metricspbv2.FromModel(models.NewGaugeMetric("runtime.frees", 2)),
+		},
+	})
+	require.NoError(t, err)
+
+	reset, err := client.ResetCounter(ctx, &metricspbv2.ResetCounterRequest{Id: "requests"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), reset.GetMetric().GetDelta())
+	_, err = client.ResetCounter(ctx, &metricspbv2.ResetCounterRequest{Id: "temp"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteMetric(ctx, &metricspbv2.DeleteMetricRequest{Id: "temp", Type: metricspbv2.MetricType_METRIC_TYPE_COUNTER})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = client.DeleteMetric(ctx, &metricspbv2.DeleteMetricRequest{Id: "temp", Type: metricspbv2.MetricType_METRIC_TYPE_GAUGE})
+	require.NoError(t, err)
+	_, err = client.DeleteMetric(ctx, &metricspbv2.DeleteMetricRequest{Id: "temp"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.DeleteByPrefix(ctx, &metricspbv2.DeleteByPrefixRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	deleted, err := client.DeleteByPrefix(ctx, &metricspbv2.DeleteByPrefixRequest{Prefix: "runtime."})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted.GetDeleted())
+
+	list, err := client.ListMetrics(ctx, &metricspbv2.ListMetricsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.GetMetrics(), 1)
+}
+
+func TestGRPCServerV2_Auth(t *testing.T) {
+	client := setupTestGRPCServer(t, config.ServerConfig{AuthTokensFile: writeTokensFile(t)})
+	update := func(name string) *metricspbv2.UpdateMetricRequest {
+		return &metricspbv2.UpdateMetricRequest{Metric: metricspbv2.FromModel(models.NewGaugeMetric(name, 1))}
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		call  func(ctx context.Context) error
+		code  codes.Code
+	}{
+		{name: "without token", call: func(ctx context.Context) error {
+			_, err := client.ListMetrics(ctx, &metricspbv2.ListMetricsRequest{})
+			return err
+		}, code: codes.Unauthenticated},
+		{name: "unknown token", token: "stolen", call: func(ctx context.Context) error {
+			_, err := client.ListMetrics(ctx, &metricspbv2.ListMetricsRequest{})
+			return err
+		}, code: codes.Unauthenticated},
+		{name: "read with read token", token: "dashboard token", call: func(ctx context.Context) error {
+			_, err := client.ListMetrics(ctx, &metricspbv2.ListMetricsRequest{})
+			return err
+		}, code: codes.OK},
+		{name: "write with read token", token: "dashboard token", call: func(ctx context.Context) error {
+			_, err := client.UpdateMetric(ctx, update("web1_cpu"))
+			return err
+		}, code: codes.PermissionDenied},
+		{name: "write with write token", token: "web1 token", call: func(ctx context.Context) error {
+			_, err := client.UpdateMetric(ctx, update("web1_cpu"))
+			return err
+		}, code: codes.OK},
+		{name: "write outside prefixes", token: "web1 token", call: func(ctx context.Context) error {
+			_, err := client.UpdateMetric(ctx, update("web2_cpu"))
+			return err
+		}, code: codes.PermissionDenied},
+		{name: "delete outside prefixes", token: "web1 token", call: func(ctx context.Context) error {
+			_, err := client.DeleteByPrefix(ctx, &metricspbv2.DeleteByPrefixRequest{Prefix: "web"})
+			return err
+		}, code: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+tt.token)
+			}
+			assert.Equal(t, tt.code, status.Code(tt.call(ctx)))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package grpcinterceptor
+
+import (
+	"context"
+	"errors"
+
+	metricspb "github.com/Soliard/go-tpl-metrics/internal/proto"
+	metricspbv2 "github.com/Soliard/go-tpl-metrics/internal/proto/v2"
+	"github.com/Soliard/go-tpl-metrics/internal/server/auth"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthInterceptor проверяет токен агента из metadata authorization вида "Bearer <token>" и добавляет агента в контекст.
+// Запросы на запись и удаление требуют области доступа write, остальные - read.
+// Без реестра токенов запросы пропускаются без проверки, проверки здоровья grpc.health.v1 не требуют токена.
+func AuthInterceptor(registry auth.Registry, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	if registry == nil {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(ctx, req)
+		}
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isHealthCheck(info) {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		token, err := auth.BearerToken(firstValue(md, "authorization"))
+		var id *auth.Identity
+		if err == nil {
+			id, err = registry.Authenticate(ctx, token)
+		}
+		if err != nil {
+			if !errors.Is(err, auth.ErrMissingToken) && !errors.Is(err, auth.ErrInvalidToken) {
+				logger.Error("cant check agent token", zap.String("method", info.FullMethod), zap.Error(err))
+				return nil, status.Error(codes.Internal, "cant check token")
+			}
+			logger.Warn("grpc request without valid token rejected",
+				zap.String("method", info.FullMethod),
+				zap.Error(err))
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		scope := requiredScope(req)
+		if !id.Allows(scope) {
+			logger.Warn("grpc request outside of token scope rejected",
+				zap.String("method", info.FullMethod),
+				zap.String("agent", id.Name),
+				zap.String("scope", string(scope)))
+			return nil, status.Error(codes.PermissionDenied, "token scope does not allow "+string(scope))
+		}
+		return handler(auth.WithIdentity(ctx, id), req)
+	}
+}
+
+// requiredScope возвращает область доступа, необходимую для запроса
+func requiredScope(req interface{}) auth.Scope {
+	switch req.(type) {
+	case *metricspb.BatchBytes,
+		*metricspbv2.UpdateMetricRequest, *metricspbv2.UpdatesRequest,
+		*metricspbv2.DeleteMetricRequest, *metricspbv2.DeleteByPrefixRequest, *metricspbv2.ResetCounterRequest:
+		return auth.ScopeWrite
+	default:
+		return auth.ScopeRead
+	}
+}
@@ -0,0 +1,14 @@
+package grpcinterceptor
+
+import (
+	"strings"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// isHealthCheck сообщает, является ли вызов проверкой grpc.health.v1.
+// Как и HTTP /ping, проверки здоровья доступны без токена и с любых адресов.
+func isHealthCheck(info *grpc.UnaryServerInfo) bool {
+	return strings.HasPrefix(info.FullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
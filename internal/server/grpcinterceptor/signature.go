@@ -4,23 +4,39 @@ import (
 	"context"
 
 	metricspb "github.com/Soliard/go-tpl-metrics/internal/proto"
+	metricspbv2 "github.com/Soliard/go-tpl-metrics/internal/proto/v2"
 	"github.com/Soliard/go-tpl-metrics/internal/signer"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-func VerifySignatureInterceptor(signKey []byte, logger *zap.Logger) grpc.UnaryServerInterceptor {
-	if !signer.SignKeyExists(signKey) {
+// VerifySignatureInterceptor проверяет подпись HashSHA256 из metadata для запросов на запись и удаление.
+// Ключ выбирается по идентификатору из metadata HashSHA256-KeyID, без него подходит любой ключ набора.
+// Для v1 подписывается полезная нагрузка BatchBytes, для v2 - детерминированно сериализованный запрос.
+// Полное имя метода входит в подпись, чтобы подпись одного вызова нельзя было применить к другому.
+// Время подписи и одноразовое значение из metadata входят в подпись и проверяются защитой от повторов, если она включена.
+func VerifySignatureInterceptor(keys *signer.Keyring, replay *signer.ReplayGuard, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	if !keys.Enabled() {
 		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 			return handler(ctx, req)
 		}
 	}
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		batch, ok := req.(*metricspb.BatchBytes)
-		if !ok {
+		var payload []byte
+		switch r := req.(type) {
+		case *metricspb.BatchBytes:
+			payload = r.Payload
+		case *metricspbv2.UpdateMetricRequest:
+			payload, _ = metricspbv2.SigningPayload(r)
+		case *metricspbv2.UpdatesRequest:
+			payload, _ = metricspbv2.SigningPayload(r)
+		case *metricspbv2.DeleteMetricRequest, *metricspbv2.DeleteByPrefixRequest, *metricspbv2.ResetCounterRequest:
+			payload, _ = metricspbv2.SigningPayload(r.(proto.Message))
+		default:
 			return handler(ctx, req)
 		}
 		md, _ := metadata.FromIncomingContext(ctx)
@@ -28,10 +44,37 @@ func VerifySignatureInterceptor(signKey []byte, logger *zap.Logger) grpc.UnarySe
 		if len(vals) == 0 {
 			return nil, status.Error(codes.InvalidArgument, "missing signature")
 		}
+		keyID := firstValue(md, signer.KeyIDHeader)
+		timestamp, nonce := firstValue(md, signer.TimestampHeader), firstValue(md, signer.NonceHeader)
+		if replay.Enabled() && (timestamp == "" || nonce == "") {
+			return nil, status.Error(codes.InvalidArgument, signer.ErrMissingNonce.Error())
+		}
 		sig, err := signer.DecodeSign(vals[0])
-		if err != nil || !signer.Verify(batch.Payload, sig, signKey) {
+		if err == nil {
+			err = keys.Verify(signer.SignedPayload(info.FullMethod, timestamp, nonce, payload), keyID, sig)
+		}
+		if err != nil {
+			logger.Warn("grpc request signature verification failed",
+				zap.String("method", info.FullMethod),
+				zap.String("key_id", keyID),
+				zap.Error(err))
 			return nil, status.Error(codes.PermissionDenied, "signature verification failed")
 		}
+		if err := replay.Check(timestamp, nonce); err != nil {
+			logger.Warn("grpc replayed or stale request rejected",
+				zap.String("method", info.FullMethod),
+				zap.String("timestamp", timestamp),
+				zap.Error(err))
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
 		return handler(ctx, req)
 	}
 }
+
+// firstValue возвращает первое значение ключа metadata или пустую строку
+func firstValue(md metadata.MD, key string) string {
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
@@ -2,7 +2,6 @@ package grpcinterceptor
 
 import (
 	"context"
-	"net"
 
 	"github.com/Soliard/go-tpl-metrics/internal/netutil"
 	"go.uber.org/zap"
@@ -11,23 +10,25 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-func TrustedSubnetInterceptor(cidr string, logger *zap.Logger) grpc.UnaryServerInterceptor {
-	if cidr == "" {
+// TrustedSubnetInterceptor пропускает только запросы с адресов, разрешенных политикой.
+// Адрес клиента берется из peer, а от доверенных прокси - из metadata x-forwarded-for или x-real-ip.
+// Проверки здоровья grpc.health.v1 пропускаются без проверки адреса.
+func TrustedSubnetInterceptor(policy *netutil.IPPolicy, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	if !policy.Enabled() {
 		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 			return handler(ctx, req)
 		}
 	}
-	_, ipnet, err := net.ParseCIDR(cidr)
-	if err != nil || ipnet == nil {
-		logger.Warn("invalid trusted_subnet CIDR, interceptor bypassed", zap.String("cidr", cidr), zap.Error(err))
-		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isHealthCheck(info) {
 			return handler(ctx, req)
 		}
-	}
-	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		ip := netutil.ExtractIPFromGRPCContext(ctx)
-		if ip == nil || !ipnet.Contains(ip) {
-			return nil, status.Error(codes.PermissionDenied, "ip not in trusted subnet")
+		ip := netutil.ExtractIPFromGRPCContext(ctx, policy.Proxies())
+		if !policy.Allowed(ip) {
+			logger.Warn("grpc request from not allowed ip rejected",
+				zap.String("method", info.FullMethod),
+				zap.Stringer("ip", ip))
+			return nil, status.Error(codes.PermissionDenied, "ip not allowed")
 		}
 		return handler(ctx, req)
 	}
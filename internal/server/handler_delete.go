@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"go.uber.org/zap"
+)
+
+// DeleteRequest - тело запроса пакетного удаления POST /delete/.
+// Серии в Metrics и Reset задаются точно: именем, типом и полным набором меток, значения игнорируются.
+type DeleteRequest struct {
+	Metrics  []*models.Metrics `json:"metrics,omitempty"`  // удаляемые серии
+	Prefixes []string          `json:"prefixes,omitempty"` // удалить все серии метрик с именем, начинающимся с префикса
+	Reset    []*models.Metrics `json:"reset,omitempty"`    // counter серии, значение которых обнуляется
+}
+
+// DeleteResponse - результат пакетного удаления
+type DeleteResponse struct {
+	Deleted int `json:"deleted"` // количество удаленных серий
+	Reset   int `json:"reset"`   // количество обнуленных counter серий
+}
+
+// validate проверяет запрос целиком до внесения изменений
+func (r *DeleteRequest) validate() error {
+	for _, m := range r.Metrics {
+		if m == nil || m.ID == "" {
+			return store.ErrInvalidMetricReceived
+		}
+		switch m.MType {
+		case models.Gauge, models.Counter, models.Histogram:
+		default:
+			return store.ErrInvalidMetricReceived
+		}
+	}
+	for _, p := range r.Prefixes {
+		if p == "" {
+			return ErrEmptyPrefix
+		}
+	}
+	for _, m := range r.Reset {
+		if m == nil || m.ID == "" || (m.MType != "" && m.MType != models.Counter) {
+			return store.ErrInvalidMetricReceived
+		}
+	}
+	return nil
+}
+
+// checkAllowed проверяет, что агент из контекста может изменять все серии и префиксы запроса
+func (r *DeleteRequest) checkAllowed(ctx context.Context) error {
+	for _, m := range r.Metrics {
+		if err := checkMetricAllowed(ctx, m.ID); err != nil {
+			return err
+		}
+	}
+	for _, p := range r.Prefixes {
+		if err := checkMetricAllowed(ctx, p); err != nil {
+			return err
+		}
+	}
+	for _, m := range r.Reset {
+		if err := checkMetricAllowed(ctx, m.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteBatch удаляет и обнуляет серии из запроса.
+// Отсутствующие серии и серии другого типа пропускаются, поэтому повтор запроса безопасен.
+// Если хотя бы одна серия или префикс не разрешены агенту, запрос отклоняется целиком.
+func (s *MetricsService) DeleteBatch(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	if err := req.checkAllowed(ctx); err != nil {
+		return nil, err
+	}
+	resp := &DeleteResponse{}
+	for _, m := range req.Metrics {
+		key := m.SeriesKey()
+		existed, err := s.GetMetric(ctx, key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return resp, err
+		}
+		if existed.MType != m.MType {
+			continue
+		}
+		err = s.DeleteMetric(ctx, key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return resp, err
+		}
+		resp.Deleted++
+	}
+	for _, p := range req.Prefixes {
+		deleted, err := s.DeleteByPrefix(ctx, p)
+		resp.Deleted += deleted
+		if err != nil {
+			return resp, err
+		}
+	}
+	for _, m := range req.Reset {
+		_, err := s.ResetCounter(ctx, m.SeriesKey())
+		if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrInvalidMetricReceived) {
+			continue
+		}
+		if err != nil {
+			return resp, err
+		}
+		resp.Reset++
+	}
+	return resp, nil
+}
+
+// DeleteViaURLHandler удаляет серию метрики вместе с историей.
+// Формат: DELETE /value/{type}/{name}[?label=key=value...]
+// Серия выбирается так же, как в GET /value/{type}/{name}.
+// Как и POST /delete, требует подписи запроса и адреса из доверенной подсети.
+func (s *MetricsService) DeleteViaURLHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logger := logger.LoggerFromCtx(ctx, s.Logger)
+	m := parseMetricURL(req)
+
+	if m.MType == "" || m.ID == "" {
+		http.Error(res, `type or name cannot be empty`, http.StatusBadRequest)
+		return
+	}
+	matchers, err := parseLabelParams(req.URL.Query())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metric, err := s.FindMetric(ctx, m.ID, matchers)
+	if err == nil && metric.MType != m.MType {
+		err = store.ErrNotFound
+	}
+	if err == nil {
+		err = s.DeleteMetric(ctx, metric.SeriesKey())
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(res, `metric with this name doesnt exists`, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, ErrAmbiguousSeries) {
+			http.Error(res, `several series match, specify labels`, http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, ErrMetricNotAllowed) {
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
+		logger.Error("cant delete metric", zap.String("id", m.ID), zap.Error(err))
+		http.Error(res, `error while deleting metric`, http.StatusInternalServerError)
+		return
+	}
+	res.WriteHeader(http.StatusOK)
+}
+
+// DeleteHandler обрабатывает пакетное удаление и обнуление метрик через JSON.
+// Требует подписи запроса. Принимает DeleteRequest и возвращает DeleteResponse.
+func (s *MetricsService) DeleteHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logger := logger.LoggerFromCtx(ctx, s.Logger)
+	if req.Header.Get("Content-Type") != "application/json" {
+		http.Error(res, "only application/json content accepting", http.StatusBadRequest)
+		return
+	}
+	defer req.Body.Close()
+
+	var deleteReq DeleteRequest
+	if err := json.NewDecoder(req.Body).Decode(&deleteReq); err != nil {
+		logger.Warn("cant decode body to delete request", zap.Error(err))
+		http.Error(res, "cant decode body to delete request", http.StatusBadRequest)
+		return
+	}
+	resp, err := s.DeleteBatch(ctx, &deleteReq)
+	if err != nil {
+		if errors.Is(err, ErrMetricNotAllowed) {
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, store.ErrInvalidMetricReceived) || errors.Is(err, ErrEmptyPrefix) {
+			http.Error(res, "invalid delete request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Error("error while batch metrics delete", zap.Error(err))
+		http.Error(res, "error while batch metrics delete", http.StatusInternalServerError)
+		return
+	}
+
+	retBody, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(res, "cant return delete result", http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	res.Write(retBody)
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+	"github.com/Soliard/go-tpl-metrics/internal/signer"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestDeleteViaURLHandler(t *testing.T) {
+	ts, service := setupTestServer(t)
+	client := resty.New()
+	defer ts.Close()
+	ctx := context.Background()
+
+	require.NoError(t, service.UpdateMetrics(ctx, []*models.Metrics{
+		models.NewGaugeMetric("temp", 1),
+		{ID: "requests", MType: models.Counter, Delta: models.PInt(1), Labels: map[string]string{"host": "a"}},
+		{ID: "requests", MType: models.Counter, Delta: models.PInt(2), Labels: map[string]string{"host": "b"}},
+	}))
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+	}{
+		{name: "wrong type", url: "/value/counter/temp", expectedCode: http.StatusNotFound},
+		{name: "gauge", url: "/value/gauge/temp", expectedCode: http.StatusOK},
+		{name: "already deleted", url: "/value/gauge/temp", expectedCode: http.StatusNotFound},
+		{name: "ambiguous series", url: "/value/counter/requests", expectedCode: http.StatusBadRequest},
+		{name: "series by labels", url: "/value/counter/requests?label=host=a", expectedCode: http.StatusOK},
+		{name: "invalid label", url: "/value/counter/requests?label=host", expectedCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.R().Delete(ts.URL + tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode())
+		})
+	}
+
+	all, err := service.GetAllMetrics(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "b", all[0].Labels["host"])
+}
+
+func TestDeleteViaURLHandlerSignature(t *testing.T) {
+	key := []byte("secret")
+	service := NewMetricsService(store.NewMemoryStorage(), &config.ServerConfig{SignKey: string(key)}, zap.NewNop())
+	ts := httptest.NewServer(MetricRouter(service))
+	defer ts.Close()
+	client := resty.New()
+	ctx := context.Background()
+	require.NoError(t, service.UpdateMetrics(ctx, []*models.Metrics{models.NewGaugeMetric("temp", 1)}))
+
+	resp, err := client.R().Delete(ts.URL + "/value/gauge/temp")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode(), "unsigned delete is rejected")
+	_, err = service.GetMetric(ctx, "temp")
+	require.NoError(t, err, "series is kept")
+
+	sign := func(path string) string {
+		return signer.EncodeSign(signer.Sign(signer.SignedPayload(signer.HTTPRequestLine(http.MethodDelete, path), "", "", nil), key))
+	}
+	resp, err = client.R().
+		SetHeader("HashSHA256", sign("/value/gauge/other")).
+		Delete(ts.URL + "/value/gauge/temp")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode(), "signature of another series is rejected")
+	_, err = service.GetMetric(ctx, "temp")
+	require.NoError(t, err, "series is kept")
+
+	resp, err = client.R().
+		SetHeader("HashSHA256", sign("/value/gauge/temp")).
+		Delete(ts.URL + "/value/gauge/temp")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	_, err = service.GetMetric(ctx, "temp")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestDeleteHandler(t *testing.T) {
+	ts, service := setupTestServer(t)
+	client := resty.New()
+	defer ts.Close()
+	ctx := context.Background()
+
+	require.NoError(t, service.UpdateMetrics(ctx, []*models.Metrics{
+		models.NewGaugeMetric("temp", 1),
+		models.NewGaugeMetric("runtime.alloc", 1),
+		models.NewGaugeMetric("runtime.frees", 1),
+		models.NewCounterMetric("requests", 10),
+		models.NewCounterMetric("errors", 3),
+	}))
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expected     DeleteResponse
+	}{
+		{
+			name:         "delete and reset",
+			body:         `{"metrics":[{"id":"temp","type":"gauge"},{"id":"errors","type":"gauge"}],"prefixes":["runtime."],"reset":[{"id":"requests"},{"id":"missing"}]}`,
+			expectedCode: http.StatusOK,
+			expected:     DeleteResponse{Deleted: 3, Reset: 1},
+		},
+		{
+			name:         "repeated request",
+			body:         `{"metrics":[{"id":"temp","type":"gauge"}],"prefixes":["runtime."]}`,
+			expectedCode: http.StatusOK,
+			expected:     DeleteResponse{},
+		},
+		{
+			name:         "empty prefix",
+			body:         `{"prefixes":[""]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unknown type",
+			body:         `{"metrics":[{"id":"errors","type":"summary"}]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "reset not counter",
+			body:         `{"reset":[{"id":"temp","type":"gauge"}]}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "invalid json",
+			body:         `{"metrics":`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(tt.body).
+				Post(ts.URL + "/delete/")
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedCode, resp.StatusCode())
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			var got DeleteResponse
+			require.NoError(t, json.Unmarshal(resp.Body(), &got))
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+
+	// невалидный запрос отклоняется целиком
+	_, err := service.GetMetric(ctx, "errors")
+	require.NoError(t, err)
+	m, err := service.GetMetric(ctx, "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), *m.Delta)
+	_, err = service.GetMetric(ctx, "temp")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"go.uber.org/zap"
+)
+
+// defaultHistoryRange задает интервал истории, если параметр from не указан
+const defaultHistoryRange = time.Hour
+
+// historyQuery содержит разобранные параметры запроса истории
+type historyQuery struct {
+	from time.Time
+	to   time.Time
+	step time.Duration
+}
+
+// HistoryHandler обрабатывает запрос истории значений метрики.
+// Формат: GET /history/{type}/{name}?from=&to=&step=[&label=key=value...]
+// from и to принимаются в RFC3339 или unix секундах, step - в формате time.Duration (например 1m).
+// Возвращает JSON массив отсчетов в хронологическом порядке.
+func (s *MetricsService) HistoryHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logger := logger.LoggerFromCtx(ctx, s.Logger)
+	m := parseMetricURL(req)
+
+	if m.MType == "" || m.ID == "" {
+		http.Error(res, `type or name cannot be empty`, http.StatusBadRequest)
+		return
+	}
+	q, err := parseHistoryQuery(req.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matchers, err := parseLabelParams(req.URL.Query())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metric, err := s.FindMetric(ctx, m.ID, matchers)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(res, `metric with this name doesnt exists`, http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, ErrAmbiguousSeries) {
+			http.Error(res, `several series match, specify labels`, http.StatusBadRequest)
+			return
+		}
+		http.Error(res, `error while getting metric`, http.StatusInternalServerError)
+		return
+	}
+	if metric.MType != m.MType {
+		http.Error(res, `invalid metric type`, http.StatusNotFound)
+		return
+	}
+
+	samples, err := s.GetHistory(ctx, metric.SeriesKey(), q.from, q.to, q.step)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(res, `metric with this name doesnt exists`, http.StatusNotFound)
+			return
+		}
+		logger.Error("error while getting metric history", zap.String("id", m.ID), zap.Error(err))
+		http.Error(res, `error while getting metric history`, http.StatusInternalServerError)
+		return
+	}
+
+	retBody, err := json.Marshal(samples)
+	if err != nil {
+		logger.Error("cant marshal metric history", zap.Error(err))
+		http.Error(res, "cant return metric history", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	res.Write(retBody)
+}
+
+// parseHistoryQuery разбирает параметры from, to и step.
+// По умолчанию to - текущее время, from - на defaultHistoryRange раньше to.
+func parseHistoryQuery(values url.Values, now time.Time) (historyQuery, error) {
+	q := historyQuery{to: now}
+	var err error
+
+	if v := values.Get("to"); v != "" {
+		q.to, err = parseHistoryTime(v)
+		if err != nil {
+			return q, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	q.from = q.to.Add(-defaultHistoryRange)
+	if v := values.Get("from"); v != "" {
+		q.from, err = parseHistoryTime(v)
+		if err != nil {
+			return q, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if q.from.After(q.to) {
+		return q, errors.New("from must not be after to")
+	}
+	if v := values.Get("step"); v != "" {
+		q.step, err = time.ParseDuration(v)
+		if err != nil {
+			return q, fmt.Errorf("invalid step: %w", err)
+		}
+		if q.step < 0 {
+			return q, errors.New("step must not be negative")
+		}
+	}
+	return q, nil
+}
+
+// parseHistoryTime разбирает время в формате RFC3339 или unix секундах
+func parseHistoryTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC3339 or unix seconds, got %q", v)
+	}
+	return time.Unix(sec, 0), nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryHandler(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	client := resty.New()
+	defer ts.Close()
+
+	for _, u := range []string{
+		"/update/gauge/historyGauge/1.5",
+		"/update/gauge/historyGauge/2.5",
+		"/update/counter/historyCounter/5",
+	} {
+		resp, err := client.R().Post(ts.URL + u)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+	}
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+		expectedLen  int
+	}{
+		{
+			name:         "gauge history with defaults",
+			url:          "/history/gauge/historyGauge",
+			expectedCode: http.StatusOK,
+			expectedLen:  2,
+		},
+		{
+			name:         "gauge history with step",
+			url:          "/history/gauge/historyGauge?step=1h&from=" + url.QueryEscape(time.Now().Add(-time.Minute).Format(time.RFC3339)),
+			expectedCode: http.StatusOK,
+			expectedLen:  1,
+		},
+		{
+			name:         "counter history",
+			url:          "/history/counter/historyCounter",
+			expectedCode: http.StatusOK,
+			expectedLen:  1,
+		},
+		{
+			name:         "wrong type",
+			url:          "/history/counter/historyGauge",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "non-existent metric",
+			url:          "/history/gauge/nonExistent",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "invalid step",
+			url:          "/history/gauge/historyGauge?step=abc",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "from after to",
+			url:          "/history/gauge/historyGauge?from=2000&to=1000",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.R().Get(ts.URL + tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode())
+			if tt.expectedCode == http.StatusOK {
+				var samples []*models.Sample
+				require.NoError(t, json.Unmarshal(resp.Body(), &samples))
+				assert.Len(t, samples, tt.expectedLen)
+			}
+		})
+	}
+}
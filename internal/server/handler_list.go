@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultListLimit - размер страницы, если параметр limit не указан
+	defaultListLimit = 100
+	// maxListLimit - максимальный размер страницы
+	maxListLimit = 1000
+)
+
+// MetricsListResponse - страница метрик, возвращаемая GET /api/v1/metrics
+type MetricsListResponse struct {
+	Metrics    []*models.Metrics `json:"metrics"`
+	NextCursor string            `json:"next_cursor,omitempty"` // передается в cursor для получения следующей страницы
+}
+
+// ListMetricsHandler возвращает метрики постранично в JSON.
+// Формат: GET /api/v1/metrics?type=&prefix=&match=&limit=&cursor=
+// match - glob шаблон имени (например runtime.*), limit - размер страницы, по умолчанию defaultListLimit.
+// Метрики упорядочены по имени, серии одной метрики - по ключу серии.
+func (s *MetricsService) ListMetricsHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logger := logger.LoggerFromCtx(ctx, s.Logger)
+
+	opts, err := parseListQuery(req.URL.Query())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := s.ListMetrics(ctx, opts)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidListOptions) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Error("error while listing metrics", zap.Error(err))
+		http.Error(res, "error while listing metrics", http.StatusInternalServerError)
+		return
+	}
+
+	resp := MetricsListResponse{Metrics: page.Metrics, NextCursor: page.NextCursor}
+	if resp.Metrics == nil {
+		resp.Metrics = []*models.Metrics{}
+	}
+	retBody, err := json.Marshal(resp)
+	if err != nil {
+		logger.Error("cant marshal metrics list", zap.Error(err))
+		http.Error(res, "cant return metrics list", http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	res.Write(retBody)
+}
+
+// parseListQuery разбирает параметры выборки метрик. Тип, шаблон и курсор проверяет хранилище.
+func parseListQuery(values url.Values) (store.ListOptions, error) {
+	opts := store.ListOptions{
+		Type:   values.Get("type"),
+		Prefix: values.Get("prefix"),
+		Match:  values.Get("match"),
+		Cursor: values.Get("cursor"),
+		Limit:  defaultListLimit,
+	}
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return opts, fmt.Errorf("invalid limit: expected integer in [1, %d]", maxListLimit)
+		}
+		opts.Limit = limit
+	}
+	return opts, nil
+}
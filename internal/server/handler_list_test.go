@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListMetricsHandler(t *testing.T) {
+	ts, service := setupTestServer(t)
+	client := resty.New()
+	defer ts.Close()
+
+	require.NoError(t, service.UpdateMetrics(context.Background(), []*models.Metrics{
+		models.NewGaugeMetric("runtime.Alloc", 1),
+		models.NewGaugeMetric("runtime.Frees", 2),
+		models.NewCounterMetric("runtime.GC", 3),
+		models.NewCounterMetric("PollCount", 1),
+	}))
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedIDs  []string
+		hasNext      bool
+	}{
+		{
+			name:         "all",
+			expectedCode: http.StatusOK,
+			expectedIDs:  []string{"PollCount", "runtime.Alloc", "runtime.Frees", "runtime.GC"},
+		},
+		{
+			name:         "type and prefix",
+			query:        "?type=counter&prefix=runtime.",
+			expectedCode: http.StatusOK,
+			expectedIDs:  []string{"runtime.GC"},
+		},
+		{
+			name:         "glob",
+			query:        "?match=" + url.QueryEscape("*.?rees"),
+			expectedCode: http.StatusOK,
+			expectedIDs:  []string{"runtime.Frees"},
+		},
+		{
+			name:         "first page",
+			query:        "?limit=3",
+			expectedCode: http.StatusOK,
+			expectedIDs:  []string{"PollCount", "runtime.Alloc", "runtime.Frees"},
+			hasNext:      true,
+		},
+		{
+			name:         "empty result",
+			query:        "?prefix=missing",
+			expectedCode: http.StatusOK,
+			expectedIDs:  []string{},
+		},
+		{name: "invalid limit", query: "?limit=0", expectedCode: http.StatusBadRequest},
+		{name: "limit too large", query: "?limit=100000", expectedCode: http.StatusBadRequest},
+		{name: "invalid type", query: "?type=summary", expectedCode: http.StatusBadRequest},
+		{name: "invalid glob", query: "?match=" + url.QueryEscape("[a-"), expectedCode: http.StatusBadRequest},
+		{name: "invalid cursor", query: "?cursor=" + url.QueryEscape("@@"), expectedCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.R().Get(ts.URL + "/api/v1/metrics" + tt.query)
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedCode, resp.StatusCode())
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+			var got MetricsListResponse
+			require.NoError(t, json.Unmarshal(resp.Body(), &got))
+			ids := make([]string, 0, len(got.Metrics))
+			for _, m := range got.Metrics {
+				ids = append(ids, m.ID)
+			}
+			assert.Equal(t, tt.expectedIDs, ids)
+			assert.Equal(t, tt.hasNext, got.NextCursor != "")
+		})
+	}
+
+	t.Run("next page", func(t *testing.T) {
+		var first MetricsListResponse
+		resp, err := client.R().SetResult(&first).Get(ts.URL + "/api/v1/metrics?limit=3")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+
+		var second MetricsListResponse
+		resp, err = client.R().SetResult(&second).
+			SetQueryParams(map[string]string{"limit": "3", "cursor": first.NextCursor}).
+			Get(ts.URL + "/api/v1/metrics")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+		require.Len(t, second.Metrics, 1)
+		assert.Equal(t, "runtime.GC", second.Metrics[0].ID)
+		assert.Empty(t, second.NextCursor)
+	})
+}
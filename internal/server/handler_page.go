@@ -32,7 +32,10 @@ func (s *MetricsService) MetricsPageHandler(res http.ResponseWriter, req *http.R
 	}
 
 	sort.Slice(data, func(i, j int) bool {
-		return data[i].ID < data[j].ID
+		if data[i].ID != data[j].ID {
+			return data[i].ID < data[j].ID
+		}
+		return data[i].SeriesKey() < data[j].SeriesKey()
 	})
 
 	var bufTemplate bytes.Buffer
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"go.uber.org/zap"
+)
+
+const (
+	// prometheusContentType - Content-Type текстового формата экспозиции Prometheus
+	prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+	// openMetricsContentType - Content-Type формата OpenMetrics
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// PrometheusHandler отдает все метрики в текстовом формате экспозиции Prometheus.
+// Формат: GET /metrics
+// Если клиент указывает application/openmetrics-text в Accept, ответ формируется в формате OpenMetrics.
+func (s *MetricsService) PrometheusHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logger := logger.LoggerFromCtx(ctx, s.Logger)
+
+	metrics, err := s.GetAllMetrics(ctx)
+	if err != nil {
+		logger.Error("error while getting all metrics for exposition", zap.Error(err))
+		http.Error(res, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	openMetrics := strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text")
+	var buf bytes.Buffer
+	skipped := writeExposition(&buf, metrics, openMetrics)
+	for _, id := range skipped {
+		logger.Warn("metric skipped in exposition: name collides after sanitizing", zap.String("series", id))
+	}
+
+	if openMetrics {
+		res.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		res.Header().Set("Content-Type", prometheusContentType)
+	}
+	res.WriteHeader(http.StatusOK)
+	res.Write(buf.Bytes())
+}
+
+// writeExposition записывает метрики, отсортированные по ID и меткам, в формате Prometheus или OpenMetrics.
+// Серии одной метрики с разными метками выводятся в одном семействе с общей строкой # TYPE.
+// Возвращает ключи серий, пропущенных из-за совпадения имен после приведения к формату Prometheus.
+func writeExposition(buf *bytes.Buffer, metrics []*models.Metrics, openMetrics bool) []string {
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].ID != metrics[j].ID {
+			return metrics[i].ID < metrics[j].ID
+		}
+		return metrics[i].SeriesKey() < metrics[j].SeriesKey()
+	})
+
+	skipped := make([]string, 0)
+	// families хранит ID метрики, которой принадлежит семейство
+	families := map[string]string{}
+	for _, m := range metrics {
+		name := sanitizeMetricName(m.ID)
+		sampleName := name
+		var value string
+		switch m.MType {
+		case models.Gauge:
+			if m.Value == nil {
+				continue
+			}
+			value = strconv.FormatFloat(*m.Value, 'g', -1, 64)
+		case models.Counter:
+			if m.Delta == nil {
+				continue
+			}
+			value = strconv.FormatInt(*m.Delta, 10)
+			if openMetrics {
+				// в OpenMetrics семейство counter называется без суффикса, а отсчет - с суффиксом _total
+				name = strings.TrimSuffix(name, "_total")
+				sampleName = name + "_total"
+			}
+		case models.Histogram:
+			if m.Histogram == nil {
+				continue
+			}
+		default:
+			continue
+		}
+		if owner, ok := families[name]; ok && owner != m.ID {
+			skipped = append(skipped, m.SeriesKey())
+			continue
+		} else if !ok {
+			families[name] = m.ID
+			buf.WriteString("# TYPE ")
+			buf.WriteString(name)
+			buf.WriteByte(' ')
+			buf.WriteString(m.MType)
+			buf.WriteByte('\n')
+		}
+
+		if m.MType == models.Histogram {
+			writeHistogramSamples(buf, name, m.Labels, m.Histogram)
+			continue
+		}
+		writeSample(buf, sampleName, m.Labels, "", value)
+	}
+	if openMetrics {
+		buf.WriteString("# EOF\n")
+	}
+	return skipped
+}
+
+// labelValueReplacer экранирует значение метки по правилам формата экспозиции
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeHistogramSamples записывает накопительные корзины гистограммы с меткой le, сумму и количество наблюдений
+func writeHistogramSamples(buf *bytes.Buffer, name string, labels map[string]string, h *models.HistogramValue) {
+	var cumulative int64
+	for i, c := range h.Counts {
+		cumulative += c
+		le := "+Inf"
+		if i < len(h.Bounds) {
+			le = strconv.FormatFloat(h.Bounds[i], 'g', -1, 64)
+		}
+		writeSample(buf, name+"_bucket", labels, le, strconv.FormatInt(cumulative, 10))
+	}
+	writeSample(buf, name+"_sum", labels, "", strconv.FormatFloat(h.Sum, 'g', -1, 64))
+	writeSample(buf, name+"_count", labels, "", strconv.FormatInt(h.Count, 10))
+}
+
+// writeSample записывает строку отсчета с метками серии в формате name{label="value",...} value.
+// Непустой le добавляется последней меткой для корзин гистограммы.
+func writeSample(buf *bytes.Buffer, name string, labels map[string]string, le string, value string) {
+	buf.WriteString(name)
+	if len(labels) > 0 || le != "" {
+		buf.WriteByte('{')
+		for i, k := range models.SortedLabelNames(labels) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(k)
+			buf.WriteString(`="`)
+			buf.WriteString(labelValueReplacer.Replace(labels[k]))
+			buf.WriteByte('"')
+		}
+		if le != "" {
+			if len(labels) > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(`le="`)
+			buf.WriteString(le)
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// sanitizeMetricName приводит ID метрики к допустимому имени Prometheus: [a-zA-Z_:][a-zA-Z0-9_:]*.
+// Недопустимые символы заменяются на '_', к имени, начинающемуся с цифры, добавляется префикс '_'.
+func sanitizeMetricName(id string) string {
+	var b strings.Builder
+	for i, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
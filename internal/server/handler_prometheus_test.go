@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	client := resty.New()
+	defer ts.Close()
+
+	for _, u := range []string{
+		"/update/gauge/HeapAlloc/1024",
+		"/update/counter/PollCount/5",
+		"/update/gauge/cpu.usage-0/12.5",
+		"/update/gauge/Alloc/1?label=host=web1",
+		"/update/gauge/Alloc/2?label=host=web%222%22",
+	} {
+		resp, err := client.R().Post(ts.URL + u)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+	}
+
+	tests := []struct {
+		name         string
+		accept       string
+		expectedType string
+		expectedBody string
+	}{
+		{
+			name:         "prometheus text format",
+			expectedType: prometheusContentType,
+			expectedBody: "# TYPE Alloc gauge\nAlloc{host=\"web1\"} 1\nAlloc{host=\"web\\\"2\\\"\"} 2\n" +
+				"# TYPE HeapAlloc gauge\nHeapAlloc 1024\n" +
+				"# TYPE PollCount counter\nPollCount 5\n" +
+				"# TYPE cpu_usage_0 gauge\ncpu_usage_0 12.5\n",
+		},
+		{
+			name:         "openmetrics format",
+			accept:       "application/openmetrics-text; version=1.0.0",
+			expectedType: openMetricsContentType,
+			expectedBody: "# TYPE Alloc gauge\nAlloc{host=\"web1\"} 1\nAlloc{host=\"web\\\"2\\\"\"} 2\n" +
+				"# TYPE HeapAlloc gauge\nHeapAlloc 1024\n" +
+				"# TYPE PollCount counter\nPollCount_total 5\n" +
+				"# TYPE cpu_usage_0 gauge\ncpu_usage_0 12.5\n" +
+				"# EOF\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := client.R()
+			if tt.accept != "" {
+				req.SetHeader("Accept", tt.accept)
+			}
+			resp, err := req.Get(ts.URL + "/metrics")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode())
+			assert.Equal(t, tt.expectedType, resp.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expectedBody, string(resp.Body()))
+		})
+	}
+}
+
+func Test_writeExposition_Histogram(t *testing.T) {
+	h := models.NewHistogram([]float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(5)
+	m := models.NewHistogramMetric("SendLatencySeconds", h)
+	m.Labels = map[string]string{"host": "web1"}
+
+	var buf bytes.Buffer
+	writeExposition(&buf, []*models.Metrics{m}, false)
+	assert.Equal(t, "# TYPE SendLatencySeconds histogram\n"+
+		"SendLatencySeconds_bucket{host=\"web1\",le=\"0.1\"} 1\n"+
+		"SendLatencySeconds_bucket{host=\"web1\",le=\"1\"} 2\n"+
+		"SendLatencySeconds_bucket{host=\"web1\",le=\"+Inf\"} 3\n"+
+		"SendLatencySeconds_sum{host=\"web1\"} 5.55\n"+
+		"SendLatencySeconds_count{host=\"web1\"} 3\n", buf.String())
+}
+
+func Test_sanitizeMetricName(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"HeapAlloc", "HeapAlloc"},
+		{"CPUutilization0", "CPUutilization0"},
+		{"http.requests-total", "http_requests_total"},
+		{"0value", "_0value"},
+		{"ns:metric", "ns:metric"},
+		{"", "_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizeMetricName(tt.id))
+		})
+	}
+}
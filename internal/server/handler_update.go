@@ -15,6 +15,7 @@ import (
 
 // UpdatesHandler обрабатывает пакетное обновление метрик через JSON.
 // Требует подписи запроса. Принимает массив метрик в теле запроса.
+// Заголовки X-Agent-ID и X-Batch-ID делают запрос идемпотентным: повтор пакета не применяется повторно.
 func (s *MetricsService) UpdatesHandler(res http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	logger := logger.LoggerFromCtx(ctx, s.Logger)
@@ -31,8 +32,14 @@ func (s *MetricsService) UpdatesHandler(res http.ResponseWriter, req *http.Reque
 		http.Error(res, "cant decode body to metrics", http.StatusBadRequest)
 		return
 	}
-	err = s.UpdateMetrics(ctx, metrics)
+	agentID := req.Header.Get("X-Agent-ID")
+	applied, err := s.UpdateMetricsBatch(ctx, agentID, req.Header.Get("X-Batch-ID"), metrics)
 	if err != nil {
+		if errors.Is(err, ErrMetricNotAllowed) {
+			logger.Warn("agent sent metrics outside of allowed prefixes", zap.Error(err))
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, store.ErrInvalidMetricReceived) {
 			logger.Warn("recieved one or more invalid metric", zap.Error(err))
 			http.Error(res, "recieved one or more invalid metric", http.StatusBadRequest)
@@ -42,6 +49,10 @@ func (s *MetricsService) UpdatesHandler(res http.ResponseWriter, req *http.Reque
 		http.Error(res, "error while batch metrics update", http.StatusInternalServerError)
 		return
 	}
+	// повтор уже примененного пакета не меняет метрики и не попадает в аудит
+	if applied {
+		s.recordHTTPUpdate(req, agentID, metrics...)
+	}
 	res.WriteHeader(http.StatusOK)
 
 }
@@ -69,6 +80,10 @@ func (s *MetricsService) UpdateHandler(res http.ResponseWriter, req *http.Reques
 			http.Error(res, `metric is not found or id is empty`, http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, ErrMetricNotAllowed) {
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, store.ErrInvalidMetricReceived) {
 			http.Error(res, "invalid metric recieved", http.StatusBadRequest)
 			return
@@ -79,6 +94,8 @@ func (s *MetricsService) UpdateHandler(res http.ResponseWriter, req *http.Reques
 		http.Error(res, "cant update metric", http.StatusInternalServerError)
 		return
 	}
+	s.recordHTTPUpdate(req, "", metric)
+	s.signMetric(retMetric)
 
 	retBody, err := json.Marshal(retMetric)
 	if err != nil {
@@ -95,18 +112,29 @@ func (s *MetricsService) UpdateHandler(res http.ResponseWriter, req *http.Reques
 }
 
 // UpdateViaURLHandler обрабатывает обновление метрики через URL параметры.
-// Формат: POST /update/{type}/{name}/{value}
+// Формат: POST /update/{type}/{name}/{value}[?label=key=value...]
 func (s *MetricsService) UpdateViaURLHandler(res http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	logger := logger.LoggerFromCtx(ctx, s.Logger)
 	metric := parseMetricURL(req)
+	labels, err := parseLabelParams(req.URL.Query())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metric.Labels = labels
 
-	_, err := s.UpdateMetric(ctx, &metric)
+	// в URL нет места для подписи метрики, если настроены ключи, подписан сам запрос с путем
+	_, err = s.updateMetric(ctx, &metric, hashNotCarried)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
 			http.Error(res, "metric is not found or id is empty", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, ErrMetricNotAllowed) {
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, store.ErrInvalidMetricReceived) {
 			http.Error(res, "invalid metric recieved", http.StatusBadRequest)
 			return
@@ -117,6 +145,7 @@ func (s *MetricsService) UpdateViaURLHandler(res http.ResponseWriter, req *http.
 		http.Error(res, "cant update metric", http.StatusInternalServerError)
 		return
 	}
+	s.recordHTTPUpdate(req, "", &metric)
 	res.WriteHeader(http.StatusOK)
 }
 
@@ -11,6 +11,7 @@ import (
 	"github.com/Soliard/go-tpl-metrics/internal/compressor"
 	"github.com/Soliard/go-tpl-metrics/internal/config"
 	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/internal/signer"
 	"github.com/Soliard/go-tpl-metrics/internal/store"
 	"github.com/Soliard/go-tpl-metrics/models"
 	"github.com/go-resty/resty/v2"
@@ -375,3 +376,117 @@ func Test_UpdatesHandler(t *testing.T) {
 		})
 	}
 }
+
+func Test_UpdatesHandler_DuplicateBatch(t *testing.T) {
+	server, service := setupTestServer(t)
+	defer server.Close()
+	client := resty.New()
+
+	body, err := json.Marshal([]*models.Metrics{models.NewCounterMetric("PollCount", 5)})
+	require.NoError(t, err)
+
+	send := func(batchID string) {
+		res, err := client.R().
+			SetHeader("Content-type", "application/json").
+			SetHeader("X-Agent-ID", "agent").
+			SetHeader("X-Batch-ID", batchID).
+			SetBody(body).
+			Post(server.URL + "/updates")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode())
+	}
+
+	send("batch-1")
+	// повтор пакета подтверждается, но не применяется
+	send("batch-1")
+	send("batch-2")
+
+	got, err := service.GetMetric(context.Background(), "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), *got.Delta)
+}
+
+func TestMetricHash(t *testing.T) {
+	key := []byte("secret")
+	logger, err := logger.New("info")
+	require.NoError(t, err)
+	service := NewMetricsService(store.NewMemoryStorage(), &config.ServerConfig{SignKey: string(key)}, logger)
+	ts := httptest.NewServer(MetricRouter(service))
+	defer ts.Close()
+	client := resty.New()
+
+	signed := func(m *models.Metrics) *models.Metrics {
+		m.Hash = signer.SignMetric(m, key)
+		return m
+	}
+	wrongKey := models.NewCounterMetric("requests", 1)
+	wrongKey.Hash = signer.SignMetric(wrongKey, []byte("other"))
+	tampered := signed(models.NewCounterMetric("requests", 1))
+	tampered.Delta = models.PInt(100)
+
+	tests := []struct {
+		name         string
+		metric       *models.Metrics
+		expectedCode int
+	}{
+		{name: "valid hash", metric: signed(models.NewCounterMetric("requests", 2)), expectedCode: http.StatusOK},
+		{name: "without hash", metric: models.NewCounterMetric("requests", 3), expectedCode: http.StatusBadRequest},
+		{name: "wrong key", metric: wrongKey, expectedCode: http.StatusBadRequest},
+		{name: "tampered value", metric: tampered, expectedCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got models.Metrics
+			resp, err := client.R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(tt.metric).
+				SetResult(&got).
+				Post(ts.URL + "/update/")
+			require.NoError(t, err)
+			require.Equal(t, tt.expectedCode, resp.StatusCode())
+			if tt.expectedCode == http.StatusOK {
+				// подпись ответа относится к накопленному значению
+				assert.True(t, signer.VerifyMetric(&got, key))
+			}
+		})
+	}
+
+	var got models.Metrics
+	resp, err := client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(`{"id":"requests","type":"counter"}`).
+		SetResult(&got).
+		Post(ts.URL + "/value/")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, int64(2), *got.Delta, "metric without hash is not applied")
+	assert.True(t, signer.VerifyMetric(&got, key))
+
+	// в URL подпись метрики передать нельзя, поэтому подписывается сам запрос
+	signRequest := func(path string) string {
+		return signer.EncodeSign(signer.Sign(signer.SignedPayload(signer.HTTPRequestLine(http.MethodPost, path), "", "", nil), key))
+	}
+	urlTests := []struct {
+		name         string
+		sign         string
+		expectedCode int
+	}{
+		{name: "unsigned url update", expectedCode: http.StatusBadRequest},
+		{name: "signature of another value", sign: signRequest("/update/counter/requests/100"), expectedCode: http.StatusBadRequest},
+		{name: "signed url update", sign: signRequest("/update/counter/requests/1"), expectedCode: http.StatusOK},
+	}
+	for _, tt := range urlTests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := client.R()
+			if tt.sign != "" {
+				req.SetHeader("HashSHA256", tt.sign)
+			}
+			resp, err := req.Post(ts.URL + "/update/counter/requests/1")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode())
+		})
+	}
+	metric, err := service.GetMetric(context.Background(), "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *metric.Delta, "only the signed url update is applied")
+}
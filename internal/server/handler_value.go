@@ -3,7 +3,11 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/Soliard/go-tpl-metrics/internal/store"
 	"github.com/Soliard/go-tpl-metrics/models"
@@ -12,6 +16,9 @@ import (
 
 // ValueHandler обрабатывает получение метрики через JSON запрос.
 // Принимает метрику с ID в теле запроса и возвращает полную метрику.
+// Метки из тела запроса используются как матчеры серии.
+// Для гистограмм в ответ добавляются оценки квантилей из query параметров q (по умолчанию 0.5, 0.9, 0.99).
+// Если настроен ключ, Hash содержит подпись возвращаемого значения.
 func (s *MetricsService) ValueHandler(res http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	res.Header().Set("Content-Type", "application/json")
@@ -27,15 +34,30 @@ func (s *MetricsService) ValueHandler(res http.ResponseWriter, req *http.Request
 		http.Error(res, "cant decode body to metric type", http.StatusBadRequest)
 	}
 
-	retMetric, err := s.GetMetric(ctx, metric.ID)
+	retMetric, err := s.FindMetric(ctx, metric.ID, metric.Labels)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
 			http.Error(res, "metric with this name doesnt exists", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, ErrAmbiguousSeries) {
+			http.Error(res, "several series match, specify labels", http.StatusBadRequest)
+			return
+		}
 		http.Error(res, "error while getting metric", http.StatusInternalServerError)
 		return
 	}
+	if retMetric.Histogram != nil {
+		quantiles, err := parseQuantileParams(req.URL.Query())
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		withQuantiles := *retMetric
+		withQuantiles.Histogram = retMetric.Histogram.WithQuantiles(quantiles)
+		retMetric = &withQuantiles
+	}
+	s.signMetric(retMetric)
 	retBody, err := json.Marshal(retMetric)
 	if err != nil {
 		s.Logger.Error("cant marshal metric",
@@ -51,8 +73,9 @@ func (s *MetricsService) ValueHandler(res http.ResponseWriter, req *http.Request
 }
 
 // ValueViaURLHandler обрабатывает получение метрики через URL параметры.
-// Формат: GET /value/{type}/{name}
+// Формат: GET /value/{type}/{name}[?label=key=value...]
 // Возвращает значение метрики в виде текста.
+// Для гистограмм возвращаются строки "квантиль оценка" для query параметров q (по умолчанию 0.5, 0.9, 0.99).
 func (s *MetricsService) ValueViaURLHandler(res http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	m := parseMetricURL(req)
@@ -61,12 +84,21 @@ func (s *MetricsService) ValueViaURLHandler(res http.ResponseWriter, req *http.R
 		http.Error(res, `type or name cannot be empty`, http.StatusBadRequest)
 		return
 	}
-	metric, err := s.GetMetric(ctx, m.ID)
+	matchers, err := parseLabelParams(req.URL.Query())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metric, err := s.FindMetric(ctx, m.ID, matchers)
 	if err != nil {
 		if errors.Is(err, store.ErrNotFound) {
 			http.Error(res, `metric with this name doesnt exists`, http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, ErrAmbiguousSeries) {
+			http.Error(res, `several series match, specify labels`, http.StatusBadRequest)
+			return
+		}
 		http.Error(res, `error while getting metric`, http.StatusInternalServerError)
 		return
 	}
@@ -76,8 +108,20 @@ func (s *MetricsService) ValueViaURLHandler(res http.ResponseWriter, req *http.R
 		return
 	}
 
+	var quantiles []float64
+	if metric.MType == models.Histogram {
+		quantiles, err = parseQuantileParams(req.URL.Query())
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	res.Header().Set("Content-Type", "plain/text; charset=utf-8")
 	res.WriteHeader(http.StatusOK)
+	if metric.MType == models.Histogram {
+		res.Write([]byte(formatQuantiles(metric.Histogram, quantiles)))
+	}
 	if m.MType == models.Counter {
 		res.Write([]byte(metric.StringifyDelta()))
 	}
@@ -85,3 +129,50 @@ func (s *MetricsService) ValueViaURLHandler(res http.ResponseWriter, req *http.R
 		res.Write([]byte(metric.StringifyValue()))
 	}
 }
+
+// parseLabelParams разбирает повторяющийся query параметр label в формате key=value
+func parseLabelParams(values url.Values) (map[string]string, error) {
+	params := values["label"]
+	if len(params) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(params))
+	for _, p := range params {
+		name, value, ok := strings.Cut(p, "=")
+		if !ok || !models.ValidLabelName(name) {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", p)
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+// parseQuantileParams разбирает повторяющийся query параметр q со значениями от 0 до 1.
+// Если параметр не задан, возвращает models.DefaultQuantiles.
+func parseQuantileParams(values url.Values) ([]float64, error) {
+	params := values["q"]
+	if len(params) == 0 {
+		return models.DefaultQuantiles, nil
+	}
+	quantiles := make([]float64, 0, len(params))
+	for _, p := range params {
+		q, err := strconv.ParseFloat(p, 64)
+		if err != nil || q < 0 || q > 1 {
+			return nil, fmt.Errorf("invalid quantile %q, expected number from 0 to 1", p)
+		}
+		quantiles = append(quantiles, q)
+	}
+	return quantiles, nil
+}
+
+// formatQuantiles форматирует оценки квантилей гистограммы построчно в виде "квантиль оценка"
+func formatQuantiles(h *models.HistogramValue, quantiles []float64) string {
+	var b strings.Builder
+	for _, q := range quantiles {
+		b.WriteString(models.FormatQuantile(q))
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(h.Quantile(q), 'g', -1, 64))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -134,3 +134,95 @@ func TestValueHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestValueViaURLHandler_Labels(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	client := resty.New()
+	defer ts.Close()
+
+	for _, u := range []string{
+		"/update/gauge/Alloc/1.5?label=host=web1",
+		"/update/gauge/Alloc/2.5?label=host=web2&label=env=prod",
+		"/update/gauge/Single/5.5?label=host=web1",
+	} {
+		resp, err := client.R().Post(ts.URL + u)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode())
+	}
+
+	tests := []struct {
+		name          string
+		url           string
+		expectedValue string
+		expectedCode  int
+	}{
+		{"exact labels", "/value/gauge/Alloc?label=host=web1", "1.5", http.StatusOK},
+		{"partial matcher", "/value/gauge/Alloc?label=env=prod", "2.5", http.StatusOK},
+		{"ambiguous without matchers", "/value/gauge/Alloc", "", http.StatusBadRequest},
+		{"single series without matchers", "/value/gauge/Single", "5.5", http.StatusOK},
+		{"no matching series", "/value/gauge/Alloc?label=host=web3", "", http.StatusNotFound},
+		{"invalid matcher", "/value/gauge/Alloc?label=host", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.R().Get(ts.URL + tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode())
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, tt.expectedValue, string(resp.Body()))
+			}
+		})
+	}
+
+	returned := models.Metrics{}
+	resp, err := client.R().
+		SetBody(&models.Metrics{ID: "Alloc", MType: models.Gauge, Labels: map[string]string{"host": "web2"}}).
+		SetResult(&returned).
+		Post(ts.URL + "/value/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, map[string]string{"env": "prod", "host": "web2"}, returned.Labels)
+}
+
+func TestValueHandler_Histogram(t *testing.T) {
+	ts, _ := setupTestServer(t)
+	client := resty.New()
+	defer ts.Close()
+
+	h := models.NewHistogram([]float64{0.1, 0.5, 1})
+	for _, v := range []float64{0.05, 0.2, 0.3, 0.4, 0.7, 2} {
+		h.Observe(v)
+	}
+	resp, err := client.R().
+		SetBody(models.NewHistogramMetric("latency", h)).
+		Post(ts.URL + "/update/")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+
+	resp, err = client.R().
+		SetBody(&models.Metrics{ID: "latency", MType: models.Histogram, Histogram: &models.HistogramValue{Bounds: []float64{1}, Counts: []int64{1}, Count: 1}}).
+		Post(ts.URL + "/update/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode(), "counts must match bounds")
+
+	resp, err = client.R().Get(ts.URL + "/value/histogram/latency?q=0.5&q=1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, "0.5 0.3666666666666667\n1 1\n", string(resp.Body()))
+
+	resp, err = client.R().Get(ts.URL + "/value/histogram/latency?q=2")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+
+	returned := models.Metrics{}
+	resp, err = client.R().
+		SetBody(&models.Metrics{ID: "latency", MType: models.Histogram}).
+		SetResult(&returned).
+		Post(ts.URL + "/value/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	require.NotNil(t, returned.Histogram)
+	assert.Equal(t, int64(6), returned.Histogram.Count)
+	assert.Len(t, returned.Histogram.Quantiles, 3)
+	assert.Contains(t, returned.Histogram.Quantiles, "0.99")
+}
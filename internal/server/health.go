@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"go.uber.org/zap"
+)
+
+// healthCheckTimeout ограничивает время одной проверки готовности
+const healthCheckTimeout = 2 * time.Second
+
+// Статусы проверок и отчета о состоянии
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// CheckReport - результат одной проверки готовности
+type CheckReport struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthReport - состояние сервера: общий статус и результаты отдельных проверок
+type HealthReport struct {
+	Status string        `json:"status"`
+	Checks []CheckReport `json:"checks"`
+}
+
+// Ready сообщает, прошли ли все проверки
+func (r *HealthReport) Ready() bool {
+	return r.Status == HealthStatusOK
+}
+
+// Readiness выполняет проверки готовности хранилища параллельно, каждую не дольше healthCheckTimeout.
+// Хранилище без store.HealthChecker считается готовым.
+func (s *MetricsService) Readiness(ctx context.Context) *HealthReport {
+	var checks []store.HealthCheck
+	if hc, ok := s.storage.(store.HealthChecker); ok {
+		checks = hc.HealthChecks()
+	}
+	report := &HealthReport{Status: HealthStatusOK, Checks: make([]CheckReport, len(checks))}
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			start := time.Now()
+			err := check.Check(checkCtx)
+			r := CheckReport{
+				Name:      check.Name,
+				Status:    HealthStatusOK,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				r.Status = HealthStatusFail
+				r.Error = err.Error()
+			}
+			report.Checks[i] = r
+		}()
+	}
+	wg.Wait()
+	for _, r := range report.Checks {
+		if r.Status != HealthStatusOK {
+			report.Status = HealthStatusFail
+		}
+	}
+	return report
+}
+
+// LivenessHandler отвечает 200 OK, пока процесс обслуживает запросы. Хранилище не проверяется.
+func (s *MetricsService) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, http.StatusOK, &HealthReport{Status: HealthStatusOK, Checks: []CheckReport{}})
+}
+
+// ReadinessHandler выполняет проверки готовности хранилища и отвечает 200 OK,
+// если все они прошли, иначе 503 Service Unavailable. Тело - HealthReport в JSON.
+func (s *MetricsService) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := s.Readiness(r.Context())
+	code := http.StatusOK
+	if !report.Ready() {
+		code = http.StatusServiceUnavailable
+		logger.LoggerFromCtx(r.Context(), s.Logger).Warn("server is not ready", zap.Any("checks", report.Checks))
+	}
+	writeHealthReport(w, code, report)
+}
+
+// writeHealthReport записывает отчет о состоянии в ответ
+func writeHealthReport(w http.ResponseWriter, code int, report *HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
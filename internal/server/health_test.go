@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// checkedStorage - хранилище в памяти с управляемой проверкой готовности
+type checkedStorage struct {
+	store.Storage
+	failing atomic.Bool
+}
+
+func (s *checkedStorage) HealthChecks() []store.HealthCheck {
+	return []store.HealthCheck{
+		{Name: "always", Check: func(context.Context) error { return nil }},
+		{Name: "switch", Check: func(context.Context) error {
+			if s.failing.Load() {
+				return errors.New("storage is down")
+			}
+			return nil
+		}},
+	}
+}
+
+func TestHealthHandlers(t *testing.T) {
+	storage := &checkedStorage{Storage: store.NewMemoryStorage()}
+	service := NewMetricsService(storage, &config.ServerConfig{AuthTokensFile: writeTokensFile(t)}, zap.NewNop())
+	router := MetricRouter(service)
+
+	get := func(path string) (int, HealthReport) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var report HealthReport
+		if path != "/ping" {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		}
+		return w.Code, report
+	}
+
+	code, report := get("/healthz")
+	assert.Equal(t, http.StatusOK, code, "health endpoints do not require a token")
+	assert.Equal(t, HealthStatusOK, report.Status)
+
+	code, report = get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthStatusOK, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, "always", report.Checks[0].Name)
+	assert.Equal(t, "switch", report.Checks[1].Name)
+	code, _ = get("/ping")
+	assert.Equal(t, http.StatusOK, code)
+
+	storage.failing.Store(true)
+	code, report = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, HealthStatusFail, report.Status)
+	assert.Equal(t, HealthStatusOK, report.Checks[0].Status)
+	assert.Equal(t, HealthStatusFail, report.Checks[1].Status)
+	assert.Equal(t, "storage is down", report.Checks[1].Error)
+	code, _ = get("/ping")
+	assert.Equal(t, http.StatusInternalServerError, code)
+
+	code, report = get("/healthz")
+	assert.Equal(t, http.StatusOK, code, "liveness does not depend on storage")
+	assert.Equal(t, HealthStatusOK, report.Status)
+}
+
+func TestReadinessFileStorage(t *testing.T) {
+	storage, err := store.NewFileStorage(t.TempDir()+"/metrics.json", false, 0)
+	require.NoError(t, err)
+	service := NewMetricsService(storage, &config.ServerConfig{}, zap.NewNop())
+
+	report := service.Readiness(context.Background())
+	require.True(t, report.Ready(), report)
+	var names []string
+	for _, c := range report.Checks {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"memory", "file"}, names)
+}
+
+func TestGRPCHealth(t *testing.T) {
+	storage := &checkedStorage{Storage: store.NewMemoryStorage()}
+	cfg := &config.ServerConfig{AuthTokensFile: writeTokensFile(t), TrustedSubnet: "10.0.0.0/8"}
+	service := NewMetricsService(storage, cfg, zap.NewNop())
+	gs := NewGRPCServer(service)
+	lis := bufconn.Listen(1 << 20)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := healthpb.NewHealthClient(conn)
+	ctx := context.Background()
+
+	for _, service := range []string{"", "metrics.Metrics", "metrics.v2.Metrics"} {
+		res, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err, "health check %q does not require a token or trusted subnet", service)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+	}
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	storage.failing.Store(true)
+	res, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.Status)
+
+	list, err := client.List(ctx, &healthpb.HealthListRequest{})
+	require.NoError(t, err)
+	assert.Len(t, list.Statuses, 3)
+	for _, st := range list.Statuses {
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, st.Status)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watch, err := client.Watch(watchCtx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	first, err := watch.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, first.Status)
+}
@@ -2,11 +2,11 @@ package server
 
 import (
 	"net/http"
-	_ "net/http/pprof"
 
 	"github.com/Soliard/go-tpl-metrics/internal/compressor"
 	"github.com/Soliard/go-tpl-metrics/internal/crypto"
 	"github.com/Soliard/go-tpl-metrics/internal/logger"
+	"github.com/Soliard/go-tpl-metrics/internal/server/auth"
 	"github.com/Soliard/go-tpl-metrics/internal/signer"
 	"github.com/go-chi/chi/v5"
 )
@@ -14,46 +14,67 @@ import (
 // MetricRouter создает и настраивает HTTP роутер для API метрик.
 // Включает маршруты для обновления, получения и отображения метрик.
 // Поддерживает два типа эндпоинтов: с подписью и без подписи.
+// Если настроен реестр токенов агентов, все эндпоинты, кроме /ping, /healthz и /readyz, требуют токен:
+// чтение - с областью доступа read, запись и удаление - write.
 func MetricRouter(s *MetricsService) chi.Router {
 	r := chi.NewRouter()
 	r.Use(logger.LoggingMiddleware(s.Logger))
 
+	// проверки доступности и готовности не требуют токена
+	r.With(compressor.GzipMiddleware(s.Logger)).Get("/ping", s.PingHandler)
+	r.Get("/healthz", s.LivenessHandler)
+	r.Get("/readyz", s.ReadinessHandler)
+
 	// эндпоинты без подписи
 	r.Group(func(r chi.Router) {
-		r.Use(compressor.GzipMiddleware(s.Logger))
-		r.Get("/", s.MetricsPageHandler)
-		r.Get("/ping", s.PingHandler)
-		r.Route("/value", func(r chi.Router) {
-			r.Post("/", s.ValueHandler)
-			r.Get("/{type}/{name}", s.ValueViaURLHandler)
+		r.Use(
+			AuthMiddleware(s.tokens, s.Logger),
+			compressor.GzipMiddleware(s.Logger),
+		)
+		r.Group(func(r chi.Router) {
+			r.Use(RequireScope(s.tokens, auth.ScopeRead, s.Logger))
+			r.Get("/", s.MetricsPageHandler)
+			r.Get("/metrics", s.PrometheusHandler)
+			r.Route("/value", func(r chi.Router) {
+				r.Post("/", s.ValueHandler)
+				r.Get("/{type}/{name}", s.ValueViaURLHandler)
+			})
+			r.Get("/history/{type}/{name}", s.HistoryHandler)
+			r.Get("/api/v1/metrics", s.ListMetricsHandler)
 		})
 		r.Route("/update", func(r chi.Router) {
 			r.Use(
-				TrustedSubnetMiddleware(s.trustedSubnet, s.Logger),
+				TrustedSubnetMiddleware(s.ipPolicy, s.Logger),
+				RequireScope(s.tokens, auth.ScopeWrite, s.Logger),
 				crypto.DecryptMiddleware(s.privateKey, s.Logger),
 			)
 			r.Post("/", s.UpdateHandler)
 			r.Post("/{type}", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) })
 			r.Post("/{type}/{name}", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadRequest) })
-			r.Post("/{type}/{name}/{value}", s.UpdateViaURLHandler)
 		})
 	})
 
 	// эндпоинты с подписью
 	r.Group(func(r chi.Router) {
 		r.Use(
-			TrustedSubnetMiddleware(s.trustedSubnet, s.Logger),
-			signer.VerifySignatureMiddleware(s.signKey, s.Logger),
-			signer.SignResponseMiddleware(s.signKey, s.Logger),
+			TrustedSubnetMiddleware(s.ipPolicy, s.Logger),
+			AuthMiddleware(s.tokens, s.Logger),
+			RequireScope(s.tokens, auth.ScopeWrite, s.Logger),
+			signer.VerifySignatureMiddleware(s.signKeys, s.replayGuard, s.Logger),
+			signer.SignResponseMiddleware(s.signKeys, s.Logger),
 			compressor.GzipMiddleware(s.Logger),
 			crypto.DecryptMiddleware(s.privateKey, s.Logger),
 		)
 		r.Route("/updates", func(r chi.Router) {
 			r.Post("/", s.UpdatesHandler)
 		})
+		r.Route("/delete", func(r chi.Router) {
+			r.Post("/", s.DeleteHandler)
+		})
+		r.Delete("/value/{type}/{name}", s.DeleteViaURLHandler)
+		// значение метрики в URL не несет подписи Hash, поэтому подписывается сам запрос
+		r.Post("/update/{type}/{name}/{value}", s.UpdateViaURLHandler)
 	})
 
-	r.Mount("/debug/pprof", http.DefaultServeMux)
-
 	return r
 }
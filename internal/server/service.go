@@ -7,11 +7,17 @@ import (
 	"context"
 	"crypto/rsa"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"time"
 
 	"github.com/Soliard/go-tpl-metrics/internal/config"
 	"github.com/Soliard/go-tpl-metrics/internal/crypto"
+	"github.com/Soliard/go-tpl-metrics/internal/netutil"
+	"github.com/Soliard/go-tpl-metrics/internal/server/audit"
+	"github.com/Soliard/go-tpl-metrics/internal/server/auth"
+	"github.com/Soliard/go-tpl-metrics/internal/signer"
 	"github.com/Soliard/go-tpl-metrics/internal/store"
 	"github.com/Soliard/go-tpl-metrics/models"
 	"github.com/jackc/pgerrcode"
@@ -22,16 +28,45 @@ import (
 // MetricsService представляет основной сервис для работы с метриками.
 // Обеспечивает HTTP API для обновления и получения метрик с поддержкой повторных попыток.
 type MetricsService struct {
-	ServerHost    string
-	storage       store.Storage
-	Logger        *zap.Logger
-	signKey       []byte
-	privateKey    *rsa.PrivateKey // Новое поле
-	trustedSubnet string
+	ServerHost  string
+	storage     store.Storage
+	Logger      *zap.Logger
+	signKeys    *signer.Keyring
+	replayGuard *signer.ReplayGuard // общий для HTTP и gRPC, чтобы запрос нельзя было повторить через другой транспорт
+	privateKey  *rsa.PrivateKey     // Новое поле
+	ipPolicy    *netutil.IPPolicy
+	tokens      auth.Registry  // реестр токенов агентов, nil - аутентификация отключена
+	auditor     *audit.Auditor // аудит изменений метрик, nil - отключен
 }
 
 var maxRetries = 3
 
+// ErrAmbiguousSeries возвращается, когда матчеры меток выбирают несколько серий метрики
+var ErrAmbiguousSeries = errors.New("label matchers select several series")
+
+// ErrInvalidMetricHash возвращается, когда подпись метрики в поле Hash не совпадает с ее значением
+var ErrInvalidMetricHash = fmt.Errorf("%w: metric hash mismatch", store.ErrInvalidMetricReceived)
+
+// ErrMissingMetricHash возвращается, когда настроены ключи, а метрика пришла без подписи в поле Hash
+var ErrMissingMetricHash = fmt.Errorf("%w: metric hash is required", store.ErrInvalidMetricReceived)
+
+// hashPolicy определяет, обязательна ли подпись метрики в поле Hash, если настроены ключи
+type hashPolicy int
+
+const (
+	// hashRequired - метрика обязана нести верную подпись: JSON API, пакеты агента и gRPC v1
+	hashRequired hashPolicy = iota
+	// hashNotCarried - формат не передает подпись метрики: значения из URL, gRPC v2 (защищен подписью запроса)
+	// и внутренние записи StatsD. Подпись проверяется, только если она все же передана.
+	hashNotCarried
+)
+
+// ErrMetricNotAllowed возвращается, когда агент изменяет метрику вне разрешенных его токену префиксов
+var ErrMetricNotAllowed = errors.New("metric is not allowed for agent")
+
+// ErrEmptyPrefix возвращается при попытке удалить метрики по пустому префиксу, то есть все метрики
+var ErrEmptyPrefix = errors.New("prefix cannot be empty")
+
 // NewMetricsService создает новый экземпляр сервиса метрик.
 // Инициализирует хранилище, логгер и ключ для подписи данных.
 func NewMetricsService(storage store.Storage, config *config.ServerConfig, logger *zap.Logger) *MetricsService {
@@ -43,23 +78,66 @@ func NewMetricsService(storage store.Storage, config *config.ServerConfig, logge
 			logger.Fatal("failed to load private key", zap.Error(err))
 		}
 	}
+	signKeys, err := signer.KeyringFromConfig(config.SignKey, config.SignKeysFile)
+	if err != nil {
+		logger.Fatal("failed to load signing keys", zap.Error(err))
+	}
+	if signKeys.Enabled() {
+		logger.Info("signing keys loaded",
+			zap.Strings("ids", signKeys.IDs()),
+			zap.String("active", signKeys.ActiveID()))
+	}
+	ipPolicy, err := netutil.NewIPPolicy(config.TrustedSubnet, config.DeniedSubnets, config.TrustedProxies)
+	if err != nil {
+		logger.Fatal("invalid ip access settings", zap.Error(err))
+	}
+	tokens, err := auth.RegistryFromConfig(context.Background(), config.AuthTokensFile, config.AuthTokensDB, config.DatabaseDSN)
+	if err != nil {
+		logger.Fatal("failed to load agent tokens", zap.Error(err))
+	}
+	auditor, err := audit.FromConfig(config.AuditLogFile, config.AuditWebhookURL, config.AuditQueueSize, logger)
+	if err != nil {
+		logger.Fatal("failed to configure audit", zap.Error(err))
+	}
 	return &MetricsService{
-		storage:       storage,
-		ServerHost:    config.ServerHost,
-		Logger:        logger,
-		signKey:       []byte(config.SignKey),
-		privateKey:    privateKey,
-		trustedSubnet: config.TrustedSubnet,
+		storage:     storage,
+		ServerHost:  config.ServerHost,
+		Logger:      logger,
+		signKeys:    signKeys,
+		replayGuard: signer.NewReplayGuard(time.Duration(config.SignMaxSkewSeconds)*time.Second, config.NonceCacheSize),
+		privateKey:  privateKey,
+		ipPolicy:    ipPolicy,
+		tokens:      tokens,
+		auditor:     auditor,
 	}
 }
 
+// Close останавливает аудит, дожидаясь доставки поставленных в очередь событий до истечения ctx,
+// и закрывает соединение реестра токенов. Вызывается после остановки HTTP и gRPC серверов.
+func (s *MetricsService) Close(ctx context.Context) error {
+	err := s.auditor.Close(ctx)
+	if closer, ok := s.tokens.(io.Closer); ok {
+		err = errors.Join(err, closer.Close())
+	}
+	return err
+}
+
 // UpdateMetrics обновляет несколько метрик с поддержкой повторных попыток.
-// Валидирует все метрики перед обновлением.
+// Валидирует все метрики перед обновлением. Предназначен для внутренних источников (StatsD):
+// подпись метрик не требуется. Метрики агентов принимаются через UpdateMetricsBatch.
 func (s *MetricsService) UpdateMetrics(ctx context.Context, metrics []*models.Metrics) error {
+	return s.updateMetrics(ctx, metrics, hashNotCarried)
+}
+
+// updateMetrics валидирует метрики с требованием к подписи policy и обновляет их с повторными попытками
+func (s *MetricsService) updateMetrics(ctx context.Context, metrics []*models.Metrics, policy hashPolicy) error {
 	var err error
 
 	for _, m := range metrics {
-		mErr := validateMetric(m)
+		mErr := validateMetric(m, s.signKeys, policy)
+		if mErr == nil {
+			mErr = checkMetricAllowed(ctx, m.ID)
+		}
 		if mErr != nil {
 			err = errors.Join(err, mErr)
 		}
@@ -79,13 +157,74 @@ func (s *MetricsService) UpdateMetrics(ctx context.Context, metrics []*models.Me
 	return err
 }
 
+// UpdateMetricsBatch идемпотентно обновляет пакет метрик, присланный агентом.
+// Пакет, уже примененный ранее с тем же agentID и batchID, подтверждается без повторного применения.
+// Если batchID не задан, пакет применяется без дедупликации.
+// Если настроены ключи, каждая метрика должна нести подпись в Hash.
+// applied сообщает, был ли пакет применен этим вызовом: для повтора пакета он false.
+func (s *MetricsService) UpdateMetricsBatch(ctx context.Context, agentID, batchID string, metrics []*models.Metrics) (applied bool, err error) {
+	return s.updateMetricsBatch(ctx, agentID, batchID, metrics, hashRequired)
+}
+
+// updateMetricsBatch реализует UpdateMetricsBatch с требованием к подписи метрик policy
+func (s *MetricsService) updateMetricsBatch(ctx context.Context, agentID, batchID string, metrics []*models.Metrics, policy hashPolicy) (bool, error) {
+	if batchID == "" {
+		err := s.updateMetrics(ctx, metrics, policy)
+		return err == nil, err
+	}
+
+	var err error
+	for _, m := range metrics {
+		mErr := validateMetric(m, s.signKeys, policy)
+		if mErr == nil {
+			mErr = checkMetricAllowed(ctx, m.ID)
+		}
+		if mErr != nil {
+			err = errors.Join(err, mErr)
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+
+	key := batchKey(agentID, batchID)
+	for i := 0; i < maxRetries; i++ {
+		err = s.storage.UpdateMetricsOnce(ctx, key, metrics)
+		if isRetriableError(err) {
+			waitForRetry(i)
+			continue
+		}
+		break
+	}
+	if errors.Is(err, store.ErrDuplicateBatch) {
+		s.Logger.Info("duplicate batch acknowledged without applying",
+			zap.String("agent", agentID),
+			zap.String("batch", batchID))
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// batchKey формирует ключ дедупликации пакета из идентификаторов агента и пакета
+func batchKey(agentID, batchID string) string {
+	return agentID + "/" + batchID
+}
+
 // UpdateMetric обновляет одну метрику с поддержкой повторных попыток.
-// Возвращает обновленную метрику.
+// Возвращает обновленную метрику. Если настроены ключи, метрика должна нести подпись в Hash.
 func (s *MetricsService) UpdateMetric(ctx context.Context, metric *models.Metrics) (*models.Metrics, error) {
+	return s.updateMetric(ctx, metric, hashRequired)
+}
+
+// updateMetric реализует UpdateMetric с требованием к подписи метрики policy
+func (s *MetricsService) updateMetric(ctx context.Context, metric *models.Metrics, policy hashPolicy) (*models.Metrics, error) {
 	var retMetric *models.Metrics
 	var err error
 
-	err = validateMetric(metric)
+	err = validateMetric(metric, s.signKeys, policy)
+	if err == nil {
+		err = checkMetricAllowed(ctx, metric.ID)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -116,6 +255,44 @@ func (s *MetricsService) GetMetric(ctx context.Context, name string) (*models.Me
 	return metric, err
 }
 
+// FindMetric находит серию метрики по имени и матчерам меток.
+// Без матчеров сначала ищется серия без меток; если ее нет, метрика ищется среди всех серий с этим именем.
+// Возвращает store.ErrNotFound, если подходящих серий нет, и ErrAmbiguousSeries, если их несколько.
+func (s *MetricsService) FindMetric(ctx context.Context, id string, matchers map[string]string) (*models.Metrics, error) {
+	metric, err := s.GetMetric(ctx, models.SeriesKey(id, matchers))
+	if err == nil || !errors.Is(err, store.ErrNotFound) {
+		return metric, err
+	}
+
+	var series []*models.Metrics
+	for i := 0; i < maxRetries; i++ {
+		series, err = s.storage.GetMetricsByID(ctx, id)
+		if isRetriableError(err) {
+			waitForRetry(i)
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var found *models.Metrics
+	for _, m := range series {
+		if !m.MatchLabels(matchers) {
+			continue
+		}
+		if found != nil {
+			return nil, ErrAmbiguousSeries
+		}
+		found = m
+	}
+	if found == nil {
+		return nil, store.ErrNotFound
+	}
+	return found, nil
+}
+
 // GetAllMetrics получает все метрики с поддержкой повторных попыток.
 func (s *MetricsService) GetAllMetrics(ctx context.Context) ([]*models.Metrics, error) {
 	var metrics []*models.Metrics
@@ -131,18 +308,155 @@ func (s *MetricsService) GetAllMetrics(ctx context.Context) ([]*models.Metrics,
 	return metrics, err
 }
 
-// validateMetric проверяет корректность метрики перед сохранением
-func validateMetric(m *models.Metrics) error {
-	if (m.Delta == nil && m.Value == nil) ||
-		(m.MType != models.Gauge && m.MType != models.Counter) {
+// ListMetrics получает страницу отфильтрованных метрик с поддержкой повторных попыток.
+func (s *MetricsService) ListMetrics(ctx context.Context, opts store.ListOptions) (*store.MetricsPage, error) {
+	var page *store.MetricsPage
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		page, err = s.storage.ListMetrics(ctx, opts)
+		if isRetriableError(err) {
+			waitForRetry(i)
+			continue
+		}
+		return page, err
+	}
+	return page, err
+}
+
+// GetHistory получает отсчеты метрики за интервал с поддержкой повторных попыток.
+func (s *MetricsService) GetHistory(ctx context.Context, name string, from, to time.Time, step time.Duration) ([]*models.Sample, error) {
+	var samples []*models.Sample
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		samples, err = s.storage.GetHistory(ctx, name, from, to, step)
+		if isRetriableError(err) {
+			waitForRetry(i)
+			continue
+		}
+		return samples, err
+	}
+	return samples, err
+}
+
+// DeleteMetric удаляет серию метрики по ключу с поддержкой повторных попыток.
+func (s *MetricsService) DeleteMetric(ctx context.Context, name string) error {
+	if err := checkMetricAllowed(ctx, name); err != nil {
+		return err
+	}
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		err = s.storage.DeleteMetric(ctx, name)
+		if isRetriableError(err) {
+			waitForRetry(i)
+			continue
+		}
+		break
+	}
+	if err == nil {
+		s.Logger.Info("metric deleted", zap.String("series", name))
+	}
+	return err
+}
+
+// DeleteByPrefix удаляет все серии метрик, имя которых начинается с prefix, с поддержкой повторных попыток.
+// Пустой префикс отклоняется с ErrEmptyPrefix, префикс вне разрешенных агенту - с ErrMetricNotAllowed.
+func (s *MetricsService) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	if prefix == "" {
+		return 0, ErrEmptyPrefix
+	}
+	if err := checkMetricAllowed(ctx, prefix); err != nil {
+		return 0, err
+	}
+	var deleted int
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		deleted, err = s.storage.DeleteByPrefix(ctx, prefix)
+		if isRetriableError(err) {
+			waitForRetry(i)
+			continue
+		}
+		break
+	}
+	if err == nil {
+		s.Logger.Info("metrics deleted by prefix", zap.String("prefix", prefix), zap.Int("count", deleted))
+	}
+	return deleted, err
+}
+
+// ResetCounter обнуляет counter серию по ключу с поддержкой повторных попыток.
+func (s *MetricsService) ResetCounter(ctx context.Context, name string) (*models.Metrics, error) {
+	if err := checkMetricAllowed(ctx, name); err != nil {
+		return nil, err
+	}
+	var metric *models.Metrics
+	var err error
+	for i := 0; i < maxRetries; i++ {
+		metric, err = s.storage.ResetCounter(ctx, name)
+		if isRetriableError(err) {
+			waitForRetry(i)
+			continue
+		}
+		return metric, err
+	}
+	return metric, err
+}
+
+// checkMetricAllowed проверяет, что агент из контекста запроса может изменять метрику или серию name.
+// Запросы без агента в контексте (аутентификация отключена, StatsD, алертинг) не ограничиваются.
+func checkMetricAllowed(ctx context.Context, name string) error {
+	if id := auth.IdentityFromCtx(ctx); id != nil && !id.AllowsMetric(name) {
+		return fmt.Errorf("%w: %s", ErrMetricNotAllowed, name)
+	}
+	return nil
+}
+
+// validateMetric проверяет корректность метрики перед сохранением.
+// Если настроены ключи, подпись метрики в Hash должна совпадать с вычисленной одним из них.
+// Метрика без подписи принимается только с policy hashNotCarried.
+func validateMetric(m *models.Metrics, keys *signer.Keyring, policy hashPolicy) error {
+	switch m.MType {
+	case models.Gauge, models.Counter:
+		if m.Delta == nil && m.Value == nil {
+			return store.ErrInvalidMetricReceived
+		}
+	case models.Histogram:
+		if m.Histogram == nil || m.Histogram.Validate() != nil {
+			return store.ErrInvalidMetricReceived
+		}
+	default:
 		return store.ErrInvalidMetricReceived
 	}
 	if m.ID == "" {
 		return store.ErrNotFound
 	}
+	for name := range m.Labels {
+		if !models.ValidLabelName(name) {
+			return store.ErrInvalidMetricReceived
+		}
+	}
+	if !keys.Enabled() {
+		return nil
+	}
+	if m.Hash == "" {
+		if policy == hashRequired {
+			return ErrMissingMetricHash
+		}
+		return nil
+	}
+	if !keys.VerifyMetric(m) {
+		return ErrInvalidMetricHash
+	}
 	return nil
 }
 
+// signMetric записывает в Hash подпись текущего значения метрики активным ключом, если он настроен.
+// Сохраненная подпись относится к присланному значению, а не к накопленному, поэтому вычисляется заново.
+func (s *MetricsService) signMetric(m *models.Metrics) {
+	if s.signKeys.CanSign() {
+		m.Hash = s.signKeys.SignMetric(m)
+	}
+}
+
 // waitForRetry реализует экспоненциальную задержку для повторных попыток
 func waitForRetry(iter int) {
 	switch iter {
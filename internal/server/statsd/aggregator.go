@@ -0,0 +1,231 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+// MetricsWriter принимает агрегированные метрики и предоставляет текущие значения
+// для относительных gauge. Реализуется MetricsService.
+type MetricsWriter interface {
+	UpdateMetrics(ctx context.Context, metrics []*models.Metrics) error
+	GetMetric(ctx context.Context, name string) (*models.Metrics, error)
+}
+
+// Aggregator накапливает строки StatsD до сброса.
+// Счетчики суммируются с учетом sample rate, для gauge сохраняется последнее значение,
+// длительности собираются в гистограмму с границами в секундах.
+type Aggregator struct {
+	writer  MetricsWriter
+	buckets []float64
+
+	mu sync.Mutex
+	interval
+	// known хранит последние значения gauge между сбросами для относительных изменений
+	known map[string]float64
+}
+
+// interval - значения, накопленные агрегатором с последнего сброса
+type interval struct {
+	counters map[string]float64
+	gauges   map[string]float64
+	timers   map[string]*models.HistogramValue
+}
+
+// newInterval создает пустой интервал накопления
+func newInterval() interval {
+	return interval{
+		counters: map[string]float64{},
+		gauges:   map[string]float64{},
+		timers:   map[string]*models.HistogramValue{},
+	}
+}
+
+// metrics преобразует накопленные значения в метрики. Дробные значения счетчиков округляются до целых.
+func (iv interval) metrics() []*models.Metrics {
+	metrics := make([]*models.Metrics, 0, len(iv.counters)+len(iv.gauges)+len(iv.timers))
+	for name, v := range iv.counters {
+		metrics = append(metrics, models.NewCounterMetric(name, int64(math.Round(v))))
+	}
+	for name, v := range iv.gauges {
+		metrics = append(metrics, models.NewGaugeMetric(name, v))
+	}
+	for name, h := range iv.timers {
+		metrics = append(metrics, models.NewHistogramMetric(name, h))
+	}
+	return metrics
+}
+
+// NewAggregator создает агрегатор, записывающий метрики через writer.
+// buckets задает границы корзин гистограмм длительностей в секундах.
+func NewAggregator(writer MetricsWriter, buckets []float64) *Aggregator {
+	return &Aggregator{
+		writer:   writer,
+		buckets:  buckets,
+		interval: newInterval(),
+		known:    map[string]float64{},
+	}
+}
+
+// Add учитывает разобранную строку.
+// Для относительного gauge без известного значения текущее значение запрашивается у writer
+// до захвата блокировки, чтобы медленное хранилище не задерживало остальные строки.
+func (a *Aggregator) Add(ctx context.Context, line Line) error {
+	var stored float64
+	if line.Kind == KindGauge && line.Relative && !a.isKnown(line.Name) {
+		var err error
+		stored, err = a.storedGauge(ctx, line.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch line.Kind {
+	case KindCounter:
+		a.counters[line.Name] += line.Value / line.SampleRate
+	case KindGauge:
+		value := line.Value
+		if line.Relative {
+			// значение могло появиться в агрегаторе, пока запрашивалось хранилище
+			base, ok := a.known[line.Name]
+			if !ok {
+				base = stored
+			}
+			value += base
+		}
+		a.gauges[line.Name] = value
+		a.known[line.Name] = value
+	case KindTimer:
+		h, ok := a.timers[line.Name]
+		if !ok {
+			h = models.NewHistogram(a.buckets)
+			a.timers[line.Name] = h
+		}
+		n := int64(math.Round(1 / line.SampleRate))
+		h.ObserveN(line.Value/1000, n)
+	}
+	return nil
+}
+
+// isKnown сообщает, известно ли агрегатору последнее значение gauge
+func (a *Aggregator) isKnown(name string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.known[name]
+	return ok
+}
+
+// storedGauge возвращает значение gauge из хранилища, 0 если метрики нет
+func (a *Aggregator) storedGauge(ctx context.Context, name string) (float64, error) {
+	m, err := a.writer.GetMetric(ctx, name)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if m.MType != models.Gauge || m.Value == nil {
+		return 0, nil
+	}
+	return *m.Value, nil
+}
+
+// Flush записывает накопленные за интервал метрики и очищает агрегатор.
+// Дробные значения счетчиков округляются до целых.
+// Если хранилище недоступно, значения интервала возвращаются в агрегатор и попадут в следующий сброс.
+// Если пакет отклонен из-за отдельных метрик, например при совпадении имени counter и gauge,
+// метрики записываются по одной, а отклоненные отбрасываются и не мешают следующим сбросам.
+func (a *Aggregator) Flush(ctx context.Context) error {
+	iv := a.drain()
+	metrics := iv.metrics()
+	if len(metrics) == 0 {
+		return nil
+	}
+	err := a.writer.UpdateMetrics(ctx, metrics)
+	switch {
+	case err == nil:
+		return nil
+	case isRejected(err):
+		return a.flushEach(ctx, iv)
+	default:
+		a.restore(iv)
+		return err
+	}
+}
+
+// flushEach записывает метрики интервала по одной. Отклоненные метрики отбрасываются,
+// а не записанные из-за ошибки хранилища возвращаются в агрегатор.
+// Возвращает объединение ошибок записи.
+func (a *Aggregator) flushEach(ctx context.Context, iv interval) error {
+	failed := newInterval()
+	var errs error
+	write := func(m *models.Metrics, keep func()) {
+		err := a.writer.UpdateMetrics(ctx, []*models.Metrics{m})
+		switch {
+		case err == nil:
+		case isRejected(err):
+			errs = errors.Join(errs, fmt.Errorf("statsd metric %s %s dropped: %w", m.MType, m.ID, err))
+		default:
+			keep()
+			errs = errors.Join(errs, err)
+		}
+	}
+	for name, v := range iv.counters {
+		write(models.NewCounterMetric(name, int64(math.Round(v))), func() { failed.counters[name] = v })
+	}
+	for name, v := range iv.gauges {
+		write(models.NewGaugeMetric(name, v), func() { failed.gauges[name] = v })
+	}
+	for name, h := range iv.timers {
+		write(models.NewHistogramMetric(name, h), func() { failed.timers[name] = h })
+	}
+	a.restore(failed)
+	return errs
+}
+
+// isRejected сообщает, что запись отклонена из-за самих метрик и повтор завершится той же ошибкой
+func isRejected(err error) bool {
+	return errors.Is(err, store.ErrInvalidMetricReceived) || errors.Is(err, store.ErrNotFound)
+}
+
+// drain забирает накопленные значения, оставляя агрегатор пустым
+func (a *Aggregator) drain() interval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	iv := a.interval
+	a.interval = newInterval()
+	return iv
+}
+
+// restore возвращает в агрегатор значения неудачно записанного интервала.
+// Счетчики и длительности складываются с накопленными после drain,
+// gauge восстанавливается, только если новое значение еще не пришло.
+func (a *Aggregator) restore(iv interval) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for name, v := range iv.counters {
+		a.counters[name] += v
+	}
+	for name, v := range iv.gauges {
+		if _, ok := a.gauges[name]; !ok {
+			a.gauges[name] = v
+		}
+	}
+	for name, h := range iv.timers {
+		if cur, ok := a.timers[name]; ok {
+			// границы корзин одинаковы у всех гистограмм агрегатора
+			cur.Merge(h)
+			continue
+		}
+		a.timers[name] = h
+	}
+}
@@ -0,0 +1,95 @@
+// Package statsd реализует прием метрик в формате StatsD по UDP.
+// Разобранные строки агрегируются за интервал сброса и записываются через MetricsService.UpdateMetrics.
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidLine возвращается для строки, не соответствующей формату name:value|type[|@rate]
+var ErrInvalidLine = errors.New("invalid statsd line")
+
+// Kind - тип метрики StatsD
+type Kind string
+
+const (
+	// KindCounter - счетчик (|c)
+	KindCounter Kind = "c"
+	// KindGauge - измеритель (|g), значение со знаком +/- изменяет текущее значение
+	KindGauge Kind = "g"
+	// KindTimer - длительность в миллисекундах (|ms)
+	KindTimer Kind = "ms"
+)
+
+// Line - разобранная строка StatsD
+type Line struct {
+	Name       string  // имя метрики
+	Kind       Kind    // тип метрики
+	Value      float64 // значение
+	SampleRate float64 // доля отправленных измерений, 1 если не указана
+	Relative   bool    // для gauge: значение со знаком изменяет текущее значение
+}
+
+// ParsePacket разбирает UDP пакет, содержащий строки, разделенные переводом строки.
+// Возвращает корректные строки и ошибки для некорректных, пустые строки пропускаются.
+func ParsePacket(packet []byte) ([]Line, []error) {
+	var lines []Line
+	var errs []error
+	for _, raw := range strings.Split(string(packet), "\n") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		line, err := ParseLine(raw)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, errs
+}
+
+// ParseLine разбирает одну строку формата name:value|type[|@rate]
+func ParseLine(raw string) (Line, error) {
+	name, rest, ok := strings.Cut(raw, ":")
+	if !ok || name == "" {
+		return Line{}, fmt.Errorf("%w: %q", ErrInvalidLine, raw)
+	}
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return Line{}, fmt.Errorf("%w: %q", ErrInvalidLine, raw)
+	}
+
+	line := Line{Name: name, Kind: Kind(fields[1]), SampleRate: 1}
+	switch line.Kind {
+	case KindCounter, KindGauge, KindTimer:
+	default:
+		return Line{}, fmt.Errorf("%w: unsupported type %q", ErrInvalidLine, fields[1])
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Line{}, fmt.Errorf("%w: invalid value %q", ErrInvalidLine, fields[0])
+	}
+	line.Value = value
+	if line.Kind == KindGauge && (strings.HasPrefix(fields[0], "+") || strings.HasPrefix(fields[0], "-")) {
+		line.Relative = true
+	}
+
+	for _, f := range fields[2:] {
+		if !strings.HasPrefix(f, "@") {
+			// прочие расширения формата (например теги) не поддерживаются и игнорируются
+			continue
+		}
+		rate, err := strconv.ParseFloat(f[1:], 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			return Line{}, fmt.Errorf("%w: invalid sample rate %q", ErrInvalidLine, f)
+		}
+		line.SampleRate = rate
+	}
+	return line, nil
+}
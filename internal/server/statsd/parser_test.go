@@ -0,0 +1,76 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    Line
+		wantErr bool
+	}{
+		{
+			name: "counter",
+			raw:  "api.requests:3|c",
+			want: Line{Name: "api.requests", Kind: KindCounter, Value: 3, SampleRate: 1},
+		},
+		{
+			name: "counter with sample rate",
+			raw:  "api.requests:1|c|@0.1",
+			want: Line{Name: "api.requests", Kind: KindCounter, Value: 1, SampleRate: 0.1},
+		},
+		{
+			name: "gauge",
+			raw:  "queue.size:42.5|g",
+			want: Line{Name: "queue.size", Kind: KindGauge, Value: 42.5, SampleRate: 1},
+		},
+		{
+			name: "relative gauge increment",
+			raw:  "queue.size:+5|g",
+			want: Line{Name: "queue.size", Kind: KindGauge, Value: 5, SampleRate: 1, Relative: true},
+		},
+		{
+			name: "relative gauge decrement",
+			raw:  "queue.size:-3|g",
+			want: Line{Name: "queue.size", Kind: KindGauge, Value: -3, SampleRate: 1, Relative: true},
+		},
+		{
+			name: "timer",
+			raw:  "db.query:320|ms|@0.5",
+			want: Line{Name: "db.query", Kind: KindTimer, Value: 320, SampleRate: 0.5},
+		},
+		{
+			name: "unknown extension ignored",
+			raw:  "api.requests:1|c|#env:prod",
+			want: Line{Name: "api.requests", Kind: KindCounter, Value: 1, SampleRate: 1},
+		},
+		{name: "no value separator", raw: "api.requests|c", wantErr: true},
+		{name: "no type", raw: "api.requests:1", wantErr: true},
+		{name: "unsupported type", raw: "users:42|s", wantErr: true},
+		{name: "invalid value", raw: "api.requests:abc|c", wantErr: true},
+		{name: "invalid sample rate", raw: "api.requests:1|c|@2", wantErr: true},
+		{name: "empty name", raw: ":1|c", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLine(tt.raw)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLine)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParsePacket(t *testing.T) {
+	lines, errs := ParsePacket([]byte("a:1|c\n\nb:2|g\nbroken\n"))
+	assert.Len(t, lines, 2)
+	assert.Len(t, errs, 1)
+}
@@ -0,0 +1,100 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+	"go.uber.org/zap"
+)
+
+// maxPacketSize - максимальный размер UDP пакета
+const maxPacketSize = 65535
+
+// Server принимает пакеты StatsD по UDP и периодически сбрасывает агрегированные метрики
+type Server struct {
+	conn          net.PacketConn
+	aggregator    *Aggregator
+	flushInterval time.Duration
+	logger        *zap.Logger
+}
+
+// Listen открывает UDP сокет на адресе addr.
+// Длительности |ms собираются в гистограммы с границами models.DefaultBuckets.
+func Listen(addr string, writer MetricsWriter, flushInterval time.Duration, logger *zap.Logger) (*Server, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		conn:          conn,
+		aggregator:    NewAggregator(writer, models.DefaultBuckets),
+		flushInterval: flushInterval,
+		logger:        logger,
+	}, nil
+}
+
+// Addr возвращает адрес, на котором принимаются пакеты
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Serve принимает пакеты до отмены контекста.
+// При остановке закрывает сокет и сбрасывает накопленные метрики.
+func (s *Server) Serve(ctx context.Context) {
+	go s.flushLoop(ctx)
+	go func() {
+		<-ctx.Done()
+		s.conn.Close()
+	}()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				s.flush(context.Background())
+				return
+			}
+			s.logger.Warn("error while reading statsd packet", zap.Error(err))
+			continue
+		}
+		s.handlePacket(ctx, buf[:n])
+	}
+}
+
+// handlePacket разбирает пакет и передает строки агрегатору
+func (s *Server) handlePacket(ctx context.Context, packet []byte) {
+	lines, errs := ParsePacket(packet)
+	for _, err := range errs {
+		s.logger.Debug("statsd line skipped", zap.Error(err))
+	}
+	for _, line := range lines {
+		if err := s.aggregator.Add(ctx, line); err != nil {
+			s.logger.Warn("cant aggregate statsd line", zap.String("name", line.Name), zap.Error(err))
+		}
+	}
+}
+
+// flushLoop сбрасывает метрики с интервалом flushInterval до отмены контекста
+func (s *Server) flushLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.flush(ctx)
+		}
+	}
+}
+
+// flush записывает агрегированные метрики и логирует ошибку записи
+func (s *Server) flush(ctx context.Context) {
+	if err := s.aggregator.Flush(ctx); err != nil {
+		s.logger.Error("cant flush statsd metrics", zap.Error(err))
+	}
+}
@@ -0,0 +1,224 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/internal/store"
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAggregator(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	_, err := storage.UpdateMetric(ctx, models.NewGaugeMetric("queue", 10))
+	require.NoError(t, err)
+	agg := NewAggregator(storage, []float64{0.1, 1})
+
+	for _, raw := range []string{
+		"requests:1|c",
+		"requests:1|c|@0.5",
+		"queue:+5|g",
+		"queue:-2|g",
+		"temp:20|g",
+		"temp:21|g",
+		"query:50|ms",
+		"query:500|ms|@0.5",
+	} {
+		line, err := ParseLine(raw)
+		require.NoError(t, err)
+		require.NoError(t, agg.Add(ctx, line))
+	}
+	require.NoError(t, agg.Flush(ctx))
+
+	requests, err := storage.GetMetric(ctx, "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *requests.Delta)
+	queue, err := storage.GetMetric(ctx, "queue")
+	require.NoError(t, err)
+	assert.Equal(t, 13.0, *queue.Value)
+	temp, err := storage.GetMetric(ctx, "temp")
+	require.NoError(t, err)
+	assert.Equal(t, 21.0, *temp.Value)
+	query, err := storage.GetMetric(ctx, "query")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 0}, query.Histogram.Counts)
+
+	// после сброса накопленные значения очищаются
+	require.NoError(t, agg.Flush(ctx))
+	requests, err = storage.GetMetric(ctx, "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *requests.Delta)
+}
+
+// flakyWriter записывает метрики в хранилище, отклоняя запись, пока установлен fail,
+// и задерживает GetMetric до закрытия release, если он задан, сообщая о вызове в lookups
+type flakyWriter struct {
+	store.Storage
+	fail    atomic.Bool
+	lookups chan struct{}
+	release chan struct{}
+}
+
+func (w *flakyWriter) UpdateMetrics(ctx context.Context, metrics []*models.Metrics) error {
+	if w.fail.Load() {
+		return errors.New("storage is unavailable")
+	}
+	return w.Storage.UpdateMetrics(ctx, metrics)
+}
+
+func (w *flakyWriter) GetMetric(ctx context.Context, name string) (*models.Metrics, error) {
+	if w.release != nil {
+		w.lookups <- struct{}{}
+		<-w.release
+	}
+	return w.Storage.GetMetric(ctx, name)
+}
+
+func TestAggregatorFlushError(t *testing.T) {
+	ctx := context.Background()
+	writer := &flakyWriter{Storage: store.NewMemoryStorage()}
+	agg := NewAggregator(writer, []float64{0.1, 1})
+	add := func(raw string) {
+		line, err := ParseLine(raw)
+		require.NoError(t, err)
+		require.NoError(t, agg.Add(ctx, line))
+	}
+
+	add("requests:2|c")
+	add("temp:20|g")
+	add("query:50|ms")
+	writer.fail.Store(true)
+	require.Error(t, agg.Flush(ctx))
+
+	// значения неудачного сброса объединяются с пришедшими после него
+	add("requests:3|c")
+	add("query:500|ms")
+	writer.fail.Store(false)
+	require.NoError(t, agg.Flush(ctx))
+
+	requests, err := writer.GetMetric(ctx, "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *requests.Delta)
+	temp, err := writer.GetMetric(ctx, "temp")
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, *temp.Value)
+	query, err := writer.GetMetric(ctx, "query")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 1, 0}, query.Histogram.Counts)
+}
+
+func TestAggregatorTypeCollision(t *testing.T) {
+	ctx := context.Background()
+	storage := store.NewMemoryStorage()
+	_, err := storage.UpdateMetric(ctx, models.NewCounterMetric("stored", 1))
+	require.NoError(t, err)
+	agg := NewAggregator(storage, []float64{0.1, 1})
+	add := func(raw string) {
+		line, err := ParseLine(raw)
+		require.NoError(t, err)
+		require.NoError(t, agg.Add(ctx, line))
+	}
+
+	add("foo:1|c")
+	add("foo:1|g")
+	add("stored:5|g")
+	add("requests:2|c")
+	err = agg.Flush(ctx)
+	require.ErrorIs(t, err, store.ErrInvalidMetricReceived)
+
+	// конфликтующие метрики отброшены, остальные записаны
+	foo, err := storage.GetMetric(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, models.Counter, foo.MType)
+	stored, err := storage.GetMetric(ctx, "stored")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *stored.Delta)
+	requests, err := storage.GetMetric(ctx, "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), *requests.Delta)
+
+	// отброшенные значения не возвращаются в агрегатор и не мешают следующим сбросам
+	add("requests:3|c")
+	require.NoError(t, agg.Flush(ctx))
+	requests, err = storage.GetMetric(ctx, "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *requests.Delta)
+	foo, err = storage.GetMetric(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *foo.Delta)
+}
+
+func TestAggregatorRelativeGaugeLookupUnlocked(t *testing.T) {
+	ctx := context.Background()
+	writer := &flakyWriter{Storage: store.NewMemoryStorage(), lookups: make(chan struct{}, 1), release: make(chan struct{})}
+	_, err := writer.Storage.UpdateMetric(ctx, models.NewGaugeMetric("queue", 10))
+	require.NoError(t, err)
+	agg := NewAggregator(writer, []float64{0.1, 1})
+
+	relative, err := ParseLine("queue:+5|g")
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() { done <- agg.Add(ctx, relative) }()
+	<-writer.lookups
+
+	// пока значение запрашивается у хранилища, остальные строки принимаются
+	counter, err := ParseLine("requests:1|c")
+	require.NoError(t, err)
+	added := make(chan error)
+	go func() { added <- agg.Add(ctx, counter) }()
+	select {
+	case err := <-added:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Add blocked by storage lookup")
+	}
+
+	close(writer.release)
+	require.NoError(t, <-done)
+	require.NoError(t, agg.Flush(ctx))
+	queue, err := writer.GetMetric(ctx, "queue")
+	require.NoError(t, err)
+	assert.Equal(t, 15.0, *queue.Value)
+}
+
+func TestServer(t *testing.T) {
+	storage := store.NewMemoryStorage()
+	srv, err := Listen("127.0.0.1:0", storage, time.Hour, zap.NewNop())
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		srv.Serve(ctx)
+		close(done)
+	}()
+
+	conn, err := net.Dial("udp", srv.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hits:2|c\nhits:3|c\nload:0.5|g"))
+	require.NoError(t, err)
+
+	// пакет должен быть обработан до остановки, которая сбрасывает агрегатор
+	require.Eventually(t, func() bool {
+		srv.aggregator.mu.Lock()
+		defer srv.aggregator.mu.Unlock()
+		return len(srv.aggregator.gauges) == 1
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	hits, err := storage.GetMetric(context.Background(), "hits")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *hits.Delta)
+	load, err := storage.GetMetric(context.Background(), "load")
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, *load.Value)
+}
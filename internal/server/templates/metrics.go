@@ -31,6 +31,7 @@ const MetricsTemplate = `
     <table>
         <tr>
             <th>ID</th>
+            <th>Labels</th>
             <th>Type</th>
             <th>Value</th>
             <th>Delta</th>
@@ -39,6 +40,7 @@ const MetricsTemplate = `
         {{range .}}
         <tr>
             <td>{{.ID}}</td>
+            <td>{{.StringifyLabels}}</td>
             <td>{{.MType}}</td>
             <td>{{.StringifyValue}}</td>
             <td>{{.StringifyDelta}}</td>
@@ -1,37 +1,27 @@
 package server
 
 import (
-	"net"
 	"net/http"
 
 	"github.com/Soliard/go-tpl-metrics/internal/netutil"
 	"go.uber.org/zap"
 )
 
-func TrustedSubnetMiddleware(cidr string, logger *zap.Logger) func(http.Handler) http.Handler {
-	// Предварительно парсим CIDR один раз
-	var ipnet *net.IPNet
-	if cidr != "" {
-		if _, n, err := net.ParseCIDR(cidr); err == nil {
-			ipnet = n
-		} else {
-			logger.Warn("invalid trusted_subnet CIDR, middleware will be bypassed", zap.String("cidr", cidr), zap.Error(err))
-		}
-	}
-
+// TrustedSubnetMiddleware пропускает только запросы с адресов, разрешенных политикой.
+// Адрес клиента берется из соединения, а от доверенных прокси - из X-Forwarded-For или X-Real-IP.
+func TrustedSubnetMiddleware(policy *netutil.IPPolicy, logger *zap.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		// Если подсеть не настроена — пропускаем без проверок
-		if cidr == "" || ipnet == nil {
+		// Если ограничения не настроены — пропускаем без проверок
+		if !policy.Enabled() {
 			return next
 		}
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := netutil.ExtractIPFromHTTPRequest(r)
-			if ip == nil {
-				http.Error(w, "forbidden: missing or invalid X-Real-IP", http.StatusForbidden)
-				return
-			}
-			if !ipnet.Contains(ip) {
-				http.Error(w, "forbidden: ip not in trusted subnet", http.StatusForbidden)
+			ip := netutil.ExtractIPFromHTTPRequest(r, policy.Proxies())
+			if !policy.Allowed(ip) {
+				logger.Warn("request from not allowed ip rejected",
+					zap.Stringer("ip", ip),
+					zap.String("remote_addr", r.RemoteAddr))
+				http.Error(w, "forbidden: ip not allowed", http.StatusForbidden)
 				return
 			}
 			next.ServeHTTP(w, r)
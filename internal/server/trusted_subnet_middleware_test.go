@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/internal/netutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTrustedSubnetMiddleware(t *testing.T) {
+	policy, err := netutil.NewIPPolicy("192.168.0.0/16", "192.168.100.0/24", "10.0.0.1")
+	require.NoError(t, err)
+	handler := TrustedSubnetMiddleware(policy, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		realIP       string
+		expectedCode int
+	}{
+		{name: "allowed client", remoteAddr: "192.168.1.5:4000", expectedCode: http.StatusOK},
+		{name: "denied client", remoteAddr: "192.168.100.5:4000", expectedCode: http.StatusForbidden},
+		{name: "client outside allowed subnets", remoteAddr: "172.16.0.5:4000", expectedCode: http.StatusForbidden},
+		{name: "spoofed x-real-ip", remoteAddr: "172.16.0.5:4000", realIP: "192.168.1.5", expectedCode: http.StatusForbidden},
+		{name: "allowed client behind trusted proxy", remoteAddr: "10.0.0.1:4000", realIP: "192.168.1.5", expectedCode: http.StatusOK},
+		{name: "denied client behind trusted proxy", remoteAddr: "10.0.0.1:4000", realIP: "192.168.100.5", expectedCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/update/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestTrustedSubnetMiddlewareDisabled(t *testing.T) {
+	handler := TrustedSubnetMiddleware(nil, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	r := httptest.NewRequest(http.MethodPost, "/update/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,197 @@
+package signer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+// KeyIDHeader - заголовок HTTP и ключ metadata gRPC с идентификатором ключа, которым сделана подпись HashSHA256
+const KeyIDHeader = "HashSHA256-KeyID"
+
+// ErrUnknownKeyID возвращается, когда подпись сделана ключом, отсутствующим в наборе
+var ErrUnknownKeyID = errors.New("unknown signing key id")
+
+// ErrInvalidSignature возвращается, когда подпись не совпадает ни с одним подходящим ключом
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// KeyringFile - формат JSON файла ключей подписи.
+// Для ротации новый ключ сначала добавляется на серверы, затем становится активным на агентах,
+// и только после этого старый ключ удаляется: пока оба ключа в наборе, принимаются подписи любым из них.
+type KeyringFile struct {
+	Active string     `json:"active"` // идентификатор ключа, которым подписываются данные; пустой - только проверка
+	Keys   []KeyEntry `json:"keys"`   // ключи, подписи которыми принимаются
+}
+
+// KeyEntry - ключ подписи с идентификатором
+type KeyEntry struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+}
+
+// Keyring - набор ключей HMAC-SHA256: один активный ключ для подписи и все ключи для проверки.
+// Нулевой указатель соответствует пустому набору: подпись и проверка отключены.
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// NewKeyring создает набор ключей. active должен быть идентификатором ключа из keys или пустым,
+// если набор используется только для проверки.
+func NewKeyring(active string, keys map[string][]byte) (*Keyring, error) {
+	k := &Keyring{active: active, keys: make(map[string][]byte, len(keys))}
+	for id, key := range keys {
+		if !SignKeyExists(key) {
+			return nil, fmt.Errorf("empty key %q", id)
+		}
+		k.keys[id] = key
+	}
+	if _, ok := k.keys[active]; active != "" && !ok {
+		return nil, fmt.Errorf("active key %q is not in keyring", active)
+	}
+	return k, nil
+}
+
+// SingleKeyring создает набор из одного ключа без идентификатора, как при настройке одним ключом.
+// Пустой ключ дает пустой набор.
+func SingleKeyring(key []byte) *Keyring {
+	k := &Keyring{keys: map[string][]byte{}}
+	if SignKeyExists(key) {
+		k.keys[""] = key
+	}
+	return k
+}
+
+// LoadKeyring читает набор ключей из JSON файла в формате KeyringFile
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file KeyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cant parse keyring file: %w", err)
+	}
+	keys := make(map[string][]byte, len(file.Keys))
+	for _, e := range file.Keys {
+		if e.ID == "" {
+			return nil, errors.New("keyring file contains key without id")
+		}
+		if _, ok := keys[e.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", e.ID)
+		}
+		keys[e.ID] = []byte(e.Key)
+	}
+	return NewKeyring(file.Active, keys)
+}
+
+// KeyringFromConfig создает набор ключей из настроек: ключа без идентификатора и файла ключей.
+// Ключ без идентификатора остается в наборе вместе с ключами из файла, что позволяет перейти на файл без простоя;
+// активным становится ключ, указанный в файле, а если он не указан - ключ без идентификатора.
+func KeyringFromConfig(signKey, keysFile string) (*Keyring, error) {
+	k := SingleKeyring([]byte(signKey))
+	if keysFile == "" {
+		return k, nil
+	}
+	loaded, err := LoadKeyring(keysFile)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := k.keys[""]; ok {
+		loaded.keys[""] = key
+	}
+	return loaded, nil
+}
+
+// Enabled проверяет, что в наборе есть ключи и подписи нужно проверять
+func (k *Keyring) Enabled() bool {
+	return k != nil && len(k.keys) > 0
+}
+
+// CanSign проверяет, что в наборе есть активный ключ для подписи
+func (k *Keyring) CanSign() bool {
+	if k == nil {
+		return false
+	}
+	_, ok := k.keys[k.active]
+	return ok
+}
+
+// ActiveID возвращает идентификатор активного ключа; пустой для ключа без идентификатора
+func (k *Keyring) ActiveID() string {
+	if k == nil {
+		return ""
+	}
+	return k.active
+}
+
+// IDs возвращает отсортированные идентификаторы ключей набора
+func (k *Keyring) IDs() []string {
+	if k == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Sign подписывает данные активным ключом. Возвращает nil, если активного ключа нет.
+func (k *Keyring) Sign(data []byte) []byte {
+	if !k.CanSign() {
+		return nil
+	}
+	return Sign(data, k.keys[k.active])
+}
+
+// Verify проверяет подпись данных ключом keyID.
+// Если идентификатор не передан, подпись принимается при совпадении с любым ключом набора.
+func (k *Keyring) Verify(data []byte, keyID string, signature []byte) error {
+	if !k.Enabled() {
+		return ErrInvalidSignature
+	}
+	if keyID != "" {
+		key, ok := k.keys[keyID]
+		if !ok {
+			return ErrUnknownKeyID
+		}
+		if !Verify(data, key, signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	for _, key := range k.keys {
+		if Verify(data, key, signature) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// SignMetric вычисляет подпись метрики активным ключом. Возвращает пустую строку, если активного ключа нет.
+func (k *Keyring) SignMetric(m *models.Metrics) string {
+	if !k.CanSign() {
+		return ""
+	}
+	return SignMetric(m, k.keys[k.active])
+}
+
+// VerifyMetric проверяет подпись метрики из поля Hash любым ключом набора.
+// Подпись отдельной метрики не несет идентификатор ключа.
+func (k *Keyring) VerifyMetric(m *models.Metrics) bool {
+	if k == nil {
+		return false
+	}
+	for _, key := range k.keys {
+		if VerifyMetric(m, key) {
+			return true
+		}
+	}
+	return false
+}
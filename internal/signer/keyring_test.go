@@ -0,0 +1,163 @@
+package signer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+func writeKeyringFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestLoadKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantIDs []string
+		active  string
+		wantErr bool
+	}{
+		{
+			name:    "active and previous keys",
+			content: `{"active":"2025-02","keys":[{"id":"2025-01","key":"old"},{"id":"2025-02","key":"new"}]}`,
+			wantIDs: []string{"2025-01", "2025-02"},
+			active:  "2025-02",
+		},
+		{
+			name:    "verification only",
+			content: `{"keys":[{"id":"2025-01","key":"old"}]}`,
+			wantIDs: []string{"2025-01"},
+		},
+		{name: "invalid json", content: `{`, wantErr: true},
+		{name: "key without id", content: `{"keys":[{"key":"old"}]}`, wantErr: true},
+		{name: "empty key", content: `{"keys":[{"id":"a","key":""}]}`, wantErr: true},
+		{name: "duplicate id", content: `{"keys":[{"id":"a","key":"x"},{"id":"a","key":"y"}]}`, wantErr: true},
+		{name: "unknown active", content: `{"active":"b","keys":[{"id":"a","key":"x"}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := LoadKeyring(writeKeyringFile(t, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadKeyring() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := keys.IDs(); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("IDs() = %v, want %v", got, tt.wantIDs)
+			}
+			if got := keys.ActiveID(); got != tt.active {
+				t.Errorf("ActiveID() = %q, want %q", got, tt.active)
+			}
+			if got := keys.CanSign(); got != (tt.active != "") {
+				t.Errorf("CanSign() = %v, want %v", got, tt.active != "")
+			}
+		})
+	}
+
+	if _, err := LoadKeyring(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadKeyring() expected error for missing file")
+	}
+}
+
+func TestKeyringFromConfig(t *testing.T) {
+	path := writeKeyringFile(t, `{"active":"new","keys":[{"id":"new","key":"new secret"}]}`)
+	tests := []struct {
+		name     string
+		signKey  string
+		keysFile string
+		wantIDs  []string
+		active   string
+		enabled  bool
+	}{
+		{name: "disabled", wantIDs: []string{}},
+		{name: "single key", signKey: "legacy", wantIDs: []string{""}, enabled: true},
+		{name: "keys file", keysFile: path, wantIDs: []string{"new"}, active: "new", enabled: true},
+		{name: "legacy key kept with file", signKey: "legacy", keysFile: path, wantIDs: []string{"", "new"}, active: "new", enabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := KeyringFromConfig(tt.signKey, tt.keysFile)
+			if err != nil {
+				t.Fatalf("KeyringFromConfig() error = %v", err)
+			}
+			if got := keys.IDs(); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("IDs() = %v, want %v", got, tt.wantIDs)
+			}
+			if got := keys.ActiveID(); got != tt.active {
+				t.Errorf("ActiveID() = %q, want %q", got, tt.active)
+			}
+			if got := keys.Enabled(); got != tt.enabled {
+				t.Errorf("Enabled() = %v, want %v", got, tt.enabled)
+			}
+		})
+	}
+}
+
+func TestKeyringVerify(t *testing.T) {
+	keys, err := NewKeyring("new", map[string][]byte{"old": []byte("old secret"), "new": []byte("new secret")})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	data := []byte("payload")
+
+	tests := []struct {
+		name      string
+		keys      *Keyring
+		keyID     string
+		signature []byte
+		wantErr   error
+	}{
+		{name: "active key", keys: keys, keyID: "new", signature: keys.Sign(data)},
+		{name: "previous key", keys: keys, keyID: "old", signature: Sign(data, []byte("old secret"))},
+		{name: "any key without id", keys: keys, signature: Sign(data, []byte("old secret"))},
+		{name: "wrong key for id", keys: keys, keyID: "new", signature: Sign(data, []byte("old secret")), wantErr: ErrInvalidSignature},
+		{name: "unknown id", keys: keys, keyID: "retired", signature: keys.Sign(data), wantErr: ErrUnknownKeyID},
+		{name: "unknown key without id", keys: keys, signature: Sign(data, []byte("other")), wantErr: ErrInvalidSignature},
+		{name: "nil keyring", signature: keys.Sign(data), wantErr: ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.keys.Verify(data, tt.keyID, tt.signature); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyringMetric(t *testing.T) {
+	previous, err := NewKeyring("old", map[string][]byte{"old": []byte("old secret")})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	rotated, err := NewKeyring("new", map[string][]byte{"old": []byte("old secret"), "new": []byte("new secret")})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	m := models.NewGaugeMetric("Alloc", 1.5)
+	m.Hash = previous.SignMetric(m)
+	if !rotated.VerifyMetric(m) {
+		t.Error("VerifyMetric() rejected metric signed with previous key")
+	}
+
+	m.Hash = rotated.SignMetric(m)
+	if previous.VerifyMetric(m) {
+		t.Error("VerifyMetric() accepted metric signed with key outside keyring")
+	}
+
+	var empty *Keyring
+	if got := empty.SignMetric(m); got != "" {
+		t.Errorf("SignMetric() on nil keyring = %q, want empty", got)
+	}
+}
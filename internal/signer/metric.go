@@ -0,0 +1,64 @@
+package signer
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+// MetricPayload возвращает каноническое представление метрики для подписи
+// в формате "<ключ серии>:<тип>:<значение>". Ключ серии совпадает с именем для метрики без меток,
+// а с метками защищает и их от подмены. Поле Hash в подпись не входит.
+func MetricPayload(m *models.Metrics) []byte {
+	var b strings.Builder
+	b.WriteString(m.SeriesKey())
+	b.WriteByte(':')
+	b.WriteString(m.MType)
+	b.WriteByte(':')
+	switch m.MType {
+	case models.Counter:
+		if m.Delta != nil {
+			b.WriteString(strconv.FormatInt(*m.Delta, 10))
+		}
+	case models.Gauge:
+		if m.Value != nil {
+			b.WriteString(strconv.FormatFloat(*m.Value, 'g', -1, 64))
+		}
+	case models.Histogram:
+		if h := m.Histogram; h != nil {
+			for i, bound := range h.Bounds {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(strconv.FormatFloat(bound, 'g', -1, 64))
+			}
+			b.WriteByte(';')
+			for i, count := range h.Counts {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(strconv.FormatInt(count, 10))
+			}
+			b.WriteByte(';')
+			b.WriteString(strconv.FormatFloat(h.Sum, 'g', -1, 64))
+			b.WriteByte(';')
+			b.WriteString(strconv.FormatInt(h.Count, 10))
+		}
+	}
+	return []byte(b.String())
+}
+
+// SignMetric вычисляет HMAC-SHA256 подпись метрики в hex для поля Hash.
+func SignMetric(m *models.Metrics, key []byte) string {
+	return EncodeSign(Sign(MetricPayload(m), key))
+}
+
+// VerifyMetric проверяет подпись метрики из поля Hash.
+func VerifyMetric(m *models.Metrics, key []byte) bool {
+	signature, err := DecodeSign(m.Hash)
+	if err != nil {
+		return false
+	}
+	return Verify(MetricPayload(m), key, signature)
+}
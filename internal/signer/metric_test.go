@@ -0,0 +1,85 @@
+package signer
+
+import (
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+func TestMetricPayload(t *testing.T) {
+	hist := models.NewHistogram([]float64{0.5, 1})
+	hist.Observe(0.25)
+	tests := []struct {
+		name   string
+		metric *models.Metrics
+		want   string
+	}{
+		{
+			name:   "gauge",
+			metric: models.NewGaugeMetric("Alloc", 1.5),
+			want:   "Alloc:gauge:1.5",
+		},
+		{
+			name:   "counter",
+			metric: models.NewCounterMetric("PollCount", 5),
+			want:   "PollCount:counter:5",
+		},
+		{
+			name:   "labels are part of series",
+			metric: &models.Metrics{ID: "requests", MType: models.Counter, Delta: models.PInt(1), Labels: map[string]string{"host": "a"}},
+			want:   `requests{host="a"}:counter:1`,
+		},
+		{
+			name:   "histogram",
+			metric: models.NewHistogramMetric("latency", hist),
+			want:   "latency:histogram:0.5,1;1,0,0;0.25;1",
+		},
+		{
+			name:   "hash is not signed",
+			metric: &models.Metrics{ID: "Alloc", MType: models.Gauge, Value: models.PFloat(2), Hash: "abc"},
+			want:   "Alloc:gauge:2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(MetricPayload(tt.metric)); got != tt.want {
+				t.Errorf("MetricPayload() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyMetric(t *testing.T) {
+	key := []byte("secret key")
+	signed := models.NewGaugeMetric("Alloc", 1.5)
+	signed.Hash = SignMetric(signed, key)
+
+	tampered := *signed
+	tampered.Value = models.PFloat(2.5)
+
+	renamed := *signed
+	renamed.ID = "Frees"
+
+	malformed := *signed
+	malformed.Hash = "not hex"
+
+	tests := []struct {
+		name   string
+		metric *models.Metrics
+		key    []byte
+		want   bool
+	}{
+		{name: "valid hash", metric: signed, key: key, want: true},
+		{name: "wrong key", metric: signed, key: []byte("other"), want: false},
+		{name: "tampered value", metric: &tampered, key: key, want: false},
+		{name: "tampered id", metric: &renamed, key: key, want: false},
+		{name: "malformed hash", metric: &malformed, key: key, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyMetric(tt.metric, tt.key); got != tt.want {
+				t.Errorf("VerifyMetric() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -28,12 +28,12 @@ func (r *SignedResponseWriter) WriteHeader(statusCode int) {
 }
 
 // SignResponseMiddleware создает middleware для подписи исходящих ответов.
-// Добавляет HMAC-SHA256 подпись в заголовок HashSHA256.
-func SignResponseMiddleware(key []byte, fallbackLogger *zap.Logger) func(next http.Handler) http.Handler {
+// Добавляет HMAC-SHA256 подпись активным ключом в заголовок HashSHA256 и идентификатор ключа в KeyIDHeader.
+func SignResponseMiddleware(keys *Keyring, fallbackLogger *zap.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			logger := logger.LoggerFromCtx(r.Context(), fallbackLogger)
-			if !SignKeyExists(key) {
+			if !keys.CanSign() {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -45,8 +45,11 @@ func SignResponseMiddleware(key []byte, fallbackLogger *zap.Logger) func(next ht
 			logger.Info("response will be signed")
 			next.ServeHTTP(sw, r)
 
-			signature := Sign(sw.bodyBuffer.Bytes(), key)
+			signature := keys.Sign(sw.bodyBuffer.Bytes())
 			w.Header().Set("HashSHA256", EncodeSign(signature))
+			if id := keys.ActiveID(); id != "" {
+				w.Header().Set(KeyIDHeader, id)
+			}
 			if sw.statusCode != 0 {
 				w.WriteHeader(sw.statusCode)
 			}
@@ -57,12 +60,14 @@ func SignResponseMiddleware(key []byte, fallbackLogger *zap.Logger) func(next ht
 }
 
 // VerifySignatureMiddleware создает middleware для проверки входящих запросов.
-// Проверяет HMAC-SHA256 подпись в заголовке HashSHA256.
-func VerifySignatureMiddleware(key []byte, fallbackLogger *zap.Logger) func(next http.Handler) http.Handler {
+// Проверяет HMAC-SHA256 подпись в заголовке HashSHA256 ключом из заголовка KeyIDHeader,
+// а без него - любым ключом набора. Подписываются метод, путь с параметрами и тело запроса. Если включена защита от повторов, запрос должен содержать
+// время подписи и одноразовое значение в заголовках TimestampHeader и NonceHeader.
+func VerifySignatureMiddleware(keys *Keyring, replay *ReplayGuard, fallbackLogger *zap.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			logger := logger.LoggerFromCtx(r.Context(), fallbackLogger)
-			if !SignKeyExists(key) {
+			if !keys.Enabled() {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -95,10 +100,26 @@ func VerifySignatureMiddleware(key []byte, fallbackLogger *zap.Logger) func(next
 			// восстановление тела
 			r.Body = io.NopCloser(bytes.NewBuffer(body))
 
-			if !Verify(body, key, signature) {
-				logger.Warn("invalid signature")
+			timestamp, nonce := r.Header.Get(TimestampHeader), r.Header.Get(NonceHeader)
+			if replay.Enabled() && (timestamp == "" || nonce == "") {
+				logger.Warn("recieved signed request without nonce")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": ErrMissingNonce.Error()})
+				return
+			}
+
+			keyID := r.Header.Get(KeyIDHeader)
+			request := HTTPRequestLine(r.Method, r.URL.RequestURI())
+			if err := keys.Verify(SignedPayload(request, timestamp, nonce, body), keyID, signature); err != nil {
+				logger.Warn("invalid signature", zap.String("key_id", keyID), zap.Error(err))
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			if err := replay.Check(timestamp, nonce); err != nil {
+				logger.Warn("replayed or stale request rejected", zap.String("timestamp", timestamp), zap.Error(err))
 				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(map[string]string{"error": "invalid signature"})
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 				return
 			}
 			next.ServeHTTP(w, r)
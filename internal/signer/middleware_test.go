@@ -39,7 +39,7 @@ func TestSignResponseMiddleware(t *testing.T) {
 				w.Write([]byte(tt.responseBody))
 			})
 
-			middleware := SignResponseMiddleware(tt.key, logger)
+			middleware := SignResponseMiddleware(SingleKeyring(tt.key), logger)
 			wrappedHandler := middleware(handler)
 
 			req := httptest.NewRequest("GET", "/", nil)
@@ -66,7 +66,7 @@ func TestVerifySignatureMiddleware(t *testing.T) {
 
 	key := []byte("secret")
 	data := []byte("test data")
-	signature := Sign(data, key)
+	signature := Sign(SignedPayload(HTTPRequestLine("POST", "/"), "", "", data), key)
 	signatureHex := EncodeSign(signature)
 
 	tests := []struct {
@@ -104,6 +104,20 @@ func TestVerifySignatureMiddleware(t *testing.T) {
 			signature:      EncodeSign([]byte("wrong")),
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "body signature without request line",
+			key:            key,
+			requestBody:    string(data),
+			signature:      EncodeSign(Sign(data, key)),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "signature of another method",
+			key:            key,
+			requestBody:    string(data),
+			signature:      EncodeSign(Sign(SignedPayload(HTTPRequestLine("DELETE", "/"), "", "", data), key)),
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "empty key",
 			key:            []byte(""),
@@ -119,7 +133,7 @@ func TestVerifySignatureMiddleware(t *testing.T) {
 				w.WriteHeader(http.StatusOK)
 			})
 
-			middleware := VerifySignatureMiddleware(tt.key, logger)
+			middleware := VerifySignatureMiddleware(SingleKeyring(tt.key), nil, logger)
 			wrappedHandler := middleware(handler)
 
 			req := httptest.NewRequest("POST", "/", bytes.NewBufferString(tt.requestBody))
@@ -139,6 +153,64 @@ func TestVerifySignatureMiddleware(t *testing.T) {
 	}
 }
 
+func TestVerifySignatureMiddlewareKeyID(t *testing.T) {
+	logger := zap.NewNop()
+	keys, err := NewKeyring("new", map[string][]byte{"old": []byte("old secret"), "new": []byte("new secret")})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	data := []byte("test data")
+
+	tests := []struct {
+		name           string
+		signKey        []byte
+		keyID          string
+		expectedStatus int
+	}{
+		{name: "active key", signKey: []byte("new secret"), keyID: "new", expectedStatus: http.StatusOK},
+		{name: "previous key", signKey: []byte("old secret"), keyID: "old", expectedStatus: http.StatusOK},
+		{name: "without key id", signKey: []byte("old secret"), expectedStatus: http.StatusOK},
+		{name: "key id mismatch", signKey: []byte("old secret"), keyID: "new", expectedStatus: http.StatusBadRequest},
+		{name: "unknown key id", signKey: []byte("old secret"), keyID: "retired", expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := VerifySignatureMiddleware(keys, nil, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest("POST", "/", bytes.NewReader(data))
+			req.Header.Set("HashSHA256", EncodeSign(Sign(SignedPayload("POST /", "", "", data), tt.signKey)))
+			if tt.keyID != "" {
+				req.Header.Set(KeyIDHeader, tt.keyID)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestSignResponseMiddlewareKeyID(t *testing.T) {
+	keys, err := NewKeyring("v2", map[string][]byte{"v2": []byte("secret")})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+	handler := SignResponseMiddleware(keys, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test response"))
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Header().Get(KeyIDHeader); got != "v2" {
+		t.Errorf("%s = %q, want %q", KeyIDHeader, got, "v2")
+	}
+	signature, err := DecodeSign(w.Header().Get("HashSHA256"))
+	if err != nil || !Verify([]byte("test response"), []byte("secret"), signature) {
+		t.Errorf("response signature is not made with active key")
+	}
+}
+
 func TestSignedResponseWriter(t *testing.T) {
 	body := []byte("test response")
 	statusCode := http.StatusOK
@@ -0,0 +1,148 @@
+package signer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TimestampHeader - заголовок HTTP и ключ metadata gRPC со временем подписи запроса в секундах Unix
+const TimestampHeader = "HashSHA256-Timestamp"
+
+// NonceHeader - заголовок HTTP и ключ metadata gRPC с одноразовым случайным значением запроса
+const NonceHeader = "HashSHA256-Nonce"
+
+// ErrMissingNonce возвращается, когда у подписанного запроса нет времени подписи или одноразового значения
+var ErrMissingNonce = errors.New("missing request timestamp or nonce")
+
+// ErrStaleRequest возвращается, когда время подписи запроса выходит за допустимое расхождение часов
+var ErrStaleRequest = errors.New("request timestamp outside allowed window")
+
+// ErrReplayedRequest возвращается, когда одноразовое значение запроса уже использовалось
+var ErrReplayedRequest = errors.New("request nonce already used")
+
+// SignedPayload возвращает данные для подписи запроса.
+// Строка запроса request (см. HTTPRequestLine, для gRPC - полное имя метода) входит в подпись,
+// чтобы подпись запроса без тела нельзя было применить к другому пути или методу.
+// Время подписи и одноразовое значение входят в подпись, чтобы их нельзя было заменить при повторе запроса.
+func SignedPayload(request, timestamp, nonce string, body []byte) []byte {
+	data := make([]byte, 0, len(request)+len(timestamp)+len(nonce)+3+len(body))
+	data = append(data, request...)
+	data = append(data, '\n')
+	data = append(data, timestamp...)
+	data = append(data, '\n')
+	data = append(data, nonce...)
+	data = append(data, '\n')
+	return append(data, body...)
+}
+
+// HTTPRequestLine возвращает строку HTTP запроса для подписи: метод, путь и параметры запроса
+func HTTPRequestLine(method, requestURI string) string {
+	return method + " " + requestURI
+}
+
+// NewNonce возвращает время подписи и случайное одноразовое значение для нового запроса
+func NewNonce() (timestamp, nonce string, err error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	return strconv.FormatInt(time.Now().Unix(), 10), hex.EncodeToString(b), nil
+}
+
+// nonceEntry - запомненное одноразовое значение со временем подписи запроса
+type nonceEntry struct {
+	nonce     string
+	timestamp int64
+}
+
+// ReplayGuard отклоняет повторы подписанных запросов: время подписи должно отличаться от текущего
+// не больше чем на окно, а одноразовое значение не должно встречаться в пределах окна.
+// Одноразовые значения хранятся в кольцевом буфере ограниченного размера. Если буфер заполнен
+// значениями, которые еще в окне, самое старое вытесняется, а запросы с временем подписи не новее
+// вытесненного отклоняются: так повтор не проходит даже после вытеснения.
+// Нулевой указатель соответствует отключенной защите.
+type ReplayGuard struct {
+	window int64
+	now    func() time.Time
+
+	mu      sync.Mutex
+	seen    map[string]struct{}
+	entries []nonceEntry
+	head    int
+	count   int
+	// floor - наибольшее время подписи среди вытесненных до истечения окна значений
+	floor int64
+}
+
+// NewReplayGuard создает защиту от повторов с допустимым расхождением часов window
+// и не более чем size запомненными одноразовыми значениями. Возвращает nil, если window не положительное.
+func NewReplayGuard(window time.Duration, size int) *ReplayGuard {
+	if window <= 0 || size <= 0 {
+		return nil
+	}
+	return &ReplayGuard{
+		window:  int64(window / time.Second),
+		now:     time.Now,
+		seen:    make(map[string]struct{}, size),
+		entries: make([]nonceEntry, size),
+		floor:   -1 << 63,
+	}
+}
+
+// Enabled проверяет, что защита от повторов включена
+func (g *ReplayGuard) Enabled() bool {
+	return g != nil
+}
+
+// Check проверяет время подписи и запоминает одноразовое значение запроса.
+// Вызывается только после проверки подписи, иначе чужие запросы могли бы занять буфер.
+func (g *ReplayGuard) Check(timestamp, nonce string) error {
+	if !g.Enabled() {
+		return nil
+	}
+	if timestamp == "" || nonce == "" {
+		return ErrMissingNonce
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrStaleRequest
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now().Unix()
+	if ts < now-g.window || ts > now+g.window || ts <= g.floor {
+		return ErrStaleRequest
+	}
+	// значения с истекшим окном больше не нужны: такие запросы отклоняются по времени
+	for g.count > 0 && g.entries[g.head].timestamp < now-g.window {
+		g.pop()
+	}
+	if _, ok := g.seen[nonce]; ok {
+		return ErrReplayedRequest
+	}
+	if g.count == len(g.entries) {
+		if evicted := g.pop(); evicted.timestamp > g.floor {
+			g.floor = evicted.timestamp
+		}
+	}
+	g.entries[(g.head+g.count)%len(g.entries)] = nonceEntry{nonce: nonce, timestamp: ts}
+	g.count++
+	g.seen[nonce] = struct{}{}
+	return nil
+}
+
+// pop удаляет самое старое запомненное значение
+func (g *ReplayGuard) pop() nonceEntry {
+	e := g.entries[g.head]
+	g.entries[g.head] = nonceEntry{}
+	delete(g.seen, e.nonce)
+	g.head = (g.head + 1) % len(g.entries)
+	g.count--
+	return e
+}
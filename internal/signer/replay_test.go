@@ -0,0 +1,165 @@
+package signer
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSignedPayload(t *testing.T) {
+	body := []byte("body")
+	request := HTTPRequestLine("POST", "/updates/?x=1")
+	if got, want := string(SignedPayload(request, "", "", body)), "POST /updates/?x=1\n\n\nbody"; got != want {
+		t.Errorf("SignedPayload() without nonce = %q, want %q", got, want)
+	}
+	if got, want := string(SignedPayload(request, "100", "abc", body)), "POST /updates/?x=1\n100\nabc\nbody"; got != want {
+		t.Errorf("SignedPayload() = %q, want %q", got, want)
+	}
+}
+
+func TestNewNonce(t *testing.T) {
+	ts1, nonce1, err := NewNonce()
+	if err != nil {
+		t.Fatalf("NewNonce() error = %v", err)
+	}
+	_, nonce2, err := NewNonce()
+	if err != nil {
+		t.Fatalf("NewNonce() error = %v", err)
+	}
+	if nonce1 == "" || nonce1 == nonce2 {
+		t.Errorf("NewNonce() nonces %q and %q must be random", nonce1, nonce2)
+	}
+	if _, err := strconv.ParseInt(ts1, 10, 64); err != nil {
+		t.Errorf("NewNonce() timestamp %q is not unix seconds", ts1)
+	}
+}
+
+func TestReplayGuard(t *testing.T) {
+	now := time.Unix(1000, 0)
+	g := NewReplayGuard(time.Minute, 3)
+	g.now = func() time.Time { return now }
+
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		wantErr   error
+	}{
+		{name: "fresh request", timestamp: "1000", nonce: "a"},
+		{name: "replayed nonce", timestamp: "1000", nonce: "a", wantErr: ErrReplayedRequest},
+		{name: "clock skew in window", timestamp: "1050", nonce: "b"},
+		{name: "too old", timestamp: "939", nonce: "c", wantErr: ErrStaleRequest},
+		{name: "too far in future", timestamp: "1061", nonce: "c", wantErr: ErrStaleRequest},
+		{name: "invalid timestamp", timestamp: "yesterday", nonce: "c", wantErr: ErrStaleRequest},
+		{name: "missing nonce", timestamp: "1000", wantErr: ErrMissingNonce},
+		{name: "missing timestamp", nonce: "c", wantErr: ErrMissingNonce},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := g.Check(tt.timestamp, tt.nonce); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReplayGuardBounded(t *testing.T) {
+	now := time.Unix(1000, 0)
+	g := NewReplayGuard(time.Minute, 2)
+	g.now = func() time.Time { return now }
+
+	for i, nonce := range []string{"a", "b", "c"} {
+		if err := g.Check(strconv.Itoa(990+i), nonce); err != nil {
+			t.Fatalf("Check(%q) error = %v", nonce, err)
+		}
+	}
+	if len(g.seen) != 2 {
+		t.Errorf("cache holds %d nonces, want 2", len(g.seen))
+	}
+	// "a" вытеснен до истечения окна, но его повтор отклоняется по времени подписи
+	if err := g.Check("990", "a"); !errors.Is(err, ErrStaleRequest) {
+		t.Errorf("Check() replay of evicted nonce error = %v, want %v", err, ErrStaleRequest)
+	}
+	if err := g.Check("1000", "d"); err != nil {
+		t.Errorf("Check() newer request error = %v", err)
+	}
+
+	// по истечении окна значения удаляются и не вытесняют новые
+	now = now.Add(2 * time.Minute)
+	if err := g.Check(strconv.FormatInt(now.Unix(), 10), "e"); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if len(g.seen) != 1 {
+		t.Errorf("cache holds %d nonces after window, want 1", len(g.seen))
+	}
+}
+
+func TestReplayGuardDisabled(t *testing.T) {
+	g := NewReplayGuard(0, 10)
+	if g.Enabled() {
+		t.Fatal("NewReplayGuard(0) must disable replay protection")
+	}
+	if err := g.Check("", ""); err != nil {
+		t.Errorf("Check() on disabled guard error = %v", err)
+	}
+}
+
+func TestVerifySignatureMiddlewareReplay(t *testing.T) {
+	key := []byte("secret")
+	body := []byte("test data")
+	handler := VerifySignatureMiddleware(SingleKeyring(key), NewReplayGuard(time.Minute, 10), zap.NewNop())(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	send := func(timestamp, nonce string, signed []byte) int {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		req.Header.Set("HashSHA256", EncodeSign(Sign(signed, key)))
+		if timestamp != "" {
+			req.Header.Set(TimestampHeader, timestamp)
+		}
+		if nonce != "" {
+			req.Header.Set(NonceHeader, nonce)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	tests := []struct {
+		name           string
+		timestamp      string
+		nonce          string
+		signed         []byte
+		expectedStatus int
+	}{
+		{name: "fresh request", timestamp: now, nonce: "n1", signed: SignedPayload("POST /", now, "n1", body), expectedStatus: http.StatusOK},
+		{name: "replayed request", timestamp: now, nonce: "n1", signed: SignedPayload("POST /", now, "n1", body), expectedStatus: http.StatusBadRequest},
+		{name: "stale request", timestamp: old, nonce: "n2", signed: SignedPayload("POST /", old, "n2", body), expectedStatus: http.StatusBadRequest},
+		{name: "nonce not signed", timestamp: now, nonce: "n3", signed: SignedPayload("POST /", "", "", body), expectedStatus: http.StatusBadRequest},
+		{name: "nonce replaced", timestamp: now, nonce: "n4", signed: SignedPayload("POST /", now, "n1", body), expectedStatus: http.StatusBadRequest},
+		{name: "without nonce", signed: SignedPayload("POST /", "", "", body), expectedStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := send(tt.timestamp, tt.nonce, tt.signed); got != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, got)
+			}
+		})
+	}
+
+	// запрос с неверной подписью не занимает одноразовое значение
+	if got := send(now, "n5", body); got != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, got)
+	}
+	if got := send(now, "n5", SignedPayload("POST /", now, "n5", body)); got != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, got)
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/internal/config"
+)
+
+const (
+	// dedupWindowCapacity ограничивает количество запоминаемых ключей пакетов одного агента
+	dedupWindowCapacity = 10000
+	// dedupWindowTTL - время, в течение которого повтор пакета распознается как дубликат
+	dedupWindowTTL = config.BatchDedupTTL
+)
+
+// dedupEntry - ключ примененного пакета и время его применения
+type dedupEntry struct {
+	Key  string    `json:"key"`
+	Seen time.Time `json:"seen"`
+}
+
+// dedupWindow - ограниченное окно ключей примененных пакетов.
+// Ключи вытесняются по достижении емкости агента или по истечении TTL,
+// поэтому частые пакеты одного агента не вытесняют ключи других агентов.
+type dedupWindow struct {
+	capacity int
+	ttl      time.Duration
+	seen     map[string]time.Time
+	// agents - ключи пакетов каждого агента в порядке применения
+	agents map[string][]dedupEntry
+}
+
+// newDedupWindow создает окно дедупликации с заданной емкостью на агента и TTL
+func newDedupWindow(capacity int, ttl time.Duration) *dedupWindow {
+	return &dedupWindow{
+		capacity: capacity,
+		ttl:      ttl,
+		seen:     map[string]time.Time{},
+		agents:   map[string][]dedupEntry{},
+	}
+}
+
+// contains проверяет, применялся ли пакет с таким ключом в пределах окна
+func (w *dedupWindow) contains(key string, now time.Time) bool {
+	w.evict(now)
+	_, ok := w.seen[key]
+	return ok
+}
+
+// add запоминает ключ примененного пакета
+func (w *dedupWindow) add(key string, now time.Time) {
+	if _, ok := w.seen[key]; ok {
+		return
+	}
+	w.seen[key] = now
+	agent := batchAgent(key)
+	w.agents[agent] = append(w.agents[agent], dedupEntry{Key: key, Seen: now})
+	w.evict(now)
+}
+
+// size возвращает количество ключей в окне
+func (w *dedupWindow) size() int {
+	return len(w.seen)
+}
+
+// entries возвращает ключи окна в порядке применения
+func (w *dedupWindow) entries() []dedupEntry {
+	all := make([]dedupEntry, 0, len(w.seen))
+	for _, order := range w.agents {
+		all = append(all, order...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Seen.Before(all[j].Seen)
+	})
+	return all
+}
+
+// evict удаляет устаревшие ключи и ключи сверх емкости агента
+func (w *dedupWindow) evict(now time.Time) {
+	for agent, order := range w.agents {
+		drop := 0
+		for drop < len(order) {
+			e := order[drop]
+			if len(order)-drop <= w.capacity && now.Sub(e.Seen) < w.ttl {
+				break
+			}
+			delete(w.seen, e.Key)
+			drop++
+		}
+		switch {
+		case drop == len(order):
+			delete(w.agents, agent)
+		case drop > 0:
+			w.agents[agent] = append(order[:0], order[drop:]...)
+		}
+	}
+}
+
+// batchAgent возвращает идентификатор агента из ключа пакета вида agent/batch
+func batchAgent(key string) string {
+	if i := strings.LastIndexByte(key, '/'); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
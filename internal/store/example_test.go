@@ -83,7 +83,7 @@ func ExampleStorage_GetAllMetrics() {
 	for _, m := range metrics {
 		fmt.Printf("- %s (%s)\n", m.ID, m.MType)
 	}
-	// Output:
+	// Unordered output:
 	// Total metrics: 3
 	// - cpu (gauge)
 	// - requests (counter)
@@ -1,79 +1,562 @@
 package store
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/Soliard/go-tpl-metrics/models"
 )
 
 // fileStorage реализует Storage интерфейс для хранения метрик в файле.
-// Использует memStorage для работы в памяти и периодически сохраняет данные в файл.
+// Использует memStorage для работы в памяти и сохраняет снимок метрик в файл:
+// при нулевом интервале после каждой записи, иначе - в фоне раз в интервал, если были изменения.
+// Между снимками каждое обновление дописывается в журнал упреждающей записи,
+// который применяется поверх снимка при восстановлении и очищается после сохранения снимка.
+// История значений дописывается в отдельный сегмент рядом с основным файлом, а запросы истории
+// обслуживаются из памяти: сегмент читается один раз при восстановлении и переписывается
+// без устаревших отсчетов, когда вырастает вдвое.
+// Записи сериализуются mu, чтобы файлы не перезаписывались одновременно, чтение метрик идет из памяти без блокировки.
 type fileStorage struct {
-	memory   *memStorage
-	filePath string
+	mu          sync.RWMutex
+	memory      *memStorage
+	filePath    string
+	historyPath string
+	batchesPath string
+	batchLines  int
+	walPath     string
+	// walSize - текущий размер журнала, compactSize - размер, после которого журнал сворачивается в снимок
+	walSize     int64
+	compactSize int64
+	// historyLines - количество строк в сегменте истории, historyKept - сколько их осталось после сворачивания.
+	// Сегмент сворачивается, когда historyLines вдвое больше historyKept, но не меньше historyCompactLines.
+	historyLines        int
+	historyKept         int
+	historyCompactLines int
+
+	storeInterval time.Duration
+	// dirty - в памяти есть изменения, еще не сохраненные в файл. Защищено mu.
+	dirty     bool
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// historyRecord - строка сегмента истории в формате JSON lines.
+// ID содержит ключ серии метрики. Запись с Deleted отмечает удаление серии:
+// отсчеты до нее не относятся к серии, созданной заново с тем же ключом.
+type historyRecord struct {
+	ID      string `json:"id"`
+	Deleted bool   `json:"deleted,omitempty"`
+	models.Sample
 }
 
 // NewFileStorage создает новое файловое хранилище.
 // Если isRestore=true, пытается восстановить данные из существующего файла.
-func NewFileStorage(filePath string, isRestore bool) (Storage, error) {
+// При storeInterval > 0 снимок метрик сохраняется в фоне с этим интервалом,
+// при storeInterval = 0 - синхронно после каждой записи. Close выполняет последнее сохранение.
+func NewFileStorage(filePath string, isRestore bool, storeInterval time.Duration) (Storage, error) {
+	s := &fileStorage{
+		filePath:      filePath,
+		historyPath:   filePath + ".history",
+		batchesPath:   filePath + ".batches",
+		walPath:       filePath + ".wal",
+		compactSize:   walCompactSize,
+		storeInterval: storeInterval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+
+		historyCompactLines: historyCapacity,
+	}
+
 	metrics := map[string]*models.Metrics{}
 	if isRestore {
-		metricsFromFile, err := restoreFromFile(filePath)
+		metricsFromFile, err := restoreFromFile(filePath, s.walPath)
 		if err != nil {
 			return nil, err
 		}
 		metrics = metricsFromFile
+		if info, err := os.Stat(s.walPath); err == nil && info.Size() > 0 {
+			// записи журнала попадут в файл со следующим снимком
+			s.walSize = info.Size()
+			s.dirty = true
+		}
+	}
+	s.memory = newMemStorage(metrics)
+
+	if isRestore {
+		lines, err := restoreBatches(s.batchesPath, s.memory.batches)
+		if err != nil {
+			return nil, err
+		}
+		s.batchLines = lines
+		if s.historyLines, err = restoreHistory(s.historyPath, s.memory); err != nil {
+			return nil, err
+		}
+	} else {
+		// без восстановления история, окно дедупликации и журнал начинаются заново вместе с метриками
+		for _, path := range []string{s.historyPath, s.batchesPath, s.walPath} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+	}
+
+	if storeInterval > 0 {
+		go s.flushLoop()
+	} else {
+		close(s.done)
+	}
+	return s, nil
+}
+
+// flushLoop сохраняет снимок метрик раз в storeInterval до вызова Close.
+// При ошибке изменения остаются несохраненными и записываются на следующем тике.
+func (s *fileStorage) flushLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.storeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Flush()
+		}
+	}
+}
+
+// Flush сохраняет снимок метрик в файл, если в памяти есть несохраненные изменения
+func (s *fileStorage) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	if err := s.saveMemoryToFile(); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// Close останавливает фоновое сохранение и записывает несохраненные изменения в файл
+func (s *fileStorage) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+	return s.Flush()
+}
+
+// persist сохраняет подготовленные состояния серий и применяет их к памяти.
+// В синхронном режиме память изменяется и сразу сохраняется снимком, а если снимок не записан - откатывается.
+// Иначе состояния сначала дописываются в журнал и попадают в память только после успешной записи,
+// снимок помечается для фонового сохранения. Разросшийся журнал сворачивается в снимок сразу;
+// ошибка сворачивания не отменяет изменений, они уже в журнале. Вызывается под s.mu.
+func (s *fileStorage) persist(states []*models.Metrics) error {
+	if s.storeInterval == 0 {
+		rollback := s.memory.commitUpdates(states)
+		if err := s.saveMemoryToFile(); err != nil {
+			rollback()
+			return err
+		}
+		return nil
+	}
+	n, err := appendWAL(s.walPath, states)
+	if err != nil {
+		// не примененные в памяти записи не должны восстановиться из журнала при перезапуске
+		if n > 0 {
+			os.Truncate(s.walPath, s.walSize)
+		}
+		return err
+	}
+	s.walSize += n
+	s.memory.commitUpdates(states)
+	s.dirty = true
+	if s.walSize >= s.compactSize && s.saveMemoryToFile() == nil {
+		s.dirty = false
 	}
+	return nil
+}
 
-	return &fileStorage{
-		memory:   &memStorage{metrics: metrics},
-		filePath: filePath,
-	}, nil
+// writeStates записывает состояния серий в историю и журнал или снимок и применяет их к памяти.
+// Ошибка означает, что память не изменена. Вызывается под s.mu.
+func (s *fileStorage) writeStates(states []*models.Metrics) error {
+	now := time.Now()
+	records := make([]historyRecord, 0, len(states))
+	for _, st := range states {
+		records = append(records, newHistoryRecord(st, now))
+	}
+	if err := s.appendHistory(records...); err != nil {
+		return err
+	}
+	if err := s.persist(states); err != nil {
+		return err
+	}
+	s.memory.recordSamples(states, now)
+	s.maybeCompactHistory()
+	return nil
 }
 
 // UpdateMetrics обновляет метрики в памяти и сохраняет в файл
 func (s *fileStorage) UpdateMetrics(ctx context.Context, metrics []*models.Metrics) error {
-	err := s.memory.UpdateMetrics(ctx, metrics)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateMetrics(ctx, metrics)
+}
+
+// updateMetrics применяет пакет целиком или не применяет вовсе: новые состояния всех серий
+// вычисляются и проверяются заранее, а в память попадают только после записи в файл,
+// поэтому ошибка типа в середине пакета или ошибка записи не оставляют частичных изменений
+// и повтор пакета не применит значения дважды. Вызывается под s.mu.
+func (s *fileStorage) updateMetrics(_ context.Context, metrics []*models.Metrics) error {
+	states, err := s.memory.prepareUpdates(metrics)
 	if err != nil {
 		return err
 	}
-	err = s.saveMemoryToFile()
+	return s.writeStates(states)
+}
+
+// UpdateMetricsOnce обновляет метрики пакета, если ключ пакета не встречался в окне дедупликации.
+// Ключ примененного пакета дописывается в файл окна, чтобы дубликаты распознавались и после перезапуска.
+func (s *fileStorage) UpdateMetricsOnce(ctx context.Context, batchKey string, metrics []*models.Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen, err := s.memory.applyOnce(batchKey, func() error {
+		return s.updateMetrics(ctx, metrics)
+	})
 	if err != nil {
 		return err
 	}
-	return nil
+	return s.saveBatch(dedupEntry{Key: batchKey, Seen: seen})
 }
 
-// UpdateMetric обновляет одну метрику в памяти и сохраняет в файл
+// UpdateMetric обновляет одну метрику и сохраняет в файл. При ошибке память не изменяется.
 func (s *fileStorage) UpdateMetric(ctx context.Context, metric *models.Metrics) (*models.Metrics, error) {
-	m, err := s.memory.UpdateMetric(ctx, metric)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states, err := s.memory.prepareUpdates([]*models.Metrics{metric})
 	if err != nil {
-		return m, err
+		return nil, err
 	}
-	err = s.saveMemoryToFile()
-	if err != nil {
-		return m, err
+	if err := s.writeStates(states); err != nil {
+		return nil, err
 	}
-	return m, nil
+	return states[0].Clone(), nil
 }
 
-// GetMetric получает метрику по имени из памяти
+// GetMetric получает метрику по ключу серии из памяти
 func (s *fileStorage) GetMetric(ctx context.Context, name string) (*models.Metrics, error) {
 	return s.memory.GetMetric(ctx, name)
 }
 
+// GetMetricsByID возвращает все серии метрики с указанным именем из памяти
+func (s *fileStorage) GetMetricsByID(ctx context.Context, id string) ([]*models.Metrics, error) {
+	return s.memory.GetMetricsByID(ctx, id)
+}
+
 // GetAllMetrics возвращает все метрики из памяти
 func (s *fileStorage) GetAllMetrics(ctx context.Context) ([]*models.Metrics, error) {
 	return s.memory.GetAllMetrics(ctx)
 }
 
-// restoreFromFile восстанавливает метрики из JSON файла
-func restoreFromFile(filePath string) (map[string]*models.Metrics, error) {
+// ListMetrics возвращает страницу метрик из памяти
+func (s *fileStorage) ListMetrics(ctx context.Context, opts ListOptions) (*MetricsPage, error) {
+	return s.memory.ListMetrics(ctx, opts)
+}
+
+// GetHistory возвращает отсчеты метрики из памяти, куда сегмент истории загружен при восстановлении
+func (s *fileStorage) GetHistory(ctx context.Context, name string, from, to time.Time, step time.Duration) ([]*models.Sample, error) {
+	return s.memory.GetHistory(ctx, name, from, to, step)
+}
+
+// DeleteMetric удаляет серию метрики и сразу сохраняет снимок без нее,
+// чтобы удаленная серия не восстановилась из журнала. Серия удаляется из памяти только после записи.
+func (s *fileStorage) DeleteMetric(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.memory.get(name) == nil {
+		return ErrNotFound
+	}
+	return s.commitDelete(name)
+}
+
+// DeleteByPrefix удаляет серии метрик с именем, начинающимся с prefix, и сразу сохраняет снимок без них
+func (s *fileStorage) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := s.memory.seriesByPrefix(prefix)
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := s.commitDelete(keys...); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// commitDelete отмечает удаление серий в сегменте истории, сохраняет снимок без них и только затем
+// удаляет серии из памяти, поэтому при ошибке записи серии остаются на месте.
+// Сохранение снимка очищает журнал, в котором остались записи удаленных серий. Вызывается под s.mu.
+func (s *fileStorage) commitDelete(keys ...string) error {
+	records := make([]historyRecord, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, historyRecord{ID: key, Deleted: true, Sample: models.Sample{Timestamp: time.Now()}})
+	}
+	if err := s.appendHistory(records...); err != nil {
+		return err
+	}
+	if err := s.saveMemoryToFile(keys...); err != nil {
+		return err
+	}
+	s.memory.dropSeries(keys...)
+	s.dirty = false
+	s.maybeCompactHistory()
+	return nil
+}
+
+// ResetCounter обнуляет значение counter серии и сохраняет его как обычное обновление
+func (s *fileStorage) ResetCounter(ctx context.Context, name string) (*models.Metrics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.memory.get(name)
+	if m == nil {
+		return nil, ErrNotFound
+	}
+	if m.MType != models.Counter {
+		return nil, ErrInvalidMetricReceived
+	}
+	m.Delta = models.PInt(0)
+	if err := s.writeStates([]*models.Metrics{m}); err != nil {
+		return nil, err
+	}
+	return m.Clone(), nil
+}
+
+// newHistoryRecord создает запись сегмента истории из состояния метрики на момент at
+func newHistoryRecord(m *models.Metrics, at time.Time) historyRecord {
+	return historyRecord{ID: m.SeriesKey(), Sample: *models.NewSample(m, at)}
+}
+
+// appendHistory дописывает записи в конец сегмента истории
+func (s *fileStorage) appendHistory(records ...historyRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	os.MkdirAll(filepath.Dir(s.historyPath), 0755)
+	file, err := os.OpenFile(s.historyPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	s.historyLines += len(records)
+	return nil
+}
+
+// maybeCompactHistory сворачивает сегмент истории, если он вырос вдвое с последнего сворачивания.
+// Ошибка не влияет на уже записанные изменения: сегмент останется прежним до следующей попытки. Вызывается под s.mu.
+func (s *fileStorage) maybeCompactHistory() {
+	if s.historyLines >= 2*max(s.historyKept, s.historyCompactLines) {
+		s.compactHistory()
+	}
+}
+
+// compactHistory переписывает сегмент истории отсчетами, хранимыми в памяти:
+// не больше historyCapacity на серию и не старше historyRetention.
+// Записи удаленных серий и вытесненные отсчеты при этом отбрасываются. Вызывается под s.mu.
+func (s *fileStorage) compactHistory() error {
+	series := s.memory.pruneHistory(time.Now().Add(-historyRetention))
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	lines := 0
+	for _, key := range keys {
+		for _, sample := range series[key] {
+			if err := enc.Encode(historyRecord{ID: key, Sample: *sample}); err != nil {
+				return err
+			}
+			lines++
+		}
+	}
+	if err := writeFileAtomic(s.historyPath, buf.Bytes()); err != nil {
+		return err
+	}
+	s.historyLines = lines
+	s.historyKept = lines
+	return nil
+}
+
+// restoreHistory загружает в память отсчеты из сегмента истории, кроме старше historyRetention.
+// Запись об удалении серии отбрасывает ее предыдущие отсчеты. Поврежденные строки,
+// например оборванная при сбое последняя запись, пропускаются. Возвращает количество строк сегмента.
+func restoreHistory(path string, memory *memStorage) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	cutoff := time.Now().Add(-historyRetention)
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+		var rec historyRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		if rec.Deleted {
+			memory.dropHistory(rec.ID)
+			continue
+		}
+		if rec.Timestamp.Before(cutoff) {
+			continue
+		}
+		sample := rec.Sample
+		memory.restoreSample(rec.ID, &sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("cant read history: %w", err)
+	}
+	return lines, nil
+}
+
+// saveBatch дописывает ключ пакета в файл окна дедупликации.
+// Когда файл разрастается вдвое больше окна, он перезаписывается актуальными ключами.
+func (s *fileStorage) saveBatch(entry dedupEntry) error {
+	if s.batchLines >= 2*max(s.memory.batchCount(), s.memory.batches.capacity) {
+		return s.rewriteBatches()
+	}
+	os.MkdirAll(filepath.Dir(s.batchesPath), 0755)
+	file, err := os.OpenFile(s.batchesPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := json.NewEncoder(file).Encode(entry); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	s.batchLines++
+	return nil
+}
+
+// rewriteBatches атомарно перезаписывает файл окна дедупликации актуальными ключами
+func (s *fileStorage) rewriteBatches() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	entries := s.memory.batchEntries()
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(s.batchesPath, buf.Bytes()); err != nil {
+		return err
+	}
+	s.batchLines = len(entries)
+	return nil
+}
+
+// restoreBatches загружает ключи пакетов из файла окна дедупликации.
+// Чтение останавливается на первой поврежденной или оборванной при сбое записи, и файл обрезается до нее,
+// чтобы следующие ключи не дописывались после мусора. Возвращает количество прочитанных строк.
+func restoreBatches(path string, window *dedupWindow) (int, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0666)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	var (
+		lines  int
+		offset int64
+	)
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		var e dedupEntry
+		if err != nil || json.Unmarshal(line, &e) != nil {
+			if err := file.Truncate(offset); err != nil {
+				return 0, fmt.Errorf("cant truncate batches: %w", err)
+			}
+			if err := file.Sync(); err != nil {
+				return 0, err
+			}
+			break
+		}
+		window.add(e.Key, e.Seen)
+		lines++
+		offset += int64(len(line))
+	}
+	window.evict(time.Now())
+	return lines, nil
+}
+
+// fileFormatVersion - текущая версия формата файла хранилища.
+// Версия 1 (без поля version) - объект {"id": метрика}, версия 2 - список метрик с метками.
+const fileFormatVersion = 2
+
+// fileSnapshot - содержимое файла хранилища версии 2
+type fileSnapshot struct {
+	Version int               `json:"version"`
+	Metrics []*models.Metrics `json:"metrics"`
+}
+
+// restoreFromFile восстанавливает метрики из JSON файла и применяет поверх них журнал walPath.
+// Поддерживает файлы версии 1 без меток и версии 2 с метками.
+func restoreFromFile(filePath, walPath string) (map[string]*models.Metrics, error) {
+	metrics, err := restoreSnapshot(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := replayWAL(walPath, metrics); err != nil {
+		return nil, fmt.Errorf("cant replay wal: %w", err)
+	}
+	return metrics, nil
+}
+
+// restoreSnapshot читает метрики из JSON файла снимка
+func restoreSnapshot(filePath string) (map[string]*models.Metrics, error) {
 	// в любом случае создаем директории и файл
 	os.MkdirAll(filepath.Dir(filePath), 0755)
 	file, err := os.OpenFile(filePath, os.O_RDONLY|os.O_CREATE, 0666)
@@ -90,30 +573,112 @@ func restoreFromFile(filePath string) (map[string]*models.Metrics, error) {
 	if string(b) == "" {
 		return metrics, nil
 	}
-	err = json.Unmarshal(b, &metrics)
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(b, &fields)
 	if err != nil {
 		return nil, fmt.Errorf("cant unmarshal (restore) data from storage file: %v", err)
 	}
-	return metrics, nil
+	var version int
+	if raw, ok := fields["version"]; !ok || json.Unmarshal(raw, &version) != nil {
+		// версия 1: метрика с именем version была бы объектом, а не числом
+		version = 1
+	}
 
+	switch version {
+	case 1:
+		legacy := map[string]*models.Metrics{}
+		if err := json.Unmarshal(b, &legacy); err != nil {
+			return nil, fmt.Errorf("cant unmarshal (restore) data from storage file: %v", err)
+		}
+		for _, m := range legacy {
+			metrics[m.SeriesKey()] = m
+		}
+	case fileFormatVersion:
+		var snapshot fileSnapshot
+		if err := json.Unmarshal(b, &snapshot); err != nil {
+			return nil, fmt.Errorf("cant unmarshal (restore) data from storage file: %v", err)
+		}
+		for _, m := range snapshot.Metrics {
+			metrics[m.SeriesKey()] = m
+		}
+	default:
+		return nil, fmt.Errorf("unsupported storage file version %d", version)
+	}
+	return metrics, nil
 }
 
-// saveMemoryToFile сохраняет метрики из памяти в JSON файл
-func (s *fileStorage) saveMemoryToFile() error {
-	os.MkdirAll(filepath.Dir(s.filePath), 0755)
-	file, err := os.OpenFile(s.filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+// saveMemoryToFile сохраняет метрики из памяти в JSON файл текущей версии формата.
+// Файл заменяется атомарно, поэтому сбой во время записи оставляет предыдущий снимок целым.
+// Серии с ключами skip в снимок не попадают.
+func (s *fileStorage) saveMemoryToFile(skip ...string) error {
+	all, err := s.memory.GetAllMetrics(context.Background())
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	skipped := make(map[string]bool, len(skip))
+	for _, key := range skip {
+		skipped[key] = true
+	}
+	metrics := make([]*models.Metrics, 0, len(all))
+	for _, m := range all {
+		if !skipped[m.SeriesKey()] {
+			metrics = append(metrics, m)
+		}
+	}
+	snapshot := fileSnapshot{
+		Version: fileFormatVersion,
+		Metrics: metrics,
+	}
+	sort.Slice(snapshot.Metrics, func(i, j int) bool {
+		return snapshot.Metrics[i].SeriesKey() < snapshot.Metrics[j].SeriesKey()
+	})
 
-	data, err := json.MarshalIndent(s.memory.metrics, "", "  ")
+	data, err := json.MarshalIndent(snapshot, "", "  ")
 	if err != nil {
 		return err
 	}
-	_, err = file.Write(data)
+	if err := writeFileAtomic(s.filePath, data); err != nil {
+		return err
+	}
+	// снимок содержит все записи журнала
+	if s.walSize > 0 {
+		if err := resetWAL(s.walPath); err != nil {
+			return err
+		}
+		s.walSize = 0
+	}
+	return nil
+}
+
+// writeFileAtomic записывает данные во временный файл рядом с path, сбрасывает его на диск
+// и переименовывает в path, после чего сбрасывает на диск директорию
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	os.MkdirAll(dir, 0755)
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	// без сброса директории переименование может не пережить сбой питания
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
 	return nil
 }
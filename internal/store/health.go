@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"runtime/metrics"
+)
+
+// HealthCheck - именованная проверка готовности хранилища
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthChecker реализуется хранилищами, которые умеют проверять свою готовность.
+// Все хранилища пакета его реализуют; хранилище без проверок считается готовым.
+type HealthChecker interface {
+	// HealthChecks возвращает проверки, которые должны проходить, чтобы хранилище принимало запросы
+	HealthChecks() []HealthCheck
+}
+
+// ErrMemoryPressure возвращается, когда процесс использует почти всю память, разрешенную GOMEMLIMIT
+var ErrMemoryPressure = errors.New("memory usage is close to the limit")
+
+// memoryPressureRatio - доля лимита памяти, после которой хранилище в памяти считается неготовым
+const memoryPressureRatio = 0.9
+
+// memoryMetrics - метрики runtime, по которым сборщик мусора считает использование памяти для GOMEMLIMIT
+var memoryMetrics = []string{"/memory/classes/total:bytes", "/memory/classes/heap/released:bytes"}
+
+// checkMemoryPressure проверяет, что использование памяти не приблизилось к GOMEMLIMIT.
+// Без лимита проверка всегда проходит.
+func checkMemoryPressure(_ context.Context) error {
+	return checkMemoryLimit(debug.SetMemoryLimit(-1))
+}
+
+// checkMemoryLimit сравнивает использование памяти процессом с лимитом limit
+func checkMemoryLimit(limit int64) error {
+	if limit == math.MaxInt64 {
+		return nil
+	}
+	samples := make([]metrics.Sample, len(memoryMetrics))
+	for i, name := range memoryMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	used := samples[0].Value.Uint64() - samples[1].Value.Uint64()
+	if float64(used) > memoryPressureRatio*float64(limit) {
+		return fmt.Errorf("%w: %d of %d bytes used", ErrMemoryPressure, used, limit)
+	}
+	return nil
+}
+
+// checkDirWritable проверяет, что в каталоге можно создать и записать файл
+func checkDirWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".healthcheck-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write([]byte("ok"))
+	return errors.Join(err, file.Close())
+}
+
+// HealthChecks проверяет запас памяти до GOMEMLIMIT
+func (s *memStorage) HealthChecks() []HealthCheck {
+	return []HealthCheck{{Name: "memory", Check: checkMemoryPressure}}
+}
+
+// HealthChecks проверяет запас памяти и возможность записи снимка и журнала в каталог файла хранилища
+func (s *fileStorage) HealthChecks() []HealthCheck {
+	return []HealthCheck{
+		{Name: "memory", Check: checkMemoryPressure},
+		{Name: "file", Check: func(context.Context) error {
+			return checkDirWritable(filepath.Dir(s.filePath))
+		}},
+	}
+}
+
+// HealthChecks проверяет соединение с базой данных и применение всех встроенных миграций
+func (s *DatabaseStorage) HealthChecks() []HealthCheck {
+	return []HealthCheck{
+		{Name: "database", Check: s.Ping},
+		{Name: "migrations", Check: s.checkMigrations},
+	}
+}
+
+// checkMigrations сравнивает версию схемы базы данных с последней встроенной миграцией
+func (s *DatabaseStorage) checkMigrations(ctx context.Context) error {
+	var version uint
+	var dirty bool
+	err := s.db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		return fmt.Errorf("cant read schema version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < s.latestMigration {
+		return fmt.Errorf("schema version %d is behind latest migration %d", version, s.latestMigration)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckMemoryLimit(t *testing.T) {
+	assert.NoError(t, checkMemoryLimit(math.MaxInt64), "no limit")
+	assert.NoError(t, checkMemoryLimit(1<<50))
+	assert.ErrorIs(t, checkMemoryLimit(1), ErrMemoryPressure)
+}
+
+func TestFileStorageHealthChecks(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewFileStorage(filepath.Join(dir, "data", "metrics.json"), false, 0)
+	require.NoError(t, err)
+	checks := storage.(HealthChecker).HealthChecks()
+	require.Len(t, checks, 2)
+	file := checks[1]
+	require.Equal(t, "file", file.Name)
+	assert.NoError(t, file.Check(context.Background()))
+
+	entries, err := os.ReadDir(filepath.Join(dir, "data"))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "check leaves no files behind")
+
+	// каталог хранилища подменен файлом: записать снимок невозможно
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "data")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data"), nil, 0644))
+	assert.Error(t, file.Check(context.Background()))
+}
+
+func TestMemStorageHealthChecks(t *testing.T) {
+	checks := NewMemoryStorage().(HealthChecker).HealthChecks()
+	require.Len(t, checks, 1)
+	assert.Equal(t, "memory", checks[0].Name)
+	assert.NoError(t, checks[0].Check(context.Background()))
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+// historyCapacity ограничивает количество отсчетов истории, хранимых для одной серии
+const historyCapacity = 4096
+
+// historyRetention - срок хранения отсчетов истории во всех хранилищах.
+// Более старые отсчеты удаляются из памяти при записи и чтении истории серии,
+// из файла - при сворачивании сегмента истории, из базы - при записи новых отсчетов.
+const historyRetention = 7 * 24 * time.Hour
+
+// sampleRing - кольцевой буфер отсчетов истории одной метрики.
+// При заполнении новые отсчеты перезаписывают самые старые.
+type sampleRing struct {
+	samples  []*models.Sample
+	capacity int
+	start    int
+}
+
+// newSampleRing создает кольцевой буфер заданной емкости.
+// Память под отсчеты выделяется по мере заполнения.
+func newSampleRing(capacity int) *sampleRing {
+	return &sampleRing{capacity: capacity}
+}
+
+// push добавляет отсчет в буфер
+func (r *sampleRing) push(s *models.Sample) {
+	if len(r.samples) < r.capacity {
+		r.samples = append(r.samples, s)
+		return
+	}
+	r.samples[r.start] = s
+	r.start = (r.start + 1) % r.capacity
+}
+
+// since возвращает отсчеты не старше cutoff в хронологическом порядке
+func (r *sampleRing) since(cutoff time.Time) []*models.Sample {
+	result := make([]*models.Sample, 0, len(r.samples))
+	for i := 0; i < len(r.samples); i++ {
+		s := r.samples[(r.start+i)%len(r.samples)]
+		if !s.Timestamp.Before(cutoff) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// prune удаляет из буфера отсчеты старше cutoff.
+// Отсчеты добавляются в хронологическом порядке, поэтому без устаревших отсчетов проверяется только самый старый.
+func (r *sampleRing) prune(cutoff time.Time) {
+	if len(r.samples) == 0 || !r.samples[r.start].Timestamp.Before(cutoff) {
+		return
+	}
+	kept := r.since(cutoff)
+	if len(kept) == len(r.samples) {
+		return
+	}
+	r.samples = kept
+	r.start = 0
+}
+
+// rangeQuery возвращает отсчеты из интервала [from, to] в хронологическом порядке
+func (r *sampleRing) rangeQuery(from, to time.Time) []*models.Sample {
+	result := make([]*models.Sample, 0)
+	for i := 0; i < len(r.samples); i++ {
+		s := r.samples[(r.start+i)%len(r.samples)]
+		if inRange(s.Timestamp, from, to) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// inRange проверяет, что момент времени попадает в интервал [from, to]
+func inRange(ts, from, to time.Time) bool {
+	return !ts.Before(from) && !ts.After(to)
+}
+
+// downsample прореживает отсчеты с шагом step: для каждого интервала длиной step,
+// отсчитываемого от from, остается последний попавший в него отсчет.
+// При step <= 0 отсчеты возвращаются без изменений.
+func downsample(samples []*models.Sample, from time.Time, step time.Duration) []*models.Sample {
+	if step <= 0 || len(samples) == 0 {
+		return samples
+	}
+	result := make([]*models.Sample, 0, len(samples))
+	lastBucket := int64(-1)
+	for _, s := range samples {
+		bucket := int64(s.Timestamp.Sub(from) / step)
+		if bucket == lastBucket {
+			result[len(result)-1] = s
+			continue
+		}
+		result = append(result, s)
+		lastBucket = bucket
+	}
+	return result
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+// ErrInvalidListOptions возвращается для некорректных параметров выборки метрик
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// ListOptions - параметры фильтрации и постраничной выборки метрик.
+// Метрики упорядочиваются по имени, серии одной метрики - по ключу серии.
+type ListOptions struct {
+	Type   string // тип метрики, пустой - любой
+	Prefix string // префикс имени метрики
+	Match  string // glob шаблон имени метрики в синтаксисе path.Match
+	Limit  int    // максимальное количество метрик на странице, 0 - без ограничения
+	Cursor string // курсор из MetricsPage.NextCursor предыдущей страницы
+}
+
+// MetricsPage - страница выборки метрик
+type MetricsPage struct {
+	Metrics    []*models.Metrics
+	NextCursor string // курсор следующей страницы, пустой для последней страницы
+}
+
+// listPosition - позиция метрики в порядке выборки, закодированная в курсоре
+type listPosition struct {
+	id  string
+	key string
+}
+
+// less сравнивает позиции в порядке выборки: по имени, затем по ключу серии
+func (p listPosition) less(other listPosition) bool {
+	if p.id != other.id {
+		return p.id < other.id
+	}
+	return p.key < other.key
+}
+
+// positionOf возвращает позицию метрики в порядке выборки
+func positionOf(m *models.Metrics) listPosition {
+	return listPosition{id: m.ID, key: m.SeriesKey()}
+}
+
+// encodeCursor кодирует позицию последней метрики страницы в непрозрачный курсор
+func encodeCursor(p listPosition) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(p.id + "\x00" + p.key))
+}
+
+// decodeCursor разбирает курсор, полученный от encodeCursor
+func decodeCursor(cursor string) (listPosition, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return listPosition{}, fmt.Errorf("%w: malformed cursor", ErrInvalidListOptions)
+	}
+	id, key, ok := strings.Cut(string(raw), "\x00")
+	if !ok {
+		return listPosition{}, fmt.Errorf("%w: malformed cursor", ErrInvalidListOptions)
+	}
+	return listPosition{id: id, key: key}, nil
+}
+
+// listQuery - проверенные параметры выборки
+type listQuery struct {
+	ListOptions
+	after *listPosition // позиция, после которой начинается страница
+}
+
+// parseListOptions проверяет параметры выборки и разбирает курсор
+func parseListOptions(opts ListOptions) (*listQuery, error) {
+	switch opts.Type {
+	case "", models.Gauge, models.Counter, models.Histogram:
+	default:
+		return nil, fmt.Errorf("%w: unknown metric type %q", ErrInvalidListOptions, opts.Type)
+	}
+	if opts.Limit < 0 {
+		return nil, fmt.Errorf("%w: negative limit", ErrInvalidListOptions)
+	}
+	if opts.Match != "" {
+		if _, err := path.Match(opts.Match, ""); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidListOptions, err)
+		}
+	}
+	q := &listQuery{ListOptions: opts}
+	if opts.Cursor != "" {
+		after, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		q.after = &after
+	}
+	return q, nil
+}
+
+// matches проверяет, что метрика проходит фильтры и находится после курсора
+func (q *listQuery) matches(m *models.Metrics) bool {
+	if q.Type != "" && m.MType != q.Type {
+		return false
+	}
+	if !strings.HasPrefix(m.ID, q.Prefix) {
+		return false
+	}
+	if q.Match != "" {
+		if ok, _ := path.Match(q.Match, m.ID); !ok {
+			return false
+		}
+	}
+	return q.after == nil || q.after.less(positionOf(m))
+}
+
+// paginate упорядочивает отфильтрованные метрики и отрезает страницу
+func (q *listQuery) paginate(metrics []*models.Metrics) *MetricsPage {
+	sort.Slice(metrics, func(i, j int) bool {
+		return positionOf(metrics[i]).less(positionOf(metrics[j]))
+	})
+	return q.page(metrics)
+}
+
+// page отрезает страницу от упорядоченных метрик и формирует курсор следующей страницы
+func (q *listQuery) page(metrics []*models.Metrics) *MetricsPage {
+	if q.Limit == 0 || len(metrics) <= q.Limit {
+		return &MetricsPage{Metrics: metrics}
+	}
+	metrics = metrics[:q.Limit]
+	return &MetricsPage{
+		Metrics:    metrics,
+		NextCursor: encodeCursor(positionOf(metrics[len(metrics)-1])),
+	}
+}
+
+// likePrefix возвращает литеральный префикс имени для индексного поиска через LIKE:
+// более длинный из Prefix и неизменяемого начала шаблона Match.
+func (q *listQuery) likePrefix() string {
+	prefix := q.Prefix
+	if q.Match != "" {
+		literal := q.Match
+		if i := strings.IndexAny(literal, `*?[\`); i >= 0 {
+			literal = literal[:i]
+		}
+		if strings.HasPrefix(literal, prefix) {
+			prefix = literal
+		}
+	}
+	return prefix
+}
+
+// escapeLike экранирует специальные символы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// globToRegexp переводит glob шаблон path.Match в эквивалентное регулярное выражение POSIX.
+// Шаблон должен быть предварительно проверен path.Match.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteByte('^')
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '\\':
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			j := i + 1
+			b.WriteByte('[')
+			if glob[j] == '^' {
+				b.WriteByte('^')
+				j++
+			}
+			for ; glob[j] != ']'; j++ {
+				c := glob[j]
+				escaped := c == '\\'
+				if escaped {
+					j++
+					c = glob[j]
+				}
+				// экранированный символ остается литералом, в том числе '-' и ']'
+				if (escaped && !isAlnum(c)) || c == '[' || c == '^' {
+					b.WriteByte('\\')
+				}
+				b.WriteByte(c)
+			}
+			b.WriteByte(']')
+			i = j
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteByte('$')
+	return b.String()
+}
+
+// isAlnum проверяет, что байт - латинская буква или цифра
+func isAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
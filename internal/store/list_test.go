@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListMetrics(t *testing.T) {
+	fileStorage, err := NewFileStorage(filepath.Join(t.TempDir(), "metrics.json"), false, 0)
+	require.NoError(t, err)
+	storages := map[string]Storage{
+		"memory": NewMemoryStorage(),
+		"file":   fileStorage,
+	}
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			require.NoError(t, storage.UpdateMetrics(ctx, []*models.Metrics{
+				models.NewGaugeMetric("runtime.Alloc", 1),
+				models.NewGaugeMetric("runtime.Frees", 2),
+				models.NewCounterMetric("runtime.GC", 3),
+				models.NewGaugeMetric("temp", 36.6),
+				{ID: "requests", MType: models.Counter, Delta: models.PInt(1), Labels: map[string]string{"host": "b"}},
+				{ID: "requests", MType: models.Counter, Delta: models.PInt(1), Labels: map[string]string{"host": "a"}},
+				models.NewCounterMetric("requests_total", 1),
+			}))
+
+			tests := []struct {
+				name string
+				opts ListOptions
+				want []string
+			}{
+				{
+					name: "all sorted by id and series",
+					want: []string{`requests{host="a"}`, `requests{host="b"}`, "requests_total", "runtime.Alloc", "runtime.Frees", "runtime.GC", "temp"},
+				},
+				{
+					name: "prefix",
+					opts: ListOptions{Prefix: "runtime."},
+					want: []string{"runtime.Alloc", "runtime.Frees", "runtime.GC"},
+				},
+				{
+					name: "prefix and type",
+					opts: ListOptions{Prefix: "runtime.", Type: models.Counter},
+					want: []string{"runtime.GC"},
+				},
+				{
+					name: "glob",
+					opts: ListOptions{Match: "*.[AF]*"},
+					want: []string{"runtime.Alloc", "runtime.Frees"},
+				},
+				{
+					name: "glob with literal underscore",
+					opts: ListOptions{Match: "requests_?otal"},
+					want: []string{"requests_total"},
+				},
+				{
+					name: "no matches",
+					opts: ListOptions{Prefix: "missing"},
+					want: []string{},
+				},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					page, err := storage.ListMetrics(ctx, tt.opts)
+					require.NoError(t, err)
+					got := make([]string, 0, len(page.Metrics))
+					for _, m := range page.Metrics {
+						got = append(got, m.SeriesKey())
+					}
+					assert.Equal(t, tt.want, got)
+					assert.Empty(t, page.NextCursor)
+				})
+			}
+
+			// постраничный обход возвращает каждую серию ровно один раз
+			var keys []string
+			opts := ListOptions{Limit: 2}
+			for pages := 0; ; pages++ {
+				require.Less(t, pages, 10)
+				page, err := storage.ListMetrics(ctx, opts)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(page.Metrics), 2)
+				for _, m := range page.Metrics {
+					keys = append(keys, m.SeriesKey())
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
+				// серия, добавленная перед курсором, не сдвигает следующие страницы
+				_, err = storage.UpdateMetric(ctx, models.NewGaugeMetric("a_new", 1))
+				require.NoError(t, err)
+			}
+			assert.Equal(t, []string{`requests{host="a"}`, `requests{host="b"}`, "requests_total", "runtime.Alloc", "runtime.Frees", "runtime.GC", "temp"}, keys)
+		})
+	}
+}
+
+func TestListMetricsInvalidOptions(t *testing.T) {
+	storage := NewMemoryStorage()
+	for _, opts := range []ListOptions{
+		{Type: "summary"},
+		{Limit: -1},
+		{Match: "[a-"},
+		{Cursor: "!not base64!"},
+		{Cursor: "bm9zZXBhcmF0b3I"},
+	} {
+		_, err := storage.ListMetrics(context.Background(), opts)
+		assert.ErrorIs(t, err, ErrInvalidListOptions, "%+v", opts)
+	}
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	names := []string{"runtime.Alloc", "runtime.GC", "runtimeXAlloc", "a/b", "ab", "a]b", "a^b", "a-b", "a*b", "b", "c", "метрика", ""}
+	for _, glob := range []string{
+		"*", "runtime.*", "*Alloc", "runtime.?C", "a?b", "a*", "[ab]*", "[!r]*", "[^r]*",
+		"a[\\]]b", "a[\\^]b", "a[\\-]b", "[a\\-c]*", "[\\a]*", "a\\*b", "[а-я]*", "a[a-c]", "runtime.[A-F]lloc",
+	} {
+		_, err := path.Match(glob, "")
+		require.NoError(t, err, glob)
+		re := regexp.MustCompile(globToRegexp(glob))
+		for _, name := range names {
+			want, _ := path.Match(glob, name)
+			assert.Equal(t, want, re.MatchString(name), "glob %q name %q", glob, name)
+		}
+	}
+}
+
+func TestListQueryLikePrefix(t *testing.T) {
+	tests := []struct {
+		opts ListOptions
+		want string
+	}{
+		{opts: ListOptions{}, want: ""},
+		{opts: ListOptions{Prefix: "run"}, want: "run"},
+		{opts: ListOptions{Match: "runtime.*"}, want: "runtime."},
+		{opts: ListOptions{Prefix: "run", Match: "runtime.*"}, want: "runtime."},
+		{opts: ListOptions{Prefix: "runtime.G", Match: "runtime.*"}, want: "runtime.G"},
+		{opts: ListOptions{Prefix: "temp", Match: "runtime.*"}, want: "temp"},
+		{opts: ListOptions{Match: `a\*b`}, want: "a"},
+	}
+	for _, tt := range tests {
+		q, err := parseListOptions(tt.opts)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, q.likePrefix(), "%+v", tt.opts)
+	}
+	assert.Equal(t, `a\_b\%c\\`, escapeLike(`a_b%c\`))
+}
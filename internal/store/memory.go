@@ -3,85 +3,502 @@ package store
 import (
 	"context"
 	"errors"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Soliard/go-tpl-metrics/models"
 )
 
-// memStorage реализует Storage интерфейс для хранения метрик в памяти
-type memStorage struct {
+// memShardCount - количество сегментов хранилища в памяти, каждый со своей блокировкой
+const memShardCount = 32
+
+// memShard - сегмент хранилища в памяти с метриками и историей серий, попавших в него по хешу ключа
+type memShard struct {
+	mu      sync.RWMutex
 	metrics map[string]*models.Metrics
+	history map[string]*sampleRing
+}
+
+// memStorage реализует Storage интерфейс для хранения метрик в памяти.
+// Серии распределяются по сегментам с отдельными блокировками, поэтому обновления разных серий не конкурируют.
+// Метрики возвращаются копиями: изменение результата не затрагивает хранилище,
+// а последующие обновления не меняют ранее возвращенные значения.
+type memStorage struct {
+	shards [memShardCount]*memShard
+
+	// batchesMu защищает окно дедупликации и применяемые пакеты
+	batchesMu sync.Mutex
+	batches   *dedupWindow
+	// inflight - пакеты, применяемые в данный момент; канал закрывается по завершении применения
+	inflight map[string]chan struct{}
 }
 
 // NewMemoryStorage создает новое хранилище в памяти.
-// Данные хранятся в map[string]*models.Metrics и не сохраняются между перезапусками.
+// Данные хранятся по ключу серии и не сохраняются между перезапусками.
 func NewMemoryStorage() Storage {
-	return &memStorage{
-		metrics: map[string]*models.Metrics{},
+	return newMemStorage(map[string]*models.Metrics{})
+}
+
+// newMemStorage создает хранилище в памяти с заранее заполненными метриками
+func newMemStorage(metrics map[string]*models.Metrics) *memStorage {
+	s := &memStorage{
+		batches:  newDedupWindow(dedupWindowCapacity, dedupWindowTTL),
+		inflight: map[string]chan struct{}{},
 	}
+	for i := range s.shards {
+		s.shards[i] = &memShard{
+			metrics: map[string]*models.Metrics{},
+			history: map[string]*sampleRing{},
+		}
+	}
+	for key, m := range metrics {
+		s.shard(key).metrics[key] = m
+	}
+	return s
 }
 
-// UpdateMetrics обновляет несколько метрик в памяти
-func (s *memStorage) UpdateMetrics(ctx context.Context, metrics []*models.Metrics) error {
+// shard возвращает сегмент для ключа серии
+func (s *memStorage) shard(key string) *memShard {
+	return s.shards[shardIndex(key)]
+}
+
+// shardIndex возвращает номер сегмента для ключа серии (хеш FNV-1a)
+func shardIndex(key string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h % memShardCount)
+}
+
+// lockShards блокирует на запись сегменты серий metrics в порядке их номеров,
+// чтобы одновременные пакеты не взаимоблокировались. Возвращает функцию снятия блокировок.
+func (s *memStorage) lockShards(metrics []*models.Metrics) (unlock func()) {
+	var locked [memShardCount]bool
 	for _, m := range metrics {
-		_, err := s.UpdateMetric(ctx, m)
-		if err != nil {
-			return err
+		locked[shardIndex(m.SeriesKey())] = true
+	}
+	for i, ok := range locked {
+		if ok {
+			s.shards[i].mu.Lock()
+		}
+	}
+	return func() {
+		for i, ok := range locked {
+			if ok {
+				s.shards[i].mu.Unlock()
+			}
 		}
 	}
+}
+
+// UpdateMetrics обновляет несколько метрик в памяти целиком или не обновляет вовсе.
+// Сегменты серий пакета заблокированы, пока новые состояния вычисляются и сохраняются,
+// поэтому ошибка типа в середине пакета не оставляет частичных изменений.
+func (s *memStorage) UpdateMetrics(ctx context.Context, metrics []*models.Metrics) error {
+	unlock := s.lockShards(metrics)
+	defer unlock()
+
+	states, err := mergeUpdates(metrics, func(key string) *models.Metrics {
+		return s.shard(key).metrics[key]
+	})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, st := range states {
+		key := st.SeriesKey()
+		sh := s.shard(key)
+		sh.metrics[key] = st
+		sh.pushSample(key, models.NewSample(st, now))
+	}
 	return nil
 }
 
+// UpdateMetricsOnce обновляет метрики пакета, если ключ пакета не встречался в окне дедупликации
+func (s *memStorage) UpdateMetricsOnce(ctx context.Context, batchKey string, metrics []*models.Metrics) error {
+	_, err := s.applyOnce(batchKey, func() error {
+		return s.UpdateMetrics(ctx, metrics)
+	})
+	return err
+}
+
+// applyOnce вызывает apply, если ключ пакета не встречался в окне дедупликации, и запоминает ключ при успехе.
+// Повтор пакета получает ErrDuplicateBatch; одновременный повтор дожидается завершения применения оригинала,
+// а разные пакеты применяются параллельно. Возвращает время применения пакета.
+func (s *memStorage) applyOnce(batchKey string, apply func() error) (time.Time, error) {
+	for {
+		s.batchesMu.Lock()
+		now := time.Now()
+		if s.batches.contains(batchKey, now) {
+			s.batchesMu.Unlock()
+			return now, ErrDuplicateBatch
+		}
+		if done, ok := s.inflight[batchKey]; ok {
+			s.batchesMu.Unlock()
+			<-done
+			continue
+		}
+		done := make(chan struct{})
+		s.inflight[batchKey] = done
+		s.batchesMu.Unlock()
+
+		err := apply()
+
+		s.batchesMu.Lock()
+		delete(s.inflight, batchKey)
+		if err == nil {
+			s.batches.add(batchKey, now)
+		}
+		s.batchesMu.Unlock()
+		close(done)
+		return now, err
+	}
+}
+
+// batchEntries возвращает копию ключей окна дедупликации в порядке применения
+func (s *memStorage) batchEntries() []dedupEntry {
+	s.batchesMu.Lock()
+	defer s.batchesMu.Unlock()
+	return append([]dedupEntry(nil), s.batches.entries()...)
+}
+
+// batchCount возвращает количество ключей в окне дедупликации
+func (s *memStorage) batchCount() int {
+	s.batchesMu.Lock()
+	defer s.batchesMu.Unlock()
+	return s.batches.size()
+}
+
 // UpdateMetric обновляет или создает одну метрику в памяти.
-// Для counter метрик значения суммируются, для gauge - перезаписываются.
+// Для counter метрик значения суммируются, для gauge - перезаписываются,
+// гистограммы объединяются по корзинам при совпадении границ.
+// Возвращает копию метрики после обновления.
 func (s *memStorage) UpdateMetric(ctx context.Context, metric *models.Metrics) (*models.Metrics, error) {
-	existed, err := s.GetMetric(ctx, metric.ID)
+	key := metric.SeriesKey()
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	stored, err := mergeMetric(sh.metrics[key], metric)
 	if err != nil {
-		if errors.Is(err, ErrNotFound) {
-			// creating new metric
-			s.metrics[metric.ID] = metric
-			return metric, nil
-		}
 		return nil, err
 	}
+	sh.metrics[key] = stored
+	sh.recordSample(key, stored)
+	return stored.Clone(), nil
+}
 
+// mergeMetric возвращает новое состояние серии после применения metric к existed (nil - серии еще нет).
+// existed не изменяется: gauge перезаписывается, counter и гистограмма накапливаются.
+func mergeMetric(existed, metric *models.Metrics) (*models.Metrics, error) {
+	if existed == nil {
+		return metric.Clone(), nil
+	}
 	if existed.MType != metric.MType {
 		return nil, ErrInvalidMetricReceived
 	}
-
-	// updating existing metric
+	merged := existed.Clone()
 	switch metric.MType {
 	case models.Gauge:
-		{
-			*existed.Value = *metric.Value
+		if metric.Value == nil {
+			return nil, ErrInvalidMetricReceived
 		}
+		merged.Value = models.PFloat(*metric.Value)
 	case models.Counter:
-		{
-			*existed.Delta += *metric.Delta
+		if metric.Delta == nil {
+			return nil, ErrInvalidMetricReceived
+		}
+		var delta int64
+		if merged.Delta != nil {
+			delta = *merged.Delta
+		}
+		merged.Delta = models.PInt(delta + *metric.Delta)
+	case models.Histogram:
+		if err := merged.Histogram.Merge(metric.Histogram); err != nil {
+			return nil, ErrInvalidMetricReceived
 		}
 	default:
-		{
-			return nil, errors.New("provided not supported metric type")
+		return nil, errors.New("provided not supported metric type")
+	}
+	return merged, nil
+}
+
+// prepareUpdates вычисляет состояния серий после применения metrics, не изменяя хранилище.
+// Серия, встречающаяся в пакете несколько раз, накапливает все его значения.
+// Между prepareUpdates и commitUpdates хранилище не должно изменяться другими записями.
+func (s *memStorage) prepareUpdates(metrics []*models.Metrics) ([]*models.Metrics, error) {
+	return mergeUpdates(metrics, s.get)
+}
+
+// mergeUpdates вычисляет состояния серий после применения metrics к состояниям, возвращаемым lookup.
+// Серия, встречающаяся в пакете несколько раз, накапливает все его значения.
+func mergeUpdates(metrics []*models.Metrics, lookup func(key string) *models.Metrics) ([]*models.Metrics, error) {
+	pending := make(map[string]*models.Metrics, len(metrics))
+	states := make([]*models.Metrics, 0, len(metrics))
+	for _, m := range metrics {
+		key := m.SeriesKey()
+		existed, ok := pending[key]
+		if !ok {
+			existed = lookup(key)
+		}
+		state, err := mergeMetric(existed, m)
+		if err != nil {
+			return nil, err
 		}
+		pending[key] = state
+		states = append(states, state)
 	}
-	return existed, nil
+	return states, nil
+}
 
+// commitUpdates сохраняет состояния серий, подготовленные prepareUpdates, без записи истории.
+// Возвращает функцию, восстанавливающую прежние состояния.
+func (s *memStorage) commitUpdates(states []*models.Metrics) (rollback func()) {
+	previous := make(map[string]*models.Metrics, len(states))
+	for _, st := range states {
+		key := st.SeriesKey()
+		sh := s.shard(key)
+		sh.mu.Lock()
+		if _, ok := previous[key]; !ok {
+			previous[key] = sh.metrics[key]
+		}
+		sh.metrics[key] = st
+		sh.mu.Unlock()
+	}
+	return func() {
+		for key, m := range previous {
+			sh := s.shard(key)
+			sh.mu.Lock()
+			if m == nil {
+				delete(sh.metrics, key)
+			} else {
+				sh.metrics[key] = m
+			}
+			sh.mu.Unlock()
+		}
+	}
+}
+
+// recordSamples записывает состояния серий в историю в памяти с отметкой времени at
+func (s *memStorage) recordSamples(states []*models.Metrics, at time.Time) {
+	for _, st := range states {
+		key := st.SeriesKey()
+		sh := s.shard(key)
+		sh.mu.Lock()
+		sh.pushSample(key, models.NewSample(st, at))
+		sh.mu.Unlock()
+	}
+}
+
+// restoreSample добавляет в историю серии отсчет, прочитанный из сегмента истории
+func (s *memStorage) restoreSample(key string, sample *models.Sample) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.pushSample(key, sample)
+}
+
+// dropHistory удаляет историю серии из памяти
+func (s *memStorage) dropHistory(key string) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	delete(sh.history, key)
+}
+
+// pruneHistory удаляет из истории всех серий отсчеты старше cutoff и возвращает оставшиеся по ключам серий
+func (s *memStorage) pruneHistory(cutoff time.Time) map[string][]*models.Sample {
+	result := map[string][]*models.Sample{}
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		for key, ring := range sh.history {
+			ring.prune(cutoff)
+			if len(ring.samples) == 0 {
+				delete(sh.history, key)
+				continue
+			}
+			result[key] = ring.since(cutoff)
+		}
+		sh.mu.Unlock()
+	}
+	return result
+}
+
+// get возвращает копию серии по ключу или nil, если серии нет
+func (s *memStorage) get(key string) *models.Metrics {
+	sh := s.shard(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	if m, ok := sh.metrics[key]; ok {
+		return m.Clone()
+	}
+	return nil
 }
 
-// GetMetric получает метрику по имени из памяти
+// GetMetric получает копию метрики по ключу серии из памяти
 func (s *memStorage) GetMetric(ctx context.Context, name string) (*models.Metrics, error) {
-	if metric, ok := s.metrics[name]; ok {
-		return metric, nil
+	sh := s.shard(name)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	if metric, ok := sh.metrics[name]; ok {
+		return metric.Clone(), nil
 	}
 	return nil, ErrNotFound
 }
 
-// GetAllMetrics возвращает все метрики из памяти
+// GetMetricsByID возвращает копии всех серий метрики с указанным именем
+func (s *memStorage) GetMetricsByID(ctx context.Context, id string) ([]*models.Metrics, error) {
+	metrics := make([]*models.Metrics, 0)
+	s.forEach(func(m *models.Metrics) {
+		if m.ID == id {
+			metrics = append(metrics, m.Clone())
+		}
+	})
+	return metrics, nil
+}
+
+// GetAllMetrics возвращает копии всех метрик из памяти.
+// Каждый сегмент читается под своей блокировкой, поэтому снимок согласован в пределах сегмента.
 func (s *memStorage) GetAllMetrics(ctx context.Context) ([]*models.Metrics, error) {
-	metrics := make([]*models.Metrics, len(s.metrics))
-	idx := 0
-	for _, m := range s.metrics {
-		metrics[idx] = m
-		idx++
-	}
+	metrics := make([]*models.Metrics, 0)
+	s.forEach(func(m *models.Metrics) {
+		metrics = append(metrics, m.Clone())
+	})
 	return metrics, nil
 }
+
+// ListMetrics возвращает страницу копий метрик, отфильтрованных по opts.
+// Копируются только подходящие метрики.
+func (s *memStorage) ListMetrics(ctx context.Context, opts ListOptions) (*MetricsPage, error) {
+	q, err := parseListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	metrics := make([]*models.Metrics, 0)
+	s.forEach(func(m *models.Metrics) {
+		if q.matches(m) {
+			metrics = append(metrics, m.Clone())
+		}
+	})
+	return q.paginate(metrics), nil
+}
+
+// forEach вызывает fn для каждой хранимой метрики под блокировкой чтения ее сегмента.
+// fn не должна сохранять переданный указатель.
+func (s *memStorage) forEach(fn func(m *models.Metrics)) {
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, m := range sh.metrics {
+			fn(m)
+		}
+		sh.mu.RUnlock()
+	}
+}
+
+// GetHistory возвращает отсчеты метрики из кольцевого буфера в памяти.
+// Отсчеты старше historyRetention удаляются перед чтением.
+func (s *memStorage) GetHistory(ctx context.Context, name string, from, to time.Time, step time.Duration) ([]*models.Sample, error) {
+	sh := s.shard(name)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	ring, ok := sh.history[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	ring.prune(time.Now().Add(-historyRetention))
+	return downsample(ring.rangeQuery(from, to), from, step), nil
+}
+
+// DeleteMetric удаляет серию метрики и ее историю из памяти
+func (s *memStorage) DeleteMetric(ctx context.Context, name string) error {
+	sh := s.shard(name)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, ok := sh.metrics[name]; !ok {
+		return ErrNotFound
+	}
+	delete(sh.metrics, name)
+	delete(sh.history, name)
+	return nil
+}
+
+// DeleteByPrefix удаляет из памяти все серии метрик, имя которых начинается с prefix
+func (s *memStorage) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	return len(s.deleteByPrefix(prefix)), nil
+}
+
+// seriesByPrefix возвращает ключи серий метрик, имя которых начинается с prefix, не изменяя хранилище
+func (s *memStorage) seriesByPrefix(prefix string) []string {
+	var keys []string
+	s.forEach(func(m *models.Metrics) {
+		if strings.HasPrefix(m.ID, prefix) {
+			keys = append(keys, m.SeriesKey())
+		}
+	})
+	return keys
+}
+
+// dropSeries удаляет серии и их историю из памяти
+func (s *memStorage) dropSeries(keys ...string) {
+	for _, key := range keys {
+		sh := s.shard(key)
+		sh.mu.Lock()
+		delete(sh.metrics, key)
+		delete(sh.history, key)
+		sh.mu.Unlock()
+	}
+}
+
+// deleteByPrefix удаляет серии метрик, имя которых начинается с prefix, и возвращает их ключи
+func (s *memStorage) deleteByPrefix(prefix string) []string {
+	var keys []string
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		for key, m := range sh.metrics {
+			if strings.HasPrefix(m.ID, prefix) {
+				delete(sh.metrics, key)
+				delete(sh.history, key)
+				keys = append(keys, key)
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return keys
+}
+
+// ResetCounter обнуляет значение counter серии в памяти и записывает отсчет в историю
+func (s *memStorage) ResetCounter(ctx context.Context, name string) (*models.Metrics, error) {
+	sh := s.shard(name)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	existed, ok := sh.metrics[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if existed.MType != models.Counter {
+		return nil, ErrInvalidMetricReceived
+	}
+	existed.Delta = models.PInt(0)
+	sh.recordSample(name, existed)
+	return existed.Clone(), nil
+}
+
+// recordSample сохраняет текущее значение метрики в историю. Вызывается под блокировкой сегмента.
+func (sh *memShard) recordSample(key string, m *models.Metrics) {
+	sh.pushSample(key, models.NewSample(m, time.Now()))
+}
+
+// pushSample добавляет отсчет в историю серии и удаляет отсчеты старше historyRetention.
+// Вызывается под блокировкой сегмента.
+func (sh *memShard) pushSample(key string, sample *models.Sample) {
+	ring, ok := sh.history[key]
+	if !ok {
+		ring = newSampleRing(historyCapacity)
+		sh.history[key] = ring
+	}
+	ring.push(sample)
+	ring.prune(time.Now().Add(-historyRetention))
+}
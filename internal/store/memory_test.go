@@ -0,0 +1,321 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageConcurrentAccess(t *testing.T) {
+	fileStorage, err := NewFileStorage(filepath.Join(t.TempDir(), "metrics.json"), false, 0)
+	require.NoError(t, err)
+	storages := map[string]Storage{
+		"memory": NewMemoryStorage(),
+		"file":   fileStorage,
+	}
+	const writers, updates = 8, 50
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var wg sync.WaitGroup
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < updates; i++ {
+						_, err := storage.UpdateMetric(ctx, models.NewCounterMetric("requests", 1))
+						assert.NoError(t, err)
+						assert.NoError(t, storage.UpdateMetrics(ctx, []*models.Metrics{
+							models.NewGaugeMetric(fmt.Sprint("gauge", w), float64(i)),
+						}))
+					}
+				}(w)
+			}
+			stop := make(chan struct{})
+			var readers sync.WaitGroup
+			for r := 0; r < 4; r++ {
+				readers.Add(1)
+				go func() {
+					defer readers.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						all, err := storage.GetAllMetrics(ctx)
+						assert.NoError(t, err)
+						for _, m := range all {
+							_ = m.String()
+						}
+						storage.GetMetric(ctx, "requests")
+						storage.GetMetricsByID(ctx, "gauge0")
+						storage.GetHistory(ctx, "requests", time.Time{}, time.Now(), 0)
+					}
+				}()
+			}
+			wg.Wait()
+			close(stop)
+			readers.Wait()
+
+			m, err := storage.GetMetric(ctx, "requests")
+			require.NoError(t, err)
+			assert.Equal(t, int64(writers*updates), *m.Delta)
+			all, err := storage.GetAllMetrics(ctx)
+			require.NoError(t, err)
+			assert.Len(t, all, writers+1)
+		})
+	}
+}
+
+func TestUpdateMetricsOnceConcurrent(t *testing.T) {
+	fileStorage, err := NewFileStorage(filepath.Join(t.TempDir(), "metrics.json"), false, 0)
+	require.NoError(t, err)
+	storages := map[string]Storage{
+		"memory": NewMemoryStorage(),
+		"file":   fileStorage,
+	}
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var applied, duplicates atomic.Int32
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := storage.UpdateMetricsOnce(ctx, "agent/1", []*models.Metrics{models.NewCounterMetric("PollCount", 5)})
+					switch {
+					case err == nil:
+						applied.Add(1)
+					case errors.Is(err, ErrDuplicateBatch):
+						duplicates.Add(1)
+					default:
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int32(1), applied.Load())
+			assert.Equal(t, int32(9), duplicates.Load())
+
+			m, err := storage.GetMetric(ctx, "PollCount")
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), *m.Delta)
+		})
+	}
+}
+
+func TestMemStorageBatchIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+	require.NoError(t, storage.UpdateMetrics(ctx, []*models.Metrics{
+		models.NewCounterMetric("requests", 1),
+		models.NewGaugeMetric("temp", 1),
+	}))
+
+	// ошибка типа в середине пакета не оставляет изменений от предыдущих метрик
+	err := storage.UpdateMetricsOnce(ctx, "agent/1", []*models.Metrics{
+		models.NewCounterMetric("requests", 10),
+		models.NewGaugeMetric("created", 1),
+		models.NewCounterMetric("temp", 1),
+	})
+	require.ErrorIs(t, err, ErrInvalidMetricReceived)
+	got, err := storage.GetMetric(ctx, "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *got.Delta)
+	_, err = storage.GetMetric(ctx, "created")
+	assert.ErrorIs(t, err, ErrNotFound)
+	history, err := storage.GetHistory(ctx, "requests", time.Time{}, time.Now(), 0)
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	// ключ неудачного пакета не запомнен, исправленный пакет применяется
+	require.NoError(t, storage.UpdateMetricsOnce(ctx, "agent/1", []*models.Metrics{
+		models.NewCounterMetric("requests", 2),
+		models.NewCounterMetric("requests", 3),
+	}))
+	got, err = storage.GetMetric(ctx, "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), *got.Delta)
+}
+
+func TestMemStorageCopyOnRead(t *testing.T) {
+	ctx := context.Background()
+	storage := NewMemoryStorage()
+
+	input := models.NewCounterMetric("requests", 1)
+	input.Labels = map[string]string{"host": "a"}
+	updated, err := storage.UpdateMetric(ctx, input)
+	require.NoError(t, err)
+	key := input.SeriesKey()
+
+	// хранилище не удерживает переданную метрику
+	*input.Delta = 100
+	input.Labels["host"] = "b"
+	// и не отдает внутренние указатели
+	*updated.Delta = 200
+
+	got, err := storage.GetMetric(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *got.Delta)
+	assert.Equal(t, "a", got.Labels["host"])
+
+	// ранее полученная метрика - неизменяемый снимок
+	_, err = storage.UpdateMetric(ctx, models.NewCounterMetric("requests", 2))
+	require.NoError(t, err)
+	_, err = storage.UpdateMetric(ctx, &models.Metrics{ID: "requests", MType: models.Counter, Delta: models.PInt(2), Labels: map[string]string{"host": "a"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *got.Delta)
+
+	all, err := storage.GetAllMetrics(ctx)
+	require.NoError(t, err)
+	for _, m := range all {
+		*m.Delta = 0
+	}
+	got, err = storage.GetMetric(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *got.Delta)
+
+	h := models.NewHistogram([]float64{1})
+	h.Observe(0.5)
+	_, err = storage.UpdateMetric(ctx, models.NewHistogramMetric("latency", h))
+	require.NoError(t, err)
+	byID, err := storage.GetMetricsByID(ctx, "latency")
+	require.NoError(t, err)
+	require.Len(t, byID, 1)
+	byID[0].Histogram.Counts[0] = 42
+	got, err = storage.GetMetric(ctx, "latency")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 0}, got.Histogram.Counts)
+}
+
+// lockedMapStorage - прежняя схема хранения (одна map) под единой блокировкой, база для сравнения в бенчмарках.
+// Как и memStorage, пишет историю и возвращает копии, поэтому разница определяется только блокировками.
+type lockedMapStorage struct {
+	mu      sync.RWMutex
+	metrics map[string]*models.Metrics
+	history memShard
+}
+
+func (s *lockedMapStorage) UpdateMetric(ctx context.Context, metric *models.Metrics) (*models.Metrics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := metric.SeriesKey()
+	existed, ok := s.metrics[key]
+	if !ok {
+		existed = metric.Clone()
+		s.metrics[key] = existed
+	} else {
+		switch metric.MType {
+		case models.Gauge:
+			*existed.Value = *metric.Value
+		case models.Counter:
+			*existed.Delta += *metric.Delta
+		}
+	}
+	s.history.recordSample(key, existed)
+	return existed.Clone(), nil
+}
+
+func (s *lockedMapStorage) GetMetric(ctx context.Context, name string) (*models.Metrics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if m, ok := s.metrics[name]; ok {
+		return m.Clone(), nil
+	}
+	return nil, ErrNotFound
+}
+
+// benchStorage - операции, сравниваемые в бенчмарках
+type benchStorage interface {
+	UpdateMetric(ctx context.Context, metric *models.Metrics) (*models.Metrics, error)
+	GetMetric(ctx context.Context, name string) (*models.Metrics, error)
+}
+
+func benchStorages() map[string]func() benchStorage {
+	return map[string]func() benchStorage{
+		"sharded": func() benchStorage { return newMemStorage(map[string]*models.Metrics{}) },
+		"single-lock-map": func() benchStorage {
+			return &lockedMapStorage{
+				metrics: map[string]*models.Metrics{},
+				history: memShard{history: map[string]*sampleRing{}},
+			}
+		},
+	}
+}
+
+// benchMetrics возвращает набор серий, по которым распределяется нагрузка
+func benchMetrics(n int) []*models.Metrics {
+	metrics := make([]*models.Metrics, n)
+	for i := range metrics {
+		metrics[i] = models.NewCounterMetric(fmt.Sprint("counter", i), 1)
+	}
+	return metrics
+}
+
+func BenchmarkMemStorage_UpdateParallel(b *testing.B) {
+	metrics := benchMetrics(1024)
+	for name, newStorage := range benchStorages() {
+		b.Run(name, func(b *testing.B) {
+			storage := newStorage()
+			ctx := context.Background()
+			var next atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(next.Add(1))
+				for pb.Next() {
+					storage.UpdateMetric(ctx, metrics[i%len(metrics)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMemStorage_MixedParallel(b *testing.B) {
+	metrics := benchMetrics(1024)
+	for name, newStorage := range benchStorages() {
+		b.Run(name, func(b *testing.B) {
+			storage := newStorage()
+			ctx := context.Background()
+			for _, m := range metrics {
+				storage.UpdateMetric(ctx, m)
+			}
+			var next atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(next.Add(1))
+				for pb.Next() {
+					m := metrics[i%len(metrics)]
+					// одна запись на девять чтений
+					if i%10 == 0 {
+						storage.UpdateMetric(ctx, m)
+					} else {
+						storage.GetMetric(ctx, m.ID)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMemStorage_GetAllMetrics(b *testing.B) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+	for _, m := range benchMetrics(1024) {
+		storage.UpdateMetric(ctx, m)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		storage.GetAllMetrics(ctx)
+	}
+}
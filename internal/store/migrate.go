@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/Soliard/go-tpl-metrics/cmd/server/migrations"
+	"github.com/golang-migrate/migrate"
+	"github.com/golang-migrate/migrate/database/postgres"
+	"github.com/golang-migrate/migrate/source"
+	bindata "github.com/golang-migrate/migrate/source/go_bindata"
+	"github.com/jmoiron/sqlx"
+)
+
+// MigrationStatus - состояние схемы базы данных
+type MigrationStatus struct {
+	Version uint // примененная версия, 0 - миграции не применялись
+	Dirty   bool // миграция версии Version прервана, схему нужно исправить и зафиксировать через Force
+	Latest  uint // последняя версия среди встроенных миграций
+}
+
+// Migrator применяет встроенные в бинарный файл миграции к базе данных
+type Migrator struct {
+	migrate *migrate.Migrate
+	latest  uint
+}
+
+// NewMigrator подключается к базе данных и готовит встроенные миграции
+func NewMigrator(ctx context.Context, databaseDSN string) (*Migrator, error) {
+	db, err := sqlx.Open("pgx", databaseDSN)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	m, err := newMigrator(db.DB)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// newMigrator создает мигратор для открытого соединения.
+// Закрытие мигратора закрывает и соединение.
+func newMigrator(db *sql.DB) (*Migrator, error) {
+	src, err := embeddedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := latestVersion(src)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		return nil, err
+	}
+	m, err := migrate.NewWithInstance("go-bindata", src, "postgres", driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{migrate: m, latest: latest}, nil
+}
+
+// embeddedMigrations возвращает источник миграций из встроенной файловой системы
+func embeddedMigrations() (source.Driver, error) {
+	entries, err := fs.ReadDir(migrations.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return bindata.WithInstance(bindata.Resource(names, migrations.FS.ReadFile))
+}
+
+// latestVersion возвращает последнюю версию миграций источника
+func latestVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if err != nil {
+		return 0, fmt.Errorf("no embedded migrations: %w", err)
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
+
+// Up применяет все непримененные миграции
+func (m *Migrator) Up() error {
+	if err := m.migrate.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+	return nil
+}
+
+// Down откатывает steps последних примененных миграций
+func (m *Migrator) Down(steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("invalid number of steps %d", steps)
+	}
+	return m.migrate.Steps(-steps)
+}
+
+// Force записывает версию схемы без выполнения миграций и снимает признак dirty.
+// Версия -1 означает, что миграции не применялись.
+func (m *Migrator) Force(version int) error {
+	if version < -1 {
+		return fmt.Errorf("invalid version %d", version)
+	}
+	return m.migrate.Force(version)
+}
+
+// Status возвращает текущую версию схемы и признак прерванной миграции
+func (m *Migrator) Status() (MigrationStatus, error) {
+	status := MigrationStatus{Latest: m.latest}
+	version, dirty, err := m.migrate.Version()
+	if err != nil && err != migrate.ErrNilVersion {
+		return status, err
+	}
+	status.Version = version
+	status.Dirty = dirty
+	return status, nil
+}
+
+// Close закрывает соединение с базой данных
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.migrate.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+	return dbErr
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	src, err := embeddedMigrations()
+	require.NoError(t, err)
+
+	latest, err := latestVersion(src)
+	require.NoError(t, err)
+	assert.Equal(t, uint(9), latest)
+
+	// каждая версия от первой до последней имеет миграции вверх и вниз
+	version, err := src.First()
+	require.NoError(t, err)
+	for {
+		for _, read := range []func(uint) (io.ReadCloser, string, error){src.ReadUp, src.ReadDown} {
+			r, _, err := read(version)
+			require.NoError(t, err, "version %d", version)
+			body, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.NotEmpty(t, body, "version %d", version)
+			r.Close()
+		}
+		if version == latest {
+			break
+		}
+		next, err := src.Next(version)
+		require.NoError(t, err)
+		assert.Equal(t, version+1, next)
+		version = next
+	}
+}
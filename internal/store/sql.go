@@ -3,12 +3,14 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/Soliard/go-tpl-metrics/models"
-	"github.com/golang-migrate/migrate"
-	"github.com/golang-migrate/migrate/database/postgres"
-	_ "github.com/golang-migrate/migrate/source/file"
+	"github.com/jackc/pgx/v5/pgtype"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 )
@@ -17,10 +19,12 @@ import (
 // Автоматически выполняет миграции при создании.
 type DatabaseStorage struct {
 	db *sqlx.DB
+	// latestMigration - последняя встроенная миграция, ее версию схемы проверяет HealthChecks
+	latestMigration uint
 }
 
 // NewDatabaseStorage создает новое хранилище в базе данных PostgreSQL.
-// Выполняет миграции из папки cmd/server/migrations.
+// Применяет встроенные миграции из cmd/server/migrations.
 func NewDatabaseStorage(ctx context.Context, databaseDSN string) (Storage, error) {
 	db, err := sqlx.Open("pgx", databaseDSN)
 	if err != nil {
@@ -31,71 +35,168 @@ func NewDatabaseStorage(ctx context.Context, databaseDSN string) (Storage, error
 		return nil, err
 	}
 
-	//exmpl migrate create -ext sql -dir migrations -seq create_metrics_table
-	// миграции
-	driver, err := postgres.WithInstance(db.DB, &postgres.Config{})
+	// миграции встроены в бинарный файл, поэтому сервер не зависит от рабочей директории
+	migr, err := newMigrator(db.DB)
 	if err != nil {
 		return nil, err
 	}
-	migr, err := migrate.NewWithDatabaseInstance(
-		//file://cmd/server/migrations
-		"file://cmd/server/migrations",
-		databaseDSN,
-		driver,
-	)
-	if err != nil {
-		return nil, err
-	}
-	if err := migr.Up(); err != nil && err != migrate.ErrNoChange {
+	if err := migr.Up(); err != nil {
 		return nil, err
 	}
 
-	return &DatabaseStorage{db: db}, nil
+	return &DatabaseStorage{db: db, latestMigration: migr.latest}, nil
+}
+
+// mergeHistCountsExpr поэлементно складывает счетчики корзин сохраненной и присланной гистограммы
+const mergeHistCountsExpr = `CASE WHEN EXCLUDED.hist_counts IS NULL THEN NULL ELSE ARRAY(
+			SELECT a + b
+			FROM unnest(metrics.hist_counts, EXCLUDED.hist_counts) WITH ORDINALITY AS t(a, b, i)
+			ORDER BY i
+		) END`
+
+// insertSampleQuery сохраняет отсчет истории метрики
+const insertSampleQuery = `
+	INSERT INTO metric_samples (id, ts, delta, value)
+	VALUES ($1, $2, $3, $4)
+`
+
+// pruneSamplesQuery удаляет у серий $1 отсчеты старше $2 и все, кроме $3 последних.
+// Обе границы вычисляются по индексу metric_samples_id_ts.
+const pruneSamplesQuery = `
+	DELETE FROM metric_samples s
+	USING unnest($1::text[]) AS k(id)
+	WHERE s.id = k.id AND s.ts < GREATEST($2, (
+		SELECT ts FROM metric_samples
+		WHERE id = k.id
+		ORDER BY ts DESC
+		OFFSET $3 - 1 LIMIT 1
+	))
+`
+
+// pruneSamplesTx применяет к истории обновленных серий ограничения historyRetention и historyCapacity
+func pruneSamplesTx(ctx context.Context, tx *sqlx.Tx, keys []string, now time.Time) error {
+	_, err := tx.ExecContext(ctx, pruneSamplesQuery, keys, now.Add(-historyRetention), historyCapacity)
+	return err
 }
 
 // UpdateMetrics обновляет несколько метрик в базе данных за одну транзакцию.
-// Для counter метрик значения суммируются, для gauge - перезаписываются.
+// Для counter метрик значения суммируются, для gauge - перезаписываются,
+// счетчики корзин гистограмм складываются в upsert при совпадении границ.
 func (s *DatabaseStorage) UpdateMetrics(ctx context.Context, metrics []*models.Metrics) error {
-	query := `
-	INSERT INTO metrics (id, type, value, delta, hash)
-	VALUES (:id, :type, :value, :delta, :hash)
-	ON CONFLICT (id) DO UPDATE SET
-		value = EXCLUDED.value,
-		delta = metrics.delta + EXCLUDED.delta,
-		hash = EXCLUDED.hash
-`
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	if err := updateMetricsTx(ctx, tx, metrics); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateMetricsOnce обновляет метрики пакета в одной транзакции с записью ключа пакета
+// в таблицу processed_batches. Ключи старше окна дедупликации удаляются.
+func (s *DatabaseStorage) UpdateMetricsOnce(ctx context.Context, batchKey string, metrics []*models.Metrics) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, `DELETE FROM processed_batches WHERE processed_at < $1`, now.Add(-dedupWindowTTL))
+	if err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO processed_batches (batch_key, processed_at)
+		VALUES ($1, $2)
+		ON CONFLICT (batch_key) DO NOTHING
+	`, batchKey, now)
+	if err != nil {
+		return err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if inserted == 0 {
+		return ErrDuplicateBatch
+	}
+
+	if err := updateMetricsTx(ctx, tx, metrics); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateMetricsTx выполняет upsert метрик и запись отсчетов истории в рамках транзакции
+func updateMetricsTx(ctx context.Context, tx *sqlx.Tx, metrics []*models.Metrics) error {
+	query := `
+	INSERT INTO metrics (series_key, id, labels, type, value, delta, hash, hist_bounds, hist_counts, hist_sum, hist_count)
+	VALUES (:series_key, :id, :labels, :type, :value, :delta, :hash, :hist_bounds, :hist_counts, :hist_sum, :hist_count)
+	ON CONFLICT (series_key) DO UPDATE SET
+		value = EXCLUDED.value,
+		delta = metrics.delta + EXCLUDED.delta,
+		hash = EXCLUDED.hash,
+		hist_counts = ` + mergeHistCountsExpr + `,
+		hist_sum = metrics.hist_sum + EXCLUDED.hist_sum,
+		hist_count = metrics.hist_count + EXCLUDED.hist_count
+	WHERE metrics.hist_bounds IS NOT DISTINCT FROM EXCLUDED.hist_bounds
+	RETURNING delta, value, hist_count, hist_sum
+`
 	stmt, err := tx.PrepareNamedContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
+	sampleStmt, err := tx.PrepareContext(ctx, insertSampleQuery)
+	if err != nil {
+		return err
+	}
+	defer sampleStmt.Close()
+
+	now := time.Now()
+	keys := make([]string, 0, len(metrics))
 	for _, m := range metrics {
+		labels, err := marshalLabels(m.Labels)
+		if err != nil {
+			return err
+		}
+		bounds, counts, sum, count := histogramParams(m.Histogram)
 		params := map[string]interface{}{
-			"id":    m.ID,
-			"type":  m.MType,
-			"value": m.Value,
-			"delta": m.Delta,
-			"hash":  m.Hash,
+			"series_key":  m.SeriesKey(),
+			"id":          m.ID,
+			"labels":      labels,
+			"type":        m.MType,
+			"value":       m.Value,
+			"delta":       m.Delta,
+			"hash":        m.Hash,
+			"hist_bounds": bounds,
+			"hist_counts": counts,
+			"hist_sum":    sum,
+			"hist_count":  count,
 		}
-		_, err := stmt.ExecContext(ctx, params)
+		stored, err := scanUpserted(stmt.QueryRowxContext(ctx, params))
 		if err != nil {
 			return err
 		}
+		sample := models.NewSample(stored, now)
+		_, err = sampleStmt.ExecContext(ctx, m.SeriesKey(), sample.Timestamp, sample.Delta, sample.Value)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, m.SeriesKey())
 	}
-
-	return tx.Commit()
+	return pruneSamplesTx(ctx, tx, keys, now)
 }
 
 // UpdateMetric обновляет или создает одну метрику в базе данных
+// и возвращает ее сохраненное состояние: накопленный counter и гистограмму
 func (s *DatabaseStorage) UpdateMetric(ctx context.Context, metric *models.Metrics) (*models.Metrics, error) {
-	existed, err := s.GetMetric(ctx, metric.ID)
+	existed, err := s.GetMetric(ctx, metric.SeriesKey())
 	if err != nil && !errors.Is(err, ErrNotFound) {
 		return nil, err
 	}
@@ -105,85 +206,192 @@ func (s *DatabaseStorage) UpdateMetric(ctx context.Context, metric *models.Metri
 		}
 	}
 
+	labels, err := marshalLabels(metric.Labels)
+	if err != nil {
+		return nil, err
+	}
+
 	var query string
 	var args []interface{}
 
 	if metric.MType == models.Counter {
 		query = `
-			INSERT INTO metrics (id, type, value, delta, hash)
-			VALUES ($1, $2, $3, $4, $5)
-			ON CONFLICT (id) DO UPDATE SET
+			INSERT INTO metrics (series_key, id, labels, type, value, delta, hash)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (series_key) DO UPDATE SET
 				delta = metrics.delta + EXCLUDED.delta,
 				hash = EXCLUDED.hash
+			RETURNING ` + metricColumns + `
 		`
-		args = []interface{}{metric.ID, metric.MType, metric.Value, metric.Delta, metric.Hash}
+		args = []interface{}{metric.SeriesKey(), metric.ID, labels, metric.MType, metric.Value, metric.Delta, metric.Hash}
 	} else if metric.MType == models.Gauge {
 		query = `
-			INSERT INTO metrics (id, type, value, delta, hash)
-			VALUES ($1, $2, $3, $4, $5)
-			ON CONFLICT (id) DO UPDATE SET
+			INSERT INTO metrics (series_key, id, labels, type, value, delta, hash)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (series_key) DO UPDATE SET
 				value = EXCLUDED.value,
 				hash = EXCLUDED.hash
+			RETURNING ` + metricColumns + `
 		`
-		args = []interface{}{metric.ID, metric.MType, metric.Value, metric.Delta, metric.Hash}
+		args = []interface{}{metric.SeriesKey(), metric.ID, labels, metric.MType, metric.Value, metric.Delta, metric.Hash}
+	} else if metric.MType == models.Histogram {
+		// при несовпадении границ корзин условие WHERE не выполняется и строка не возвращается
+		query = `
+			INSERT INTO metrics (series_key, id, labels, type, hash, hist_bounds, hist_counts, hist_sum, hist_count)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (series_key) DO UPDATE SET
+				hist_counts = ` + mergeHistCountsExpr + `,
+				hist_sum = metrics.hist_sum + EXCLUDED.hist_sum,
+				hist_count = metrics.hist_count + EXCLUDED.hist_count,
+				hash = EXCLUDED.hash
+			WHERE metrics.hist_bounds = EXCLUDED.hist_bounds
+			RETURNING ` + metricColumns + `
+		`
+		bounds, counts, sum, count := histogramParams(metric.Histogram)
+		args = []interface{}{metric.SeriesKey(), metric.ID, labels, metric.MType, metric.Hash, bounds, counts, sum, count}
 	} else {
 		return nil, ErrInvalidMetricReceived
 	}
 
-	_, err = s.db.ExecContext(ctx, query, args...)
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
+	stored, err := scanMetric(tx.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		// строка не возвращается при несовпадении границ корзин гистограммы
+		return nil, ErrInvalidMetricReceived
+	}
 	if err != nil {
 		return nil, err
 	}
-	return metric, nil
+	sample := models.NewSample(stored, time.Now())
+	_, err = tx.ExecContext(ctx, insertSampleQuery, metric.SeriesKey(), sample.Timestamp, sample.Delta, sample.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := pruneSamplesTx(ctx, tx, []string{metric.SeriesKey()}, sample.Timestamp); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return stored, nil
 }
 
-// GetMetric получает метрику по имени из базы данных
+// GetMetric получает метрику по ключу серии из базы данных
 func (s *DatabaseStorage) GetMetric(ctx context.Context, name string) (*models.Metrics, error) {
 	query := `
 		SELECT 
-			id, type, delta, value, hash
+			` + metricColumns + `
 		FROM
 			metrics
 		WHERE 
-			id = $1
+			series_key = $1
 		`
 
-	var metric models.Metrics
-	err := s.db.QueryRowContext(ctx, query, name).
-		Scan(&metric.ID, &metric.MType, &metric.Delta, &metric.Value, &metric.Hash)
-
+	metric, err := scanMetric(s.db.QueryRowContext(ctx, query, name))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
 		return nil, err
 	}
-	return &metric, nil
+	return metric, nil
+}
+
+// GetMetricsByID возвращает все серии метрики с указанным именем из базы данных
+func (s *DatabaseStorage) GetMetricsByID(ctx context.Context, id string) ([]*models.Metrics, error) {
+	query := `
+		SELECT
+			` + metricColumns + `
+		FROM metrics
+		WHERE
+			id = $1
+	`
+	return s.queryMetrics(ctx, query, id)
 }
 
 // GetAllMetrics возвращает все метрики из базы данных
 func (s *DatabaseStorage) GetAllMetrics(ctx context.Context) ([]*models.Metrics, error) {
-	var metrics []*models.Metrics
 	query := `
 		SELECT
-			id, type, delta, value, hash
+			` + metricColumns + `
 		FROM metrics
 	`
-	rows, err := s.db.QueryContext(ctx, query)
+	return s.queryMetrics(ctx, query)
+}
+
+// ListMetrics возвращает страницу метрик из базы данных.
+// Префикс имени ищется через LIKE по индексу с сортировкой "C", она же задает побайтовый порядок выборки,
+// совпадающий с порядком остальных хранилищ. Следующая страница начинается сравнением с позицией курсора по тому же индексу.
+func (s *DatabaseStorage) ListMetrics(ctx context.Context, opts ListOptions) (*MetricsPage, error) {
+	q, err := parseListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		where []string
+		args  []interface{}
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if prefix := q.likePrefix(); prefix != "" {
+		where = append(where, `id COLLATE "C" LIKE `+arg(escapeLike(prefix)+"%"))
+	}
+	if q.Match != "" {
+		where = append(where, `id ~ `+arg(globToRegexp(q.Match)))
+	}
+	if q.Type != "" {
+		where = append(where, `type = `+arg(q.Type))
+	}
+	if q.after != nil {
+		where = append(where, `(id COLLATE "C", series_key COLLATE "C") > (`+arg(q.after.id)+`, `+arg(q.after.key)+`)`)
+	}
+
+	query := `
+		SELECT
+			` + metricColumns + `
+		FROM metrics
+	`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY id COLLATE "C", series_key COLLATE "C"`
+	if q.Limit > 0 {
+		// лишняя строка показывает, что есть следующая страница
+		query += ` LIMIT ` + arg(q.Limit+1)
+	}
+
+	metrics, err := s.queryMetrics(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return q.page(metrics), nil
+}
+
+// queryMetrics выполняет запрос, возвращающий строки метрик с метками
+func (s *DatabaseStorage) queryMetrics(ctx context.Context, query string, args ...interface{}) ([]*models.Metrics, error) {
+	var metrics []*models.Metrics
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var m models.Metrics
-		err = rows.Scan(&m.ID, &m.MType, &m.Delta, &m.Value, &m.Hash)
+		m, err := scanMetric(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		metrics = append(metrics, &m)
+		metrics = append(metrics, m)
 	}
 
 	err = rows.Err()
@@ -194,6 +402,220 @@ func (s *DatabaseStorage) GetAllMetrics(ctx context.Context) ([]*models.Metrics,
 	return metrics, nil
 }
 
+// metricColumns - колонки метрики в порядке, ожидаемом scanMetric
+const metricColumns = `id, labels, type, delta, value, hash, hist_bounds, hist_counts, hist_sum, hist_count`
+
+// rowScanner - общий интерфейс sql.Row и sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMetric считывает метрику из строки с колонками metricColumns
+func scanMetric(row rowScanner) (*models.Metrics, error) {
+	var m models.Metrics
+	var labels []byte
+	var bounds []float64
+	var counts []int64
+	var sum *float64
+	var count *int64
+	typeMap := pgtype.NewMap()
+	err := row.Scan(&m.ID, &labels, &m.MType, &m.Delta, &m.Value, &m.Hash,
+		typeMap.SQLScanner(&bounds), typeMap.SQLScanner(&counts), &sum, &count)
+	if err != nil {
+		return nil, err
+	}
+	if m.Labels, err = unmarshalLabels(labels); err != nil {
+		return nil, err
+	}
+	if bounds != nil && sum != nil && count != nil {
+		m.Histogram = &models.HistogramValue{Bounds: bounds, Counts: counts, Sum: *sum, Count: *count}
+	}
+	return &m, nil
+}
+
+// scanUpserted считывает состояние метрики после upsert из RETURNING delta, value, hist_count, hist_sum.
+// Отсутствие строки означает несовпадение границ корзин гистограммы.
+func scanUpserted(row rowScanner) (*models.Metrics, error) {
+	var stored models.Metrics
+	var count *int64
+	var sum *float64
+	err := row.Scan(&stored.Delta, &stored.Value, &count, &sum)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidMetricReceived
+		}
+		return nil, err
+	}
+	if count != nil && sum != nil {
+		stored.Histogram = &models.HistogramValue{Count: *count, Sum: *sum}
+	}
+	return &stored, nil
+}
+
+// histogramParams возвращает значения колонок гистограммы, для остальных типов метрик - NULL
+func histogramParams(h *models.HistogramValue) (bounds []float64, counts []int64, sum *float64, count *int64) {
+	if h == nil {
+		return nil, nil, nil, nil
+	}
+	return h.Bounds, h.Counts, models.PFloat(h.Sum), models.PInt(h.Count)
+}
+
+// marshalLabels кодирует метки в JSON для колонки labels
+func marshalLabels(labels map[string]string) (string, error) {
+	if len(labels) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// unmarshalLabels декодирует колонку labels, пустой набор меток возвращается как nil
+func unmarshalLabels(data []byte) (map[string]string, error) {
+	var labels map[string]string
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return labels, nil
+}
+
+// GetHistory возвращает отсчеты серии метрики из таблицы metric_samples
+func (s *DatabaseStorage) GetHistory(ctx context.Context, name string, from, to time.Time, step time.Duration) ([]*models.Sample, error) {
+	if _, err := s.GetMetric(ctx, name); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			ts, delta, value
+		FROM metric_samples
+		WHERE
+			id = $1 AND ts BETWEEN $2 AND $3
+		ORDER BY ts
+	`
+	rows, err := s.db.QueryContext(ctx, query, name, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := make([]*models.Sample, 0)
+	for rows.Next() {
+		var sample models.Sample
+		err = rows.Scan(&sample.Timestamp, &sample.Delta, &sample.Value)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, &sample)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return downsample(samples, from, step), nil
+}
+
+// DeleteMetric удаляет серию метрики и ее отсчеты истории в одной транзакции
+func (s *DatabaseStorage) DeleteMetric(ctx context.Context, name string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM metrics WHERE series_key = $1`, name)
+	if err != nil {
+		return err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM metric_samples WHERE id = $1`, name)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteByPrefix удаляет серии метрик с именем, начинающимся с prefix, и их отсчеты истории.
+// Префикс сравнивается как строка, без интерпретации символов шаблона LIKE.
+func (s *DatabaseStorage) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var keys []string
+	err = tx.SelectContext(ctx, &keys, `
+		DELETE FROM metrics
+		WHERE left(id, length($1)) = $1
+		RETURNING series_key
+	`, prefix)
+	if err != nil {
+		return 0, err
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM metric_samples WHERE id = ANY($1)`, keys)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// ResetCounter обнуляет значение counter серии и записывает отсчет истории
+func (s *DatabaseStorage) ResetCounter(ctx context.Context, name string) (*models.Metrics, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE metrics SET delta = 0
+		WHERE series_key = $1 AND type = $2
+		RETURNING ` + metricColumns
+	metric, err := scanMetric(tx.QueryRowContext(ctx, query, name, models.Counter))
+	if errors.Is(err, sql.ErrNoRows) {
+		// серия отсутствует или имеет другой тип
+		if _, err := s.GetMetric(ctx, name); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidMetricReceived
+	}
+	if err != nil {
+		return nil, err
+	}
+	sample := models.NewSample(metric, time.Now())
+	_, err = tx.ExecContext(ctx, insertSampleQuery, name, sample.Timestamp, sample.Delta, sample.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := pruneSamplesTx(ctx, tx, []string{name}, sample.Timestamp); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return metric, nil
+}
+
 // Ping проверяет соединение с базой данных
 func (s *DatabaseStorage) Ping(ctx context.Context) error {
 	return s.db.PingContext(ctx)
@@ -6,6 +6,7 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/Soliard/go-tpl-metrics/internal/config"
 	"github.com/Soliard/go-tpl-metrics/models"
@@ -16,12 +17,31 @@ import (
 type Storage interface {
 	// UpdateMetric обновляет или создает одну метрику
 	UpdateMetric(ctx context.Context, metric *models.Metrics) (*models.Metrics, error)
-	// UpdateMetrics обновляет или создает несколько метрик за одну операцию
+	// UpdateMetrics обновляет или создает несколько метрик за одну операцию: пакет применяется целиком или не применяется вовсе
 	UpdateMetrics(ctx context.Context, metrics []*models.Metrics) error
-	// GetMetric получает метрику по имени
+	// UpdateMetricsOnce обновляет метрики пакета, если пакет с таким ключом еще не применялся.
+	// Для повторного пакета возвращает ErrDuplicateBatch, не изменяя метрики.
+	UpdateMetricsOnce(ctx context.Context, batchKey string, metrics []*models.Metrics) error
+	// GetMetric получает метрику по ключу серии (для метрики без меток ключ совпадает с именем)
 	GetMetric(ctx context.Context, name string) (*models.Metrics, error)
+	// GetMetricsByID получает все серии метрики с указанным именем независимо от меток
+	GetMetricsByID(ctx context.Context, id string) ([]*models.Metrics, error)
 	// GetAllMetrics получает все метрики из хранилища
 	GetAllMetrics(ctx context.Context) ([]*models.Metrics, error)
+	// ListMetrics получает страницу метрик, отфильтрованных по типу, префиксу и glob шаблону имени.
+	// Возвращает ErrInvalidListOptions для некорректных параметров или курсора.
+	ListMetrics(ctx context.Context, opts ListOptions) (*MetricsPage, error)
+	// GetHistory получает отсчеты серии метрики за интервал [from, to].
+	// Если step > 0, отсчеты прореживаются до одного на каждый интервал step.
+	GetHistory(ctx context.Context, name string, from, to time.Time, step time.Duration) ([]*models.Sample, error)
+	// DeleteMetric удаляет серию метрики по ключу вместе с ее историей.
+	// Возвращает ErrNotFound, если серии нет.
+	DeleteMetric(ctx context.Context, name string) error
+	// DeleteByPrefix удаляет все серии метрик, имя которых начинается с prefix, и возвращает их количество
+	DeleteByPrefix(ctx context.Context, prefix string) (int, error)
+	// ResetCounter обнуляет значение counter серии по ключу и возвращает метрику после обнуления.
+	// Возвращает ErrNotFound, если серии нет, и ErrInvalidMetricReceived, если серия не counter.
+	ResetCounter(ctx context.Context, name string) (*models.Metrics, error)
 }
 
 // ErrNotFound возвращается когда метрика не найдена в хранилище
@@ -30,6 +50,9 @@ var ErrNotFound = errors.New("not found")
 // ErrInvalidMetricReceived возвращается когда получена некорректная метрика
 var ErrInvalidMetricReceived = errors.New("invalid metric recieved")
 
+// ErrDuplicateBatch возвращается когда пакет с таким ключом уже был применен
+var ErrDuplicateBatch = errors.New("duplicate batch")
+
 // New создает новое хранилище на основе конфигурации.
 // Выбирает тип хранилища в зависимости от настроек:
 // - FileStoragePath указан -> файловое хранилище
@@ -37,7 +60,8 @@ var ErrInvalidMetricReceived = errors.New("invalid metric recieved")
 // - иначе -> хранилище в памяти
 func New(ctx context.Context, config *config.ServerConfig) (Storage, error) {
 	if config.FileStoragePath != "" {
-		return NewFileStorage(config.FileStoragePath, config.IsRestoreFromFile)
+		return NewFileStorage(config.FileStoragePath, config.IsRestoreFromFile,
+			time.Duration(config.StoreIntervalSeconds)*time.Second)
 	} else if config.DatabaseDSN != "" {
 		return NewDatabaseStorage(ctx, config.DatabaseDSN)
 	} else {
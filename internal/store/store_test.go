@@ -2,10 +2,16 @@ package store
 
 import (
 	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Soliard/go-tpl-metrics/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMemStorage(t *testing.T) {
@@ -61,3 +67,544 @@ func TestMemStorage(t *testing.T) {
 		assert.Equal(t, 100.0, *metric.Value)
 	})
 }
+
+func TestMemStorageHistory(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+	from := time.Now().Add(-time.Minute)
+
+	for i := 1; i <= 3; i++ {
+		_, err := storage.UpdateMetric(ctx, models.NewCounterMetric("historyCounter", 10))
+		assert.NoError(t, err)
+	}
+
+	samples, err := storage.GetHistory(ctx, "historyCounter", from, time.Now(), 0)
+	assert.NoError(t, err)
+	if assert.Len(t, samples, 3) {
+		assert.Equal(t, int64(10), *samples[0].Delta)
+		assert.Equal(t, int64(30), *samples[2].Delta)
+	}
+
+	samples, err = storage.GetHistory(ctx, "historyCounter", from, time.Now(), time.Hour)
+	assert.NoError(t, err)
+	if assert.Len(t, samples, 1) {
+		assert.Equal(t, int64(30), *samples[0].Delta)
+	}
+
+	samples, err = storage.GetHistory(ctx, "historyCounter", from.Add(-time.Hour), from, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, samples)
+
+	_, err = storage.GetHistory(ctx, "nonExistent", from, time.Now(), 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemStorageHistoryRetention(t *testing.T) {
+	storage := newMemStorage(map[string]*models.Metrics{})
+	ctx := context.Background()
+	old := &models.Sample{Timestamp: time.Now().Add(-historyRetention - time.Hour), Value: models.PFloat(0)}
+	oldRing := func(key string) {
+		ring := newSampleRing(historyCapacity)
+		ring.push(old)
+		storage.shard(key).history[key] = ring
+	}
+
+	// устаревший отсчет удаляется при чтении истории
+	oldRing("idle")
+	samples, err := storage.GetHistory(ctx, "idle", time.Time{}, time.Now(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, samples)
+	assert.Empty(t, storage.shard("idle").history["idle"].samples)
+
+	// и при записи нового отсчета
+	oldRing("cpu")
+	_, err = storage.UpdateMetric(ctx, models.NewGaugeMetric("cpu", 1))
+	require.NoError(t, err)
+	ring := storage.shard("cpu").history["cpu"]
+	if assert.Len(t, ring.samples, 1) {
+		assert.Equal(t, 1.0, *ring.samples[0].Value)
+	}
+}
+
+func TestSampleRing(t *testing.T) {
+	ring := newSampleRing(3)
+	base := time.Unix(1000, 0)
+	for i := 0; i < 5; i++ {
+		ring.push(&models.Sample{Timestamp: base.Add(time.Duration(i) * time.Second), Value: models.PFloat(float64(i))})
+	}
+
+	samples := ring.rangeQuery(base, base.Add(time.Hour))
+	if assert.Len(t, samples, 3) {
+		assert.Equal(t, 2.0, *samples[0].Value)
+		assert.Equal(t, 4.0, *samples[2].Value)
+	}
+}
+
+func TestFileStorageHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	storage, err := NewFileStorage(path, false, 0)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	from := time.Now().Add(-time.Minute)
+
+	_, err = storage.UpdateMetric(ctx, models.NewGaugeMetric("historyGauge", 1.5))
+	assert.NoError(t, err)
+	err = storage.UpdateMetrics(ctx, []*models.Metrics{
+		models.NewGaugeMetric("historyGauge", 2.5),
+		models.NewCounterMetric("other", 1),
+	})
+	assert.NoError(t, err)
+
+	restored, err := NewFileStorage(path, true, 0)
+	assert.NoError(t, err)
+	samples, err := restored.GetHistory(ctx, "historyGauge", from, time.Now(), 0)
+	assert.NoError(t, err)
+	if assert.Len(t, samples, 2) {
+		assert.Equal(t, 1.5, *samples[0].Value)
+		assert.Equal(t, 2.5, *samples[1].Value)
+	}
+}
+
+func TestFileStorageHistoryRetention(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	storage, err := NewFileStorage(path, false, 0)
+	require.NoError(t, err)
+	require.NoError(t, storage.UpdateMetrics(ctx, []*models.Metrics{models.NewGaugeMetric("cpu", 1)}))
+	require.NoError(t, storage.UpdateMetrics(ctx, []*models.Metrics{models.NewGaugeMetric("cpu", 2)}))
+
+	// отсчет старше срока хранения, записанный до появления ограничения
+	old := historyRecord{ID: "cpu", Sample: models.Sample{Timestamp: time.Now().Add(-historyRetention - time.Hour), Value: models.PFloat(0)}}
+	require.NoError(t, storage.(*fileStorage).appendHistory(old))
+
+	restored, err := NewFileStorage(path, true, 0)
+	require.NoError(t, err)
+	fs := restored.(*fileStorage)
+	samples, err := restored.GetHistory(ctx, "cpu", time.Time{}, time.Now(), 0)
+	require.NoError(t, err)
+	assert.Len(t, samples, 2, "samples older than retention are not restored")
+	assert.Equal(t, 3, fs.historyLines)
+
+	// сегмент вырос вдвое: при сохранении снимка он переписывается без устаревших и удаленных серий
+	fs.historyCompactLines = 2
+	require.NoError(t, restored.UpdateMetrics(ctx, []*models.Metrics{models.NewGaugeMetric("temp", 1)}))
+	require.NoError(t, restored.DeleteMetric(ctx, "temp"))
+	require.NoError(t, restored.UpdateMetrics(ctx, []*models.Metrics{models.NewGaugeMetric("cpu", 3)}))
+	require.NoError(t, restored.UpdateMetrics(ctx, []*models.Metrics{models.NewGaugeMetric("cpu", 4)}))
+	data, err := os.ReadFile(fs.historyPath)
+	require.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(data), "\n"))
+	assert.NotContains(t, string(data), "temp")
+	assert.Equal(t, 4, fs.historyKept)
+
+	restored, err = NewFileStorage(path, true, 0)
+	require.NoError(t, err)
+	samples, err = restored.GetHistory(ctx, "cpu", time.Time{}, time.Now(), 0)
+	require.NoError(t, err)
+	if assert.Len(t, samples, 4) {
+		assert.Equal(t, 1.0, *samples[0].Value)
+		assert.Equal(t, 4.0, *samples[3].Value)
+	}
+	_, err = restored.GetHistory(ctx, "temp", time.Time{}, time.Now(), 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSampleRingPrune(t *testing.T) {
+	ring := newSampleRing(3)
+	base := time.Unix(1000, 0)
+	for i := 0; i < 5; i++ {
+		ring.push(&models.Sample{Timestamp: base.Add(time.Duration(i) * time.Second), Value: models.PFloat(float64(i))})
+	}
+
+	ring.prune(base.Add(3 * time.Second))
+	samples := ring.since(time.Time{})
+	if assert.Len(t, samples, 2) {
+		assert.Equal(t, 3.0, *samples[0].Value)
+		assert.Equal(t, 4.0, *samples[1].Value)
+	}
+	ring.push(&models.Sample{Timestamp: base.Add(5 * time.Second), Value: models.PFloat(5)})
+	assert.Len(t, ring.since(time.Time{}), 3)
+}
+
+func TestDedupWindow(t *testing.T) {
+	w := newDedupWindow(2, time.Minute)
+	now := time.Now()
+
+	w.add("a", now)
+	w.add("b", now)
+	assert.True(t, w.contains("a", now))
+
+	// превышение емкости вытесняет самый старый ключ
+	w.add("c", now)
+	assert.False(t, w.contains("a", now))
+	assert.True(t, w.contains("b", now))
+
+	// емкость считается по агенту: пакеты одного агента не вытесняют ключи другого
+	w.add("agent-1/1", now.Add(time.Millisecond))
+	w.add("agent-2/1", now.Add(time.Second))
+	w.add("agent-2/2", now.Add(2*time.Second))
+	w.add("agent-2/3", now.Add(3*time.Second))
+	assert.True(t, w.contains("agent-1/1", now))
+	assert.False(t, w.contains("agent-2/1", now))
+	assert.Equal(t, []string{"b", "c", "agent-1/1", "agent-2/2", "agent-2/3"}, entryKeys(w.entries()))
+
+	// истечение TTL вытесняет все ключи
+	assert.False(t, w.contains("c", now.Add(time.Hour)))
+	assert.Empty(t, w.entries())
+}
+
+func entryKeys(entries []dedupEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for _, e := range entries {
+		keys = append(keys, e.Key)
+	}
+	return keys
+}
+
+func TestUpdateMetricsOnce(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	fileStorage, err := NewFileStorage(path, false, 0)
+	assert.NoError(t, err)
+
+	storages := map[string]Storage{
+		"memory": NewMemoryStorage(),
+		"file":   fileStorage,
+	}
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			batch := []*models.Metrics{models.NewCounterMetric("PollCount", 5)}
+			assert.NoError(t, storage.UpdateMetricsOnce(ctx, "agent/1", batch))
+			assert.ErrorIs(t, storage.UpdateMetricsOnce(ctx, "agent/1", batch), ErrDuplicateBatch)
+			assert.NoError(t, storage.UpdateMetricsOnce(ctx, "agent/2", batch))
+
+			metric, err := storage.GetMetric(ctx, "PollCount")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(10), *metric.Delta)
+		})
+	}
+
+	t.Run("file restore", func(t *testing.T) {
+		restored, err := NewFileStorage(path, true, 0)
+		assert.NoError(t, err)
+		batch := []*models.Metrics{models.NewCounterMetric("PollCount", 5)}
+		assert.ErrorIs(t, restored.UpdateMetricsOnce(ctx, "agent/1", batch), ErrDuplicateBatch)
+		assert.NoError(t, restored.UpdateMetricsOnce(ctx, "agent/3", batch))
+	})
+}
+
+func TestRestoreBatchesTornTail(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	storage, err := NewFileStorage(path, false, 0)
+	require.NoError(t, err)
+	batch := []*models.Metrics{models.NewCounterMetric("PollCount", 5)}
+	require.NoError(t, storage.UpdateMetricsOnce(ctx, "agent/1", batch))
+	require.NoError(t, storage.(*fileStorage).Close())
+
+	batchesPath := path + ".batches"
+	info, err := os.Stat(batchesPath)
+	require.NoError(t, err)
+	file, err := os.OpenFile(batchesPath, os.O_WRONLY|os.O_APPEND, 0666)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"key":"agent/2","se`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// оборванная запись не мешает запуску и обрезается, новые ключи дописываются за целыми
+	restored, err := NewFileStorage(path, true, 0)
+	require.NoError(t, err)
+	torn, err := os.Stat(batchesPath)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), torn.Size())
+	assert.ErrorIs(t, restored.UpdateMetricsOnce(ctx, "agent/1", batch), ErrDuplicateBatch)
+	require.NoError(t, restored.UpdateMetricsOnce(ctx, "agent/2", batch))
+	require.NoError(t, restored.(*fileStorage).Close())
+
+	again, err := NewFileStorage(path, true, 0)
+	require.NoError(t, err)
+	defer again.(*fileStorage).Close()
+	assert.ErrorIs(t, again.UpdateMetricsOnce(ctx, "agent/2", batch), ErrDuplicateBatch)
+}
+
+func TestLabeledSeries(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	fileStorage, err := NewFileStorage(path, false, 0)
+	assert.NoError(t, err)
+
+	for name, storage := range map[string]Storage{"memory": NewMemoryStorage(), "file": fileStorage} {
+		t.Run(name, func(t *testing.T) {
+			web1 := models.NewGaugeMetric("Alloc", 1)
+			web1.Labels = map[string]string{"host": "web1"}
+			web2 := models.NewGaugeMetric("Alloc", 2)
+			web2.Labels = map[string]string{"host": "web2"}
+			err := storage.UpdateMetrics(ctx, []*models.Metrics{web1, web2, models.NewGaugeMetric("Alloc", 3)})
+			assert.NoError(t, err)
+
+			series, err := storage.GetMetricsByID(ctx, "Alloc")
+			assert.NoError(t, err)
+			assert.Len(t, series, 3)
+
+			metric, err := storage.GetMetric(ctx, `Alloc{host="web2"}`)
+			assert.NoError(t, err)
+			assert.Equal(t, 2.0, *metric.Value)
+			metric, err = storage.GetMetric(ctx, "Alloc")
+			assert.NoError(t, err)
+			assert.Equal(t, 3.0, *metric.Value)
+		})
+	}
+
+	restored, err := NewFileStorage(path, true, 0)
+	assert.NoError(t, err)
+	metric, err := restored.GetMetric(ctx, `Alloc{host="web1"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"host": "web1"}, metric.Labels)
+}
+
+func TestRestoreLegacyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	legacy := `{"PollCount":{"id":"PollCount","type":"counter","delta":5},"version":{"id":"version","type":"gauge","value":1}}`
+	assert.NoError(t, os.WriteFile(path, []byte(legacy), 0666))
+
+	storage, err := NewFileStorage(path, true, 0)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	metric, err := storage.GetMetric(ctx, "PollCount")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), *metric.Delta)
+	_, err = storage.GetMetric(ctx, "version")
+	assert.NoError(t, err)
+
+	// после записи файл сохраняется в текущей версии формата
+	_, err = storage.UpdateMetric(ctx, models.NewCounterMetric("PollCount", 1))
+	assert.NoError(t, err)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"version": 2`)
+}
+
+func TestMemStorageHistogram(t *testing.T) {
+	storage := NewMemoryStorage()
+	ctx := context.Background()
+
+	first := models.NewHistogram([]float64{1, 10})
+	first.Observe(0.5)
+	_, err := storage.UpdateMetric(ctx, models.NewHistogramMetric("latency", first))
+	assert.NoError(t, err)
+
+	second := models.NewHistogram([]float64{1, 10})
+	second.Observe(5)
+	second.Observe(20)
+	_, err = storage.UpdateMetric(ctx, models.NewHistogramMetric("latency", second))
+	assert.NoError(t, err)
+
+	metric, err := storage.GetMetric(ctx, "latency")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 1, 1}, metric.Histogram.Counts)
+	assert.Equal(t, int64(3), metric.Histogram.Count)
+	assert.Equal(t, 25.5, metric.Histogram.Sum)
+	// присланная гистограмма не изменяется при объединении
+	assert.Equal(t, int64(1), first.Count)
+
+	_, err = storage.UpdateMetric(ctx, models.NewHistogramMetric("latency", models.NewHistogram([]float64{1, 100})))
+	assert.ErrorIs(t, err, ErrInvalidMetricReceived)
+}
+
+func TestFileStoragePeriodicFlush(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	storage, err := NewFileStorage(path, false, 50*time.Millisecond)
+	assert.NoError(t, err)
+	defer storage.(io.Closer).Close()
+
+	_, err = storage.UpdateMetric(ctx, models.NewCounterMetric("PollCount", 5))
+	assert.NoError(t, err)
+	// в периодическом режиме запись не сохраняет файл сразу
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Eventually(t, func() bool {
+		restored, err := restoreFromFile(path, path+".wal")
+		return err == nil && restored["PollCount"] != nil && *restored["PollCount"].Delta == 5
+	}, time.Second, 10*time.Millisecond)
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err), "temp file must be renamed")
+}
+
+func TestFileStorageCloseFlushes(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	storage, err := NewFileStorage(path, false, time.Hour)
+	assert.NoError(t, err)
+
+	err = storage.UpdateMetrics(ctx, []*models.Metrics{
+		models.NewCounterMetric("PollCount", 5),
+		models.NewGaugeMetric("Alloc", 1.5),
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, storage.(io.Closer).Close())
+	// повторный Close безопасен
+	assert.NoError(t, storage.(io.Closer).Close())
+
+	restored, err := NewFileStorage(path, true, 0)
+	assert.NoError(t, err)
+	metric, err := restored.GetMetric(ctx, "Alloc")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, *metric.Value)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "metrics.json")
+	assert.NoError(t, writeFileAtomic(path, []byte("first")))
+	// оставшийся после сбоя временный файл перезаписывается и не попадает в результат
+	assert.NoError(t, os.WriteFile(path+".tmp", []byte("partial garbage from crash"), 0666))
+	assert.NoError(t, writeFileAtomic(path, []byte("second")))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDeleteAndResetCounter(t *testing.T) {
+	fileStorage, err := NewFileStorage(filepath.Join(t.TempDir(), "metrics.json"), false, time.Hour)
+	assert.NoError(t, err)
+	defer fileStorage.(io.Closer).Close()
+	storages := map[string]Storage{
+		"memory": NewMemoryStorage(),
+		"file":   fileStorage,
+	}
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			err := storage.UpdateMetrics(ctx, []*models.Metrics{
+				models.NewCounterMetric("requests", 5),
+				models.NewGaugeMetric("temp", 1.5),
+				models.NewGaugeMetric("runtime.alloc", 1),
+				{ID: "runtime.frees", MType: models.Gauge, Value: models.PFloat(2), Labels: map[string]string{"host": "a"}},
+			})
+			assert.NoError(t, err)
+
+			assert.NoError(t, storage.DeleteMetric(ctx, "temp"))
+			assert.ErrorIs(t, storage.DeleteMetric(ctx, "temp"), ErrNotFound)
+			_, err = storage.GetHistory(ctx, "temp", time.Time{}, time.Now(), 0)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			deleted, err := storage.DeleteByPrefix(ctx, "runtime.")
+			assert.NoError(t, err)
+			assert.Equal(t, 2, deleted)
+
+			m, err := storage.ResetCounter(ctx, "requests")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), *m.Delta)
+			_, err = storage.ResetCounter(ctx, "missing")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			all, err := storage.GetAllMetrics(ctx)
+			assert.NoError(t, err)
+			assert.Len(t, all, 1)
+			samples, err := storage.GetHistory(ctx, "requests", time.Time{}, time.Now(), 0)
+			assert.NoError(t, err)
+			if assert.Len(t, samples, 2) {
+				assert.Equal(t, int64(0), *samples[1].Delta)
+			}
+
+			assert.NoError(t, storage.UpdateMetrics(ctx, []*models.Metrics{models.NewGaugeMetric("temp", 3)}))
+			_, err = storage.ResetCounter(ctx, "temp")
+			assert.ErrorIs(t, err, ErrInvalidMetricReceived)
+		})
+	}
+}
+
+func TestFileStorageDeleteRestore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	// периодический режим: обновления попадают только в журнал
+	storage, err := NewFileStorage(path, false, time.Hour)
+	assert.NoError(t, err)
+
+	_, err = storage.UpdateMetric(ctx, models.NewGaugeMetric("temp", 1.5))
+	assert.NoError(t, err)
+	_, err = storage.UpdateMetric(ctx, models.NewCounterMetric("requests", 5))
+	assert.NoError(t, err)
+	assert.NoError(t, storage.DeleteMetric(ctx, "temp"))
+	_, err = storage.ResetCounter(ctx, "requests")
+	assert.NoError(t, err)
+	// серия, созданная заново после удаления, не наследует историю
+	_, err = storage.UpdateMetric(ctx, models.NewGaugeMetric("temp", 2.5))
+	assert.NoError(t, err)
+
+	// восстановление без штатной остановки: снимок плюс журнал
+	restored, err := NewFileStorage(path, true, 0)
+	assert.NoError(t, err)
+	m, err := restored.GetMetric(ctx, "requests")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), *m.Delta)
+	samples, err := restored.GetHistory(ctx, "temp", time.Time{}, time.Now(), 0)
+	assert.NoError(t, err)
+	if assert.Len(t, samples, 1) {
+		assert.Equal(t, 2.5, *samples[0].Value)
+	}
+
+	assert.NoError(t, restored.DeleteMetric(ctx, "temp"))
+	restored, err = NewFileStorage(path, true, 0)
+	assert.NoError(t, err)
+	_, err = restored.GetMetric(ctx, "temp")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = restored.GetHistory(ctx, "temp", time.Time{}, time.Now(), 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// testStorages возвращает хранилища всех типов.
+// Хранилище в базе данных добавляется, если задан TEST_DATABASE_DSN; серии с префиксом prefix удаляются до и после теста.
+func testStorages(t *testing.T, prefix string) map[string]Storage {
+	t.Helper()
+	fileStorage, err := NewFileStorage(filepath.Join(t.TempDir(), "metrics.json"), false, 0)
+	require.NoError(t, err)
+	storages := map[string]Storage{
+		"memory": NewMemoryStorage(),
+		"file":   fileStorage,
+	}
+	if dsn := os.Getenv("TEST_DATABASE_DSN"); dsn != "" {
+		db, err := NewDatabaseStorage(context.Background(), dsn)
+		require.NoError(t, err)
+		cleanup := func() { db.DeleteByPrefix(context.Background(), prefix) }
+		cleanup()
+		t.Cleanup(cleanup)
+		storages["database"] = db
+	}
+	return storages
+}
+
+func TestUpdateMetricReturnsStored(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range testStorages(t, "stored_") {
+		t.Run(name, func(t *testing.T) {
+			counter := models.NewCounterMetric("stored_requests", 2)
+			counter.Labels = map[string]string{"host": "a"}
+			got, err := s.UpdateMetric(ctx, counter)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), *got.Delta)
+
+			got, err = s.UpdateMetric(ctx, &models.Metrics{ID: "stored_requests", MType: models.Counter,
+				Delta: models.PInt(3), Labels: map[string]string{"host": "a"}})
+			require.NoError(t, err)
+			assert.Equal(t, "stored_requests", got.ID)
+			assert.Equal(t, models.Counter, got.MType)
+			assert.Equal(t, map[string]string{"host": "a"}, got.Labels)
+			assert.Equal(t, int64(5), *got.Delta, "accumulated value is returned, not the delta")
+
+			hist := models.NewHistogram([]float64{1})
+			hist.Observe(0.5)
+			_, err = s.UpdateMetric(ctx, models.NewHistogramMetric("stored_latency", hist))
+			require.NoError(t, err)
+			got, err = s.UpdateMetric(ctx, models.NewHistogramMetric("stored_latency", hist))
+			require.NoError(t, err)
+			require.NotNil(t, got.Histogram)
+			assert.Equal(t, int64(2), got.Histogram.Count)
+			assert.Equal(t, []int64{2, 0}, got.Histogram.Counts)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+)
+
+// walCompactSize - размер журнала упреждающей записи, после которого он сворачивается в новый снимок
+const walCompactSize = 4 << 20
+
+// Журнал упреждающей записи (WAL) файлового хранилища.
+// Каждая запись - строка "<crc32 в hex> <метрика в JSON>\n" с состоянием серии после обновления,
+// поэтому повторное применение журнала поверх снимка идемпотентно: побеждает последняя запись серии.
+// Контрольная сумма позволяет отличить оборванную при сбое запись в конце журнала от целой.
+
+// errWALRecord возвращается для поврежденной или оборванной записи журнала
+var errWALRecord = errors.New("corrupted wal record")
+
+// encodeWALRecord кодирует метрику в запись журнала
+func encodeWALRecord(m *models.Metrics) ([]byte, error) {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, 0, len(payload)+10)
+	record = fmt.Appendf(record, "%08x ", crc32.ChecksumIEEE(payload))
+	record = append(record, payload...)
+	return append(record, '\n'), nil
+}
+
+// decodeWALRecord разбирает строку журнала без завершающего перевода строки
+func decodeWALRecord(line []byte) (*models.Metrics, error) {
+	sum, payload, ok := bytes.Cut(line, []byte{' '})
+	if !ok || len(sum) != 8 {
+		return nil, errWALRecord
+	}
+	want, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil || crc32.ChecksumIEEE(payload) != uint32(want) {
+		return nil, errWALRecord
+	}
+	var m models.Metrics
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil, errWALRecord
+	}
+	return &m, nil
+}
+
+// appendWAL дописывает записи в журнал и сбрасывает его на диск.
+// Возвращает количество записанных байт.
+func appendWAL(path string, metrics []*models.Metrics) (int64, error) {
+	if len(metrics) == 0 {
+		return 0, nil
+	}
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		record, err := encodeWALRecord(m)
+		if err != nil {
+			return 0, err
+		}
+		buf.Write(record)
+	}
+
+	os.MkdirAll(filepath.Dir(path), 0755)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	n, err := file.Write(buf.Bytes())
+	if err != nil {
+		return int64(n), err
+	}
+	return int64(n), file.Sync()
+}
+
+// replayWAL применяет записи журнала к метрикам снимка.
+// Чтение останавливается на первой поврежденной или оборванной записи, и журнал обрезается до нее,
+// чтобы следующие записи не оказались после мусора. Возвращает количество примененных записей.
+func replayWAL(path string, metrics map[string]*models.Metrics) (int, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0666)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+
+	var (
+		applied int
+		offset  int64
+	)
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return applied, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		var m *models.Metrics
+		if err == nil {
+			m, err = decodeWALRecord(line[:len(line)-1])
+		} else {
+			// запись без перевода строки оборвана при записи
+			err = errWALRecord
+		}
+		if err != nil {
+			if err := file.Truncate(offset); err != nil {
+				return 0, fmt.Errorf("cant truncate wal: %w", err)
+			}
+			return applied, file.Sync()
+		}
+		metrics[m.SeriesKey()] = m
+		applied++
+		offset += int64(len(line))
+	}
+}
+
+// resetWAL очищает журнал после сохранения снимка, содержащего все его записи
+func resetWAL(path string) error {
+	err := os.Truncate(path, 0)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Soliard/go-tpl-metrics/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorageWALRecovery(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	storage, err := NewFileStorage(path, false, time.Hour)
+	require.NoError(t, err)
+
+	h := models.NewHistogram([]float64{1})
+	h.Observe(0.5)
+	require.NoError(t, storage.UpdateMetrics(ctx, []*models.Metrics{
+		models.NewCounterMetric("PollCount", 5),
+		models.NewGaugeMetric("Alloc", 1.5),
+		models.NewHistogramMetric("latency", h),
+	}))
+	_, err = storage.UpdateMetric(ctx, models.NewCounterMetric("PollCount", 2))
+	require.NoError(t, err)
+
+	// сбой: снимок не сохранялся, Close не вызывался
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	restored, err := NewFileStorage(path, true, time.Hour)
+	require.NoError(t, err)
+	metric, err := restored.GetMetric(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), *metric.Delta)
+	metric, err = restored.GetMetric(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, *metric.Value)
+	metric, err = restored.GetMetric(ctx, "latency")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), metric.Histogram.Count)
+
+	// после снимка журнал пуст, а повторное восстановление дает те же значения
+	require.NoError(t, restored.(*fileStorage).Close())
+	info, err := os.Stat(path + ".wal")
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+	again, err := NewFileStorage(path, true, 0)
+	require.NoError(t, err)
+	metric, err = again.GetMetric(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), *metric.Delta)
+}
+
+func TestReplayWALTornTail(t *testing.T) {
+	tests := []struct {
+		name string
+		tail string
+	}{
+		{name: "torn record", tail: `1a2b3c4d {"id":"Alloc","ty`},
+		{name: "bad checksum", tail: "00000000 {\"id\":\"Alloc\",\"type\":\"gauge\",\"value\":9}\n"},
+		{name: "garbage", tail: "\x00\x00\x00\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "metrics.json.wal")
+			size, err := appendWAL(path, []*models.Metrics{
+				models.NewCounterMetric("PollCount", 5),
+				models.NewGaugeMetric("Alloc", 1.5),
+			})
+			require.NoError(t, err)
+			file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
+			require.NoError(t, err)
+			_, err = file.WriteString(tt.tail)
+			require.NoError(t, err)
+			require.NoError(t, file.Close())
+
+			metrics := map[string]*models.Metrics{}
+			applied, err := replayWAL(path, metrics)
+			require.NoError(t, err)
+			assert.Equal(t, 2, applied)
+			assert.Equal(t, 1.5, *metrics["Alloc"].Value)
+
+			// поврежденный хвост обрезан, новые записи дописываются за целыми
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			assert.Equal(t, size, info.Size())
+			_, err = appendWAL(path, []*models.Metrics{models.NewGaugeMetric("Alloc", 3)})
+			require.NoError(t, err)
+			applied, err = replayWAL(path, metrics)
+			require.NoError(t, err)
+			assert.Equal(t, 3, applied)
+			assert.Equal(t, 3.0, *metrics["Alloc"].Value)
+		})
+	}
+}
+
+func TestFileStorageWALCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	storage, err := NewFileStorage(path, false, time.Hour)
+	require.NoError(t, err)
+	fs := storage.(*fileStorage)
+	fs.compactSize = 256
+
+	for i := 0; i < 9; i++ {
+		_, err := storage.UpdateMetric(ctx, models.NewCounterMetric("PollCount", 1))
+		require.NoError(t, err)
+	}
+	info, err := os.Stat(fs.walPath)
+	require.NoError(t, err)
+	assert.Less(t, info.Size(), fs.compactSize)
+
+	// снимок сохранен при сворачивании журнала, а хвост журнала доигрывается поверх него
+	snapshot, err := restoreSnapshot(path)
+	require.NoError(t, err)
+	require.Contains(t, snapshot, "PollCount")
+	assert.Less(t, *snapshot["PollCount"].Delta, int64(9))
+	metrics, err := restoreFromFile(path, fs.walPath)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), *metrics["PollCount"].Delta)
+}
+
+func TestFileStorageBatchIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	for name, interval := range map[string]time.Duration{"sync": 0, "wal": time.Hour} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "metrics.json")
+			storage, err := NewFileStorage(path, false, interval)
+			require.NoError(t, err)
+			defer storage.(*fileStorage).Close()
+			require.NoError(t, storage.UpdateMetrics(ctx, []*models.Metrics{
+				models.NewCounterMetric("requests", 1),
+				models.NewGaugeMetric("temp", 1),
+			}))
+
+			// ошибка типа в середине пакета не оставляет изменений от предыдущих метрик
+			err = storage.UpdateMetrics(ctx, []*models.Metrics{
+				models.NewCounterMetric("requests", 10),
+				models.NewCounterMetric("temp", 1),
+			})
+			require.ErrorIs(t, err, ErrInvalidMetricReceived)
+			got, err := storage.GetMetric(ctx, "requests")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), *got.Delta)
+
+			// серия, встречающаяся в пакете дважды, накапливает оба значения
+			require.NoError(t, storage.UpdateMetrics(ctx, []*models.Metrics{
+				models.NewCounterMetric("requests", 2),
+				models.NewCounterMetric("requests", 3),
+			}))
+			got, err = storage.GetMetric(ctx, "requests")
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), *got.Delta)
+
+			restored, err := NewFileStorage(path, true, interval)
+			require.NoError(t, err)
+			defer restored.(*fileStorage).Close()
+			got, err = restored.GetMetric(ctx, "requests")
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), *got.Delta, "file contains only applied batches")
+		})
+	}
+}
+
+func TestFileStorageWriteErrorKeepsMemory(t *testing.T) {
+	ctx := context.Background()
+	for name, interval := range map[string]time.Duration{"sync": 0, "wal": time.Hour} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "metrics.json")
+			storage, err := NewFileStorage(path, false, interval)
+			require.NoError(t, err)
+			fs := storage.(*fileStorage)
+			defer fs.Close()
+			require.NoError(t, storage.UpdateMetrics(ctx, []*models.Metrics{models.NewCounterMetric("requests", 1)}))
+
+			// каталог на месте временного файла снимка и журнала делает запись невозможной
+			require.NoError(t, os.RemoveAll(fs.walPath))
+			require.NoError(t, os.Mkdir(fs.walPath, 0755))
+			require.NoError(t, os.Mkdir(path+".tmp", 0755))
+
+			batch := []*models.Metrics{models.NewCounterMetric("requests", 5)}
+			require.Error(t, storage.UpdateMetricsOnce(ctx, "agent/1", batch))
+			_, err = storage.UpdateMetric(ctx, models.NewCounterMetric("requests", 5))
+			require.Error(t, err)
+			_, err = storage.ResetCounter(ctx, "requests")
+			require.Error(t, err)
+			require.Error(t, storage.DeleteMetric(ctx, "requests"))
+			_, err = storage.DeleteByPrefix(ctx, "req")
+			require.Error(t, err)
+			got, err := storage.GetMetric(ctx, "requests")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), *got.Delta, "failed writes do not change memory")
+
+			// после восстановления диска повтор пакета применяется один раз
+			require.NoError(t, os.Remove(fs.walPath))
+			require.NoError(t, os.Remove(path+".tmp"))
+			require.NoError(t, storage.UpdateMetricsOnce(ctx, "agent/1", batch))
+			require.ErrorIs(t, storage.UpdateMetricsOnce(ctx, "agent/1", batch), ErrDuplicateBatch)
+			got, err = storage.GetMetric(ctx, "requests")
+			require.NoError(t, err)
+			assert.Equal(t, int64(6), *got.Delta)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// Package tlsutil настраивает TLS и взаимный TLS для HTTP и gRPC сервера и агента.
+// Сертификаты перечитываются при изменении файлов без перезапуска.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReloadInterval - как часто при установке соединения проверяется изменение файлов сертификатов
+var ReloadInterval = time.Second
+
+// ErrNoCertificates возвращается, когда в файле CA нет ни одного сертификата
+var ErrNoCertificates = errors.New("no certificates found in CA file")
+
+// fileState - время изменения и размер файла, по которым определяется его замена
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// reloader хранит сертификат и пул CA, загруженные из файлов, и перечитывает их при изменении файлов.
+// Если новые файлы не загружаются (например, сертификат уже заменен, а ключ еще нет),
+// продолжают использоваться ранее загруженные и попытка повторяется при следующей проверке.
+type reloader struct {
+	certFile, keyFile, caFile string
+	logger                    *zap.Logger
+
+	mu      sync.Mutex
+	checked time.Time
+	states  map[string]fileState
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+}
+
+func newReloader(certFile, keyFile, caFile string, logger *zap.Logger) (*reloader, error) {
+	r := &reloader{certFile: certFile, keyFile: keyFile, caFile: caFile, logger: logger}
+	states, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(states); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// files возвращает пути настроенных файлов
+func (r *reloader) files() []string {
+	var files []string
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func (r *reloader) stat() (map[string]fileState, error) {
+	states := make(map[string]fileState, 3)
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		states[f] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+	return states, nil
+}
+
+// load загружает сертификат и CA и запоминает состояние файлов, из которых они загружены
+func (r *reloader) load(states map[string]fileState) error {
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("cant load certificate: %w", err)
+		}
+		cert = &c
+	}
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		var err error
+		pool, err = loadCertPool(r.caFile)
+		if err != nil {
+			return err
+		}
+	}
+	r.cert, r.pool, r.states = cert, pool, states
+	return nil
+}
+
+// current возвращает сертификат и пул CA, перечитав файлы, если они изменились
+func (r *reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) < ReloadInterval {
+		return r.cert, r.pool
+	}
+	r.checked = time.Now()
+
+	states, err := r.stat()
+	if err != nil {
+		r.logger.Warn("cant check tls files, keep loaded certificates", zap.Error(err))
+		return r.cert, r.pool
+	}
+	changed := false
+	for f, s := range states {
+		if old := r.states[f]; !s.modTime.Equal(old.modTime) || s.size != old.size {
+			changed = true
+		}
+	}
+	if !changed {
+		return r.cert, r.pool
+	}
+	if err := r.load(states); err != nil {
+		r.logger.Warn("cant reload tls files, keep loaded certificates", zap.Error(err))
+		return r.cert, r.pool
+	}
+	r.logger.Info("tls certificates reloaded", zap.Strings("files", r.files()))
+	return r.cert, r.pool
+}
+
+// loadCertPool читает PEM сертификаты CA из файла
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cant read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, ErrNoCertificates
+	}
+	return pool, nil
+}
+
+// verifyChain проверяет цепочку клиентского сертификата по пулу CA
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("no client certificate")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		c, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("cant parse client certificate: %w", err)
+		}
+		certs = append(certs, c)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+// NewServerConfig создает TLS конфигурацию сервера с сертификатом из certFile и keyFile.
+// Если задан clientCAFile, включается взаимный TLS: клиент обязан предъявить сертификат,
+// подписанный одним из CA из этого файла. Сертификат, ключ и CA перечитываются при изменении файлов.
+func NewServerConfig(certFile, keyFile, clientCAFile string, logger *zap.Logger) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("tls certificate and key files are required")
+	}
+	r, err := newReloader(certFile, keyFile, clientCAFile, logger)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		},
+	}
+	if clientCAFile != "" {
+		// цепочка проверяется вручную, чтобы использовать перечитанный пул CA без пересоздания конфигурации
+		cfg.ClientAuth = tls.RequireAnyClientCert
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			_, pool := r.current()
+			return verifyChain(rawCerts, pool)
+		}
+	}
+	return cfg, nil
+}
+
+// NewClientConfig создает TLS конфигурацию клиента. Сертификат сервера проверяется по CA из caFile,
+// а если он не задан - по системным CA. Если заданы certFile и keyFile, клиент предъявляет сертификат
+// для взаимного TLS; он перечитывается при изменении файлов, а CA загружается один раз.
+func NewClientConfig(caFile, certFile, keyFile string, logger *zap.Logger) (*tls.Config, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("tls client certificate and key must be set together")
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile != "" {
+		r, err := newReloader(certFile, keyFile, "", logger)
+		if err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.current()
+			return cert, nil
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,166 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Soliard/go-tpl-metrics/internal/tlsutil/tlstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// startTLSServer запускает HTTPS сервер с конфигурацией cfg и возвращает его адрес
+func startTLSServer(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+		TLSConfig: cfg,
+		ErrorLog:  log.New(io.Discard, "", 0),
+	}
+	go srv.ServeTLS(lis, "", "")
+	t.Cleanup(func() { srv.Close() })
+	return "https://" + lis.Addr().String()
+}
+
+// get выполняет запрос по новому соединению, чтобы каждый раз проходило TLS рукопожатие
+func get(t *testing.T, cfg *tls.Config, url string) (*http.Response, error) {
+	t.Helper()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+	resp, err := client.Get(url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestServerConfigTLS(t *testing.T) {
+	ca := tlstest.NewCA(t)
+	certFile, keyFile := ca.ServerCert("server")
+	serverCfg, err := NewServerConfig(certFile, keyFile, "", zap.NewNop())
+	require.NoError(t, err)
+	url := startTLSServer(t, serverCfg)
+
+	clientCfg, err := NewClientConfig(ca.CertFile, "", "", zap.NewNop())
+	require.NoError(t, err)
+	resp, err := get(t, clientCfg, url)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// без CA сертификат сервера не проходит проверку системными CA
+	_, err = get(t, &tls.Config{}, url)
+	assert.Error(t, err)
+
+	otherCfg, err := NewClientConfig(tlstest.NewCA(t).CertFile, "", "", zap.NewNop())
+	require.NoError(t, err)
+	_, err = get(t, otherCfg, url)
+	assert.Error(t, err)
+}
+
+func TestServerConfigMutualTLS(t *testing.T) {
+	ca := tlstest.NewCA(t)
+	certFile, keyFile := ca.ServerCert("server")
+	serverCfg, err := NewServerConfig(certFile, keyFile, ca.CertFile, zap.NewNop())
+	require.NoError(t, err)
+	url := startTLSServer(t, serverCfg)
+
+	clientCert, clientKey := ca.ClientCert("agent")
+	other := tlstest.NewCA(t)
+	otherCert, otherKey := other.ClientCert("agent")
+	serverAsClient, serverAsClientKey := ca.ServerCert("server-as-client")
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		wantErr  bool
+	}{
+		{name: "trusted client certificate", certFile: clientCert, keyFile: clientKey},
+		{name: "without client certificate", wantErr: true},
+		{name: "certificate from other CA", certFile: otherCert, keyFile: otherKey, wantErr: true},
+		{name: "certificate without client auth usage", certFile: serverAsClient, keyFile: serverAsClientKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientCfg, err := NewClientConfig(ca.CertFile, tt.certFile, tt.keyFile, zap.NewNop())
+			require.NoError(t, err)
+			_, err = get(t, clientCfg, url)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestServerConfigReload(t *testing.T) {
+	interval := ReloadInterval
+	ReloadInterval = 0
+	t.Cleanup(func() { ReloadInterval = interval })
+
+	ca := tlstest.NewCA(t)
+	certFile, keyFile := ca.ServerCert("server")
+	serverCfg, err := NewServerConfig(certFile, keyFile, ca.CertFile, zap.NewNop())
+	require.NoError(t, err)
+	url := startTLSServer(t, serverCfg)
+
+	clientCert, clientKey := ca.ClientCert("agent")
+	clientCfg, err := NewClientConfig(ca.CertFile, clientCert, clientKey, zap.NewNop())
+	require.NoError(t, err)
+
+	resp, err := get(t, clientCfg, url)
+	require.NoError(t, err)
+	serial := resp.TLS.PeerCertificates[0].SerialNumber
+
+	// новый сертификат применяется без перезапуска сервера
+	ca.ServerCert("server")
+	resp, err = get(t, clientCfg, url)
+	require.NoError(t, err)
+	assert.NotEqual(t, serial, resp.TLS.PeerCertificates[0].SerialNumber)
+
+	// поврежденный файл не сбрасывает загруженный сертификат
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	_, err = get(t, clientCfg, url)
+	assert.NoError(t, err)
+
+	// замена CA клиентов отзывает доверие к сертификатам старого CA
+	ca.ServerCert("server")
+	newCA := tlstest.NewCA(t)
+	data, err := os.ReadFile(newCA.CertFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(ca.CertFile, data, 0o600))
+	_, err = get(t, clientCfg, url)
+	assert.Error(t, err)
+}
+
+func TestConfigErrors(t *testing.T) {
+	ca := tlstest.NewCA(t)
+	certFile, keyFile := ca.ServerCert("server")
+	emptyCA := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(emptyCA, []byte("not a certificate"), 0o600))
+
+	_, err := NewServerConfig("", "", "", zap.NewNop())
+	assert.Error(t, err, "missing certificate")
+	_, err = NewServerConfig(certFile, filepath.Join(t.TempDir(), "missing.pem"), "", zap.NewNop())
+	assert.Error(t, err, "missing key")
+	_, err = NewServerConfig(keyFile, certFile, "", zap.NewNop())
+	assert.Error(t, err, "swapped certificate and key")
+	_, err = NewServerConfig(certFile, keyFile, emptyCA, zap.NewNop())
+	assert.ErrorIs(t, err, ErrNoCertificates)
+
+	_, err = NewClientConfig(ca.CertFile, certFile, "", zap.NewNop())
+	assert.Error(t, err, "certificate without key")
+	_, err = NewClientConfig(emptyCA, "", "", zap.NewNop())
+	assert.ErrorIs(t, err, ErrNoCertificates)
+}
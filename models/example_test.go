@@ -84,3 +84,41 @@ func ExamplePInt() {
 	// Original: 100
 	// Pointer value: 100
 }
+
+// ExampleHistogramValue_Quantile демонстрирует оценку квантилей гистограммы
+func ExampleHistogramValue_Quantile() {
+	h := models.NewHistogram([]float64{0.1, 0.5, 1})
+	for _, v := range []float64{0.05, 0.2, 0.3, 0.4, 0.7, 2} {
+		h.Observe(v)
+	}
+
+	fmt.Printf("Count: %d, Sum: %.2f\n", h.Count, h.Sum)
+	fmt.Printf("Counts: %v\n", h.Counts)
+	fmt.Printf("p50: %.2f\n", h.Quantile(0.5))
+	fmt.Printf("p99: %.2f\n", h.Quantile(0.99))
+	// Output:
+	// Count: 6, Sum: 3.65
+	// Counts: [1 3 1 1]
+	// p50: 0.37
+	// p99: 1.00
+}
+
+// ExampleHistogramValue_Merge демонстрирует объединение гистограмм с одинаковыми границами
+func ExampleHistogramValue_Merge() {
+	a := models.NewHistogram([]float64{1, 10})
+	a.Observe(0.5)
+	b := models.NewHistogram([]float64{1, 10})
+	b.Observe(5)
+	b.Observe(50)
+
+	err := a.Merge(b)
+	fmt.Printf("Error: %v\n", err)
+	fmt.Printf("Counts: %v, Count: %d\n", a.Counts, a.Count)
+
+	err = a.Merge(models.NewHistogram([]float64{1, 100}))
+	fmt.Printf("Error: %v\n", err)
+	// Output:
+	// Error: <nil>
+	// Counts: [1 1 1], Count: 3
+	// Error: invalid histogram: bounds mismatch
+}
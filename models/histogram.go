@@ -0,0 +1,200 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidHistogram возвращается для гистограммы с некорректными границами или счетчиками
+var ErrInvalidHistogram = errors.New("invalid histogram")
+
+// DefaultBuckets - границы корзин по умолчанию для длительностей в секундах
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultQuantiles - квантили, оценки которых отдаются для гистограмм по умолчанию
+var DefaultQuantiles = []float64{0.5, 0.9, 0.99}
+
+// HistogramValue представляет значение метрики типа histogram.
+// Bounds - возрастающие верхние границы корзин, последняя корзина (+Inf) подразумевается.
+// Counts - количество наблюдений в каждой корзине (не накопительное), len(Counts) == len(Bounds)+1.
+type HistogramValue struct {
+	Bounds    []float64          `json:"bounds"`              // верхние границы корзин
+	Counts    []int64            `json:"counts"`              // количество наблюдений в корзинах
+	Sum       float64            `json:"sum"`                 // сумма наблюдений
+	Count     int64              `json:"count"`               // общее количество наблюдений
+	Quantiles map[string]float64 `json:"quantiles,omitempty"` // оценки квантилей, заполняются сервером в ответах
+}
+
+// NewHistogram создает пустую гистограмму с указанными границами корзин
+func NewHistogram(bounds []float64) *HistogramValue {
+	return &HistogramValue{
+		Bounds: append([]float64(nil), bounds...),
+		Counts: make([]int64, len(bounds)+1),
+	}
+}
+
+// NewHistogramMetric создает новую метрику типа histogram
+func NewHistogramMetric(id string, h *HistogramValue) *Metrics {
+	return &Metrics{
+		ID:        id,
+		MType:     Histogram,
+		Histogram: h,
+	}
+}
+
+// Observe добавляет наблюдение в соответствующую корзину
+func (h *HistogramValue) Observe(v float64) {
+	h.ObserveN(v, 1)
+}
+
+// ObserveN добавляет n одинаковых наблюдений, например при восстановлении прореженной выборки
+func (h *HistogramValue) ObserveN(v float64, n int64) {
+	i := sort.SearchFloat64s(h.Bounds, v)
+	h.Counts[i] += n
+	h.Sum += v * float64(n)
+	h.Count += n
+}
+
+// Validate проверяет, что границы возрастают, а счетчики согласованы с границами и общим количеством
+func (h *HistogramValue) Validate() error {
+	if len(h.Bounds) == 0 {
+		return fmt.Errorf("%w: no bounds", ErrInvalidHistogram)
+	}
+	for i := 1; i < len(h.Bounds); i++ {
+		if !(h.Bounds[i] > h.Bounds[i-1]) {
+			return fmt.Errorf("%w: bounds must be strictly increasing", ErrInvalidHistogram)
+		}
+	}
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("%w: expected %d counts, got %d", ErrInvalidHistogram, len(h.Bounds)+1, len(h.Counts))
+	}
+	var total int64
+	for _, c := range h.Counts {
+		if c < 0 {
+			return fmt.Errorf("%w: negative count", ErrInvalidHistogram)
+		}
+		total += c
+	}
+	if total != h.Count {
+		return fmt.Errorf("%w: count %d does not match buckets total %d", ErrInvalidHistogram, h.Count, total)
+	}
+	return nil
+}
+
+// Merge добавляет наблюдения другой гистограммы с теми же границами корзин
+func (h *HistogramValue) Merge(other *HistogramValue) error {
+	if !h.SameBounds(other) {
+		return fmt.Errorf("%w: bounds mismatch", ErrInvalidHistogram)
+	}
+	for i, c := range other.Counts {
+		h.Counts[i] += c
+	}
+	h.Sum += other.Sum
+	h.Count += other.Count
+	return nil
+}
+
+// SameBounds проверяет, что гистограммы имеют одинаковые границы корзин
+func (h *HistogramValue) SameBounds(other *HistogramValue) bool {
+	if len(h.Bounds) != len(other.Bounds) {
+		return false
+	}
+	for i, b := range h.Bounds {
+		if b != other.Bounds[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Quantile оценивает квантиль q (0 <= q <= 1) линейной интерполяцией внутри корзины.
+// Нижней границей первой корзины считается 0, если ее верхняя граница положительна.
+// Если квантиль попадает в корзину +Inf, возвращается наибольшая конечная граница.
+// Для пустой гистограммы возвращается NaN.
+func (h *HistogramValue) Quantile(q float64) float64 {
+	if h.Count == 0 || q < 0 || q > 1 {
+		return math.NaN()
+	}
+	rank := q * float64(h.Count)
+	var cumulative int64
+	for i, c := range h.Counts {
+		if c == 0 || float64(cumulative+c) < rank {
+			cumulative += c
+			continue
+		}
+		if i == len(h.Bounds) {
+			return h.Bounds[len(h.Bounds)-1]
+		}
+		upper := h.Bounds[i]
+		lower := 0.0
+		if i > 0 {
+			lower = h.Bounds[i-1]
+		} else if upper <= 0 {
+			return upper
+		}
+		return lower + (upper-lower)*(rank-float64(cumulative))/float64(c)
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+// Clone возвращает копию гистограммы без оценок квантилей
+func (h *HistogramValue) Clone() *HistogramValue {
+	return &HistogramValue{
+		Bounds: append([]float64(nil), h.Bounds...),
+		Counts: append([]int64(nil), h.Counts...),
+		Sum:    h.Sum,
+		Count:  h.Count,
+	}
+}
+
+// WithQuantiles возвращает копию гистограммы с оценками указанных квантилей
+func (h *HistogramValue) WithQuantiles(qs []float64) *HistogramValue {
+	c := h.Clone()
+	c.Quantiles = make(map[string]float64, len(qs))
+	for _, q := range qs {
+		if v := h.Quantile(q); !math.IsNaN(v) {
+			c.Quantiles[FormatQuantile(q)] = v
+		}
+	}
+	return c
+}
+
+// FormatQuantile форматирует квантиль для использования в качестве ключа (например "0.99")
+func FormatQuantile(q float64) string {
+	return strconv.FormatFloat(q, 'g', -1, 64)
+}
+
+// ParseBuckets разбирает границы корзин в формате 0.01,0.1,1.
+// Пустая строка означает границы по умолчанию.
+func ParseBuckets(s string, defaults []float64) ([]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return defaults, nil
+	}
+	parts := strings.Split(s, ",")
+	bounds := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		b, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket bound %q: %w", p, err)
+		}
+		bounds = append(bounds, b)
+	}
+	if err := NewHistogram(bounds).Validate(); err != nil {
+		return nil, err
+	}
+	return bounds, nil
+}
+
+// ExponentialBuckets возвращает count границ, начиная со start и умножая каждую следующую на factor
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start
+		start *= factor
+	}
+	return bounds
+}
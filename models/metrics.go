@@ -5,10 +5,13 @@ package models
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// Counter и Gauge - константы для типов метрик
+// Counter, Gauge и Histogram - константы для типов метрик
 const (
 	// Counter представляет тип метрики-счетчика
 	// Счетчики накапливают значения (например, количество запросов)
@@ -16,17 +19,48 @@ const (
 	// Gauge представляет тип метрики-измерителя
 	// Измерители показывают текущее значение (например, использование памяти)
 	Gauge = "gauge"
+	// Histogram представляет тип метрики-гистограммы
+	// Гистограммы распределяют наблюдения по корзинам (например, длительности запросов)
+	Histogram = "histogram"
 )
 
 // Metrics представляет метрику в системе мониторинга.
 // Используется для передачи данных между агентом и сервером.
 // Delta и Value объявлены через указатели для различения значения "0" от не заданного значения.
+// Серия метрики определяется именем и набором меток, см. SeriesKey.
 type Metrics struct {
-	ID    string   `json:"id"`              // имя метрики
-	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
-	Hash  string   `json:"hash,omitempty"`  // подпись метрики для проверки целостности
+	ID        string            `json:"id"`                  // имя метрики
+	MType     string            `json:"type"`                // параметр, принимающий значение gauge, counter или histogram
+	Delta     *int64            `json:"delta,omitempty"`     // значение метрики в случае передачи counter
+	Value     *float64          `json:"value,omitempty"`     // значение метрики в случае передачи gauge
+	Histogram *HistogramValue   `json:"histogram,omitempty"` // значение метрики в случае передачи histogram
+	Hash      string            `json:"hash,omitempty"`      // подпись метрики для проверки целостности
+	Labels    map[string]string `json:"labels,omitempty"`    // метки серии (например host, agent, environment)
+}
+
+// Sample представляет значение метрики в определенный момент времени.
+// Для gauge хранится Value, для counter - накопленное значение Delta после обновления,
+// для histogram - накопленные количество наблюдений в Delta и их сумма в Value.
+type Sample struct {
+	Timestamp time.Time `json:"timestamp"`       // время фиксации значения
+	Delta     *int64    `json:"delta,omitempty"` // накопленное значение counter
+	Value     *float64  `json:"value,omitempty"` // значение gauge
+}
+
+// NewSample создает отсчет истории из текущего состояния метрики.
+func NewSample(m *Metrics, ts time.Time) *Sample {
+	s := &Sample{Timestamp: ts}
+	if m.Delta != nil {
+		s.Delta = PInt(*m.Delta)
+	}
+	if m.Value != nil {
+		s.Value = PFloat(*m.Value)
+	}
+	if m.Histogram != nil {
+		s.Delta = PInt(m.Histogram.Count)
+		s.Value = PFloat(m.Histogram.Sum)
+	}
+	return s
 }
 
 // NewGaugeMetric создает новую метрику типа gauge с указанным именем и значением.
@@ -47,6 +81,94 @@ func NewCounterMetric(id string, delta int64) *Metrics {
 	}
 }
 
+// SeriesKey возвращает ключ серии метрики: имя и отсортированные по имени метки.
+// Для метрики без меток ключ совпадает с ID.
+func (m *Metrics) SeriesKey() string {
+	return SeriesKey(m.ID, m.Labels)
+}
+
+// SeriesKey формирует ключ серии в формате id{label1="value1",label2="value2"}.
+// Метки сортируются по имени, значения экранируются, поэтому ключ однозначен.
+func SeriesKey(id string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return id
+	}
+	return id + "{" + formatLabels(labels) + "}"
+}
+
+// StringifyLabels возвращает метки в формате label1="value1",label2="value2", отсортированные по имени.
+// Возвращает пустую строку, если меток нет.
+func (m *Metrics) StringifyLabels() string {
+	return formatLabels(m.Labels)
+}
+
+// MatchLabels проверяет, что метрика содержит все метки matchers с совпадающими значениями.
+func (m *Metrics) MatchLabels(matchers map[string]string) bool {
+	for k, v := range matchers {
+		if got, ok := m.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// SortedLabelNames возвращает имена меток в отсортированном порядке.
+func SortedLabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidLabelName проверяет, что имя метки соответствует [a-zA-Z_][a-zA-Z0-9_]*.
+func ValidLabelName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLabels разбирает метки в формате key=value,key2=value2.
+// Пустая строка означает отсутствие меток.
+func ParseLabels(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	labels := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !ValidLabelName(name) {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+// formatLabels форматирует метки в порядке сортировки имен
+func formatLabels(labels map[string]string) string {
+	var b strings.Builder
+	for i, k := range SortedLabelNames(labels) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	return b.String()
+}
+
 // StringifyDelta возвращает строковое представление поля Delta.
 // Возвращает пустую строку, если Delta равно nil.
 func (m *Metrics) StringifyDelta() string {
@@ -79,6 +201,27 @@ func (m *Metrics) String() string {
 		m.Hash)
 }
 
+// Clone возвращает глубокую копию метрики, не разделяющую с исходной значения, гистограмму и метки
+func (m *Metrics) Clone() *Metrics {
+	c := &Metrics{ID: m.ID, MType: m.MType, Hash: m.Hash}
+	if m.Delta != nil {
+		c.Delta = PInt(*m.Delta)
+	}
+	if m.Value != nil {
+		c.Value = PFloat(*m.Value)
+	}
+	if m.Histogram != nil {
+		c.Histogram = m.Histogram.Clone()
+	}
+	if m.Labels != nil {
+		c.Labels = make(map[string]string, len(m.Labels))
+		for k, v := range m.Labels {
+			c.Labels[k] = v
+		}
+	}
+	return c
+}
+
 // PFloat создает указатель на float64 значение.
 // Удобная функция для создания указателей на float64.
 func PFloat(float float64) *float64 {